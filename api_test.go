@@ -0,0 +1,1094 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/notify"
+	"github.com/willmelton21/chirpy/internal/oidc/oidctest"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+	"github.com/willmelton21/chirpy/internal/webhooks"
+)
+
+func TestSignupAndLogin(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		user := srv.signup(t, "alice@example.com", testPassword)
+		assert.NotEqual(t, uuid.Nil, user.ID)
+		assert.Equal(t, "alice@example.com", user.Email)
+		assert.False(t, user.Is_Chirpy_Red)
+
+		sess := srv.login(t, "alice@example.com", testPassword)
+		assert.Equal(t, user.ID, sess.ID)
+		assert.NotEmpty(t, sess.Token)
+		assert.NotEmpty(t, sess.RefreshToken)
+
+		srv.do(t, call{method: "POST", path: "/api/login", body: map[string]string{"email": "alice@example.com", "password": "wrong"}}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "POST", path: "/api/login", body: map[string]string{"email": "nobody@example.com", "password": testPassword}}, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestUpdateCredentials(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		sess := srv.newUser(t, "alice@example.com")
+
+		var updated User
+		srv.do(t, call{
+			method: "PUT", path: "/api/users",
+			body: map[string]string{"email": "alice@example.org", "password": "new password"},
+			auth: bearer(sess.Token),
+		}, http.StatusOK, &updated)
+		assert.Equal(t, "alice@example.org", updated.Email)
+
+		srv.login(t, "alice@example.org", "new password")
+		srv.do(t, call{method: "POST", path: "/api/login", body: map[string]string{"email": "alice@example.com", "password": testPassword}}, http.StatusUnauthorized, nil)
+
+		srv.do(t, call{method: "PUT", path: "/api/users", body: map[string]string{"email": "x@example.com", "password": "x"}}, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestChirps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+
+		first := srv.postChirp(t, alice.Token, "hello world")
+		time.Sleep(time.Millisecond)
+		second := srv.postChirp(t, bob.Token, "what a kerfuffle")
+		time.Sleep(time.Millisecond)
+		third := srv.postChirp(t, alice.Token, "bye")
+
+		assert.Equal(t, alice.ID, first.UserID)
+		assert.Equal(t, "what a ****", second.Body)
+
+		assert.Equal(t, []uuid.UUID{first.ID, second.ID, third.ID}, ids(srv.listChirps(t, "")))
+		assert.Equal(t, []uuid.UUID{third.ID, second.ID, first.ID}, ids(srv.listChirps(t, "?sort=desc")))
+		assert.Equal(t, []uuid.UUID{first.ID, third.ID}, ids(srv.listChirps(t, "?author_id="+alice.ID.String())))
+		assert.Equal(t, []uuid.UUID{third.ID, first.ID}, ids(srv.listChirps(t, "?author_id="+alice.ID.String()+"&sort=desc")))
+
+		var got Chirp
+		srv.do(t, call{method: "GET", path: "/api/chirps/" + second.ID.String()}, http.StatusOK, &got)
+		assert.Equal(t, second, got)
+		srv.do(t, call{method: "GET", path: "/api/chirps/" + uuid.NewString()}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/chirps/not-a-uuid"}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/chirps?author_id=not-a-uuid"}, http.StatusBadRequest, nil)
+
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "anonymous"}}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "forged"}, auth: bearer("not-a-jwt")}, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestDeleteChirp(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		chirp := srv.postChirp(t, alice.Token, "mine")
+		path := "/api/chirps/" + chirp.ID.String()
+
+		srv.do(t, call{method: "DELETE", path: path}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "DELETE", path: path, auth: bearer(bob.Token)}, http.StatusForbidden, nil)
+		srv.do(t, call{method: "DELETE", path: path, auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "GET", path: path}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "DELETE", path: path, auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+	})
+}
+
+func TestRefreshAndRevoke(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		refresh := call{method: "POST", path: "/api/refresh", auth: bearer(alice.RefreshToken)}
+
+		var refreshed struct {
+			Token string `json:"token"`
+		}
+		srv.do(t, refresh, http.StatusOK, &refreshed)
+		assert.NotEmpty(t, refreshed.Token)
+		srv.postChirp(t, refreshed.Token, "posted with a refreshed token")
+
+		srv.do(t, call{method: "POST", path: "/api/refresh", auth: bearer("unknown")}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "POST", path: "/api/refresh"}, http.StatusUnauthorized, nil)
+
+		srv.do(t, call{method: "POST", path: "/api/revoke", auth: bearer(alice.RefreshToken)}, http.StatusNoContent, nil)
+		srv.do(t, refresh, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestAPIKeys(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+
+		created := srv.createAPIKey(t, alice.Token, "chirps:write", "chirps:write")
+		assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+		assert.Equal(t, []auth.Scope{auth.ScopeChirpsWrite}, created.Scopes)
+		assert.Nil(t, created.LastUsedAt)
+
+		var chirp Chirp
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "from a bot"}, auth: apiKey(created.Key)}, http.StatusCreated, &chirp)
+		assert.Equal(t, alice.ID, chirp.UserID)
+
+		// The key itself is never shown again.
+		var keys []APIKey
+		srv.do(t, call{method: "GET", path: "/api/users/me/api_keys", auth: bearer(alice.Token)}, http.StatusOK, &keys)
+		if assert.Len(t, keys, 1) {
+			assert.Equal(t, created.ID, keys[0].ID)
+			assert.Empty(t, keys[0].Key)
+			assert.NotNil(t, keys[0].LastUsedAt)
+		}
+		srv.do(t, call{method: "GET", path: "/api/users/me/api_keys", auth: bearer(bob.Token)}, http.StatusOK, &keys)
+		assert.Empty(t, keys)
+
+		// Keys only work where their scope allows, and can't manage keys.
+		srv.do(t, call{method: "PUT", path: "/api/users", body: map[string]string{"email": "eve@example.com", "password": testPassword}, auth: apiKey(created.Key)}, http.StatusForbidden, nil)
+		srv.do(t, call{method: "GET", path: "/api/users/me/api_keys", auth: apiKey(created.Key)}, http.StatusUnauthorized, nil)
+
+		path := "/api/users/me/api_keys/" + created.ID.String()
+		srv.do(t, call{method: "DELETE", path: path, auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "DELETE", path: path, auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "revoked"}, auth: apiKey(created.Key)}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "DELETE", path: path, auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+	})
+}
+
+func TestBlocksAndMutes(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		fromAlice := srv.postChirp(t, alice.Token, "from alice")
+		time.Sleep(time.Millisecond)
+		fromBob := srv.postChirp(t, bob.Token, "from bob")
+		time.Sleep(time.Millisecond)
+		fromCarol := srv.postChirp(t, carol.Token, "from carol")
+		bobsChirp := "/api/chirps/" + fromBob.ID.String()
+		everyone := []uuid.UUID{fromAlice.ID, fromBob.ID, fromCarol.ID}
+
+		// Alice blocks Bob and mutes Carol; doing it twice is fine.
+		for i := 0; i < 2; i++ {
+			srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + bob.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+			srv.do(t, call{method: "PUT", path: "/api/users/me/mutes/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		}
+		var relations []Relation
+		srv.do(t, call{method: "GET", path: "/api/users/me/blocks", auth: bearer(alice.Token)}, http.StatusOK, &relations)
+		if assert.Len(t, relations, 1) {
+			assert.Equal(t, bob.ID, relations[0].UserID)
+		}
+		srv.do(t, call{method: "GET", path: "/api/users/me/mutes", auth: bearer(alice.Token)}, http.StatusOK, &relations)
+		if assert.Len(t, relations, 1) {
+			assert.Equal(t, carol.ID, relations[0].UserID)
+		}
+		// Carol isn't told she is muted.
+		srv.do(t, call{method: "GET", path: "/api/users/me/mutes", auth: bearer(carol.Token)}, http.StatusOK, &relations)
+		assert.Empty(t, relations)
+
+		// Blocks hide chirps both ways. Mutes only keep chirps off
+		// Alice's timeline.
+		assert.Equal(t, []uuid.UUID{fromAlice.ID}, ids(srv.listChirpsAs(t, alice.Token, "")))
+		assert.Equal(t, []uuid.UUID{fromBob.ID, fromCarol.ID}, ids(srv.listChirpsAs(t, bob.Token, "")))
+		assert.Empty(t, srv.listChirpsAs(t, alice.Token, "?author_id="+bob.ID.String()))
+		assert.Empty(t, srv.listChirpsAs(t, bob.Token, "?author_id="+alice.ID.String()))
+		assert.Equal(t, []uuid.UUID{fromCarol.ID}, ids(srv.listChirpsAs(t, alice.Token, "?author_id="+carol.ID.String())))
+		assert.Equal(t, everyone, ids(srv.listChirpsAs(t, carol.Token, "")))
+		assert.Equal(t, everyone, ids(srv.listChirps(t, "")))
+
+		srv.do(t, call{method: "GET", path: bobsChirp, auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/chirps/" + fromAlice.ID.String(), auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/chirps/" + fromCarol.ID.String(), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "GET", path: bobsChirp}, http.StatusOK, nil)
+		srv.do(t, call{method: "GET", path: bobsChirp, auth: bearer("not-a-jwt")}, http.StatusUnauthorized, nil)
+
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/blocks/" + bob.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/mutes/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		assert.Equal(t, everyone, ids(srv.listChirpsAs(t, alice.Token, "")))
+		srv.do(t, call{method: "GET", path: bobsChirp, auth: bearer(alice.Token)}, http.StatusOK, nil)
+
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(alice.Token)}, http.StatusBadRequest, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/mutes/" + uuid.NewString(), auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/users/me/blocks"}, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestPrivateAccounts(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		fromAlice := srv.postChirp(t, alice.Token, "from alice")
+		time.Sleep(time.Millisecond)
+		fromBob := srv.postChirp(t, bob.Token, "from bob")
+		alicesChirp := "/api/chirps/" + fromAlice.ID.String()
+		following := "/api/users/me/following/" + alice.ID.String()
+
+		var user User
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(alice.Token)}, http.StatusOK, &user)
+		assert.True(t, user.IsPrivate)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{}, auth: bearer(alice.Token)}, http.StatusUnprocessableEntity, nil)
+
+		// Only Alice sees her chirps until she approves someone.
+		assert.Equal(t, []uuid.UUID{fromBob.ID}, ids(srv.listChirps(t, "")))
+		assert.Equal(t, []uuid.UUID{fromBob.ID}, ids(srv.listChirpsAs(t, bob.Token, "")))
+		assert.Empty(t, srv.listChirpsAs(t, bob.Token, "?author_id="+alice.ID.String()))
+		assert.Equal(t, []uuid.UUID{fromAlice.ID, fromBob.ID}, ids(srv.listChirpsAs(t, alice.Token, "")))
+		srv.do(t, call{method: "GET", path: alicesChirp}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: alicesChirp, auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: alicesChirp, auth: bearer(alice.Token)}, http.StatusOK, nil)
+
+		// Following a private account asks; asking twice is fine.
+		var follow Follow
+		for i := 0; i < 2; i++ {
+			srv.do(t, call{method: "PUT", path: following, auth: bearer(bob.Token)}, http.StatusOK, &follow)
+			assert.Equal(t, alice.ID, follow.UserID)
+			assert.Equal(t, store.FollowPending, follow.Status)
+		}
+		srv.do(t, call{method: "PUT", path: following, auth: bearer(carol.Token)}, http.StatusOK, nil)
+		var follows []Follow
+		srv.do(t, call{method: "GET", path: "/api/users/me/follow_requests", auth: bearer(alice.Token)}, http.StatusOK, &follows)
+		assert.Len(t, follows, 2)
+		srv.do(t, call{method: "GET", path: "/api/users/me/following", auth: bearer(bob.Token)}, http.StatusOK, &follows)
+		if assert.Len(t, follows, 1) {
+			assert.Equal(t, alice.ID, follows[0].UserID)
+		}
+		srv.do(t, call{method: "GET", path: alicesChirp, auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+
+		// Alice approves Bob and denies Carol.
+		srv.do(t, call{method: "POST", path: "/api/users/me/follow_requests/" + bob.ID.String() + "/approve", auth: bearer(alice.Token)}, http.StatusOK, &follow)
+		assert.Equal(t, bob.ID, follow.UserID)
+		assert.Equal(t, store.FollowAccepted, follow.Status)
+		srv.do(t, call{method: "POST", path: "/api/users/me/follow_requests/" + carol.ID.String() + "/deny", auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "POST", path: "/api/users/me/follow_requests/" + carol.ID.String() + "/approve", auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/users/me/follow_requests", auth: bearer(alice.Token)}, http.StatusOK, &follows)
+		assert.Empty(t, follows)
+		srv.do(t, call{method: "GET", path: "/api/users/me/followers", auth: bearer(alice.Token)}, http.StatusOK, &follows)
+		if assert.Len(t, follows, 1) {
+			assert.Equal(t, bob.ID, follows[0].UserID)
+		}
+
+		assert.Equal(t, []uuid.UUID{fromAlice.ID, fromBob.ID}, ids(srv.listChirpsAs(t, bob.Token, "")))
+		assert.Equal(t, []uuid.UUID{fromAlice.ID}, ids(srv.listChirpsAs(t, bob.Token, "?author_id="+alice.ID.String())))
+		srv.do(t, call{method: "GET", path: alicesChirp, auth: bearer(bob.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "GET", path: alicesChirp, auth: bearer(carol.Token)}, http.StatusNotFound, nil)
+
+		// Unfollowing takes the chirps away again.
+		srv.do(t, call{method: "DELETE", path: following, auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "DELETE", path: following, auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: alicesChirp, auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+
+		// Going public approves pending requests and shows chirps to all.
+		srv.do(t, call{method: "PUT", path: following, auth: bearer(carol.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": false}, auth: bearer(alice.Token)}, http.StatusOK, &user)
+		assert.False(t, user.IsPrivate)
+		srv.do(t, call{method: "GET", path: "/api/users/me/followers", auth: bearer(alice.Token)}, http.StatusOK, &follows)
+		assert.Len(t, follows, 1)
+		srv.do(t, call{method: "GET", path: alicesChirp}, http.StatusOK, nil)
+
+		// Following a public account is immediate. Nobody can follow across
+		// a block.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/following/" + bob.ID.String(), auth: bearer(carol.Token)}, http.StatusOK, &follow)
+		assert.Equal(t, store.FollowAccepted, follow.Status)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + carol.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "GET", path: "/api/users/me/following", auth: bearer(carol.Token)}, http.StatusOK, &follows)
+		if assert.Len(t, follows, 1) {
+			assert.Equal(t, alice.ID, follows[0].UserID)
+		}
+		srv.do(t, call{method: "PUT", path: "/api/users/me/following/" + bob.ID.String(), auth: bearer(carol.Token)}, http.StatusForbidden, nil)
+		srv.do(t, call{method: "GET", path: "/api/users/me/following"}, http.StatusUnauthorized, nil)
+	})
+}
+
+// bookmarkPage is a GET /api/bookmarks response.
+type bookmarkPage struct {
+	Bookmarks  []Bookmark `json:"bookmarks"`
+	NextCursor string     `json:"next_cursor"`
+}
+
+func bookmarkedIDs(bookmarks []Bookmark) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		ids = append(ids, b.Chirp.ID)
+	}
+	return ids
+}
+
+func TestBookmarks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		var chirps []Chirp
+		for i := 0; i < 3; i++ {
+			chirps = append(chirps, srv.postChirp(t, bob.Token, "chirp"))
+		}
+		bookmark := func(c Chirp) string { return "/api/chirps/" + c.ID.String() + "/bookmark" }
+
+		for _, c := range chirps {
+			var saved Bookmark
+			srv.do(t, call{method: "POST", path: bookmark(c), auth: bearer(alice.Token)}, http.StatusOK, &saved)
+			assert.Equal(t, c.ID, saved.Chirp.ID)
+			assert.Nil(t, saved.CollectionID)
+			time.Sleep(time.Millisecond)
+		}
+		srv.do(t, call{method: "POST", path: bookmark(chirps[0]), auth: bearer(alice.Token)}, http.StatusOK, nil)
+
+		// Newest first, two at a time.
+		var page bookmarkPage
+		srv.do(t, call{method: "GET", path: "/api/bookmarks?limit=2", auth: bearer(alice.Token)}, http.StatusOK, &page)
+		assert.Equal(t, []uuid.UUID{chirps[2].ID, chirps[1].ID}, bookmarkedIDs(page.Bookmarks))
+		require.NotEmpty(t, page.NextCursor)
+		var last bookmarkPage
+		srv.do(t, call{method: "GET", path: "/api/bookmarks?limit=2&cursor=" + page.NextCursor, auth: bearer(alice.Token)}, http.StatusOK, &last)
+		assert.Equal(t, []uuid.UUID{chirps[0].ID}, bookmarkedIDs(last.Bookmarks))
+		assert.Empty(t, last.NextCursor)
+		srv.do(t, call{method: "GET", path: "/api/bookmarks?cursor=nope", auth: bearer(alice.Token)}, http.StatusBadRequest, nil)
+
+		// Bookmarks are private, and go away with the chirp.
+		srv.do(t, call{method: "GET", path: "/api/bookmarks", auth: bearer(bob.Token)}, http.StatusOK, &page)
+		assert.Empty(t, page.Bookmarks)
+		srv.do(t, call{method: "DELETE", path: "/api/chirps/" + chirps[1].ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "DELETE", path: bookmark(chirps[2]), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "DELETE", path: bookmark(chirps[2]), auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/bookmarks", auth: bearer(alice.Token)}, http.StatusOK, &page)
+		assert.Equal(t, []uuid.UUID{chirps[0].ID}, bookmarkedIDs(page.Bookmarks))
+
+		// Chirps the caller can't see can't be bookmarked, and drop out of
+		// the list.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "POST", path: bookmark(chirps[2]), auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "GET", path: "/api/bookmarks", auth: bearer(alice.Token)}, http.StatusOK, &page)
+		assert.Empty(t, page.Bookmarks)
+		srv.do(t, call{method: "POST", path: bookmark(chirps[0]), auth: bearer(bob.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "GET", path: "/api/bookmarks"}, http.StatusUnauthorized, nil)
+
+		// Hidden chirps don't leave gaps in a page.
+		carol := srv.newUser(t, "carol@example.com")
+		older := srv.postChirp(t, carol.Token, "older")
+		srv.do(t, call{method: "POST", path: bookmark(older), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		time.Sleep(time.Millisecond)
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "POST", path: bookmark(chirps[2]), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		time.Sleep(time.Millisecond)
+		newer := srv.postChirp(t, carol.Token, "newer")
+		srv.do(t, call{method: "POST", path: bookmark(newer), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		var full bookmarkPage
+		srv.do(t, call{method: "GET", path: "/api/bookmarks?limit=2", auth: bearer(alice.Token)}, http.StatusOK, &full)
+		assert.Equal(t, []uuid.UUID{newer.ID, older.ID}, bookmarkedIDs(full.Bookmarks))
+		assert.Empty(t, full.NextCursor)
+	})
+}
+
+func TestBookmarkCollections(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		first := srv.postChirp(t, bob.Token, "first")
+		time.Sleep(time.Millisecond)
+		second := srv.postChirp(t, bob.Token, "second")
+		create := func(token, name string, want int, out any) {
+			t.Helper()
+			srv.do(t, call{method: "POST", path: "/api/bookmarks/collections", body: map[string]string{"name": name}, auth: bearer(token)}, want, out)
+		}
+
+		// Collections are for Chirpy Red users.
+		create(alice.Token, "reading", http.StatusForbidden, nil)
+		upgrade := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}}
+		srv.do(t, call{method: "POST", path: "/api/polka/webhooks", body: upgrade, auth: apiKey(testPolkaKey)}, http.StatusNoContent, nil)
+
+		var reading BookmarkCollection
+		create(alice.Token, "reading", http.StatusCreated, &reading)
+		assert.Equal(t, "reading", reading.Name)
+		create(alice.Token, "reading", http.StatusConflict, nil)
+		create(alice.Token, " ", http.StatusUnprocessableEntity, nil)
+		var collections []BookmarkCollection
+		srv.do(t, call{method: "GET", path: "/api/bookmarks/collections", auth: bearer(alice.Token)}, http.StatusOK, &collections)
+		if assert.Len(t, collections, 1) {
+			assert.Equal(t, reading.ID, collections[0].ID)
+		}
+
+		var saved Bookmark
+		srv.do(t, call{method: "POST", path: "/api/chirps/" + first.ID.String() + "/bookmark", body: map[string]any{"collection_id": reading.ID}, auth: bearer(alice.Token)}, http.StatusOK, &saved)
+		if assert.NotNil(t, saved.CollectionID) {
+			assert.Equal(t, reading.ID, *saved.CollectionID)
+		}
+		srv.do(t, call{method: "POST", path: "/api/chirps/" + second.ID.String() + "/bookmark", auth: bearer(alice.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "POST", path: "/api/chirps/" + second.ID.String() + "/bookmark", body: map[string]any{"collection_id": uuid.New()}, auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "POST", path: "/api/chirps/" + second.ID.String() + "/bookmark", body: map[string]any{"collection_id": reading.ID}, auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+
+		var page bookmarkPage
+		srv.do(t, call{method: "GET", path: "/api/bookmarks?collection_id=" + reading.ID.String(), auth: bearer(alice.Token)}, http.StatusOK, &page)
+		assert.Equal(t, []uuid.UUID{first.ID}, bookmarkedIDs(page.Bookmarks))
+
+		// Deleting the collection keeps its bookmarks.
+		srv.do(t, call{method: "DELETE", path: "/api/bookmarks/collections/" + reading.ID.String(), auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/bookmarks/collections/" + reading.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "GET", path: "/api/bookmarks", auth: bearer(alice.Token)}, http.StatusOK, &page)
+		assert.Equal(t, []uuid.UUID{second.ID, first.ID}, bookmarkedIDs(page.Bookmarks))
+		for _, b := range page.Bookmarks {
+			assert.Nil(t, b.CollectionID)
+		}
+	})
+}
+
+// oauthTokens is a token endpoint response.
+type oauthTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+	Error        string `json:"error"`
+}
+
+func TestOAuth(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		dev := srv.newUser(t, "dev@example.com")
+		client := srv.createOAuthClient(t, dev.Token, false)
+		require.NotEmpty(t, client.Secret)
+		const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+
+		request := func(scope string) url.Values {
+			return url.Values{
+				"response_type":         {"code"},
+				"client_id":             {client.ID.String()},
+				"redirect_uri":          {testRedirectURI},
+				"scope":                 {scope},
+				"state":                 {"xyz"},
+				"code_challenge":        {auth.PKCEChallenge(verifier)},
+				"code_challenge_method": {auth.PKCEMethodS256},
+			}
+		}
+
+		// The browser is sent to the consent page, or straight back to the
+		// app if the request is bad.
+		browser := *srv.Client()
+		browser.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+		location := func(query url.Values) *url.URL {
+			t.Helper()
+			resp, err := browser.Get(srv.URL + "/api/oauth/authorize?" + query.Encode())
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusFound, resp.StatusCode)
+			loc, err := url.Parse(resp.Header.Get("Location"))
+			require.NoError(t, err)
+			return loc
+		}
+		assert.Equal(t, oauthConsentPage, location(request("chirps:read")).Path)
+		plain := request("chirps:read")
+		plain.Set("code_challenge_method", "plain")
+		loc := location(plain)
+		assert.Equal(t, "app.example.com", loc.Host)
+		assert.Equal(t, "invalid_request", loc.Query().Get("error"))
+		assert.Equal(t, "xyz", loc.Query().Get("state"))
+
+		// An unregistered redirect URI is never redirected to.
+		evil := request("chirps:read")
+		evil.Set("redirect_uri", "https://evil.example.com/")
+		srv.do(t, call{method: "GET", path: "/api/oauth/authorize?" + evil.Encode()}, http.StatusUnprocessableEntity, nil)
+
+		// The consent page shows the app's name.
+		var info OAuthClient
+		srv.do(t, call{method: "GET", path: "/api/oauth/clients/" + client.ID.String()}, http.StatusOK, &info)
+		assert.Equal(t, "Example App", info.Name)
+
+		consent := func(scope string, approve bool) url.Values {
+			t.Helper()
+			body := map[string]any{"approve": approve}
+			for name := range request(scope) {
+				body[name] = request(scope).Get(name)
+			}
+			var resp struct {
+				RedirectTo string `json:"redirect_to"`
+			}
+			srv.do(t, call{method: "POST", path: "/api/oauth/authorize", body: body, auth: bearer(alice.Token)}, http.StatusOK, &resp)
+			loc, err := url.Parse(resp.RedirectTo)
+			require.NoError(t, err)
+			assert.Equal(t, testRedirectURI, loc.Scheme+"://"+loc.Host+loc.Path)
+			assert.Equal(t, "xyz", loc.Query().Get("state"))
+			return loc.Query()
+		}
+		assert.Equal(t, "access_denied", consent("chirps:read", false).Get("error"))
+
+		exchange := func(code, verifier string, want int) oauthTokens {
+			t.Helper()
+			var tokens oauthTokens
+			srv.postForm(t, "/api/oauth/token", url.Values{
+				"grant_type":    {"authorization_code"},
+				"code":          {code},
+				"redirect_uri":  {testRedirectURI},
+				"code_verifier": {verifier},
+				"client_id":     {client.ID.String()},
+				"client_secret": {client.Secret},
+			}, want, &tokens)
+			return tokens
+		}
+
+		// A code needs the verifier it was challenged with, and works once.
+		code := consent("chirps:read chirps:write", true).Get("code")
+		assert.Equal(t, "invalid_grant", exchange(code, "wrong verifier", http.StatusBadRequest).Error)
+		assert.Equal(t, "invalid_grant", exchange(code, verifier, http.StatusBadRequest).Error)
+
+		code = consent("chirps:read chirps:write", true).Get("code")
+		tokens := exchange(code, verifier, http.StatusOK)
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.Equal(t, "chirps:read chirps:write", tokens.Scope)
+		assert.Equal(t, "invalid_grant", exchange(code, verifier, http.StatusBadRequest).Error)
+
+		// The access token acts as alice, within its scopes.
+		var chirp Chirp
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "from an app"}, auth: bearer(tokens.AccessToken)}, http.StatusCreated, &chirp)
+		assert.Equal(t, alice.ID, chirp.UserID)
+		srv.do(t, call{method: "PUT", path: "/api/users", body: map[string]string{"email": "eve@example.com", "password": testPassword}, auth: bearer(tokens.AccessToken)}, http.StatusForbidden, nil)
+		srv.do(t, call{method: "GET", path: "/api/users/me/api_keys", auth: bearer(tokens.AccessToken)}, http.StatusForbidden, nil)
+		srv.do(t, call{method: "POST", path: "/api/oauth/clients", body: map[string]any{"name": "x", "redirect_uris": []string{testRedirectURI}}, auth: bearer(tokens.AccessToken)}, http.StatusForbidden, nil)
+
+		// The client's refresh token only works at the token endpoint.
+		srv.do(t, call{method: "POST", path: "/api/refresh", auth: bearer(tokens.RefreshToken)}, http.StatusUnauthorized, nil)
+
+		// Refreshing can narrow the scopes and rotates the refresh token.
+		refresh := url.Values{
+			"grant_type":    {"refresh_token"},
+			"refresh_token": {tokens.RefreshToken},
+			"scope":         {"chirps:read"},
+		}
+		secretPost := func(form url.Values) url.Values {
+			form.Set("client_id", client.ID.String())
+			form.Set("client_secret", client.Secret)
+			return form
+		}
+		var refreshed oauthTokens
+		srv.postForm(t, "/api/oauth/token", secretPost(refresh), http.StatusOK, &refreshed)
+		assert.Equal(t, "chirps:read", refreshed.Scope)
+		srv.postForm(t, "/api/oauth/token", secretPost(refresh), http.StatusBadRequest, nil)
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "narrowed"}, auth: bearer(refreshed.AccessToken)}, http.StatusForbidden, nil)
+		widen := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshed.RefreshToken}, "scope": {"profile:write"}}
+		var widened oauthTokens
+		srv.postForm(t, "/api/oauth/token", secretPost(widen), http.StatusBadRequest, &widened)
+		assert.Equal(t, "invalid_scope", widened.Error)
+
+		// Clients must authenticate, and can only see their own tokens.
+		srv.postForm(t, "/api/oauth/introspect", url.Values{"token": {refreshed.AccessToken}, "client_id": {client.ID.String()}, "client_secret": {"wrong"}}, http.StatusUnauthorized, nil)
+		introspect := func(token string) map[string]any {
+			t.Helper()
+			var resp map[string]any
+			srv.postForm(t, "/api/oauth/introspect", secretPost(url.Values{"token": {token}}), http.StatusOK, &resp)
+			return resp
+		}
+		active := introspect(refreshed.AccessToken)
+		assert.Equal(t, true, active["active"])
+		assert.Equal(t, "chirps:read", active["scope"])
+		assert.Equal(t, alice.ID.String(), active["sub"])
+		assert.Equal(t, true, introspect(refreshed.RefreshToken)["active"])
+		assert.Equal(t, map[string]any{"active": false}, introspect(alice.Token))
+		assert.Equal(t, map[string]any{"active": false}, introspect(alice.RefreshToken))
+
+		srv.postForm(t, "/api/oauth/revoke", secretPost(url.Values{"token": {refreshed.RefreshToken}}), http.StatusOK, nil)
+		assert.Equal(t, map[string]any{"active": false}, introspect(refreshed.RefreshToken))
+		srv.postForm(t, "/api/oauth/revoke", secretPost(url.Values{"token": {"unknown"}}), http.StatusOK, nil)
+
+		// Public clients send no secret and rely on PKCE.
+		public := srv.createOAuthClient(t, dev.Token, true)
+		assert.Empty(t, public.Secret)
+		client = public
+		tokens = exchange(consent("chirps:read", true).Get("code"), verifier, http.StatusOK)
+		assert.NotEmpty(t, tokens.AccessToken)
+
+		// Deleting a client revokes what it was issued.
+		var clients []OAuthClient
+		srv.do(t, call{method: "GET", path: "/api/oauth/clients", auth: bearer(dev.Token)}, http.StatusOK, &clients)
+		assert.Len(t, clients, 2)
+		srv.do(t, call{method: "DELETE", path: "/api/oauth/clients/" + public.ID.String(), auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/oauth/clients/" + public.ID.String(), auth: bearer(dev.Token)}, http.StatusNoContent, nil)
+		srv.postForm(t, "/api/oauth/token", url.Values{"grant_type": {"refresh_token"}, "refresh_token": {tokens.RefreshToken}, "client_id": {public.ID.String()}}, http.StatusUnauthorized, nil)
+	})
+}
+
+func TestOIDC(t *testing.T) {
+	provider := oidctest.NewServer(t, "chirpy", "provider-secret")
+
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		var providers []OIDCProvider
+		srv.do(t, call{method: "GET", path: "/api/auth/oidc"}, http.StatusOK, &providers)
+		assert.Equal(t, []OIDCProvider{{Name: "idp", LoginURL: "/api/auth/oidc/idp/login"}}, providers)
+
+		identities := func(token string) []Identity {
+			t.Helper()
+			var ids []Identity
+			srv.do(t, call{method: "GET", path: "/api/users/me/identities", auth: bearer(token)}, http.StatusOK, &ids)
+			return ids
+		}
+		errorCode := func(resp ErrorResponse) apierror.Code { return resp.Code }
+
+		// Signing in for the first time signs up, without a password.
+		provider.SignInAs(oidctest.User{Subject: "carol-1", Email: "carol@example.com", EmailVerified: true})
+		var carol session
+		srv.oidcLogin(t, "idp", http.StatusOK, &carol)
+		assert.Equal(t, "carol@example.com", carol.Email)
+		require.Len(t, identities(carol.Token), 1)
+		assert.Equal(t, Identity{Provider: "idp", Subject: "carol-1", Email: "carol@example.com", CreatedAt: identities(carol.Token)[0].CreatedAt}, identities(carol.Token)[0])
+		srv.do(t, call{method: "POST", path: "/api/login", body: map[string]string{"email": "carol@example.com", "password": "anything"}}, http.StatusUnauthorized, nil)
+
+		var again session
+		srv.oidcLogin(t, "idp", http.StatusOK, &again)
+		assert.Equal(t, carol.ID, again.ID)
+
+		// Carol can't unlink her only way in until she sets a password.
+		var resp ErrorResponse
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/identities/idp", auth: bearer(carol.Token)}, http.StatusConflict, &resp)
+		assert.Equal(t, apierror.IdentityLastLoginMethod, errorCode(resp))
+		srv.do(t, call{method: "PUT", path: "/api/users", body: map[string]string{"email": "carol@example.com", "password": testPassword}, auth: bearer(carol.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/identities/idp", auth: bearer(carol.Token)}, http.StatusNoContent, nil)
+		assert.Empty(t, identities(carol.Token))
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/identities/idp", auth: bearer(carol.Token)}, http.StatusNotFound, nil)
+
+		// A provider account is never merged into an existing user by
+		// email; the user logs in and links it.
+		alice := srv.newUser(t, "alice@example.com")
+		provider.SignInAs(oidctest.User{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true})
+		srv.oidcLogin(t, "idp", http.StatusConflict, &resp)
+		assert.Equal(t, apierror.UserEmailTaken, errorCode(resp))
+
+		link := func(token string, want int, out any) {
+			t.Helper()
+			browser := srv.browser(t)
+			req, err := http.NewRequest("POST", srv.URL+"/api/users/me/identities/idp", nil)
+			require.NoError(t, err)
+			req.Header.Set("Authorization", bearer(token))
+			res, err := browser.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			var started struct {
+				AuthorizationURL string `json:"authorization_url"`
+			}
+			require.NoError(t, json.NewDecoder(res.Body).Decode(&started))
+			finishOIDC(t, browser, started.AuthorizationURL, want, out)
+		}
+		var linked Identity
+		link(alice.Token, http.StatusCreated, &linked)
+		assert.Equal(t, "alice-1", linked.Subject)
+		var aliceAgain session
+		srv.oidcLogin(t, "idp", http.StatusOK, &aliceAgain)
+		assert.Equal(t, alice.ID, aliceAgain.ID)
+
+		bob := srv.newUser(t, "bob@example.com")
+		link(bob.Token, http.StatusConflict, &resp)
+		assert.Equal(t, apierror.IdentityTaken, errorCode(resp))
+
+		// New users need an email the provider has verified.
+		provider.SignInAs(oidctest.User{Subject: "dave-1", Email: "dave@example.com"})
+		srv.oidcLogin(t, "idp", http.StatusUnauthorized, &resp)
+		assert.Equal(t, apierror.AuthOIDCFailed, errorCode(resp))
+
+		// The callback only finishes the sign-in this browser started.
+		provider.SignInAs(oidctest.User{Subject: "carol-1", Email: "carol@example.com", EmailVerified: true})
+		browser := srv.browser(t)
+		callback, err := url.Parse(redirect(t, browser, redirect(t, browser, srv.URL+"/api/auth/oidc/idp/login")))
+		require.NoError(t, err)
+		forged := callback.Query()
+		forged.Set("state", "attacker")
+		res, err := browser.Get(srv.URL + callback.Path + "?" + forged.Encode())
+		require.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		srv.do(t, call{method: "GET", path: callback.RequestURI()}, http.StatusUnauthorized, nil)
+	}, withOIDCProvider("idp", provider))
+
+	down := oidctest.NewServer(t, "chirpy", "provider-secret")
+	down.Close()
+	srv := newTestServer(t, store.NewMemory(), withOIDCProvider("down", down))
+	var resp ErrorResponse
+	srv.do(t, call{method: "GET", path: "/api/auth/oidc/down/login"}, http.StatusBadGateway, &resp)
+	assert.Equal(t, apierror.OIDCProviderUnavailable, resp.Code)
+}
+
+func TestPolkaWebhook(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		event := func(name string, userID uuid.UUID) map[string]any {
+			return map[string]any{"event": name, "data": map[string]string{"user_id": userID.String()}}
+		}
+		webhook := func(body any, auth string, want int) {
+			t.Helper()
+			srv.do(t, call{method: "POST", path: "/api/polka/webhooks", body: body, auth: auth}, want, nil)
+		}
+
+		webhook(event("user.upgraded", alice.ID), "", http.StatusUnauthorized)
+		webhook(event("user.upgraded", alice.ID), apiKey("wrong"), http.StatusUnauthorized)
+
+		webhook(event("user.payment_failed", alice.ID), apiKey(testPolkaKey), http.StatusNoContent)
+		assert.False(t, srv.login(t, "alice@example.com", testPassword).Is_Chirpy_Red)
+
+		// Only alice's own endpoints hear about her upgrade.
+		bob := srv.newUser(t, "bob@example.com")
+		hooks := map[uuid.UUID]uuid.UUID{}
+		for _, user := range []session{alice, bob} {
+			var endpoint WebhookEndpoint
+			srv.do(t, call{method: "POST", path: "/api/webhooks", body: map[string]any{"url": "http://127.0.0.1:1/hook", "events": []string{"user.upgraded"}}, auth: bearer(user.Token)}, http.StatusCreated, &endpoint)
+			hooks[endpoint.ID] = user.ID
+		}
+
+		webhook(event("user.upgraded", alice.ID), apiKey(testPolkaKey), http.StatusNoContent)
+		assert.True(t, srv.login(t, "alice@example.com", testPassword).Is_Chirpy_Red)
+
+		var queued []Job
+		srv.do(t, call{method: "GET", path: "/admin/jobs", auth: apiKey(testAdminKey)}, http.StatusOK, &queued)
+		require.Len(t, queued, 1)
+		var args struct {
+			DeliveryID uuid.UUID `json:"delivery_id"`
+		}
+		require.NoError(t, json.Unmarshal(queued[0].Payload, &args))
+		var delivery WebhookDelivery
+		srv.do(t, call{method: "GET", path: "/admin/webhooks/deliveries/" + args.DeliveryID.String(), auth: apiKey(testAdminKey)}, http.StatusOK, &delivery)
+		assert.Equal(t, alice.ID, hooks[delivery.EndpointID])
+
+		webhook(event("user.upgraded", uuid.New()), apiKey(testPolkaKey), http.StatusNotFound)
+	})
+}
+
+func TestNotifications(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		dave := srv.newUser(t, "dave@example.com")
+		type page struct {
+			UnreadCount   int64                 `json:"unread_count"`
+			Notifications []notify.Notification `json:"notifications"`
+		}
+		list := func(token, query string) page {
+			t.Helper()
+			var p page
+			srv.do(t, call{method: "GET", path: "/api/notifications" + query, auth: bearer(token)}, http.StatusOK, &p)
+			return p
+		}
+		type marked struct {
+			Marked      int64 `json:"marked"`
+			UnreadCount int64 `json:"unread_count"`
+		}
+		markRead := func(token string, body any) marked {
+			t.Helper()
+			var m marked
+			srv.do(t, call{method: "POST", path: "/api/notifications/read", body: body, auth: bearer(token)}, http.StatusOK, &m)
+			return m
+		}
+
+		// Only mentions Alice can see notify her: not across a block, and
+		// not from a private account she doesn't follow. Mentioning
+		// yourself doesn't count.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(carol.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(dave.Token)}, http.StatusNoContent, nil)
+		srv.postChirp(t, carol.Token, "hi @alice@example.com")
+		srv.postChirp(t, dave.Token, "hi @alice@example.com")
+		srv.postChirp(t, alice.Token, "hi @alice@example.com and @nobody@example.com")
+		mention := srv.postChirp(t, bob.Token, "hi @alice@example.com")
+		time.Sleep(time.Millisecond)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/following/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusOK, nil)
+		time.Sleep(time.Millisecond)
+		upgrade := map[string]any{"event": "user.upgraded", "data": map[string]string{"user_id": alice.ID.String()}}
+		srv.do(t, call{method: "POST", path: "/api/polka/webhooks", body: upgrade, auth: apiKey(testPolkaKey)}, http.StatusNoContent, nil)
+
+		got := list(alice.Token, "")
+		assert.Equal(t, int64(3), got.UnreadCount)
+		require.Len(t, got.Notifications, 3)
+		upgraded, followed, mentioned := got.Notifications[0], got.Notifications[1], got.Notifications[2]
+		assert.Equal(t, notify.TypeChirpyRed, upgraded.Type)
+		assert.Nil(t, upgraded.ActorID)
+		assert.Equal(t, notify.TypeFollow, followed.Type)
+		assert.Equal(t, &bob.ID, followed.ActorID)
+		assert.Equal(t, notify.TypeMention, mentioned.Type)
+		assert.Equal(t, &bob.ID, mentioned.ActorID)
+		assert.Equal(t, &mention.ID, mentioned.ChirpID)
+		assert.False(t, mentioned.Read)
+		assert.Len(t, list(alice.Token, "?limit=1").Notifications, 1)
+		assert.Empty(t, list(bob.Token, "").Notifications)
+
+		// Marking read only counts notifications that were unread, and only
+		// your own.
+		assert.Equal(t, marked{Marked: 0, UnreadCount: 0}, markRead(bob.Token, map[string]any{"ids": []uuid.UUID{mentioned.ID}}))
+		assert.Equal(t, marked{Marked: 1, UnreadCount: 2}, markRead(alice.Token, map[string]any{"ids": []uuid.UUID{mentioned.ID}}))
+		assert.Equal(t, marked{Marked: 0, UnreadCount: 2}, markRead(alice.Token, map[string]any{"ids": []uuid.UUID{mentioned.ID}}))
+		got = list(alice.Token, "?unread=true")
+		assert.Equal(t, []uuid.UUID{upgraded.ID, followed.ID}, notificationIDs(got.Notifications))
+		assert.True(t, list(alice.Token, "").Notifications[2].Read)
+		assert.Equal(t, marked{Marked: 2, UnreadCount: 0}, markRead(alice.Token, map[string]any{"all": true}))
+		assert.Empty(t, list(alice.Token, "?unread=true").Notifications)
+
+		// Turned-off types aren't sent.
+		var prefs map[notify.Type]bool
+		srv.do(t, call{method: "GET", path: "/api/notifications/preferences", auth: bearer(alice.Token)}, http.StatusOK, &prefs)
+		assert.Equal(t, map[notify.Type]bool{notify.TypeFollow: true, notify.TypeLike: true, notify.TypeReply: true, notify.TypeMention: true, notify.TypeChirpyRed: true}, prefs)
+		srv.do(t, call{method: "PUT", path: "/api/notifications/preferences", body: map[string]bool{"mention": false}, auth: bearer(alice.Token)}, http.StatusOK, &prefs)
+		assert.False(t, prefs[notify.TypeMention])
+		srv.postChirp(t, bob.Token, "hi again @alice@example.com")
+		assert.Len(t, list(alice.Token, "").Notifications, 3)
+
+		srv.do(t, call{method: "PUT", path: "/api/notifications/preferences", body: map[string]bool{"digest": false}, auth: bearer(alice.Token)}, http.StatusUnprocessableEntity, nil)
+		srv.do(t, call{method: "POST", path: "/api/notifications/read", body: map[string]any{}, auth: bearer(alice.Token)}, http.StatusUnprocessableEntity, nil)
+		srv.do(t, call{method: "GET", path: "/api/notifications?limit=0", auth: bearer(alice.Token)}, http.StatusBadRequest, nil)
+		srv.do(t, call{method: "GET", path: "/api/notifications"}, http.StatusUnauthorized, nil)
+	})
+}
+
+func notificationIDs(notifications []notify.Notification) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(notifications))
+	for _, n := range notifications {
+		out = append(out, n.ID)
+	}
+	return out
+}
+
+func TestWebhooksAndJobs(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		admin := apiKey(testAdminKey)
+
+		var created WebhookEndpoint
+		srv.do(t, call{method: "POST", path: "/api/webhooks", body: map[string]any{"url": "http://127.0.0.1:1/hook", "events": []string{"chirp.created"}}, auth: bearer(alice.Token)}, http.StatusCreated, &created)
+		assert.NotEmpty(t, created.Secret)
+		assert.Equal(t, []string{"chirp.created"}, created.Events)
+		var endpoints []WebhookEndpoint
+		srv.do(t, call{method: "GET", path: "/api/webhooks", auth: bearer(alice.Token)}, http.StatusOK, &endpoints)
+		if assert.Len(t, endpoints, 1) {
+			assert.Equal(t, created.ID, endpoints[0].ID)
+			assert.Empty(t, endpoints[0].Secret)
+		}
+		srv.do(t, call{method: "GET", path: "/api/webhooks", auth: bearer(bob.Token)}, http.StatusOK, &endpoints)
+		assert.Empty(t, endpoints)
+
+		// A chirp queues a delivery job for alice's endpoint.
+		srv.postChirp(t, bob.Token, "hello hooks")
+		var queued []Job
+		srv.do(t, call{method: "GET", path: "/admin/jobs?status=pending", auth: admin}, http.StatusOK, &queued)
+		require.Len(t, queued, 1)
+		assert.Equal(t, webhooks.JobDeliver, queued[0].Kind)
+		var job Job
+		srv.do(t, call{method: "GET", path: "/admin/jobs/" + queued[0].ID.String(), auth: admin}, http.StatusOK, &job)
+		assert.Equal(t, queued[0].ID, job.ID)
+		srv.do(t, call{method: "POST", path: "/admin/jobs/" + job.ID.String() + "/retry", auth: admin}, http.StatusConflict, nil)
+		srv.do(t, call{method: "GET", path: "/admin/jobs/" + uuid.NewString(), auth: admin}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "POST", path: "/admin/jobs/" + uuid.NewString() + "/retry", auth: admin}, http.StatusNotFound, nil)
+		require.NoError(t, srv.cfg.store.KillJob(context.Background(), job.ID, "gave up"))
+		var retried Job
+		srv.do(t, call{method: "POST", path: "/admin/jobs/" + job.ID.String() + "/retry", auth: admin}, http.StatusAccepted, &retried)
+		assert.Equal(t, jobs.StatusPending, retried.Status)
+		assert.Empty(t, retried.LastError)
+		srv.do(t, call{method: "GET", path: "/admin/jobs?status=done", auth: admin}, http.StatusBadRequest, nil)
+
+		var args struct {
+			DeliveryID uuid.UUID `json:"delivery_id"`
+		}
+		require.NoError(t, json.Unmarshal(job.Payload, &args))
+		deliveryPath := "/admin/webhooks/deliveries/" + args.DeliveryID.String()
+		var delivery WebhookDelivery
+		srv.do(t, call{method: "GET", path: deliveryPath, auth: admin}, http.StatusOK, &delivery)
+		assert.Equal(t, created.ID, delivery.EndpointID)
+		assert.Equal(t, webhooks.StatusPending, delivery.Status)
+		srv.do(t, call{method: "POST", path: deliveryPath + "/replay", auth: admin}, http.StatusConflict, nil)
+
+		// Deleting the endpoint takes its deliveries with it.
+		srv.do(t, call{method: "DELETE", path: "/api/webhooks/" + created.ID.String(), auth: bearer(bob.Token)}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/webhooks/" + created.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "GET", path: deliveryPath, auth: admin}, http.StatusNotFound, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/webhooks/" + created.ID.String(), auth: bearer(alice.Token)}, http.StatusNotFound, nil)
+
+		srv.do(t, call{method: "GET", path: "/admin/jobs"}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "GET", path: "/api/webhooks"}, http.StatusUnauthorized, nil)
+	})
+}
+
+// brokenDeliveries is a store whose webhook deliveries can't be read.
+type brokenDeliveries struct{ store.Store }
+
+func (brokenDeliveries) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	return database.WebhookDelivery{}, errors.New("connection reset")
+}
+
+func TestWebhookDeliveryStoreErrors(t *testing.T) {
+	// Only a missing delivery is a 404; anything else is the server's fault.
+	srv := newTestServer(t, brokenDeliveries{store.NewMemory()})
+	path := "/admin/webhooks/deliveries/" + uuid.NewString()
+
+	var resp ErrorResponse
+	srv.do(t, call{method: "GET", path: path, auth: apiKey(testAdminKey)}, http.StatusInternalServerError, &resp)
+	assert.Equal(t, apierror.Internal, resp.Code)
+	srv.do(t, call{method: "POST", path: path + "/replay", auth: apiKey(testAdminKey)}, http.StatusInternalServerError, &resp)
+	assert.Equal(t, apierror.Internal, resp.Code)
+}
+
+func TestAdminReset(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		srv.postChirp(t, alice.Token, "soon gone")
+
+		srv.do(t, call{method: "POST", path: "/admin/reset"}, http.StatusOK, nil)
+
+		assert.Empty(t, srv.listChirps(t, ""))
+		srv.do(t, call{method: "POST", path: "/api/login", body: map[string]string{"email": "alice@example.com", "password": testPassword}}, http.StatusUnauthorized, nil)
+		srv.do(t, call{method: "POST", path: "/api/refresh", auth: bearer(alice.RefreshToken)}, http.StatusUnauthorized, nil)
+		srv.signup(t, "alice@example.com", testPassword)
+	})
+
+	prod := newTestServer(t, store.NewMemory(), func(cfg *apiConfig) { cfg.Platform = "prod" })
+	prod.do(t, call{method: "POST", path: "/admin/reset"}, http.StatusForbidden, nil)
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	assert.Equal(t, "OK", string(srv.do(t, call{method: "GET", path: "/api/healthz"}, http.StatusOK, nil)))
+	srv.do(t, call{method: "GET", path: "/livez"}, http.StatusOK, nil)
+	srv.do(t, call{method: "GET", path: "/readyz"}, http.StatusOK, nil)
+}
+
+func ids(chirps []Chirp) []uuid.UUID {
+	out := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		out = append(out, c.ID)
+	}
+	return out
+}
+
+func TestRequestValidation(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	alice := srv.newUser(t, "alice@example.com")
+
+	var resp ErrorResponse
+	srv.do(t, call{method: "POST", path: "/api/users", body: map[string]string{"email": "not-an-email", "password": "short"}}, http.StatusUnprocessableEntity, &resp)
+	assert.Equal(t, []validate.FieldError{
+		{Field: "email", Message: "must be an email address"},
+		{Field: "password", Message: "must be at least 8 characters"},
+	}, resp.Fields)
+
+	resp = ErrorResponse{}
+	srv.do(t, call{method: "POST", path: "/api/users", body: map[string]string{"email": "bob@example.com", "password": testPassword, "role": "admin"}}, http.StatusBadRequest, &resp)
+	assert.Equal(t, []validate.FieldError{{Field: "role", Message: "is not a known field"}}, resp.Fields)
+
+	resp = ErrorResponse{}
+	srv.do(t, call{method: "POST", path: "/api/login", body: map[string]any{"email": "alice@example.com", "password": 12345678}}, http.StatusBadRequest, &resp)
+	assert.Equal(t, []validate.FieldError{{Field: "password", Message: "must be a string"}}, resp.Fields)
+
+	srv.do(t, call{method: "POST", path: "/api/login", body: `{"email": `}, http.StatusBadRequest, nil)
+	srv.do(t, call{method: "POST", path: "/api/login", body: `{} {}`}, http.StatusBadRequest, nil)
+	srv.do(t, call{method: "POST", path: "/api/login", body: ""}, http.StatusBadRequest, nil)
+
+	chirp := func(body string, want int) {
+		t.Helper()
+		srv.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": body}, auth: bearer(alice.Token)}, want, nil)
+	}
+	chirp(strings.Repeat("é", maxChirpLength), http.StatusCreated)
+	chirp(strings.Repeat("a", maxChirpLength+1), http.StatusUnprocessableEntity)
+	chirp("   ", http.StatusUnprocessableEntity)
+	chirp(strings.Repeat("a", maxBodyBytes), http.StatusRequestEntityTooLarge)
+}
+
+func TestErrorCodes(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	alice := srv.newUser(t, "alice@example.com")
+	bob := srv.newUser(t, "bob@example.com")
+	chirp := srv.postChirp(t, alice.Token, "hello")
+	expired, err := auth.MakeJWT(context.Background(), alice.ID, testSecret, -time.Minute)
+	require.NoError(t, err)
+	reader := srv.createAPIKey(t, alice.Token, "chirps:read")
+	appToken, err := auth.MakeClientJWT(context.Background(), alice.ID, uuid.New(), []auth.Scope{auth.ScopeChirpsRead}, testSecret, time.Minute)
+	require.NoError(t, err)
+
+	tests := []struct {
+		call call
+		want apierror.Code
+	}{
+		{call{method: "DELETE", path: "/api/chirps/" + chirp.ID.String()}, apierror.AuthMissing},
+		{call{method: "DELETE", path: "/api/chirps/" + chirp.ID.String(), auth: "Token " + alice.Token}, apierror.AuthMalformed},
+		{call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "hi"}, auth: "Token " + alice.Token}, apierror.AuthMalformed},
+		{call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "hi"}, auth: bearer(expired)}, apierror.AuthTokenExpired},
+		{call{method: "PUT", path: "/api/users", body: map[string]string{"email": "a@example.com", "password": testPassword}, auth: bearer("garbage")}, apierror.AuthTokenInvalid},
+		{call{method: "POST", path: "/api/refresh", auth: bearer("unknown")}, apierror.AuthRefreshTokenInvalid},
+		{call{method: "POST", path: "/api/login", body: map[string]string{"email": "alice@example.com", "password": "wrong password"}}, apierror.AuthInvalidCredentials},
+		{call{method: "POST", path: "/api/users", body: map[string]string{"email": "bob@example.com", "password": testPassword}}, apierror.UserEmailTaken},
+		{call{method: "PUT", path: "/api/users", body: map[string]string{"email": "alice@example.com", "password": testPassword}, auth: bearer(bob.Token)}, apierror.UserEmailTaken},
+		{call{method: "GET", path: "/api/chirps/" + uuid.NewString()}, apierror.ChirpNotFound},
+		{call{method: "GET", path: "/api/chirps?author_id=nope"}, apierror.RequestInvalidParameter},
+		{call{method: "DELETE", path: "/api/chirps/" + chirp.ID.String(), auth: bearer(bob.Token)}, apierror.ChirpForbidden},
+		{call{method: "POST", path: "/api/chirps", body: map[string]string{}, auth: bearer(alice.Token)}, apierror.ValidationFailed},
+		{call{method: "POST", path: "/api/chirps", body: "{", auth: bearer(alice.Token)}, apierror.RequestMalformed},
+		{call{method: "POST", path: "/api/polka/webhooks", body: map[string]any{"event": "user.upgraded"}, auth: apiKey("wrong")}, apierror.AuthAPIKeyInvalid},
+		{call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "hi"}, auth: apiKey("wrong")}, apierror.AuthAPIKeyInvalid},
+		{call{method: "POST", path: "/api/chirps", body: map[string]string{"body": "hi"}, auth: apiKey(reader.Key)}, apierror.AuthInsufficientScope},
+		{call{method: "POST", path: "/api/users/me/api_keys", body: map[string]any{"name": "bot", "scopes": []string{"chirps:*"}}, auth: bearer(alice.Token)}, apierror.ValidationFailed},
+		{call{method: "DELETE", path: "/api/users/me/api_keys/" + uuid.NewString(), auth: bearer(alice.Token)}, apierror.APIKeyNotFound},
+		{call{method: "GET", path: "/api/users/me/api_keys", auth: bearer(appToken)}, apierror.AuthInsufficientScope},
+		{call{method: "GET", path: "/api/oauth/clients/" + uuid.NewString()}, apierror.OAuthClientNotFound},
+		{call{method: "POST", path: "/api/oauth/clients", body: map[string]any{"name": "app", "redirect_uris": []string{"http://example.com/cb"}}, auth: bearer(alice.Token)}, apierror.ValidationFailed},
+		{call{method: "GET", path: "/api/auth/oidc/nope/login"}, apierror.OIDCProviderNotFound},
+		{call{method: "GET", path: "/api/auth/oidc/nope/callback?code=x&state=y"}, apierror.OIDCProviderNotFound},
+		{call{method: "DELETE", path: "/api/users/me/identities/nope", auth: bearer(alice.Token)}, apierror.IdentityNotFound},
+		{call{method: "PUT", path: "/api/users/me/blocks/" + uuid.NewString(), auth: bearer(alice.Token)}, apierror.UserNotFound},
+		{call{method: "PUT", path: "/api/users/me/mutes/" + alice.ID.String(), auth: bearer(alice.Token)}, apierror.RequestInvalidParameter},
+		{call{method: "DELETE", path: "/api/users/me/blocks/" + bob.ID.String(), auth: bearer(alice.Token)}, apierror.BlockNotFound},
+		{call{method: "DELETE", path: "/api/users/me/mutes/" + bob.ID.String(), auth: bearer(alice.Token)}, apierror.MuteNotFound},
+		{call{method: "DELETE", path: "/api/users/me/following/" + bob.ID.String(), auth: bearer(alice.Token)}, apierror.FollowNotFound},
+		{call{method: "PUT", path: "/api/users/me/following/" + uuid.NewString(), auth: bearer(alice.Token)}, apierror.UserNotFound},
+		{call{method: "POST", path: "/api/users/me/follow_requests/" + bob.ID.String() + "/approve", auth: bearer(alice.Token)}, apierror.FollowRequestNotFound},
+		{call{method: "DELETE", path: "/api/chirps/" + uuid.NewString() + "/bookmark", auth: bearer(alice.Token)}, apierror.BookmarkNotFound},
+		{call{method: "POST", path: "/api/bookmarks/collections", body: map[string]string{"name": "later"}, auth: bearer(alice.Token)}, apierror.CollectionRequiresChirpyRed},
+		{call{method: "DELETE", path: "/api/bookmarks/collections/" + uuid.NewString(), auth: bearer(alice.Token)}, apierror.CollectionNotFound},
+	}
+	for _, tt := range tests {
+		var resp ErrorResponse
+		srv.do(t, tt.call, tt.want.Status(), &resp)
+		assert.Equal(t, tt.want, resp.Code, "%s %s", tt.call.method, tt.call.path)
+		assert.NotEmpty(t, resp.Error)
+	}
+}
+
+func TestProblemDetails(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	missing := "/api/chirps/" + uuid.NewString()
+
+	tests := []struct {
+		path string
+		want ProblemDetails
+	}{
+		{missing, ProblemDetails{
+			Type:     "urn:chirpy:error:chirp.not_found",
+			Title:    "Chirp not found",
+			Status:   http.StatusNotFound,
+			Detail:   "Couldn't get chirp",
+			Instance: missing,
+			Code:     apierror.ChirpNotFound,
+		}},
+		{"/api/chirps?author_id=nope", ProblemDetails{
+			Type:     "urn:chirpy:error:request.invalid_parameter",
+			Title:    "Invalid query parameter",
+			Status:   http.StatusBadRequest,
+			Detail:   "author_id must be a UUID",
+			Instance: "/api/chirps",
+			Code:     apierror.RequestInvalidParameter,
+		}},
+	}
+	for _, tt := range tests {
+		req, err := http.NewRequest("GET", srv.URL+tt.path, nil)
+		require.NoError(t, err)
+		req.Header.Set("Accept", "application/json, application/problem+json;q=0.9")
+		resp, err := srv.Client().Do(req)
+		require.NoError(t, err)
+
+		assert.Equal(t, tt.want.Status, resp.StatusCode, tt.path)
+		assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"), tt.path)
+		var problem ProblemDetails
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+		resp.Body.Close()
+		assert.Equal(t, tt.want, problem, tt.path)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/logging"
+)
+
+// apiKeyTouchInterval is how often a personal API key's last-used time
+// is updated. Writing it on every request would double the writes a busy
+// bot makes.
+const apiKeyTouchInterval = time.Minute
+
+// authenticate runs next only for requests with a valid credential of the
+// given scheme, with the caller's auth.Principal in the request context.
+// If scope is set, a personal API key granted scope is accepted in place
+// of an access token. Delegated credentials, API keys and OAuth client
+// tokens, only reach routes with a scope they were granted. Routes opt in
+// with router.HandleAuth and router.HandleScope.
+func (cfg *apiConfig) authenticate(scheme auth.Scheme, scope auth.Scope, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, code, err := cfg.authenticateRequest(r, scheme, scope)
+		if err != nil {
+			respondWithError(w, r, code, "", err)
+			return
+		}
+		if scheme == auth.SchemeAccessToken && !p.Allows(scope) {
+			msg := fmt.Sprintf("Credential doesn't have the %s scope", scope)
+			if scope == "" {
+				msg = "This endpoint needs a token from logging in to Chirpy"
+			}
+			respondWithError(w, r, apierror.AuthInsufficientScope, msg, nil)
+			return
+		}
+		if p.UserID != uuid.Nil {
+			logging.SetUserID(r.Context(), p.UserID)
+		}
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), p)))
+	})
+}
+
+func (cfg *apiConfig) authenticateRequest(r *http.Request, scheme auth.Scheme, scope auth.Scope) (auth.Principal, apierror.Code, error) {
+	switch scheme {
+	case auth.SchemeAccessToken:
+		if scope != "" {
+			if key, err := auth.GetAPIKey(r.Header); err == nil {
+				return cfg.userAPIKey(r, key)
+			}
+		}
+		token, err := accessToken(r)
+		if err != nil {
+			return auth.Principal{}, apierror.For(err, apierror.Internal), err
+		}
+		claims, err := auth.ParseAccessToken(r.Context(), token, cfg.Secret)
+		if err != nil {
+			return auth.Principal{}, apierror.For(err, apierror.AuthTokenInvalid), err
+		}
+		return auth.Principal{
+			Scheme:    scheme,
+			UserID:    claims.UserID,
+			Token:     token,
+			ExpiresAt: claims.ExpiresAt,
+			ClientID:  claims.ClientID,
+			Scopes:    claims.Scopes,
+		}, "", nil
+
+	case auth.SchemeRefreshToken:
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			return auth.Principal{}, apierror.For(err, apierror.Internal), err
+		}
+		user, err := cfg.store.GetUserFromRefreshToken(r.Context(), token)
+		if err != nil {
+			return auth.Principal{}, apierror.For(err, apierror.AuthRefreshTokenInvalid), err
+		}
+		return auth.Principal{Scheme: scheme, UserID: user.ID, Token: token}, "", nil
+
+	case auth.SchemePolkaKey:
+		return checkAPIKey(r, scheme, cfg.PolkaKey)
+
+	case auth.SchemeAdminKey:
+		if cfg.AdminKey == "" {
+			return auth.Principal{}, apierror.AuthForbidden, errors.New("admin API is disabled: ADMIN_KEY is not set")
+		}
+		return checkAPIKey(r, scheme, cfg.AdminKey)
+
+	default:
+		return auth.Principal{}, apierror.Internal, fmt.Errorf("route requires unknown auth scheme %q", scheme)
+	}
+}
+
+// userAPIKey authenticates a personal API key and records that it was
+// used.
+func (cfg *apiConfig) userAPIKey(r *http.Request, key string) (auth.Principal, apierror.Code, error) {
+	apiKey, err := cfg.store.GetAPIKeyByHash(r.Context(), auth.HashAPIKey(key))
+	if err != nil {
+		return auth.Principal{}, apierror.For(err, apierror.AuthAPIKeyInvalid), err
+	}
+
+	if !apiKey.LastUsedAt.Valid || time.Since(apiKey.LastUsedAt.Time) > apiKeyTouchInterval {
+		err = cfg.store.TouchAPIKey(r.Context(), apiKey.ID)
+		if err != nil {
+			slog.WarnContext(r.Context(), "Error recording API key use", "api_key_id", apiKey.ID, "error", err)
+		}
+	}
+	return auth.Principal{Scheme: auth.SchemeAPIKey, UserID: apiKey.UserID, Scopes: auth.ParseScopes(apiKey.Scopes)}, "", nil
+}
+
+// accessToken reads the Bearer token. Browsers can't set headers on a
+// WebSocket handshake, so those may pass it as access_token instead.
+func accessToken(r *http.Request) (string, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if errors.Is(err, auth.ErrNoAuthHeaderIncluded) && strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		if token := r.URL.Query().Get("access_token"); token != "" {
+			return token, nil
+		}
+	}
+	return token, err
+}
+
+func checkAPIKey(r *http.Request, scheme auth.Scheme, want string) (auth.Principal, apierror.Code, error) {
+	key, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return auth.Principal{}, apierror.For(err, apierror.Internal), err
+	}
+	if subtle.ConstantTimeCompare([]byte(key), []byte(want)) != 1 {
+		return auth.Principal{}, apierror.AuthAPIKeyInvalid, fmt.Errorf("wrong %s", scheme)
+	}
+	return auth.Principal{Scheme: scheme}, "", nil
+}
+
+// principal returns the caller set by authenticate. It is the zero
+// Principal on routes registered without HandleAuth.
+func principal(r *http.Request) auth.Principal {
+	p, _ := auth.PrincipalFrom(r.Context())
+	return p
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+func TestAuthenticate(t *testing.T) {
+	ctx := context.Background()
+	st := store.NewMemory()
+	user, err := st.CreateUser(ctx, "alice@example.com", "unused")
+	require.NoError(t, err)
+	access, err := auth.MakeJWT(ctx, user.ID, testSecret, time.Hour)
+	require.NoError(t, err)
+	expired, err := auth.MakeJWT(ctx, user.ID, testSecret, -time.Hour)
+	require.NoError(t, err)
+	require.NoError(t, st.CreateRefreshToken(ctx, "refresh", user.ID, time.Now().Add(time.Hour)))
+	require.NoError(t, st.CreateRefreshToken(ctx, "revoked", user.ID, time.Now().Add(time.Hour)))
+	require.NoError(t, st.RevokeRefreshToken(ctx, "revoked"))
+
+	cfg := &apiConfig{store: st, Secret: testSecret, PolkaKey: testPolkaKey, AdminKey: testAdminKey}
+	noAdmin := &apiConfig{store: st, Secret: testSecret}
+
+	tests := []struct {
+		name     string
+		cfg      *apiConfig
+		scheme   auth.Scheme
+		header   string
+		target   string
+		upgrade  bool
+		wantUser uuid.UUID
+		wantCode apierror.Code
+	}{
+		{name: "access token", scheme: auth.SchemeAccessToken, header: bearer(access), wantUser: user.ID},
+		{name: "lowercase scheme", scheme: auth.SchemeAccessToken, header: "bearer " + access, wantUser: user.ID},
+		{name: "no header", scheme: auth.SchemeAccessToken, wantCode: apierror.AuthMissing},
+		{name: "wrong scheme", scheme: auth.SchemeAccessToken, header: apiKey(access), wantCode: apierror.AuthMalformed},
+		{name: "expired", scheme: auth.SchemeAccessToken, header: bearer(expired), wantCode: apierror.AuthTokenExpired},
+		{name: "refresh token as access token", scheme: auth.SchemeAccessToken, header: bearer("refresh"), wantCode: apierror.AuthTokenInvalid},
+		{name: "websocket query token", scheme: auth.SchemeAccessToken, target: "/?access_token=" + access, upgrade: true, wantUser: user.ID},
+		{name: "query token without upgrade", scheme: auth.SchemeAccessToken, target: "/?access_token=" + access, wantCode: apierror.AuthMissing},
+		{name: "refresh token", scheme: auth.SchemeRefreshToken, header: bearer("refresh"), wantUser: user.ID},
+		{name: "revoked refresh token", scheme: auth.SchemeRefreshToken, header: bearer("revoked"), wantCode: apierror.AuthRefreshTokenInvalid},
+		{name: "access token as refresh token", scheme: auth.SchemeRefreshToken, header: bearer(access), wantCode: apierror.AuthRefreshTokenInvalid},
+		{name: "polka key", scheme: auth.SchemePolkaKey, header: apiKey(testPolkaKey)},
+		{name: "admin key as polka key", scheme: auth.SchemePolkaKey, header: apiKey(testAdminKey), wantCode: apierror.AuthAPIKeyInvalid},
+		{name: "admin key", scheme: auth.SchemeAdminKey, header: apiKey(testAdminKey)},
+		{name: "bearer as admin key", scheme: auth.SchemeAdminKey, header: bearer(testAdminKey), wantCode: apierror.AuthMalformed},
+		{name: "admin API disabled", cfg: noAdmin, scheme: auth.SchemeAdminKey, header: apiKey(""), wantCode: apierror.AuthForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.cfg == nil {
+				tt.cfg = cfg
+			}
+			if tt.target == "" {
+				tt.target = "/"
+			}
+
+			var got auth.Principal
+			handler := tt.cfg.authenticate(tt.scheme, "", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = principal(r)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			req := httptest.NewRequest("GET", tt.target, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.upgrade {
+				req.Header.Set("Upgrade", "websocket")
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if tt.wantCode != "" {
+				var resp ErrorResponse
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&resp))
+				assert.Equal(t, tt.wantCode.Status(), rec.Code)
+				assert.Equal(t, tt.wantCode, resp.Code)
+				return
+			}
+			require.Equal(t, http.StatusNoContent, rec.Code, rec.Body.String())
+			assert.Equal(t, tt.scheme, got.Scheme)
+			assert.Equal(t, tt.wantUser, got.UserID)
+		})
+	}
+}
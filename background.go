@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+const (
+	jobPurgeRefreshTokens = "tokens.purge_expired"
+	jobPurgeFinishedJobs  = "jobs.purge_finished"
+)
+
+// finishedJobRetention is how long succeeded and dead jobs are kept, so
+// admins can still inspect and retry recent failures.
+const finishedJobRetention = 7 * 24 * time.Hour
+
+// registerJobs installs the server's own job handlers and cron schedules.
+// Subsystems with their own jobs (like webhooks) register them when they
+// are constructed.
+func registerJobs(queue *jobs.Queue, st store.Store) error {
+	jobs.Register(queue, jobPurgeRefreshTokens, jobs.Options{MaxAttempts: 3}, func(ctx context.Context, _ struct{}) error {
+		purged, err := st.DeleteExpiredRefreshTokens(ctx)
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d expired refresh tokens", purged)
+		}
+		return nil
+	})
+
+	jobs.Register(queue, jobPurgeFinishedJobs, jobs.Options{MaxAttempts: 3}, func(ctx context.Context, _ struct{}) error {
+		purged, err := st.DeleteFinishedJobsBefore(ctx, time.Now().Add(-finishedJobRetention))
+		if err != nil {
+			return err
+		}
+		if purged > 0 {
+			log.Printf("Purged %d finished jobs", purged)
+		}
+		return nil
+	})
+
+	return errors.Join(
+		queue.Schedule("17 * * * *", jobPurgeRefreshTokens, struct{}{}),
+		queue.Schedule("43 3 * * *", jobPurgeFinishedJobs, struct{}{}),
+	)
+}
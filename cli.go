@@ -0,0 +1,336 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/config"
+	"github.com/willmelton21/chirpy/internal/migrations"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+const usage = `Usage: chirpy <command> [arguments]
+
+Commands:
+  serve                            Run the HTTP server (the default)
+  migrate up|down|status           Apply, roll back or list schema migrations
+  user create -email E -password P Create a user
+  user promote -email E            Upgrade a user to Chirpy Red
+  user delete -email E             Delete a user and everything they own
+  token revoke-all [-email E]      Revoke refresh tokens for everyone, or one user
+  seed [-users N] [-chirps N]      Fill a dev database with sample users and chirps
+
+Configuration is read from the environment, .env and CONFIG_FILE, as for
+the server.
+`
+
+// errUsage means the command line was wrong; usage has already been shown.
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(runCLI(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// runCLI dispatches a subcommand and returns the process exit code.
+func runCLI(args []string, stdout, stderr io.Writer) int {
+	if len(args) > 0 && (args[0] == "help" || args[0] == "-h" || args[0] == "--help") {
+		fmt.Fprint(stdout, usage)
+		return 0
+	}
+
+	conf, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(stderr, "chirpy: %s\n", err)
+		return 1
+	}
+
+	if len(args) == 0 || args[0] == "serve" {
+		serve(conf)
+		return 0
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	switch args[0] {
+	case "migrate":
+		err = cmdMigrate(ctx, conf, args[1:], stdout)
+	case "user":
+		err = cmdUser(ctx, conf, args[1:], stdout)
+	case "token":
+		err = cmdToken(ctx, conf, args[1:], stdout)
+	case "seed":
+		err = cmdSeed(ctx, conf, args[1:], stdout)
+	default:
+		err = errUsage
+	}
+
+	if errors.Is(err, errUsage) {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "chirpy %s: %s\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// openDB opens DB_URL's database and returns its driver name.
+func openDB(conf config.Config) (string, *sql.DB, error) {
+	if conf.DBURL == "" {
+		return "", nil, errors.New("DB_URL is required")
+	}
+	driver, db, err := store.Open(conf.DBURL)
+	if err != nil {
+		return "", nil, err
+	}
+	if db == nil {
+		return "", nil, fmt.Errorf("the %s store only lives inside a running server", driver)
+	}
+	return driver, db, nil
+}
+
+// openStore opens the database for admin commands, refusing to touch a
+// schema this binary doesn't match.
+func openStore(ctx context.Context, conf config.Config) (store.Store, func() error, error) {
+	driver, db, err := openDB(conf)
+	if err != nil {
+		return nil, nil, err
+	}
+	migrator, err := migrations.New(db, driver)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	err = migrator.Check(ctx)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	st, err := store.New(driver, db)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+	return st, db.Close, nil
+}
+
+func cmdMigrate(ctx context.Context, conf config.Config, args []string, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	driver, db, err := openDB(conf)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	migrator, err := migrations.New(db, driver)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		results, err := migrator.Up(ctx)
+		for _, res := range results {
+			fmt.Fprintf(stdout, "OK   %s (%s)\n", res.Source.Path, res.Duration.Round(time.Millisecond))
+		}
+		if err != nil {
+			return err
+		}
+		if len(results) == 0 {
+			fmt.Fprintln(stdout, "No migrations to apply")
+		}
+	case "down":
+		res, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "OK   %s rolled back (%s)\n", res.Source.Path, res.Duration.Round(time.Millisecond))
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "%-25s %s\n", "Applied At", "Migration")
+		for _, st := range statuses {
+			applied := "Pending"
+			if !st.AppliedAt.IsZero() {
+				applied = st.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(stdout, "%-25s %s\n", applied, st.Source.Path)
+		}
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+func cmdUser(ctx context.Context, conf config.Config, args []string, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	email := flags.String("email", "", "the user's email")
+	password := flags.String("password", "", "password for a new user")
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return errUsage
+	}
+	if *email == "" {
+		return errors.New("-email is required")
+	}
+
+	st, closeDB, err := openStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	if args[0] == "create" {
+		if *password == "" {
+			return errors.New("-password is required")
+		}
+		hashed, err := auth.HashPassword(ctx, *password)
+		if err != nil {
+			return err
+		}
+		user, err := st.CreateUser(ctx, *email, hashed)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Created user %s (%s)\n", user.Email, user.ID)
+		return nil
+	}
+
+	user, err := st.GetUserByEmail(ctx, *email)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("no user with email %s", *email)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "promote":
+		err = st.UpgradeUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Upgraded %s to Chirpy Red\n", user.Email)
+	case "delete":
+		err = st.DeleteUser(ctx, user.ID)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "Deleted user %s (%s)\n", user.Email, user.ID)
+	default:
+		return errUsage
+	}
+	return nil
+}
+
+func cmdToken(ctx context.Context, conf config.Config, args []string, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "revoke-all" {
+		return errUsage
+	}
+
+	flags := flag.NewFlagSet("token revoke-all", flag.ContinueOnError)
+	email := flags.String("email", "", "only revoke this user's tokens")
+	err := flags.Parse(args[1:])
+	if err != nil {
+		return errUsage
+	}
+
+	st, closeDB, err := openStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	var revoked int64
+	if *email == "" {
+		revoked, err = st.RevokeAllRefreshTokens(ctx)
+	} else {
+		user, lookupErr := st.GetUserByEmail(ctx, *email)
+		if lookupErr != nil {
+			return fmt.Errorf("looking up %s: %w", *email, lookupErr)
+		}
+		revoked, err = st.RevokeUserRefreshTokens(ctx, user.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "Revoked %d refresh tokens. Access tokens already issued stay valid until they expire.\n", revoked)
+	return nil
+}
+
+var seedChirps = []string{
+	"Just set up my Chirpy account!",
+	"Anyone else think Go's error handling grows on you?",
+	"Coffee first, code second.",
+	"Shipping on a Friday. Wish me luck.",
+	"The best code is the code you don't write.",
+	"Reading the Go spec for fun again.",
+}
+
+func cmdSeed(ctx context.Context, conf config.Config, args []string, stdout io.Writer) error {
+	flags := flag.NewFlagSet("seed", flag.ContinueOnError)
+	users := flags.Int("users", 5, "number of users to create")
+	chirps := flags.Int("chirps", 3, "chirps per user")
+	password := flags.String("password", "password", "password for every seeded user")
+	err := flags.Parse(args)
+	if err != nil || *users < 0 || *chirps < 0 {
+		return errUsage
+	}
+	if conf.Platform != "dev" {
+		return errors.New("seeding is only allowed when PLATFORM=dev")
+	}
+
+	st, closeDB, err := openStore(ctx, conf)
+	if err != nil {
+		return err
+	}
+	defer closeDB()
+
+	hashed, err := auth.HashPassword(ctx, *password)
+	if err != nil {
+		return err
+	}
+
+	created := 0
+	for i := 1; i <= *users; i++ {
+		email := fmt.Sprintf("seed%d@example.com", i)
+		user, err := st.GetUserByEmail(ctx, email)
+		if errors.Is(err, store.ErrNotFound) {
+			user, err = st.CreateUser(ctx, email, hashed)
+			created++
+		}
+		if err != nil {
+			return fmt.Errorf("seeding %s: %w", email, err)
+		}
+
+		for j := 0; j < *chirps; j++ {
+			_, err = st.CreateChirp(ctx, user.ID, seedChirps[(i+j)%len(seedChirps)])
+			if err != nil {
+				return fmt.Errorf("seeding chirps for %s: %w", email, err)
+			}
+		}
+	}
+
+	fmt.Fprintf(stdout, "Seeded %d new users and %d chirps. Every seeded user's password is %q.\n", created, (*users)*(*chirps), *password)
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+// maxBodyBytes caps JSON request bodies. The largest legitimate request,
+// a chirp or a webhook registration, is well under 1 KiB.
+const maxBodyBytes = 64 << 10
+
+// maxChirpLength is counted in characters, before profanity is filtered.
+const maxChirpLength = 140
+
+// decodeJSON reads a single JSON value from r's body into dst, rejecting
+// unknown fields. It responds with 400 or 413 and returns false if the
+// body can't be decoded.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return decodeJSONBody(w, r, dst, true)
+}
+
+// decodeJSONLenient is decodeJSON for third-party payloads, which may gain
+// fields we don't use.
+func decodeJSONLenient(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return decodeJSONBody(w, r, dst, false)
+}
+
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, dst any, strict bool) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	decoder := json.NewDecoder(r.Body)
+	if strict {
+		decoder.DisallowUnknownFields()
+	}
+
+	err := decoder.Decode(dst)
+	if err == nil && decoder.Decode(&struct{}{}) != io.EOF {
+		err = errors.New("body must contain a single JSON value")
+	}
+	if err == nil {
+		return true
+	}
+
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	var sizeErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &sizeErr):
+		respondWithError(w, r, apierror.RequestTooLarge, fmt.Sprintf("Request body must be at most %d bytes", sizeErr.Limit), nil)
+	case errors.Is(err, io.EOF):
+		respondWithError(w, r, apierror.RequestMalformed, "Request body is empty", nil)
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF):
+		respondWithError(w, r, apierror.RequestMalformed, "Request body isn't valid JSON", err)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		respondWithFieldErrors(w, r, apierror.RequestMalformed, "Request body has a field of the wrong type", []validate.FieldError{
+			{Field: typeErr.Field, Message: "must be " + jsonKind(typeErr.Type)},
+		})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		// encoding/json has no typed error for this.
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		respondWithFieldErrors(w, r, apierror.RequestMalformed, "Request body has an unknown field", []validate.FieldError{
+			{Field: field, Message: "is not a known field"},
+		})
+	default:
+		respondWithError(w, r, apierror.RequestMalformed, "Couldn't decode request body", err)
+	}
+	return false
+}
+
+var textUnmarshaler = reflect.TypeFor[encoding.TextUnmarshaler]()
+
+// jsonKind describes the JSON value that decodes into t.
+func jsonKind(t reflect.Type) string {
+	if reflect.PointerTo(t).Implements(textUnmarshaler) {
+		return "a string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	default:
+		return "a number"
+	}
+}
+
+// checkValid responds with 422 and the invalid fields, and returns false,
+// if v found any.
+func checkValid(w http.ResponseWriter, r *http.Request, v *validate.Validator) bool {
+	var verr *validate.Error
+	if !errors.As(v.Err(), &verr) {
+		return true
+	}
+	respondWithFieldErrors(w, r, apierror.ValidationFailed, "", verr.Fields)
+	return false
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+const (
+	maxAPIKeyNameLength = 100
+	// apiKeyPrefixLength is how much of a key is kept in the clear, so
+	// users can tell their keys apart.
+	apiKeyPrefixLength = len(auth.APIKeyPrefix) + 6
+)
+
+type APIKey struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Name       string       `json:"name"`
+	Prefix     string       `json:"prefix"`
+	Scopes     []auth.Scope `json:"scopes"`
+	LastUsedAt *time.Time   `json:"last_used_at"`
+	Key        string       `json:"key,omitempty"`
+}
+
+func apiKeyFromDB(k database.ApiKey) APIKey {
+	key := APIKey{
+		ID:        k.ID,
+		CreatedAt: k.CreatedAt,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		Scopes:    auth.ParseScopes(k.Scopes),
+	}
+	if k.LastUsedAt.Valid {
+		key.LastUsedAt = &k.LastUsedAt.Time
+	}
+	return key
+}
+
+func (cfg *apiConfig) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name   string   `json:"name"`
+		Scopes []string `json:"scopes"`
+	}
+
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	var v validate.Validator
+	if v.Required("name", params.Name) {
+		v.MaxLength("name", params.Name, maxAPIKeyNameLength)
+	}
+	v.Check(len(params.Scopes) > 0, "scopes", "must list at least one scope")
+	var scopes []auth.Scope
+	for i, scope := range params.Scopes {
+		v.Check(auth.IsKnownScope(scope), fmt.Sprintf("scopes[%d]", i), "is not a scope")
+		if !slices.Contains(scopes, auth.Scope(scope)) {
+			scopes = append(scopes, auth.Scope(scope))
+		}
+	}
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	key, err := auth.MakeAPIKey()
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create API key", err)
+		return
+	}
+
+	apiKey, err := cfg.store.CreateAPIKey(r.Context(), userID, params.Name, key[:apiKeyPrefixLength], auth.HashAPIKey(key), auth.FormatScopes(scopes))
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create API key", err)
+		return
+	}
+
+	// Only the hash is stored, so this is the one chance to see the key.
+	resp := apiKeyFromDB(apiKey)
+	resp.Key = key
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	dbKeys, err := cfg.store.ListAPIKeys(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list API keys", err)
+		return
+	}
+
+	keys := make([]APIKey, 0, len(dbKeys))
+	for _, k := range dbKeys {
+		keys = append(keys, apiKeyFromDB(k))
+	}
+	respondWithJSON(w, http.StatusOK, keys)
+}
+
+func (cfg *apiConfig) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	keyID, err := uuid.Parse(r.PathValue("keyID"))
+	if err != nil {
+		respondWithError(w, r, apierror.APIKeyNotFound, "API key not found", err)
+		return
+	}
+
+	err = cfg.store.DeleteAPIKey(r.Context(), keyID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.APIKeyNotFound), "Couldn't revoke API key", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+const (
+	defaultBookmarkLimit = 20
+	maxBookmarkLimit     = 100
+
+	maxCollectionNameLength = 50
+)
+
+// Bookmark is a chirp the caller saved. Only the caller can see it.
+type Bookmark struct {
+	Chirp        Chirp      `json:"chirp"`
+	CollectionID *uuid.UUID `json:"collection_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// BookmarkCollection is a named group of bookmarks. Only Chirpy Red users
+// can create them.
+type BookmarkCollection struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+}
+
+func bookmarkFrom(b database.Bookmark, c database.Chirp) Bookmark {
+	bookmark := Bookmark{
+		Chirp: Chirp{
+			ID:        c.ID,
+			CreatedAt: c.CreatedAt,
+			UpdatedAt: c.UpdatedAt,
+			Body:      c.Body,
+			UserID:    c.UserID,
+		},
+		CreatedAt: b.CreatedAt,
+	}
+	if b.CollectionID.Valid {
+		bookmark.CollectionID = &b.CollectionID.UUID
+	}
+	return bookmark
+}
+
+func collectionFrom(c database.BookmarkCollection) BookmarkCollection {
+	return BookmarkCollection{ID: c.ID, CreatedAt: c.CreatedAt, Name: c.Name}
+}
+
+// encodeBookmarkCursor makes the opaque next_cursor that resumes listing
+// after b.
+func encodeBookmarkCursor(b database.Bookmark) string {
+	raw := b.CreatedAt.UTC().Format(time.RFC3339Nano) + " " + b.ChirpID.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeBookmarkCursor(s string) (store.BookmarkCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return store.BookmarkCursor{}, err
+	}
+	ts, id, ok := strings.Cut(string(raw), " ")
+	if !ok {
+		return store.BookmarkCursor{}, errors.New("cursor has no chirp ID")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return store.BookmarkCursor{}, err
+	}
+	chirpID, err := uuid.Parse(id)
+	if err != nil {
+		return store.BookmarkCursor{}, err
+	}
+	return store.BookmarkCursor{CreatedAt: createdAt, ChirpID: chirpID}, nil
+}
+
+// GetBookmarks lists the caller's bookmarks, newest first. Bookmarks of
+// chirps the caller can no longer see are left out.
+func (cfg *apiConfig) GetBookmarks(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Bookmarks  []Bookmark `json:"bookmarks"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+	}
+
+	query := r.URL.Query()
+
+	limit := defaultBookmarkLimit
+	if s := query.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxBookmarkLimit {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "limit must be between 1 and 100", err)
+			return
+		}
+		limit = n
+	}
+	var collectionID uuid.UUID
+	if s := query.Get("collection_id"); s != "" {
+		id, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "collection_id must be a UUID", err)
+			return
+		}
+		collectionID = id
+	}
+	var after store.BookmarkCursor
+	if s := query.Get("cursor"); s != "" {
+		cursor, err := decodeBookmarkCursor(s)
+		if err != nil {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "Invalid cursor", err)
+			return
+		}
+		after = cursor
+	}
+
+	// One extra row tells us whether there is another page.
+	rows, err := cfg.store.ListBookmarks(r.Context(), principal(r).UserID, collectionID, after, limit+1)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list bookmarks", err)
+		return
+	}
+	resp := response{Bookmarks: make([]Bookmark, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		resp.NextCursor = encodeBookmarkCursor(rows[limit-1].Bookmark)
+	}
+	for _, row := range rows {
+		resp.Bookmarks = append(resp.Bookmarks, bookmarkFrom(row.Bookmark, row.Chirp))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// BookmarkChirp saves the chirp in the path for the caller, optionally in
+// one of their collections. Bookmarking a chirp again moves it to the
+// given collection, or out of any collection.
+func (cfg *apiConfig) BookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		CollectionID *uuid.UUID `json:"collection_id"`
+	}
+
+	userID := principal(r).UserID
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, apierror.ChirpNotFound, "", err)
+		return
+	}
+
+	// The body is optional.
+	params := parameters{}
+	if r.ContentLength != 0 && !decodeJSON(w, r, &params) {
+		return
+	}
+	var collectionID uuid.UUID
+	notFound := apierror.ChirpNotFound
+	if params.CollectionID != nil {
+		collectionID = *params.CollectionID
+		notFound = apierror.CollectionNotFound
+	}
+
+	chirp, err := cfg.store.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.ChirpNotFound), "Couldn't get chirp", err)
+		return
+	}
+	visible, err := cfg.store.CanSeeChirps(r.Context(), userID, chirp.UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't bookmark chirp", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, r, apierror.ChirpNotFound, "", nil)
+		return
+	}
+
+	bookmark, err := cfg.store.BookmarkChirp(r.Context(), userID, chirpID, collectionID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, notFound), "Couldn't bookmark chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, bookmarkFrom(bookmark, chirp))
+}
+
+func (cfg *apiConfig) RemoveBookmark(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, r, apierror.BookmarkNotFound, "", err)
+		return
+	}
+
+	err = cfg.store.RemoveBookmark(r.Context(), principal(r).UserID, chirpID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.BookmarkNotFound), "Couldn't remove bookmark", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) GetBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	collections, err := cfg.store.ListBookmarkCollections(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list collections", err)
+		return
+	}
+
+	resp := make([]BookmarkCollection, 0, len(collections))
+	for _, c := range collections {
+		resp = append(resp, collectionFrom(c))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// CreateBookmarkCollection is for Chirpy Red users only.
+func (cfg *apiConfig) CreateBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name string `json:"name"`
+	}
+
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	params.Name = strings.TrimSpace(params.Name)
+	var v validate.Validator
+	if v.Required("name", params.Name) {
+		v.MaxLength("name", params.Name, maxCollectionNameLength)
+	}
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	user, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't get user", err)
+		return
+	}
+	if !user.IsChirpyRed.Bool {
+		respondWithError(w, r, apierror.CollectionRequiresChirpyRed, "", nil)
+		return
+	}
+
+	collection, err := cfg.store.CreateBookmarkCollection(r.Context(), userID, params.Name)
+	if errors.Is(err, store.ErrConflict) {
+		respondWithError(w, r, apierror.CollectionNameTaken, "", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create collection", err)
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, collectionFrom(collection))
+}
+
+// DeleteBookmarkCollection keeps the collection's bookmarks, unfiled.
+func (cfg *apiConfig) DeleteBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, err := uuid.Parse(r.PathValue("collectionID"))
+	if err != nil {
+		respondWithError(w, r, apierror.CollectionNotFound, "", err)
+		return
+	}
+
+	err = cfg.store.DeleteBookmarkCollection(r.Context(), collectionID, principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.CollectionNotFound), "Couldn't delete collection", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
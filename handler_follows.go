@@ -0,0 +1,179 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/notify"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+// Follow is one side of a follow: the other user and whether the follow is
+// accepted or still a pending request.
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func followsOf(follows []database.Follow, other func(database.Follow) uuid.UUID) []Follow {
+	out := make([]Follow, 0, len(follows))
+	for _, f := range follows {
+		out = append(out, Follow{UserID: other(f), Status: f.Status, CreatedAt: f.CreatedAt})
+	}
+	return out
+}
+
+func followerOf(f database.Follow) uuid.UUID { return f.FollowerID }
+func followeeOf(f database.Follow) uuid.UUID { return f.FolloweeID }
+
+// UpdatePrivacy makes the caller's account private or public. Making it
+// public approves every pending follow request.
+func (cfg *apiConfig) UpdatePrivacy(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IsPrivate *bool `json:"is_private"`
+	}
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	var v validate.Validator
+	v.Check(params.IsPrivate != nil, "is_private", "is required")
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	user, err := cfg.store.SetUserPrivate(r.Context(), principal(r).UserID, *params.IsPrivate)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't update privacy", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, User{
+		ID:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		Is_Chirpy_Red: user.IsChirpyRed.Bool,
+		IsPrivate:     user.IsPrivate,
+	})
+}
+
+func (cfg *apiConfig) GetFollowing(w http.ResponseWriter, r *http.Request) {
+	follows, err := cfg.store.ListFollowing(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list follows", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, followsOf(follows, followeeOf))
+}
+
+// FollowUser follows the user in the path, or asks to if their account is
+// private. Following someone already followed, or already asked, is a no-op.
+func (cfg *apiConfig) FollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := relationTarget(w, r, apierror.UserNotFound)
+	if !ok {
+		return
+	}
+	viewerID := principal(r).UserID
+
+	blocked, err := cfg.store.Blocked(r.Context(), viewerID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't follow user", err)
+		return
+	}
+	if blocked {
+		respondWithError(w, r, apierror.FollowBlocked, "", nil)
+		return
+	}
+
+	follow, err := cfg.store.GetFollow(r.Context(), viewerID, userID)
+	if err == nil {
+		respondWithJSON(w, http.StatusOK, Follow{UserID: userID, Status: follow.Status, CreatedAt: follow.CreatedAt})
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.Internal, "Couldn't follow user", err)
+		return
+	}
+
+	follow, err = cfg.store.Follow(r.Context(), viewerID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't follow user", err)
+		return
+	}
+	cfg.emitNotification(r, notify.UserFollowed{FollowerID: viewerID, FolloweeID: userID})
+
+	respondWithJSON(w, http.StatusOK, Follow{UserID: userID, Status: follow.Status, CreatedAt: follow.CreatedAt})
+}
+
+// UnfollowUser stops following the user in the path, or withdraws a
+// pending request.
+func (cfg *apiConfig) UnfollowUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := relationTarget(w, r, apierror.FollowNotFound)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.Unfollow(r.Context(), principal(r).UserID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.FollowNotFound), "Couldn't unfollow user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) GetFollowers(w http.ResponseWriter, r *http.Request) {
+	follows, err := cfg.store.ListFollowers(r.Context(), principal(r).UserID, store.FollowAccepted)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list followers", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, followsOf(follows, followerOf))
+}
+
+func (cfg *apiConfig) GetFollowRequests(w http.ResponseWriter, r *http.Request) {
+	follows, err := cfg.store.ListFollowers(r.Context(), principal(r).UserID, store.FollowPending)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list follow requests", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, followsOf(follows, followerOf))
+}
+
+func (cfg *apiConfig) ApproveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, apierror.FollowRequestNotFound, "", err)
+		return
+	}
+
+	follow, err := cfg.store.AcceptFollow(r.Context(), userID, principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.FollowRequestNotFound), "Couldn't approve follow request", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, Follow{UserID: userID, Status: follow.Status, CreatedAt: follow.CreatedAt})
+}
+
+// DenyFollowRequest deletes the request. The requester isn't told and can
+// ask again.
+func (cfg *apiConfig) DenyFollowRequest(w http.ResponseWriter, r *http.Request) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, apierror.FollowRequestNotFound, "", err)
+		return
+	}
+
+	err = cfg.store.DenyFollow(r.Context(), userID, principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.FollowRequestNotFound), "Couldn't deny follow request", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
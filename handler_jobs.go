@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+type Job struct {
+	ID          uuid.UUID       `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	Kind        string          `json:"kind"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int32           `json:"attempts"`
+	MaxAttempts int32           `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+func jobFromDB(j database.Job) Job {
+	return Job{
+		ID:          j.ID,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		Kind:        j.Kind,
+		Payload:     j.Payload,
+		Status:      j.Status,
+		Attempts:    j.Attempts,
+		MaxAttempts: j.MaxAttempts,
+		RunAt:       j.RunAt,
+		LastError:   j.LastError.String,
+	}
+}
+
+func (cfg *apiConfig) GetJobs(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", jobs.StatusPending, jobs.StatusRunning, jobs.StatusSucceeded, jobs.StatusDead:
+	default:
+		respondWithError(w, r, apierror.RequestInvalidParameter, "Unknown job status", nil)
+		return
+	}
+
+	limit := 100
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 1000 {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "limit must be between 1 and 1000", err)
+			return
+		}
+		limit = n
+	}
+
+	dbJobs, err := cfg.store.ListJobs(r.Context(), status, limit)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list jobs", err)
+		return
+	}
+
+	out := make([]Job, 0, len(dbJobs))
+	for _, j := range dbJobs {
+		out = append(out, jobFromDB(j))
+	}
+	respondWithJSON(w, http.StatusOK, out)
+}
+
+func (cfg *apiConfig) GetJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(r.PathValue("jobID"))
+	if err != nil {
+		respondWithError(w, r, apierror.JobNotFound, "Job not found", err)
+		return
+	}
+
+	job, err := cfg.store.GetJob(r.Context(), jobID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.JobNotFound, "Job not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't get job", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, jobFromDB(job))
+}
+
+func (cfg *apiConfig) RetryJob(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(r.PathValue("jobID"))
+	if err != nil {
+		respondWithError(w, r, apierror.JobNotFound, "Job not found", err)
+		return
+	}
+
+	job, err := cfg.store.GetJob(r.Context(), jobID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.JobNotFound, "Job not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't get job", err)
+		return
+	}
+	if job.Status != jobs.StatusDead {
+		respondWithError(w, r, apierror.JobNotRetryable, "Only dead jobs can be retried", nil)
+		return
+	}
+
+	// The job can still be retried or deleted between the two calls.
+	job, err = cfg.store.RetryDeadJob(r.Context(), jobID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.JobNotRetryable, "Only dead jobs can be retried", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't retry job", err)
+		return
+	}
+	respondWithJSON(w, http.StatusAccepted, jobFromDB(job))
+}
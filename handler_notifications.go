@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/notify"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 200
+)
+
+// emitNotification hands a domain event to the notification service. Like
+// webhooks, a failure here is logged rather than failing the request.
+func (cfg *apiConfig) emitNotification(r *http.Request, event any) {
+	if cfg.notifications == nil {
+		return
+	}
+	err := cfg.notifications.Emit(r.Context(), event)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error emitting notification", "event", fmt.Sprintf("%T", event), "error", err)
+	}
+}
+
+func (cfg *apiConfig) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		UnreadCount   int64                 `json:"unread_count"`
+		Notifications []notify.Notification `json:"notifications"`
+	}
+
+	userID := principal(r).UserID
+
+	limit := defaultNotificationLimit
+	if s := r.URL.Query().Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "limit must be between 1 and 200", err)
+			return
+		}
+		limit = n
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "true"
+
+	notifications, err := cfg.notifications.List(r.Context(), userID, unreadOnly, limit)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list notifications", err)
+		return
+	}
+
+	unread, err := cfg.notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't count notifications", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{UnreadCount: unread, Notifications: notifications})
+}
+
+func (cfg *apiConfig) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		IDs []uuid.UUID `json:"ids"`
+		All bool        `json:"all"`
+	}
+	type response struct {
+		Marked      int64 `json:"marked"`
+		UnreadCount int64 `json:"unread_count"`
+	}
+
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	var v validate.Validator
+	v.Check(params.All || len(params.IDs) > 0, "ids", "is required unless all is true")
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	ids := params.IDs
+	if params.All {
+		ids = nil
+	}
+	marked, err := cfg.notifications.MarkRead(r.Context(), userID, ids)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't mark notifications read", err)
+		return
+	}
+
+	unread, err := cfg.notifications.UnreadCount(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't count notifications", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{Marked: marked, UnreadCount: unread})
+}
+
+func (cfg *apiConfig) GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	prefs, err := cfg.notifications.Preferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't load notification preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
+
+func (cfg *apiConfig) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	params := map[string]bool{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	var v validate.Validator
+	for name := range params {
+		v.Check(notify.IsKnownType(name), name, "is not a notification type")
+	}
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	for name, enabled := range params {
+		err := cfg.notifications.SetPreference(r.Context(), userID, notify.Type(name), enabled)
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't update notification preferences", err)
+			return
+		}
+	}
+
+	prefs, err := cfg.notifications.Preferences(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't load notification preferences", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, prefs)
+}
@@ -0,0 +1,587 @@
+package main
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+const (
+	maxOAuthClientNameLength = 100
+	maxRedirectURIs          = 10
+
+	oauthCodeLifetime         = 10 * time.Minute
+	oauthAccessTokenLifetime  = time.Hour
+	oauthRefreshTokenLifetime = 60 * 24 * time.Hour
+
+	// oauthConsentPage is served by the /app/ file server.
+	oauthConsentPage = "/app/oauth/consent.html"
+)
+
+type OAuthClient struct {
+	ID           uuid.UUID `json:"id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Public       bool      `json:"public"`
+	Secret       string    `json:"client_secret,omitempty"`
+}
+
+func oauthClientFromDB(c database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		Name:         c.Name,
+		RedirectURIs: strings.Fields(c.RedirectUris),
+		Public:       !c.HashedSecret.Valid,
+	}
+}
+
+// oauthError is an RFC 6749 error. OAuth clients expect this shape from
+// the token endpoints and in redirects, rather than ErrorResponse.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *oauthError) values() url.Values {
+	return url.Values{"error": {e.Code}, "error_description": {e.Description}}
+}
+
+func respondWithOAuthError(w http.ResponseWriter, status int, e oauthError) {
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, status, e)
+}
+
+// validRedirectURI reports whether s may be registered as a redirect URI:
+// an absolute https URL without a fragment, or http on the loopback
+// interface for apps running on the user's machine.
+func validRedirectURI(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		if u.Hostname() == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(u.Hostname())
+		return ip != nil && ip.IsLoopback()
+	default:
+		return false
+	}
+}
+
+func (cfg *apiConfig) CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Public       bool     `json:"public"`
+	}
+
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	var v validate.Validator
+	if v.Required("name", params.Name) {
+		v.MaxLength("name", params.Name, maxOAuthClientNameLength)
+	}
+	v.Check(len(params.RedirectURIs) > 0, "redirect_uris", "must list at least one URI")
+	v.Check(len(params.RedirectURIs) <= maxRedirectURIs, "redirect_uris", fmt.Sprintf("must list at most %d URIs", maxRedirectURIs))
+	for i, uri := range params.RedirectURIs {
+		v.Check(validRedirectURI(uri), fmt.Sprintf("redirect_uris[%d]", i), "must be an https URL, or http on localhost, without a fragment")
+	}
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	// Public clients, such as single-page and mobile apps, can't keep a
+	// secret and rely on PKCE alone.
+	var secret string
+	var hashedSecret sql.NullString
+	if !params.Public {
+		var err error
+		secret, err = auth.MakeClientSecret()
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't create client secret", err)
+			return
+		}
+		hashedSecret = sql.NullString{String: auth.HashClientSecret(secret), Valid: true}
+	}
+
+	client, err := cfg.store.CreateOAuthClient(r.Context(), userID, params.Name, strings.Join(params.RedirectURIs, " "), hashedSecret)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create OAuth client", err)
+		return
+	}
+
+	// Only the hash is stored, so this is the one chance to see the secret.
+	resp := oauthClientFromDB(client)
+	resp.Secret = secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	dbClients, err := cfg.store.ListOAuthClients(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list OAuth clients", err)
+		return
+	}
+
+	clients := make([]OAuthClient, 0, len(dbClients))
+	for _, c := range dbClients {
+		clients = append(clients, oauthClientFromDB(c))
+	}
+	respondWithJSON(w, http.StatusOK, clients)
+}
+
+// GetOAuthClient returns the public details of a client, which the
+// consent page shows the user.
+func (cfg *apiConfig) GetOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		ID   uuid.UUID `json:"id"`
+		Name string    `json:"name"`
+	}
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, r, apierror.OAuthClientNotFound, "", err)
+		return
+	}
+
+	client, err := cfg.store.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.OAuthClientNotFound), "Couldn't get OAuth client", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{ID: client.ID, Name: client.Name})
+}
+
+func (cfg *apiConfig) DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	clientID, err := uuid.Parse(r.PathValue("clientID"))
+	if err != nil {
+		respondWithError(w, r, apierror.OAuthClientNotFound, "", err)
+		return
+	}
+
+	err = cfg.store.DeleteOAuthClient(r.Context(), clientID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.OAuthClientNotFound), "Couldn't delete OAuth client", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeRequest is an authorization request from RFC 6749 section
+// 4.1.1, with the PKCE challenge from RFC 7636.
+type authorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// checkClient writes an error and returns false unless the request names a
+// client and one of its redirect URIs. These errors are never redirected:
+// until both are known good, the redirect URI can't be trusted.
+func (cfg *apiConfig) checkClient(w http.ResponseWriter, r *http.Request, req authorizeRequest) (database.OauthClient, bool) {
+	clientID, err := uuid.Parse(req.ClientID)
+	if err != nil {
+		respondWithError(w, r, apierror.OAuthClientNotFound, "", err)
+		return database.OauthClient{}, false
+	}
+	client, err := cfg.store.GetOAuthClient(r.Context(), clientID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.OAuthClientNotFound), "Couldn't get OAuth client", err)
+		return database.OauthClient{}, false
+	}
+
+	var v validate.Validator
+	v.Check(slices.Contains(strings.Fields(client.RedirectUris), req.RedirectURI), "redirect_uri", "isn't registered for this client")
+	if !checkValid(w, r, &v) {
+		return database.OauthClient{}, false
+	}
+	return client, true
+}
+
+// scopes checks the rest of req and returns the scopes it asks for, or
+// the error to send to the client's redirect URI.
+func (req authorizeRequest) scopes() ([]auth.Scope, *oauthError) {
+	if req.ResponseType != "code" {
+		return nil, &oauthError{"unsupported_response_type", "response_type must be code"}
+	}
+	if req.CodeChallenge == "" || req.CodeChallengeMethod != auth.PKCEMethodS256 {
+		return nil, &oauthError{"invalid_request", "PKCE is required, with code_challenge_method S256"}
+	}
+	scopes := auth.ParseScopes(req.Scope)
+	if len(scopes) == 0 {
+		return nil, &oauthError{"invalid_scope", "scope is required"}
+	}
+	for _, scope := range scopes {
+		if !auth.IsKnownScope(string(scope)) {
+			return nil, &oauthError{"invalid_scope", fmt.Sprintf("%s is not a scope", scope)}
+		}
+	}
+	return scopes, nil
+}
+
+// redirect returns req's redirect URI with params and the client's state
+// added to its query.
+func (req authorizeRequest) redirect(params url.Values) string {
+	u, _ := url.Parse(req.RedirectURI)
+	query := u.Query()
+	for name, values := range params {
+		query[name] = values
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// Authorize starts the authorization-code flow. A valid request is sent on
+// to the consent page, which asks the user and calls ApproveAuthorization.
+func (cfg *apiConfig) Authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	req := authorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	if _, ok := cfg.checkClient(w, r, req); !ok {
+		return
+	}
+	if _, oauthErr := req.scopes(); oauthErr != nil {
+		http.Redirect(w, r, req.redirect(oauthErr.values()), http.StatusFound)
+		return
+	}
+
+	http.Redirect(w, r, oauthConsentPage+"?"+r.URL.RawQuery, http.StatusFound)
+}
+
+// ApproveAuthorization records the user's answer on the consent page. The
+// response says where to send the browser: back to the client with a code
+// or an access_denied error.
+func (cfg *apiConfig) ApproveAuthorization(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		authorizeRequest
+		Approve bool `json:"approve"`
+	}
+	type response struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	req := params.authorizeRequest
+
+	client, ok := cfg.checkClient(w, r, req)
+	if !ok {
+		return
+	}
+	scopes, oauthErr := req.scopes()
+	if oauthErr == nil && !params.Approve {
+		oauthErr = &oauthError{"access_denied", "The user denied the request"}
+	}
+	if oauthErr != nil {
+		respondWithJSON(w, http.StatusOK, response{RedirectTo: req.redirect(oauthErr.values())})
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create authorization code", err)
+		return
+	}
+	err = cfg.store.CreateAuthorizationCode(r.Context(), database.OauthCode{
+		Code:          code,
+		ClientID:      client.ID,
+		UserID:        userID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        auth.FormatScopes(scopes),
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeLifetime),
+	})
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't save authorization code", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, response{RedirectTo: req.redirect(url.Values{"code": {code}})})
+}
+
+// authenticateClient identifies the client calling a token endpoint, by
+// HTTP Basic auth or the client_id and client_secret form fields. Public
+// clients send only client_id. It writes an invalid_client error and
+// returns false if that fails.
+func (cfg *apiConfig) authenticateClient(w http.ResponseWriter, r *http.Request) (database.OauthClient, bool) {
+	id, secret, basic := r.BasicAuth()
+	if !basic {
+		id, secret = r.PostFormValue("client_id"), r.PostFormValue("client_secret")
+	}
+
+	fail := func(description string) (database.OauthClient, bool) {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		}
+		respondWithOAuthError(w, http.StatusUnauthorized, oauthError{"invalid_client", description})
+		return database.OauthClient{}, false
+	}
+
+	clientID, err := uuid.Parse(id)
+	if err != nil {
+		return fail("Unknown client")
+	}
+	client, err := cfg.store.GetOAuthClient(r.Context(), clientID)
+	if errors.Is(err, store.ErrNotFound) {
+		return fail("Unknown client")
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't get OAuth client", err)
+		return database.OauthClient{}, false
+	}
+
+	if !client.HashedSecret.Valid {
+		if secret != "" {
+			return fail("Public clients have no secret")
+		}
+		return client, true
+	}
+	if subtle.ConstantTimeCompare([]byte(auth.HashClientSecret(secret)), []byte(client.HashedSecret.String)) != 1 {
+		return fail("Wrong client secret")
+	}
+	return client, true
+}
+
+// Token is the OAuth token endpoint. It exchanges an authorization code,
+// or a refresh token from an earlier exchange, for a new access token and
+// refresh token.
+func (cfg *apiConfig) Token(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	client, ok := cfg.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+
+	var userID uuid.UUID
+	var scopes []auth.Scope
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		code, err := cfg.store.ConsumeAuthorizationCode(r.Context(), r.PostFormValue("code"))
+		if errors.Is(err, store.ErrNotFound) {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthError{"invalid_grant", "Unknown, used or expired code"})
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't get authorization code", err)
+			return
+		}
+		if code.ClientID != client.ID || code.RedirectUri != r.PostFormValue("redirect_uri") {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthError{"invalid_grant", "Code was issued to another client or redirect URI"})
+			return
+		}
+		if !auth.VerifyPKCE(r.PostFormValue("code_verifier"), code.CodeChallenge) {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthError{"invalid_grant", "code_verifier doesn't match the code_challenge"})
+			return
+		}
+		userID, scopes = code.UserID, auth.ParseScopes(code.Scopes)
+
+	case "refresh_token":
+		token := r.PostFormValue("refresh_token")
+		rt, err := cfg.store.GetClientRefreshToken(r.Context(), token)
+		if errors.Is(err, store.ErrNotFound) || (err == nil && rt.ClientID.UUID != client.ID) {
+			respondWithOAuthError(w, http.StatusBadRequest, oauthError{"invalid_grant", "Unknown, revoked or expired refresh token"})
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't get refresh token", err)
+			return
+		}
+		userID, scopes = rt.UserID, auth.ParseScopes(rt.Scopes)
+
+		// A client may ask for fewer scopes than it was granted, never more.
+		if requested := r.PostFormValue("scope"); requested != "" {
+			narrowed := auth.ParseScopes(requested)
+			for _, scope := range narrowed {
+				if !slices.Contains(scopes, scope) {
+					respondWithOAuthError(w, http.StatusBadRequest, oauthError{"invalid_scope", fmt.Sprintf("%s wasn't granted", scope)})
+					return
+				}
+			}
+			scopes = narrowed
+		}
+
+		// Refresh tokens are rotated, so a leaked one stops working once
+		// the client next refreshes.
+		err = cfg.store.RevokeRefreshToken(r.Context(), token)
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't revoke refresh token", err)
+			return
+		}
+
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, oauthError{"unsupported_grant_type", "grant_type must be authorization_code or refresh_token"})
+		return
+	}
+
+	cfg.issueClientTokens(w, r, userID, client.ID, scopes)
+}
+
+func (cfg *apiConfig) issueClientTokens(w http.ResponseWriter, r *http.Request, userID, clientID uuid.UUID, scopes []auth.Scope) {
+	type response struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	accessToken, err := auth.MakeClientJWT(r.Context(), userID, clientID, scopes, cfg.Secret, oauthAccessTokenLifetime)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create access JWT", err)
+		return
+	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create refreshToken", err)
+		return
+	}
+	err = cfg.store.CreateClientRefreshToken(r.Context(), refreshToken, userID, clientID, auth.FormatScopes(scopes), time.Now().Add(oauthRefreshTokenLifetime))
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't save refreshToken", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, response{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenLifetime.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        auth.FormatScopes(scopes),
+	})
+}
+
+// Introspect tells a client whether one of its tokens is active, as in
+// RFC 7662. Tokens issued to other clients, or from logging in, are
+// reported as inactive.
+func (cfg *apiConfig) Introspect(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	client, ok := cfg.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostFormValue("token")
+
+	resp := response{}
+	if claims, err := auth.ParseAccessToken(r.Context(), token, cfg.Secret); err == nil {
+		if claims.ClientID == client.ID {
+			resp = response{
+				Active:    true,
+				Scope:     auth.FormatScopes(claims.Scopes),
+				ClientID:  client.ID.String(),
+				Subject:   claims.UserID.String(),
+				ExpiresAt: claims.ExpiresAt.Unix(),
+				IssuedAt:  claims.IssuedAt.Unix(),
+				TokenType: "access_token",
+			}
+		}
+	} else if rt, err := cfg.store.GetClientRefreshToken(r.Context(), token); err == nil {
+		if rt.ClientID.UUID == client.ID {
+			resp = response{
+				Active:    true,
+				Scope:     rt.Scopes,
+				ClientID:  client.ID.String(),
+				Subject:   rt.UserID.String(),
+				ExpiresAt: rt.ExpiresAt.Unix(),
+				IssuedAt:  rt.CreatedAt.Unix(),
+				TokenType: "refresh_token",
+			}
+		}
+	} else if !errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.Internal, "Couldn't get refresh token", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// RevokeOAuthToken revokes one of the calling client's refresh tokens, as
+// in RFC 7009. It succeeds for unknown tokens so clients can't probe for
+// valid ones. Access tokens can't be revoked; they expire within
+// oauthAccessTokenLifetime.
+func (cfg *apiConfig) RevokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	client, ok := cfg.authenticateClient(w, r)
+	if !ok {
+		return
+	}
+	token := r.PostFormValue("token")
+
+	rt, err := cfg.store.GetClientRefreshToken(r.Context(), token)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.Internal, "Couldn't get refresh token", err)
+		return
+	}
+	if err == nil && rt.ClientID.UUID == client.ID {
+		err = cfg.store.RevokeRefreshToken(r.Context(), token)
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't revoke refresh token", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/oidc"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+const (
+	// oidcCookie carries the sealed oidc.Attempt from the login redirect
+	// to the callback.
+	oidcCookie     = "chirpy_oidc"
+	oidcCookiePath = "/api/auth/oidc/"
+)
+
+// oidcRedirectURL is the callback registered with provider name.
+func oidcRedirectURL(publicURL, name string) string {
+	return strings.TrimSuffix(publicURL, "/") + oidcCookiePath + name + "/callback"
+}
+
+type OIDCProvider struct {
+	Name     string `json:"name"`
+	LoginURL string `json:"login_url"`
+}
+
+type Identity struct {
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func identityFromDB(i database.Identity) Identity {
+	return Identity{
+		Provider:  i.Provider,
+		Subject:   i.Subject,
+		Email:     i.Email,
+		CreatedAt: i.CreatedAt,
+	}
+}
+
+// oidcProvider looks up the provider named in the path, responding with
+// an error if there isn't one.
+func (cfg *apiConfig) oidcProvider(w http.ResponseWriter, r *http.Request) (*oidc.Provider, bool) {
+	provider, ok := cfg.oidc[r.PathValue("provider")]
+	if !ok {
+		respondWithError(w, r, apierror.OIDCProviderNotFound, "", nil)
+		return nil, false
+	}
+	return provider, true
+}
+
+// beginOIDC starts a sign-in with provider, storing the attempt in a
+// cookie for the callback, and returns where to send the browser.
+func (cfg *apiConfig) beginOIDC(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, linkUserID uuid.UUID) (string, bool) {
+	attempt, err := oidc.NewAttempt(provider.Name, linkUserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't start sign-in", err)
+		return "", false
+	}
+	authURL, err := provider.AuthCodeURL(r.Context(), attempt)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.OIDCProviderNotFound), "Couldn't reach the identity provider", err)
+		return "", false
+	}
+	sealed, err := attempt.Seal(cfg.Secret)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't start sign-in", err)
+		return "", false
+	}
+
+	// Lax lets the cookie ride along on the provider's top-level redirect
+	// back to the callback.
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookie,
+		Value:    sealed,
+		Path:     oidcCookiePath,
+		Expires:  attempt.ExpiresAt,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.PublicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	})
+	return authURL, true
+}
+
+func (cfg *apiConfig) GetOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	providers := make([]OIDCProvider, 0, len(cfg.oidc))
+	for _, name := range slices.Sorted(maps.Keys(cfg.oidc)) {
+		providers = append(providers, OIDCProvider{Name: name, LoginURL: oidcCookiePath + name + "/login"})
+	}
+	respondWithJSON(w, http.StatusOK, providers)
+}
+
+// OIDCLogin sends the browser to the provider to sign in.
+func (cfg *apiConfig) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	authURL, ok := cfg.beginOIDC(w, r, provider, uuid.Nil)
+	if !ok {
+		return
+	}
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// OIDCCallback finishes a sign-in the provider has sent the browser back
+// from. It logs the user in, signing them up on their first visit, or
+// links the provider to the account that started the attempt.
+func (cfg *apiConfig) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	provider, ok := cfg.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+
+	// The attempt is single use whatever happens next.
+	cookie, err := r.Cookie(oidcCookie)
+	http.SetCookie(w, &http.Cookie{Name: oidcCookie, Path: oidcCookiePath, MaxAge: -1, HttpOnly: true})
+	if err != nil {
+		respondWithError(w, r, apierror.AuthOIDCFailed, "No sign-in in progress; start again", err)
+		return
+	}
+	attempt, err := oidc.OpenAttempt(cookie.Value, cfg.Secret)
+	if err != nil {
+		respondWithError(w, r, apierror.AuthOIDCFailed, "Sign-in expired; start again", err)
+		return
+	}
+
+	query := r.URL.Query()
+	if attempt.Provider != provider.Name || !attempt.CheckState(query.Get("state")) {
+		respondWithError(w, r, apierror.AuthOIDCFailed, "Sign-in state doesn't match; start again", nil)
+		return
+	}
+	if e := query.Get("error"); e != "" {
+		respondWithError(w, r, apierror.AuthOIDCFailed, "The identity provider refused: "+e, nil)
+		return
+	}
+
+	claims, err := provider.Exchange(r.Context(), query.Get("code"), attempt)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.AuthOIDCFailed), "Couldn't sign in with the identity provider", err)
+		return
+	}
+
+	if attempt.LinkUserID != uuid.Nil {
+		identity, err := cfg.store.CreateIdentity(r.Context(), attempt.LinkUserID, provider.Name, claims.Subject, claims.Email)
+		if errors.Is(err, store.ErrConflict) {
+			respondWithError(w, r, apierror.IdentityTaken, "", err)
+			return
+		}
+		if err != nil {
+			respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't link identity", err)
+			return
+		}
+		respondWithJSON(w, http.StatusCreated, identityFromDB(identity))
+		return
+	}
+
+	identity, err := cfg.store.GetIdentity(r.Context(), provider.Name, claims.Subject)
+	if err == nil {
+		user, err := cfg.store.GetUserByID(r.Context(), identity.UserID)
+		if err != nil {
+			respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't get user", err)
+			return
+		}
+		cfg.startSession(w, r, user)
+		return
+	}
+	if !errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.Internal, "Couldn't look up identity", err)
+		return
+	}
+
+	cfg.signUpWithOIDC(w, r, provider, claims)
+}
+
+// signUpWithOIDC creates a user for someone signing in with provider for
+// the first time. The user has no password until they set one.
+func (cfg *apiConfig) signUpWithOIDC(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, claims oidc.Claims) {
+	// An unverified email could belong to someone else, and a provider
+	// account is never merged into an existing user by email: the owner
+	// logs in and links it instead.
+	if claims.Email == "" || !claims.EmailVerified {
+		respondWithError(w, r, apierror.AuthOIDCFailed, "The identity provider didn't share a verified email", nil)
+		return
+	}
+
+	user, err := cfg.store.CreateUser(r.Context(), claims.Email, "")
+	if errors.Is(err, store.ErrConflict) {
+		respondWithError(w, r, apierror.UserEmailTaken, "An account already uses this email; log in and link "+provider.Name+" to it", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create user", err)
+		return
+	}
+
+	_, err = cfg.store.CreateIdentity(r.Context(), user.ID, provider.Name, claims.Subject, claims.Email)
+	if err != nil {
+		// Don't leave a user behind that nobody can log in as.
+		if delErr := cfg.store.DeleteUser(r.Context(), user.ID); delErr != nil {
+			err = errors.Join(err, delErr)
+		}
+		respondWithError(w, r, apierror.Internal, "Couldn't link identity", err)
+		return
+	}
+	cfg.startSession(w, r, user)
+}
+
+func (cfg *apiConfig) GetIdentities(w http.ResponseWriter, r *http.Request) {
+	dbIdentities, err := cfg.store.ListIdentities(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list identities", err)
+		return
+	}
+
+	identities := make([]Identity, 0, len(dbIdentities))
+	for _, i := range dbIdentities {
+		identities = append(identities, identityFromDB(i))
+	}
+	respondWithJSON(w, http.StatusOK, identities)
+}
+
+// LinkIdentity starts linking a provider to the caller's account. The
+// caller sends the browser to the returned URL, and the callback links
+// whoever signs in there.
+func (cfg *apiConfig) LinkIdentity(w http.ResponseWriter, r *http.Request) {
+	type response struct {
+		AuthorizationURL string `json:"authorization_url"`
+	}
+
+	provider, ok := cfg.oidcProvider(w, r)
+	if !ok {
+		return
+	}
+	authURL, ok := cfg.beginOIDC(w, r, provider, principal(r).UserID)
+	if !ok {
+		return
+	}
+	respondWithJSON(w, http.StatusOK, response{AuthorizationURL: authURL})
+}
+
+func (cfg *apiConfig) UnlinkIdentity(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+	name := r.PathValue("provider")
+
+	user, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't get user", err)
+		return
+	}
+	identities, err := cfg.store.ListIdentities(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list identities", err)
+		return
+	}
+	// Users who signed up with a provider have no password until they set
+	// one; unlinking their last identity would lock them out.
+	if user.HashedPassword == "" && len(identities) == 1 && identities[0].Provider == name {
+		respondWithError(w, r, apierror.IdentityLastLoginMethod, "Set a password or link another provider first", nil)
+		return
+	}
+
+	err = cfg.store.DeleteIdentity(r.Context(), userID, name)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.IdentityNotFound), "Couldn't unlink identity", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
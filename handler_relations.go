@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// Relation is a user the caller has blocked or muted.
+type Relation struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// hiddenAuthors returns the users whose chirps viewerID doesn't see: private
+// accounts viewerID isn't an approved follower of, anyone on either side of
+// a block, and on timelines, anyone viewerID muted. Anonymous viewers only
+// miss private accounts.
+func (cfg *apiConfig) hiddenAuthors(ctx context.Context, viewerID uuid.UUID, timeline bool) (map[uuid.UUID]bool, error) {
+	private, err := cfg.store.HiddenPrivateUsers(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	hidden := make(map[uuid.UUID]bool, len(private))
+	for _, id := range private {
+		hidden[id] = true
+	}
+	if viewerID == uuid.Nil {
+		return hidden, nil
+	}
+
+	blocked, err := cfg.store.BlockedUsers(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range blocked {
+		hidden[id] = true
+	}
+	if !timeline {
+		return hidden, nil
+	}
+
+	muted, err := cfg.mutedAuthors(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range muted {
+		hidden[id] = true
+	}
+	return hidden, nil
+}
+
+// mutedAuthors lists the users viewerID muted, none for uuid.Nil.
+func (cfg *apiConfig) mutedAuthors(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	if viewerID == uuid.Nil {
+		return nil, nil
+	}
+	mutes, err := cfg.store.ListMutes(ctx, viewerID)
+	if err != nil {
+		return nil, err
+	}
+	muted := make([]uuid.UUID, 0, len(mutes))
+	for _, m := range mutes {
+		muted = append(muted, m.MutedID)
+	}
+	return muted, nil
+}
+
+// streamable reports whether a message on topic may go to viewerID's
+// stream. Streams stay open for hours, so chirp events are checked with
+// CanSeeChirps as they are sent: a block, an unfollow or an account going
+// private applies to streams already open. Events of authors that can't be
+// checked are dropped.
+func (cfg *apiConfig) streamable(ctx context.Context, viewerID uuid.UUID, topic string) bool {
+	authorID, ok := pubsub.ChirpAuthor(topic)
+	if !ok {
+		return true
+	}
+	visible, err := cfg.store.CanSeeChirps(ctx, viewerID, authorID)
+	if err != nil && !errors.Is(err, store.ErrNotFound) && ctx.Err() == nil {
+		slog.ErrorContext(ctx, "Error checking chirp visibility", "viewer_id", viewerID, "author_id", authorID, "error", err)
+	}
+	return visible
+}
+
+// relationTarget parses the user in the path. Users can't block, mute or
+// follow themselves.
+func relationTarget(w http.ResponseWriter, r *http.Request, notFound apierror.Code) (uuid.UUID, bool) {
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, r, notFound, "", err)
+		return uuid.Nil, false
+	}
+	if userID == principal(r).UserID {
+		respondWithError(w, r, apierror.RequestInvalidParameter, "You can't do that to yourself", nil)
+		return uuid.Nil, false
+	}
+	return userID, true
+}
+
+func (cfg *apiConfig) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	blocks, err := cfg.store.ListBlocks(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list blocks", err)
+		return
+	}
+
+	relations := make([]Relation, 0, len(blocks))
+	for _, b := range blocks {
+		relations = append(relations, Relation{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, relations)
+}
+
+// BlockUser blocks the user in the path. Blocking someone already blocked
+// is a no-op.
+func (cfg *apiConfig) BlockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := relationTarget(w, r, apierror.UserNotFound)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.BlockUser(r.Context(), principal(r).UserID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't block user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := relationTarget(w, r, apierror.BlockNotFound)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.UnblockUser(r.Context(), principal(r).UserID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.BlockNotFound), "Couldn't unblock user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) GetMutes(w http.ResponseWriter, r *http.Request) {
+	mutes, err := cfg.store.ListMutes(r.Context(), principal(r).UserID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list mutes", err)
+		return
+	}
+
+	relations := make([]Relation, 0, len(mutes))
+	for _, m := range mutes {
+		relations = append(relations, Relation{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, relations)
+}
+
+// MuteUser hides the user in the path from the caller's timelines. The
+// muted user isn't told. Muting someone already muted is a no-op.
+func (cfg *apiConfig) MuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := relationTarget(w, r, apierror.UserNotFound)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.MuteUser(r.Context(), principal(r).UserID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't mute user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	userID, ok := relationTarget(w, r, apierror.MuteNotFound)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.UnmuteUser(r.Context(), principal(r).UserID, userID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.MuteNotFound), "Couldn't unmute user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
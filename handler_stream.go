@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+)
+
+const (
+	// Event types published on chirp topics.
+	streamChirpCreated = "chirp.created"
+	streamChirpDeleted = "chirp.deleted"
+
+	streamHeartbeat = 15 * time.Second
+	streamBuffer    = 64
+)
+
+// publishChirp fans a chirp event out to streaming clients.
+func (cfg *apiConfig) publishChirp(eventType string, authorID uuid.UUID, data any) {
+	if cfg.broker == nil {
+		return
+	}
+	_, err := cfg.broker.Publish(pubsub.ChirpTopic(authorID), eventType, data)
+	if err != nil {
+		log.Printf("Error publishing %s: %s", eventType, err)
+	}
+}
+
+func (cfg *apiConfig) StreamChirps(w http.ResponseWriter, r *http.Request) {
+	viewerID := principal(r).UserID
+
+	filter := pubsub.AllChirps
+	if s := r.URL.Query().Get("author_id"); s != "" {
+		authorID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "Invalid author_id", err)
+			return
+		}
+		filter = pubsub.Topic(pubsub.ChirpTopic(authorID))
+	}
+
+	// Mutes only apply to the timeline and are read once; changes apply
+	// when the client reconnects. Visibility is checked for every event.
+	if r.URL.Query().Get("author_id") == "" {
+		muted, err := cfg.mutedAuthors(r.Context(), viewerID)
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't open stream", err)
+			return
+		}
+		filter = pubsub.ExceptAuthors(filter, muted...)
+	}
+
+	// Browsers send Last-Event-ID on reconnect; the query param lets clients
+	// resume on their first connection too.
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var resumeFrom uint64
+	if lastID != "" {
+		id, err := strconv.ParseUint(lastID, 10, 64)
+		if err != nil {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "Invalid Last-Event-ID", err)
+			return
+		}
+		resumeFrom = id
+	}
+
+	// The server's read and write timeouts are sized for ordinary requests;
+	// a stream instead bounds each write with StreamWriteTimeout.
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Now().Add(cfg.StreamWriteTimeout))
+
+	sub := cfg.broker.Subscribe(filter, resumeFrom, streamBuffer)
+	defer cfg.broker.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: 3000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-cfg.shutdown:
+			return
+		case <-heartbeat.C:
+			fmt.Fprintf(w, ": heartbeat\n\n")
+		case msg, ok := <-sub.C:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with
+				// Last-Event-ID and picks up from the history window.
+				return
+			}
+			if !cfg.streamable(r.Context(), viewerID, msg.Topic) {
+				continue
+			}
+			fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Type, msg.Data)
+		}
+		rc.SetWriteDeadline(time.Now().Add(cfg.StreamWriteTimeout))
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
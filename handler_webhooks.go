@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/metrics"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+	"github.com/willmelton21/chirpy/internal/webhooks"
+)
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	Disabled  bool      `json:"disabled"`
+}
+
+type WebhookDeliveryAttempt struct {
+	Attempt    int32     `json:"attempt"`
+	CreatedAt  time.Time `json:"created_at"`
+	StatusCode int32     `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int32     `json:"duration_ms"`
+}
+
+type WebhookDelivery struct {
+	ID         uuid.UUID                `json:"id"`
+	EndpointID uuid.UUID                `json:"endpoint_id"`
+	Event      string                   `json:"event"`
+	Status     string                   `json:"status"`
+	Attempts   int32                    `json:"attempts"`
+	LastError  string                   `json:"last_error,omitempty"`
+	Payload    json.RawMessage          `json:"payload"`
+	AttemptLog []WebhookDeliveryAttempt `json:"attempt_log,omitempty"`
+}
+
+func webhookEndpointFromDB(e database.WebhookEndpoint) WebhookEndpoint {
+	return WebhookEndpoint{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+		URL:       e.Url,
+		Events:    e.Events,
+		Disabled:  e.DisabledAt.Valid,
+	}
+}
+
+func webhookDeliveryFromDB(d database.WebhookDelivery) WebhookDelivery {
+	return WebhookDelivery{
+		ID:         d.ID,
+		EndpointID: d.EndpointID,
+		Event:      d.Event,
+		Status:     d.Status,
+		Attempts:   d.Attempts,
+		LastError:  d.LastError.String,
+		Payload:    d.Payload,
+	}
+}
+
+// emitUserWebhook queues eventType, an event about userID's account, for
+// delivery to userID's own endpoints. Failing to queue shouldn't fail the
+// request that caused the event, so errors are only logged.
+func (cfg *apiConfig) emitUserWebhook(r *http.Request, eventType string, userID uuid.UUID, data any) {
+	if cfg.webhooks == nil {
+		return
+	}
+	cfg.metrics.WebhookEvents.WithLabelValues(metrics.WebhookOutbound, eventType).Inc()
+	err := cfg.webhooks.EnqueueUser(r.Context(), eventType, userID, data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error queueing webhook", "event", eventType, "error", err)
+	}
+}
+
+// emitChirpWebhook queues eventType, an event about a chirp by authorID,
+// for delivery to the endpoints whose owner can see the chirp.
+func (cfg *apiConfig) emitChirpWebhook(r *http.Request, eventType string, authorID uuid.UUID, data any) {
+	if cfg.webhooks == nil {
+		return
+	}
+	cfg.metrics.WebhookEvents.WithLabelValues(metrics.WebhookOutbound, eventType).Inc()
+	err := cfg.webhooks.EnqueueChirp(r.Context(), eventType, authorID, data)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error queueing webhook", "event", eventType, "error", err)
+	}
+}
+
+func (cfg *apiConfig) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	type parameters struct {
+		URL    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+
+	var v validate.Validator
+	target, err := url.Parse(params.URL)
+	validURL := err == nil && (target.Scheme == "http" || target.Scheme == "https") && target.Host != ""
+	v.Check(validURL, "url", "must be an absolute http(s) URL")
+	if validURL {
+		err = cfg.webhooks.CheckURL(r.Context(), target.String())
+		switch {
+		case errors.Is(err, webhooks.ErrPrivateAddress):
+			v.Add("url", "must not point to a private or internal address")
+		case err != nil:
+			v.Add("url", "host doesn't resolve")
+		}
+	}
+	v.Check(len(params.Events) > 0, "events", "must list at least one event")
+	for i, event := range params.Events {
+		v.Check(webhooks.IsKnownEvent(event), fmt.Sprintf("events[%d]", i), "is not a webhook event")
+	}
+	if !checkValid(w, r, &v) {
+		return
+	}
+
+	secret, err := webhooks.NewSecret()
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create webhook secret", err)
+		return
+	}
+
+	endpoint, err := cfg.store.CreateWebhookEndpoint(r.Context(), userID, target.String(), secret, params.Events)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't create webhook", err)
+		return
+	}
+
+	// The secret is only ever shown once, at creation.
+	resp := webhookEndpointFromDB(endpoint)
+	resp.Secret = endpoint.Secret
+	respondWithJSON(w, http.StatusCreated, resp)
+}
+
+func (cfg *apiConfig) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	dbEndpoints, err := cfg.store.ListWebhookEndpoints(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list webhooks", err)
+		return
+	}
+
+	endpoints := make([]WebhookEndpoint, 0, len(dbEndpoints))
+	for _, e := range dbEndpoints {
+		endpoints = append(endpoints, webhookEndpointFromDB(e))
+	}
+	respondWithJSON(w, http.StatusOK, endpoints)
+}
+
+func (cfg *apiConfig) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	userID := principal(r).UserID
+
+	webhookID, err := uuid.Parse(r.PathValue("webhookID"))
+	if err != nil {
+		respondWithError(w, r, apierror.WebhookNotFound, "Webhook not found", err)
+		return
+	}
+
+	err = cfg.store.DeleteWebhookEndpoint(r.Context(), webhookID, userID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.WebhookNotFound, "Webhook not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't delete webhook", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) GetWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, r, apierror.WebhookDeliveryNotFound, "Delivery not found", err)
+		return
+	}
+
+	delivery, err := cfg.store.GetWebhookDelivery(r.Context(), deliveryID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.WebhookDeliveryNotFound, "Delivery not found", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't get delivery", err)
+		return
+	}
+
+	attempts, err := cfg.store.ListWebhookDeliveryAttempts(r.Context(), deliveryID)
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't list delivery attempts", err)
+		return
+	}
+
+	resp := webhookDeliveryFromDB(delivery)
+	for _, a := range attempts {
+		resp.AttemptLog = append(resp.AttemptLog, WebhookDeliveryAttempt{
+			Attempt:    a.Attempt,
+			CreatedAt:  a.CreatedAt,
+			StatusCode: a.StatusCode.Int32,
+			Error:      a.Error.String,
+			DurationMs: a.DurationMs,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+
+	deliveryID, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, r, apierror.WebhookDeliveryNotFound, "Delivery not found", err)
+		return
+	}
+
+	delivery, err := cfg.webhooks.Replay(r.Context(), deliveryID)
+	if errors.Is(err, store.ErrNotFound) {
+		respondWithError(w, r, apierror.WebhookDeliveryNotFound, "Delivery not found", err)
+		return
+	}
+	if errors.Is(err, webhooks.ErrNotReplayable) {
+		respondWithError(w, r, apierror.WebhookDeliveryNotReplayable, "Only failed deliveries can be replayed", err)
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't replay delivery", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, webhookDeliveryFromDB(delivery))
+}
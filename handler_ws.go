@@ -0,0 +1,328 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+	"github.com/willmelton21/chirpy/internal/wsapi"
+)
+
+const (
+	// The server pings every 90% of the pong wait, so a client has the
+	// remaining tenth to answer before its read deadline passes.
+	wsPongWait   = 60 * time.Second
+	wsMaxMessage = 4096
+	wsOutBuffer  = 256
+)
+
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:   1024,
+	WriteBufferSize:  1024,
+	HandshakeTimeout: 10 * time.Second,
+}
+
+// wsClient is one authenticated /api/ws connection. The read loop runs on
+// the handler goroutine; every write goes through out so only writeLoop
+// touches the connection's writer.
+type wsClient struct {
+	cfg    *apiConfig
+	conn   *websocket.Conn
+	userID uuid.UUID
+	// delegated is set while the connection is authenticated by a
+	// personal API key or an OAuth client's token rather than the user's
+	// own access token.
+	delegated bool
+	out       chan wsapi.Envelope
+	// ctx is the upgrade request's, so spans started for messages on the
+	// connection join its trace.
+	ctx context.Context
+
+	mu   sync.Mutex
+	subs map[string]*pubsub.Subscription
+	// expires fires when the access token does. It is nil while the
+	// connection is authenticated by an API key, which doesn't expire.
+	expires *time.Timer
+
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (cfg *apiConfig) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	caller := principal(r)
+	userID, expiresAt := caller.UserID, caller.ExpiresAt
+
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an HTTP error.
+		return
+	}
+
+	c := &wsClient{
+		cfg:       cfg,
+		conn:      conn,
+		userID:    userID,
+		delegated: caller.Delegated(),
+		out:       make(chan wsapi.Envelope, wsOutBuffer),
+		ctx:       r.Context(),
+		subs:      make(map[string]*pubsub.Subscription),
+		done:      make(chan struct{}),
+	}
+	if caller.Scheme != auth.SchemeAPIKey {
+		c.expires = time.AfterFunc(time.Until(expiresAt), c.expire)
+	}
+	defer c.close()
+
+	go c.writeLoop()
+
+	welcome, _ := json.Marshal(wsapi.Welcome{UserID: userID, ExpiresAt: expiresAt})
+	c.send(wsapi.Envelope{Type: wsapi.TypeWelcome, Data: welcome})
+
+	c.readLoop()
+}
+
+func (c *wsClient) pongWait() time.Duration {
+	if c.cfg.wsPongWait > 0 {
+		return c.cfg.wsPongWait
+	}
+	return wsPongWait
+}
+
+func (c *wsClient) readLoop() {
+	c.conn.SetReadLimit(wsMaxMessage)
+	c.conn.SetReadDeadline(time.Now().Add(c.pongWait()))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(c.pongWait()))
+	})
+
+	for {
+		var msg wsapi.Envelope
+		err := c.conn.ReadJSON(&msg)
+		if err != nil {
+			if _, ok := err.(*json.SyntaxError); ok {
+				c.sendError("", wsapi.CodeBadRequest, "Message must be a JSON envelope")
+				continue
+			}
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(c.pongWait()))
+
+		if msg.V != wsapi.Version {
+			c.sendError(msg.ID, wsapi.CodeBadVersion, "Unsupported protocol version")
+			continue
+		}
+
+		switch msg.Type {
+		case wsapi.TypeSubscribe:
+			c.subscribe(msg)
+		case wsapi.TypeUnsubscribe:
+			c.unsubscribe(msg)
+		case wsapi.TypeAuth:
+			c.reauth(msg)
+		case wsapi.TypePing:
+			c.send(wsapi.Envelope{Type: wsapi.TypePong, ID: msg.ID})
+		default:
+			c.sendError(msg.ID, wsapi.CodeBadRequest, "Unknown message type")
+		}
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(c.pongWait() * 9 / 10)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-c.cfg.shutdown:
+			c.closeWith(websocket.CloseGoingAway, "server shutting down")
+			return
+		case msg := <-c.out:
+			msg.V = wsapi.Version
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.StreamWriteTimeout))
+			if err := c.conn.WriteJSON(msg); err != nil {
+				c.close()
+				return
+			}
+			if msg.Code == wsapi.CodeTokenExpired {
+				c.closeWith(websocket.ClosePolicyViolation, "token expired")
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(c.cfg.StreamWriteTimeout))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.close()
+				return
+			}
+		}
+	}
+}
+
+// send queues msg for the writer. A client that lets its queue fill up is
+// disconnected rather than allowed to stall the publishers feeding it.
+func (c *wsClient) send(msg wsapi.Envelope) {
+	select {
+	case c.out <- msg:
+	case <-c.done:
+	default:
+		log.Printf("Dropping websocket client %s: outbound queue full", c.userID)
+		c.close()
+	}
+}
+
+func (c *wsClient) sendError(id, code, text string) {
+	c.send(wsapi.Envelope{Type: wsapi.TypeError, ID: id, Code: code, Error: text})
+}
+
+func (c *wsClient) subscribe(msg wsapi.Envelope) {
+	channel, err := wsapi.ParseChannel(msg.Channel)
+	if err != nil {
+		c.sendError(msg.ID, wsapi.CodeBadChannel, err.Error())
+		return
+	}
+
+	var filter pubsub.Filter
+	switch {
+	case channel.Name == wsapi.ChannelChirps:
+		filter = pubsub.AllChirps
+	case channel.Name == wsapi.ChannelNotifications:
+		if c.delegated {
+			c.sendError(msg.ID, wsapi.CodeUnauthorized, "Notifications need an access token")
+			return
+		}
+		filter = pubsub.Topic(pubsub.NotificationTopic(c.userID))
+	default:
+		filter = pubsub.Topic(pubsub.ChirpTopic(channel.Author))
+	}
+	if channel.Name == wsapi.ChannelChirps {
+		// Mutes only apply to the timeline and are read once; changes
+		// apply when the client resubscribes. forward checks visibility
+		// for every event.
+		muted, err := c.cfg.mutedAuthors(c.ctx, c.userID)
+		if err != nil {
+			log.Printf("Error listing muted authors for %s: %s", c.userID, err)
+			c.sendError(msg.ID, wsapi.CodeInternal, "Couldn't subscribe")
+			return
+		}
+		filter = pubsub.ExceptAuthors(filter, muted...)
+	}
+
+	c.mu.Lock()
+	if _, ok := c.subs[channel.Name]; ok {
+		c.mu.Unlock()
+		c.send(wsapi.Envelope{Type: wsapi.TypeAck, ID: msg.ID, Channel: channel.Name})
+		return
+	}
+	sub := c.cfg.broker.Subscribe(filter, msg.LastEventID, streamBuffer)
+	c.subs[channel.Name] = sub
+	c.mu.Unlock()
+
+	c.send(wsapi.Envelope{Type: wsapi.TypeAck, ID: msg.ID, Channel: channel.Name})
+	go c.forward(channel.Name, sub)
+}
+
+// forward relays one subscription's messages until it is closed.
+func (c *wsClient) forward(channel string, sub *pubsub.Subscription) {
+	for msg := range sub.C {
+		if !c.cfg.streamable(c.ctx, c.userID, msg.Topic) {
+			continue
+		}
+		c.send(wsapi.Envelope{
+			Type:    wsapi.TypeEvent,
+			Channel: channel,
+			EventID: msg.ID,
+			Event:   msg.Type,
+			Data:    msg.Data,
+		})
+	}
+	if sub.Dropped() {
+		c.mu.Lock()
+		if c.subs[channel] == sub {
+			delete(c.subs, channel)
+		}
+		c.mu.Unlock()
+		c.send(wsapi.Envelope{
+			Type:    wsapi.TypeError,
+			Channel: channel,
+			Code:    wsapi.CodeDropped,
+			Error:   "Fell behind; resubscribe with last_event_id",
+		})
+	}
+}
+
+func (c *wsClient) unsubscribe(msg wsapi.Envelope) {
+	c.mu.Lock()
+	sub, ok := c.subs[msg.Channel]
+	delete(c.subs, msg.Channel)
+	c.mu.Unlock()
+
+	if ok {
+		c.cfg.broker.Unsubscribe(sub)
+	}
+	c.send(wsapi.Envelope{Type: wsapi.TypeAck, ID: msg.ID, Channel: msg.Channel})
+}
+
+// reauth swaps in a fresh access token so the connection can outlive the
+// one it was opened with. A token issued to an OAuth client needs the
+// chirps:read scope, as it would to open the connection.
+func (c *wsClient) reauth(msg wsapi.Envelope) {
+	claims, err := auth.ParseAccessToken(c.ctx, msg.Token, c.cfg.Secret)
+	if err != nil || claims.UserID != c.userID {
+		c.sendError(msg.ID, wsapi.CodeUnauthorized, "Couldn't validate JWT")
+		return
+	}
+	p := auth.Principal{Scheme: auth.SchemeAccessToken, ClientID: claims.ClientID, Scopes: claims.Scopes}
+	if !p.Allows(auth.ScopeChirpsRead) {
+		c.sendError(msg.ID, wsapi.CodeUnauthorized, "Token doesn't have the chirps:read scope")
+		return
+	}
+	expiresAt := claims.ExpiresAt
+
+	c.mu.Lock()
+	if c.expires == nil {
+		c.expires = time.AfterFunc(time.Until(expiresAt), c.expire)
+	} else {
+		c.expires.Reset(time.Until(expiresAt))
+	}
+	c.delegated = p.Delegated()
+	c.mu.Unlock()
+
+	c.send(wsapi.Envelope{Type: wsapi.TypeAck, ID: msg.ID})
+}
+
+func (c *wsClient) expire() {
+	// writeLoop closes the connection once this has been written.
+	c.sendError("", wsapi.CodeTokenExpired, "Access token expired")
+}
+
+func (c *wsClient) close() {
+	c.closeWith(websocket.CloseNormalClosure, "")
+}
+
+func (c *wsClient) closeWith(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		c.mu.Lock()
+		if c.expires != nil {
+			c.expires.Stop()
+		}
+		for name, sub := range c.subs {
+			c.cfg.broker.Unsubscribe(sub)
+			delete(c.subs, name)
+		}
+		c.mu.Unlock()
+
+		// WriteControl is safe to call alongside writeLoop.
+		c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(c.cfg.StreamWriteTimeout))
+		c.conn.Close()
+	})
+}
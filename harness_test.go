@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/health"
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/logging"
+	"github.com/willmelton21/chirpy/internal/metrics"
+	"github.com/willmelton21/chirpy/internal/notify"
+	"github.com/willmelton21/chirpy/internal/oidc"
+	"github.com/willmelton21/chirpy/internal/oidc/oidctest"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/store/storetest"
+	"github.com/willmelton21/chirpy/internal/webhooks"
+)
+
+const (
+	testSecret   = "test-secret-test-secret-test-secret"
+	testPolkaKey = "test-polka-key"
+	testAdminKey = "test-admin-key"
+	testPassword = "correct horse battery staple"
+)
+
+// testServer is the full API, with the same routes and middleware as
+// serve, on an httptest.Server.
+type testServer struct {
+	*httptest.Server
+	cfg *apiConfig
+	// drain closes cfg.shutdown, as the server does when it starts
+	// shutting down. It is safe to call more than once.
+	drain func()
+}
+
+// newTestServer starts the API on st. configure can change the config
+// before the server starts; changing it afterwards races with handlers.
+// PublicURL is already set to the server's URL when configure runs. The
+// job queue is wired up but its workers don't run, so jobs only pile up.
+func newTestServer(t *testing.T, st store.Store, configure ...func(cfg *apiConfig)) *testServer {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(nil)
+	cfg := &apiConfig{
+		store:              st,
+		broker:             pubsub.NewBroker(64),
+		metrics:            metrics.New(nil),
+		Platform:           "dev",
+		Secret:             testSecret,
+		PolkaKey:           testPolkaKey,
+		AdminKey:           testAdminKey,
+		PublicURL:          "http://" + srv.Listener.Addr().String(),
+		StreamWriteTimeout: time.Second,
+		shutdown:           make(chan struct{}),
+	}
+	cfg.jobs = jobs.New(st)
+	cfg.webhooks = webhooks.NewDispatcher(st, cfg.jobs)
+	cfg.webhooks.AllowPrivateNetworks = true
+	cfg.notifications = notify.NewService(st, cfg.broker)
+	for _, fn := range configure {
+		fn(cfg)
+	}
+
+	logger, err := logging.New(io.Discard, logging.FormatJSON, slog.LevelError)
+	require.NoError(t, err)
+	srv.Config.Handler = cfg.handler(logger, cfg.routes(health.NewChecker()))
+	srv.Start()
+	drain := sync.OnceFunc(func() { close(cfg.shutdown) })
+	t.Cleanup(func() {
+		drain()
+		srv.Close()
+	})
+	return &testServer{Server: srv, cfg: cfg, drain: drain}
+}
+
+// forEachBackend runs test against a fresh server on every backend in
+// storetest.Backends, configured as by newTestServer.
+func forEachBackend(t *testing.T, test func(t *testing.T, srv *testServer), configure ...func(cfg *apiConfig)) {
+	for _, backend := range storetest.Backends() {
+		t.Run(backend.Name, func(t *testing.T) {
+			test(t, newTestServer(t, backend.New(t), configure...))
+		})
+	}
+}
+
+// call is one API request. A string body is sent as is; anything else is
+// encoded as JSON.
+type call struct {
+	method string
+	path   string
+	body   any
+	auth   string
+}
+
+func bearer(token string) string { return "Bearer " + token }
+func apiKey(key string) string   { return "ApiKey " + key }
+
+// do sends c and fails the test unless the response has status want. The
+// body is decoded into out unless out is nil, and returned either way.
+func (s *testServer) do(t *testing.T, c call, want int, out any) []byte {
+	t.Helper()
+
+	var body io.Reader
+	switch b := c.body.(type) {
+	case nil:
+	case string:
+		body = bytes.NewBufferString(b)
+	default:
+		encoded, err := json.Marshal(b)
+		require.NoError(t, err)
+		body = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequest(c.method, s.URL+c.path, body)
+	require.NoError(t, err)
+	if c.auth != "" {
+		req.Header.Set("Authorization", c.auth)
+	}
+	resp, err := s.Client().Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, want, resp.StatusCode, "%s %s: %s", c.method, c.path, respBody)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(respBody, out), "%s %s: %s", c.method, c.path, respBody)
+	}
+	return respBody
+}
+
+// session is a logged-in user.
+type session struct {
+	User
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (s *testServer) signup(t *testing.T, email, password string) User {
+	t.Helper()
+	var user User
+	s.do(t, call{method: "POST", path: "/api/users", body: map[string]string{"email": email, "password": password}}, http.StatusCreated, &user)
+	return user
+}
+
+func (s *testServer) login(t *testing.T, email, password string) session {
+	t.Helper()
+	var sess session
+	s.do(t, call{method: "POST", path: "/api/login", body: map[string]string{"email": email, "password": password}}, http.StatusOK, &sess)
+	return sess
+}
+
+// newUser signs up email with testPassword and logs in.
+func (s *testServer) newUser(t *testing.T, email string) session {
+	t.Helper()
+	s.signup(t, email, testPassword)
+	return s.login(t, email, testPassword)
+}
+
+func (s *testServer) postChirp(t *testing.T, token, body string) Chirp {
+	t.Helper()
+	var chirp Chirp
+	s.do(t, call{method: "POST", path: "/api/chirps", body: map[string]string{"body": body}, auth: bearer(token)}, http.StatusCreated, &chirp)
+	return chirp
+}
+
+// createAPIKey gives the logged-in user a personal API key with scopes.
+func (s *testServer) createAPIKey(t *testing.T, token string, scopes ...string) APIKey {
+	t.Helper()
+	var key APIKey
+	s.do(t, call{method: "POST", path: "/api/users/me/api_keys", body: map[string]any{"name": "bot", "scopes": scopes}, auth: bearer(token)}, http.StatusCreated, &key)
+	return key
+}
+
+// testRedirectURI is the redirect URI of OAuth clients from
+// createOAuthClient.
+const testRedirectURI = "https://app.example.com/callback"
+
+// createOAuthClient registers an OAuth client for the logged-in user.
+func (s *testServer) createOAuthClient(t *testing.T, token string, public bool) OAuthClient {
+	t.Helper()
+	var client OAuthClient
+	body := map[string]any{"name": "Example App", "redirect_uris": []string{testRedirectURI}, "public": public}
+	s.do(t, call{method: "POST", path: "/api/oauth/clients", body: body, auth: bearer(token)}, http.StatusCreated, &client)
+	return client
+}
+
+// postForm is do for the OAuth endpoints, which take form-encoded bodies.
+func (s *testServer) postForm(t *testing.T, path string, form url.Values, want int, out any) []byte {
+	t.Helper()
+	resp, err := s.Client().PostForm(s.URL+path, form)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, want, resp.StatusCode, "POST %s: %s", path, respBody)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(respBody, out), "POST %s: %s", path, respBody)
+	}
+	return respBody
+}
+
+// withOIDCProvider registers provider with the API as name.
+func withOIDCProvider(name string, provider *oidctest.Server) func(cfg *apiConfig) {
+	return func(cfg *apiConfig) {
+		if cfg.oidc == nil {
+			cfg.oidc = make(map[string]*oidc.Provider)
+		}
+		cfg.oidc[name] = oidc.New(name, oidc.Config{
+			Issuer:       provider.Issuer(),
+			ClientID:     provider.ClientID,
+			ClientSecret: provider.ClientSecret,
+			RedirectURL:  oidcRedirectURL(cfg.PublicURL, name),
+		})
+	}
+}
+
+// browser is a client that keeps cookies and doesn't follow redirects, so
+// tests can step through sign-in flows.
+func (s *testServer) browser(t *testing.T) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+	return &http.Client{
+		Jar:           jar,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+}
+
+// redirect gets rawURL with browser and returns where it redirects to.
+func redirect(t *testing.T, browser *http.Client, rawURL string) string {
+	t.Helper()
+	resp, err := browser.Get(rawURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode, "GET %s", rawURL)
+	return resp.Header.Get("Location")
+}
+
+// finishOIDC sends browser to the provider at authURL, follows its
+// redirect back to the callback and fails the test unless the callback
+// responds with status want. The body is decoded into out unless out is
+// nil.
+func finishOIDC(t *testing.T, browser *http.Client, authURL string, want int, out any) {
+	t.Helper()
+	callback := redirect(t, browser, authURL)
+	resp, err := browser.Get(callback)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	require.Equal(t, want, resp.StatusCode, "GET %s: %s", callback, body)
+	if out != nil {
+		require.NoError(t, json.Unmarshal(body, out), "GET %s: %s", callback, body)
+	}
+}
+
+// oidcLogin signs in with provider name from a fresh browser.
+func (s *testServer) oidcLogin(t *testing.T, name string, want int, out any) {
+	t.Helper()
+	browser := s.browser(t)
+	finishOIDC(t, browser, redirect(t, browser, s.URL+"/api/auth/oidc/"+name+"/login"), want, out)
+}
+
+func (s *testServer) listChirps(t *testing.T, query string) []Chirp {
+	t.Helper()
+	return s.listChirpsAs(t, "", query)
+}
+
+// listChirpsAs lists chirps as seen by the user with token.
+func (s *testServer) listChirpsAs(t *testing.T, token, query string) []Chirp {
+	t.Helper()
+	c := call{method: "GET", path: "/api/chirps" + query}
+	if token != "" {
+		c.auth = bearer(token)
+	}
+	var chirps []Chirp
+	s.do(t, c, http.StatusOK, &chirps)
+	return chirps
+}
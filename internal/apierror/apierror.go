@@ -0,0 +1,190 @@
+// Package apierror is the catalog of error codes the API returns. Each
+// code has one HTTP status and title, so the same failure looks the same
+// from every endpoint.
+//
+// Codes are part of the API: clients match on them. Never change or reuse
+// one; add a new code instead.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/oidc"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+// Code identifies a kind of failure, as "<area>.<reason>".
+type Code string
+
+const (
+	Internal Code = "internal"
+
+	RequestMalformed        Code = "request.malformed"
+	RequestTooLarge         Code = "request.too_large"
+	RequestInvalidParameter Code = "request.invalid_parameter"
+	ValidationFailed        Code = "validation.failed"
+
+	AuthMissing             Code = "auth.missing"
+	AuthMalformed           Code = "auth.malformed"
+	AuthTokenInvalid        Code = "auth.token_invalid"
+	AuthTokenExpired        Code = "auth.token_expired"
+	AuthRefreshTokenInvalid Code = "auth.refresh_token_invalid"
+	AuthInvalidCredentials  Code = "auth.invalid_credentials"
+	AuthAPIKeyInvalid       Code = "auth.api_key_invalid"
+	AuthForbidden           Code = "auth.forbidden"
+	AuthInsufficientScope   Code = "auth.insufficient_scope"
+	AuthOIDCFailed          Code = "auth.oidc_failed"
+
+	UserNotFound   Code = "user.not_found"
+	UserEmailTaken Code = "user.email_taken"
+
+	ChirpNotFound  Code = "chirp.not_found"
+	ChirpForbidden Code = "chirp.forbidden"
+
+	WebhookNotFound              Code = "webhook.not_found"
+	WebhookDeliveryNotFound      Code = "webhook.delivery_not_found"
+	WebhookDeliveryNotReplayable Code = "webhook.delivery_not_replayable"
+
+	APIKeyNotFound Code = "api_key.not_found"
+
+	OAuthClientNotFound Code = "oauth_client.not_found"
+
+	OIDCProviderNotFound    Code = "oidc.provider_not_found"
+	OIDCProviderUnavailable Code = "oidc.provider_unavailable"
+
+	IdentityNotFound        Code = "identity.not_found"
+	IdentityTaken           Code = "identity.taken"
+	IdentityLastLoginMethod Code = "identity.last_login_method"
+
+	BlockNotFound Code = "block.not_found"
+	MuteNotFound  Code = "mute.not_found"
+
+	FollowNotFound        Code = "follow.not_found"
+	FollowBlocked         Code = "follow.blocked"
+	FollowRequestNotFound Code = "follow_request.not_found"
+
+	BookmarkNotFound            Code = "bookmark.not_found"
+	CollectionNotFound          Code = "collection.not_found"
+	CollectionNameTaken         Code = "collection.name_taken"
+	CollectionRequiresChirpyRed Code = "collection.requires_chirpy_red"
+
+	JobNotFound     Code = "job.not_found"
+	JobNotRetryable Code = "job.not_retryable"
+)
+
+type entry struct {
+	status int
+	title  string
+}
+
+var catalog = map[Code]entry{
+	Internal: {http.StatusInternalServerError, "Internal server error"},
+
+	RequestMalformed:        {http.StatusBadRequest, "Malformed request"},
+	RequestTooLarge:         {http.StatusRequestEntityTooLarge, "Request body too large"},
+	RequestInvalidParameter: {http.StatusBadRequest, "Invalid query parameter"},
+	ValidationFailed:        {http.StatusUnprocessableEntity, "Request has invalid fields"},
+
+	AuthMissing:             {http.StatusUnauthorized, "Authentication required"},
+	AuthMalformed:           {http.StatusUnauthorized, "Malformed Authorization header"},
+	AuthTokenInvalid:        {http.StatusUnauthorized, "Invalid access token"},
+	AuthTokenExpired:        {http.StatusUnauthorized, "Access token expired"},
+	AuthRefreshTokenInvalid: {http.StatusUnauthorized, "Invalid refresh token"},
+	AuthInvalidCredentials:  {http.StatusUnauthorized, "Incorrect email or password"},
+	AuthAPIKeyInvalid:       {http.StatusUnauthorized, "Invalid API key"},
+	AuthForbidden:           {http.StatusForbidden, "Forbidden"},
+	AuthInsufficientScope:   {http.StatusForbidden, "Credential lacks the required scope"},
+	AuthOIDCFailed:          {http.StatusUnauthorized, "Sign-in with the identity provider failed"},
+
+	UserNotFound:   {http.StatusNotFound, "User not found"},
+	UserEmailTaken: {http.StatusConflict, "Email already in use"},
+
+	ChirpNotFound:  {http.StatusNotFound, "Chirp not found"},
+	ChirpForbidden: {http.StatusForbidden, "Not your chirp"},
+
+	WebhookNotFound:              {http.StatusNotFound, "Webhook not found"},
+	WebhookDeliveryNotFound:      {http.StatusNotFound, "Webhook delivery not found"},
+	WebhookDeliveryNotReplayable: {http.StatusConflict, "Webhook delivery can't be replayed"},
+
+	APIKeyNotFound: {http.StatusNotFound, "API key not found"},
+
+	OAuthClientNotFound: {http.StatusNotFound, "OAuth client not found"},
+
+	OIDCProviderNotFound:    {http.StatusNotFound, "Identity provider not found"},
+	OIDCProviderUnavailable: {http.StatusBadGateway, "Identity provider unavailable"},
+
+	IdentityNotFound:        {http.StatusNotFound, "Linked identity not found"},
+	IdentityTaken:           {http.StatusConflict, "Identity is linked to another user"},
+	IdentityLastLoginMethod: {http.StatusConflict, "Can't remove the only way to log in"},
+
+	BlockNotFound: {http.StatusNotFound, "You haven't blocked this user"},
+	MuteNotFound:  {http.StatusNotFound, "You haven't muted this user"},
+
+	FollowNotFound:        {http.StatusNotFound, "You don't follow this user"},
+	FollowBlocked:         {http.StatusForbidden, "You can't follow this user"},
+	FollowRequestNotFound: {http.StatusNotFound, "Follow request not found"},
+
+	BookmarkNotFound:            {http.StatusNotFound, "You haven't bookmarked this chirp"},
+	CollectionNotFound:          {http.StatusNotFound, "Collection not found"},
+	CollectionNameTaken:         {http.StatusConflict, "You already have a collection with that name"},
+	CollectionRequiresChirpyRed: {http.StatusForbidden, "Collections are a Chirpy Red feature"},
+
+	JobNotFound:     {http.StatusNotFound, "Job not found"},
+	JobNotRetryable: {http.StatusConflict, "Job can't be retried"},
+}
+
+// Codes lists every code in the catalog.
+func Codes() []Code {
+	codes := make([]Code, 0, len(catalog))
+	for code := range catalog {
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// Status is the HTTP status for c. Codes missing from the catalog are
+// treated as Internal.
+func (c Code) Status() int {
+	if e, ok := catalog[c]; ok {
+		return e.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Title is a short, fixed description of c.
+func (c Code) Title() string {
+	if e, ok := catalog[c]; ok {
+		return e.title
+	}
+	return catalog[Internal].title
+}
+
+// For maps a domain error to its code. notFound is used for
+// store.ErrNotFound, which doesn't say what was missing. Errors it doesn't
+// recognise are Internal.
+func For(err error, notFound Code) Code {
+	var verr *validate.Error
+	switch {
+	case errors.As(err, &verr):
+		return ValidationFailed
+	case errors.Is(err, auth.ErrNoAuthHeaderIncluded):
+		return AuthMissing
+	case errors.Is(err, auth.ErrMalformedAuthHeader):
+		return AuthMalformed
+	case errors.Is(err, auth.ErrTokenExpired):
+		return AuthTokenExpired
+	case errors.Is(err, auth.ErrInvalidToken):
+		return AuthTokenInvalid
+	case errors.Is(err, oidc.ErrUnavailable):
+		return OIDCProviderUnavailable
+	case errors.Is(err, oidc.ErrRejected):
+		return AuthOIDCFailed
+	case errors.Is(err, store.ErrNotFound):
+		return notFound
+	default:
+		return Internal
+	}
+}
@@ -0,0 +1,49 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/oidc"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/validate"
+)
+
+func TestCatalog(t *testing.T) {
+	format := regexp.MustCompile(`^[a-z_]+(\.[a-z_]+)?$`)
+	for _, code := range Codes() {
+		assert.Regexp(t, format, string(code))
+		assert.NotEmpty(t, code.Title(), code)
+		assert.GreaterOrEqual(t, code.Status(), 400, code)
+	}
+}
+
+func TestUnknownCodeIsInternal(t *testing.T) {
+	assert.Equal(t, http.StatusInternalServerError, Code("nope").Status())
+	assert.Equal(t, Internal.Title(), Code("nope").Title())
+}
+
+func TestFor(t *testing.T) {
+	tests := []struct {
+		err  error
+		want Code
+	}{
+		{&validate.Error{}, ValidationFailed},
+		{auth.ErrNoAuthHeaderIncluded, AuthMissing},
+		{auth.ErrMalformedAuthHeader, AuthMalformed},
+		{fmt.Errorf("%w: %w", auth.ErrInvalidToken, auth.ErrTokenExpired), AuthTokenExpired},
+		{fmt.Errorf("%w: bad signature", auth.ErrInvalidToken), AuthTokenInvalid},
+		{fmt.Errorf("getting chirp: %w", store.ErrNotFound), ChirpNotFound},
+		{fmt.Errorf("%w: discovery returned 503", oidc.ErrUnavailable), OIDCProviderUnavailable},
+		{fmt.Errorf("%w: nonce doesn't match", oidc.ErrRejected), AuthOIDCFailed},
+		{errors.New("connection refused"), Internal},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, For(tt.err, ChirpNotFound), tt.err.Error())
+	}
+}
@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strings"
+)
+
+// Scope is a permission a personal API key can be granted. Access tokens
+// carry every scope.
+type Scope string
+
+const (
+	ScopeChirpsRead   Scope = "chirps:read"
+	ScopeChirpsWrite  Scope = "chirps:write"
+	ScopeProfileWrite Scope = "profile:write"
+)
+
+// Scopes lists every scope a key can be granted.
+func Scopes() []Scope {
+	return []Scope{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+}
+
+// IsKnownScope reports whether s is in Scopes.
+func IsKnownScope(s string) bool {
+	return slices.Contains(Scopes(), Scope(s))
+}
+
+// ParseScopes splits a space-separated list of scopes, the form they are
+// stored in.
+func ParseScopes(s string) []Scope {
+	var scopes []Scope
+	for _, field := range strings.Fields(s) {
+		scopes = append(scopes, Scope(field))
+	}
+	return scopes
+}
+
+// FormatScopes joins scopes with spaces, the inverse of ParseScopes.
+func FormatScopes(scopes []Scope) string {
+	fields := make([]string, len(scopes))
+	for i, s := range scopes {
+		fields[i] = string(s)
+	}
+	return strings.Join(fields, " ")
+}
+
+// APIKeyPrefix starts every personal API key, so a leaked key is easy to
+// recognise.
+const APIKeyPrefix = "chirpy_"
+
+// MakeAPIKey returns a new random personal API key.
+func MakeAPIKey() (string, error) {
+	key, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + key, nil
+}
+
+// HashAPIKey is how personal API keys are stored. Unlike passwords they
+// are long and random, so a fast hash is safe, and being deterministic
+// lets keys be looked up by their hash.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,17 +1,18 @@
 package auth
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
-	"os"
 	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -22,9 +23,21 @@ const (
 	TokenTypeAccess TokenType = "chirpy-access"
 )
 
+var tracer = otel.Tracer("github.com/willmelton21/chirpy/internal/auth")
+
 // ErrNoAuthHeaderIncluded -
 var ErrNoAuthHeaderIncluded = errors.New("no auth header included in request")
 
+// ErrMalformedAuthHeader is returned when the Authorization header doesn't
+// use the expected scheme.
+var ErrMalformedAuthHeader = errors.New("malformed authorization header")
+
+// ErrInvalidToken wraps every reason ValidateJWT rejects a token.
+var ErrInvalidToken = errors.New("invalid access token")
+
+// ErrTokenExpired is also in the chain when the token has expired.
+var ErrTokenExpired = jwt.ErrTokenExpired
+
 func MakeRefreshToken() (string, error) {
 	key := make([]byte, 32)
 	_, err := rand.Read(key)
@@ -35,24 +48,32 @@ func MakeRefreshToken() (string, error) {
 	return encodedKey, nil
 }
 
-func GetAPIKey(headers http.Header) (string, error) {
-
-	authHeader := headers.Get("Authorization")	
-	if authHeader == ""{
-		return "",fmt.Errorf("Couldn't Get Header Auth")
+// GetAuthorization returns the credentials from an Authorization header
+// using scheme, such as "Bearer" or "ApiKey". Schemes are matched case
+// insensitively, as RFC 9110 requires.
+func GetAuthorization(headers http.Header, scheme string) (string, error) {
+	authHeader := headers.Get("Authorization")
+	if authHeader == "" {
+		return "", ErrNoAuthHeaderIncluded
 	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "ApiKey" {
-		return "",fmt.Errorf("Malformed Auth Header")
+	gotScheme, credentials, _ := strings.Cut(authHeader, " ")
+	credentials = strings.TrimSpace(credentials)
+	if !strings.EqualFold(gotScheme, scheme) || credentials == "" {
+		return "", ErrMalformedAuthHeader
 	}
+	return credentials, nil
+}
 
-	token := splitAuth[1]
-
-	return token,nil
+// GetAPIKey -
+func GetAPIKey(headers http.Header) (string, error) {
+	return GetAuthorization(headers, "ApiKey")
 }
 
 // HashPassword -
-func HashPassword(password string) (string, error) {
+func HashPassword(ctx context.Context, password string) (string, error) {
+	_, span := tracer.Start(ctx, "auth.HashPassword")
+	defer span.End()
+
 	dat, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
 		return "", err
@@ -61,101 +82,152 @@ func HashPassword(password string) (string, error) {
 }
 
 // CheckPasswordHash -
-func CheckPasswordHash(password, hash string) error {
+func CheckPasswordHash(ctx context.Context, password, hash string) error {
+	_, span := tracer.Start(ctx, "auth.CheckPasswordHash")
+	defer span.End()
+
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 }
 
-func GetUserIDFromToken(tokenString string) (uuid.UUID, error) {
- // Parse the token
-	tokenSecret := os.Getenv("SECRET")
-    token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-        return []byte(tokenSecret), nil
-    })
-    
-    if err != nil {
-        return uuid.Nil, err
-    }
-    
-    // Extract claims
-    if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-        // Get the subject claim which contains the user ID
-        sub, ok := claims["sub"].(string)
-        if !ok {
-            return uuid.Nil, fmt.Errorf("invalid subject in token")
-        }
-        
-        // Parse the string back to UUID
-        userID, err := uuid.Parse(sub)
-        if err != nil {
-            return uuid.Nil, fmt.Errorf("invalid user ID format in token")
-        }
-        
-        return userID, nil
-    }
-    
-    return uuid.Nil, fmt.Errorf("invalid token")
-
+// AccessClaims is what an access token says about its bearer.
+type AccessClaims struct {
+	UserID    uuid.UUID
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// ClientID is the OAuth client the token was issued to. It is
+	// uuid.Nil for tokens from login, which carry every scope.
+	ClientID uuid.UUID
+	Scopes   []Scope
+}
 
+// jwtClaims are the claims in an access token. scope and client_id are
+// named as in RFC 9068 and only set on tokens issued to OAuth clients.
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
 }
 
 // MakeJWT -
 func MakeJWT(
+	ctx context.Context,
 	userID uuid.UUID,
 	tokenSecret string,
 	expiresIn time.Duration,
 ) (string, error) {
+	_, span := tracer.Start(ctx, "auth.MakeJWT")
+	defer span.End()
+
+	return signJWT(jwtClaims{}, userID, tokenSecret, expiresIn)
+}
+
+// MakeClientJWT makes an access token for an OAuth client that only
+// carries scopes. ValidateJWT accepts it like any other access token;
+// ParseAccessToken also returns the client and scopes.
+func MakeClientJWT(
+	ctx context.Context,
+	userID, clientID uuid.UUID,
+	scopes []Scope,
+	tokenSecret string,
+	expiresIn time.Duration,
+) (string, error) {
+	_, span := tracer.Start(ctx, "auth.MakeJWT")
+	defer span.End()
+
+	return signJWT(jwtClaims{Scope: FormatScopes(scopes), ClientID: clientID.String()}, userID, tokenSecret, expiresIn)
+}
+
+func signJWT(claims jwtClaims, userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
 	signingKey := []byte(tokenSecret)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+	claims.RegisteredClaims = jwt.RegisteredClaims{
 		Issuer:    string(TokenTypeAccess),
 		IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 		ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 		Subject:   userID.String(),
-	})
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	return token.SignedString(signingKey)
 }
 
 // ValidateJWT -
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claimsStruct := jwt.RegisteredClaims{}
+func ValidateJWT(ctx context.Context, tokenString, tokenSecret string) (uuid.UUID, error) {
+	id, _, err := ValidateJWTExpiry(ctx, tokenString, tokenSecret)
+	return id, err
+}
+
+// ValidateJWTExpiry validates like ValidateJWT and also returns when the
+// token expires, for connections that outlive a single request.
+func ValidateJWTExpiry(ctx context.Context, tokenString, tokenSecret string) (uuid.UUID, time.Time, error) {
+	claims, err := ParseAccessToken(ctx, tokenString, tokenSecret)
+	return claims.UserID, claims.ExpiresAt, err
+}
+
+// ParseAccessToken validates like ValidateJWT and returns all of the
+// token's claims.
+func ParseAccessToken(ctx context.Context, tokenString, tokenSecret string) (AccessClaims, error) {
+	_, span := tracer.Start(ctx, "auth.ValidateJWT")
+	defer span.End()
+
+	claims, err := validateJWT(tokenString, tokenSecret)
+	if err != nil {
+		return AccessClaims{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+func validateJWT(tokenString, tokenSecret string) (AccessClaims, error) {
+	claimsStruct := jwtClaims{}
 	token, err := jwt.ParseWithClaims(
 		tokenString,
 		&claimsStruct,
 		func(token *jwt.Token) (interface{}, error) { return []byte(tokenSecret), nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
 	)
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
 
 	userIDString, err := token.Claims.GetSubject()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
 
 	issuer, err := token.Claims.GetIssuer()
 	if err != nil {
-		return uuid.Nil, err
+		return AccessClaims{}, err
 	}
 	if issuer != string(TokenTypeAccess) {
-		return uuid.Nil, errors.New("invalid issuer")
+		return AccessClaims{}, errors.New("invalid issuer")
+	}
+
+	expiresAt, err := token.Claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return AccessClaims{}, errors.New("token has no expiry")
 	}
 
 	id, err := uuid.Parse(userIDString)
 	if err != nil {
-		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+		return AccessClaims{}, fmt.Errorf("invalid user ID: %w", err)
 	}
-	return id, nil
-}
 
-// GetBearerToken -
-func GetBearerToken(headers http.Header) (string, error) {
-	authHeader := headers.Get("Authorization")
-	if authHeader == "" {
-		return "", ErrNoAuthHeaderIncluded
+	claims := AccessClaims{
+		UserID:    id,
+		ExpiresAt: expiresAt.Time,
+		Scopes:    ParseScopes(claimsStruct.Scope),
+	}
+	if claimsStruct.IssuedAt != nil {
+		claims.IssuedAt = claimsStruct.IssuedAt.Time
 	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "Bearer" {
-		return "", errors.New("malformed authorization header")
+	if claimsStruct.ClientID != "" {
+		claims.ClientID, err = uuid.Parse(claimsStruct.ClientID)
+		if err != nil {
+			return AccessClaims{}, fmt.Errorf("invalid client ID: %w", err)
+		}
 	}
+	return claims, nil
+}
 
-	return splitAuth[1], nil
+// GetBearerToken -
+func GetBearerToken(headers http.Header) (string, error) {
+	return GetAuthorization(headers, "Bearer")
 }
@@ -1,11 +1,14 @@
 package auth
 
 import (
+	"context"
 	"testing"
 	"github.com/stretchr/testify/assert"
 	"github.com/google/uuid"
+	"github.com/golang-jwt/jwt/v5"
 	"time"
 	"net/http"
+	"strings"
 )
 
 
@@ -18,27 +21,29 @@ func TestMakeAndValidateJWT(t *testing.T) {
 	secret := "test-secret"
 
 	//Test 1: Create and avlidate a toekn
-	token, err := MakeJWT(userID, secret, time.Hour)
+	token, err := MakeJWT(context.Background(), userID, secret, time.Hour)
 	assert.NoError(t,err)
 	assert.NotEmpty(t,token)
 
 	//Validate the token
-	extractedID, err := ValidateJWT(token,secret)
+	extractedID, err := ValidateJWT(context.Background(), token,secret)
 	assert.NoError(t,err)
 	assert.Equal(t, userID, extractedID)
 
 	//Test 2: Expired token
-	expiredToken, err := MakeJWT(userID,secret, -time.Hour)
+	expiredToken, err := MakeJWT(context.Background(), userID,secret, -time.Hour)
 	assert.NoError(t,err)
 
-	_,err = ValidateJWT(expiredToken,secret)
-	assert.Error(t,err)
+	_,err = ValidateJWT(context.Background(), expiredToken,secret)
+	assert.ErrorIs(t, err, ErrTokenExpired)
+	assert.ErrorIs(t, err, ErrInvalidToken)
 
 	wrongSecret := "wrong-secret"
-	_, err = ValidateJWT(token, wrongSecret)
-	assert.Error(t, err)
+	_, err = ValidateJWT(context.Background(), token, wrongSecret)
+	assert.ErrorIs(t, err, ErrInvalidToken)
+	assert.NotErrorIs(t, err, ErrTokenExpired)
 
-	_, err = ValidateJWT("not.a.valid.token",secret)
+	_, err = ValidateJWT(context.Background(), "not.a.valid.token",secret)
 	assert.Error(t,err)
 
 }
@@ -87,3 +92,123 @@ func TestGetBearerToken(t *testing.T) {
 		})
 	}
 }
+
+func TestGetAuthorization(t *testing.T) {
+	header := func(value string) http.Header {
+		return http.Header{"Authorization": []string{value}}
+	}
+
+	key, err := GetAuthorization(header("apikey  secret "), "ApiKey")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", key)
+
+	_, err = GetAuthorization(http.Header{}, "ApiKey")
+	assert.ErrorIs(t, err, ErrNoAuthHeaderIncluded)
+
+	_, err = GetAuthorization(header("Bearer secret"), "ApiKey")
+	assert.ErrorIs(t, err, ErrMalformedAuthHeader)
+
+	_, err = GetAuthorization(header("ApiKey "), "ApiKey")
+	assert.ErrorIs(t, err, ErrMalformedAuthHeader)
+}
+
+func TestPrincipalContext(t *testing.T) {
+	_, ok := PrincipalFrom(context.Background())
+	assert.False(t, ok)
+
+	want := Principal{Scheme: SchemeAccessToken, UserID: uuid.New()}
+	got, ok := PrincipalFrom(WithPrincipal(context.Background(), want))
+	assert.True(t, ok)
+	assert.Equal(t, want, got)
+}
+
+func TestAPIKeys(t *testing.T) {
+	key, err := MakeAPIKey()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(key, APIKeyPrefix))
+	other, err := MakeAPIKey()
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	assert.Equal(t, HashAPIKey(key), HashAPIKey(key))
+	assert.NotEqual(t, HashAPIKey(key), HashAPIKey(other))
+	assert.NotContains(t, HashAPIKey(key), key)
+
+	assert.True(t, IsKnownScope("chirps:write"))
+	assert.False(t, IsKnownScope("chirps:*"))
+}
+
+func TestPrincipalAllows(t *testing.T) {
+	bot := Principal{Scheme: SchemeAPIKey, Scopes: []Scope{ScopeChirpsRead}}
+	assert.True(t, bot.Allows(ScopeChirpsRead))
+	assert.False(t, bot.Allows(ScopeChirpsWrite))
+	assert.False(t, bot.Allows(""))
+
+	app := Principal{Scheme: SchemeAccessToken, ClientID: uuid.New(), Scopes: []Scope{ScopeChirpsWrite}}
+	assert.True(t, app.Allows(ScopeChirpsWrite))
+	assert.False(t, app.Allows(ScopeProfileWrite))
+	assert.False(t, app.Allows(""))
+
+	user := Principal{Scheme: SchemeAccessToken}
+	assert.True(t, user.Allows(ScopeProfileWrite))
+	assert.True(t, user.Allows(""))
+}
+
+func TestClientJWT(t *testing.T) {
+	ctx := context.Background()
+	userID, clientID := uuid.New(), uuid.New()
+	scopes := []Scope{ScopeChirpsRead, ScopeChirpsWrite}
+
+	token, err := MakeClientJWT(ctx, userID, clientID, scopes, "test-secret", time.Hour)
+	assert.NoError(t, err)
+
+	// Anything that only needs the user still accepts it.
+	id, err := ValidateJWT(ctx, token, "test-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, userID, id)
+
+	claims, err := ParseAccessToken(ctx, token, "test-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, userID, claims.UserID)
+	assert.Equal(t, clientID, claims.ClientID)
+	assert.Equal(t, scopes, claims.Scopes)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), claims.ExpiresAt, time.Minute)
+
+	firstParty, err := MakeJWT(ctx, userID, "test-secret", time.Hour)
+	assert.NoError(t, err)
+	claims, err = ParseAccessToken(ctx, firstParty, "test-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, uuid.Nil, claims.ClientID)
+	assert.Empty(t, claims.Scopes)
+}
+
+func TestValidateJWTOnlyAcceptsHS256(t *testing.T) {
+	userID := uuid.New()
+	claims := jwtClaims{RegisteredClaims: jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   userID.String(),
+	}}
+
+	for _, method := range []jwt.SigningMethod{jwt.SigningMethodHS384, jwt.SigningMethodHS512} {
+		token, err := jwt.NewWithClaims(method, claims).SignedString([]byte("test-secret"))
+		assert.NoError(t, err)
+		_, err = ValidateJWT(context.Background(), token, "test-secret")
+		assert.ErrorIs(t, err, ErrInvalidToken, method.Alg())
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test-secret"))
+	assert.NoError(t, err)
+	id, err := ValidateJWT(context.Background(), token, "test-secret")
+	assert.NoError(t, err)
+	assert.Equal(t, userID, id)
+}
+
+func TestPKCE(t *testing.T) {
+	// The example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	assert.Equal(t, challenge, PKCEChallenge(verifier))
+	assert.True(t, VerifyPKCE(verifier, challenge))
+	assert.False(t, VerifyPKCE(verifier+"x", challenge))
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// PKCEMethodS256 is the only PKCE code challenge method Chirpy accepts.
+// RFC 7636's "plain" method doesn't protect a code that is intercepted
+// along with its challenge.
+const PKCEMethodS256 = "S256"
+
+// PKCEChallenge returns the S256 code challenge for verifier: the
+// unpadded base64url SHA-256 of it.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// VerifyPKCE reports whether verifier is the one challenge was made from.
+func VerifyPKCE(verifier, challenge string) bool {
+	return subtle.ConstantTimeCompare([]byte(PKCEChallenge(verifier)), []byte(challenge)) == 1
+}
+
+// MakeClientSecret makes a secret for a confidential OAuth client.
+func MakeClientSecret() (string, error) {
+	return MakeRefreshToken()
+}
+
+// HashClientSecret is how client secrets are stored. Like API keys they
+// are long and random, so they are hashed the same way.
+func HashClientSecret(secret string) string {
+	return HashAPIKey(secret)
+}
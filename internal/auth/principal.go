@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Scheme is a kind of credential a route can require.
+type Scheme string
+
+const (
+	// SchemeAccessToken is a JWT from MakeJWT or MakeClientJWT, sent as a
+	// Bearer token.
+	SchemeAccessToken Scheme = "access_token"
+	// SchemeRefreshToken is a token from MakeRefreshToken, sent as a
+	// Bearer token. Only the refresh and revoke routes accept it.
+	SchemeRefreshToken Scheme = "refresh_token"
+	// SchemePolkaKey is the payment provider's shared key, sent as ApiKey.
+	SchemePolkaKey Scheme = "polka_key"
+	// SchemeAdminKey is the operator's key, sent as ApiKey.
+	SchemeAdminKey Scheme = "admin_key"
+	// SchemeAPIKey is a user's personal key from MakeAPIKey, sent as
+	// ApiKey. Routes accept it in place of an access token when the key
+	// has the scope they require.
+	SchemeAPIKey Scheme = "api_key"
+)
+
+// Principal is who a request was authenticated as.
+type Principal struct {
+	Scheme Scheme
+	// UserID is the authenticated user. It is uuid.Nil for the shared
+	// keys, which don't belong to a user.
+	UserID uuid.UUID
+	// Token is the credential itself, for routes that act on it, such as
+	// revoking a refresh token.
+	Token string
+	// ExpiresAt is when an access token stops being valid.
+	ExpiresAt time.Time
+	// ClientID is the OAuth client an access token was issued to, or
+	// uuid.Nil.
+	ClientID uuid.UUID
+	// Scopes are what a personal API key or OAuth client was granted.
+	Scopes []Scope
+}
+
+// Delegated reports whether p acts for the user through a personal API
+// key or an OAuth client, and so only has the scopes it was granted.
+func (p Principal) Delegated() bool {
+	return p.Scheme == SchemeAPIKey || p.ClientID != uuid.Nil
+}
+
+// Allows reports whether p may act with scope. Delegated principals need
+// the scope itself, so they are never allowed an empty scope; any other
+// credential allows everything.
+func (p Principal) Allows(scope Scope) bool {
+	return !p.Delegated() || (scope != "" && slices.Contains(p.Scopes, scope))
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored by WithPrincipal.
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
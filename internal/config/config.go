@@ -0,0 +1,390 @@
+// Package config loads chirpy's settings into one typed struct.
+//
+// Values are layered, later sources winning: built-in defaults, then an
+// optional YAML file (CONFIG_FILE), then environment variables, which also
+// pick up anything in a local .env file.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// MinSecretLength is the shortest JWT signing secret Validate accepts.
+const MinSecretLength = 32
+
+const redacted = "[redacted]"
+
+// Config is everything the server needs to start.
+type Config struct {
+	DBURL    string `yaml:"db_url"`
+	Platform string `yaml:"platform"`
+	Secret   string `yaml:"secret"`
+	PolkaKey string `yaml:"polka_key"`
+	AdminKey string `yaml:"admin_key"`
+	// AutoMigrate applies pending migrations when the server starts.
+	AutoMigrate bool `yaml:"auto_migrate"`
+	// PublicURL is where browsers reach the server, such as
+	// https://chirpy.example.com. OIDC callbacks are built from it.
+	PublicURL string `yaml:"public_url"`
+
+	// OIDC holds the OpenID Connect providers users can sign in with,
+	// keyed by the name used in their URLs.
+	OIDC map[string]OIDCProvider `yaml:"oidc"`
+
+	Server  Server  `yaml:"server"`
+	Log     Log     `yaml:"log"`
+	Tracing Tracing `yaml:"tracing"`
+}
+
+// Server holds listener settings and timeouts.
+type Server struct {
+	Addr               string        `yaml:"addr"`
+	ReadHeaderTimeout  time.Duration `yaml:"read_header_timeout"`
+	ReadTimeout        time.Duration `yaml:"read_timeout"`
+	WriteTimeout       time.Duration `yaml:"write_timeout"`
+	IdleTimeout        time.Duration `yaml:"idle_timeout"`
+	StreamWriteTimeout time.Duration `yaml:"stream_write_timeout"`
+	ShutdownTimeout    time.Duration `yaml:"shutdown_timeout"`
+	// DrainDelay is how long /readyz reports not-ready before the server
+	// stops accepting connections, giving load balancers time to notice.
+	DrainDelay time.Duration `yaml:"drain_delay"`
+}
+
+// Log controls the structured logger.
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is json or text.
+	Format string `yaml:"format"`
+}
+
+// Tracing controls OpenTelemetry export.
+type Tracing struct {
+	// Exporter is none, stdout or otlp. The OTLP exporter reads its
+	// endpoint from the standard OTEL_EXPORTER_OTLP_* variables.
+	Exporter    string  `yaml:"exporter"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// OIDCProvider is Chirpy's registration with an OpenID Connect provider.
+// Its callback URL is PublicURL + "/api/auth/oidc/{name}/callback".
+type OIDCProvider struct {
+	Issuer       string `yaml:"issuer"`
+	ClientID     string `yaml:"client_id"`
+	ClientSecret string `yaml:"client_secret"`
+}
+
+// Default returns the settings used for anything not configured.
+func Default() Config {
+	return Config{
+		Platform: "prod",
+		Server: Server{
+			Addr:               ":8080",
+			ReadHeaderTimeout:  5 * time.Second,
+			ReadTimeout:        15 * time.Second,
+			WriteTimeout:       30 * time.Second,
+			IdleTimeout:        2 * time.Minute,
+			StreamWriteTimeout: 30 * time.Second,
+			ShutdownTimeout:    20 * time.Second,
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "json",
+		},
+		Tracing: Tracing{
+			Exporter:    "none",
+			ServiceName: "chirpy",
+			SampleRatio: 1,
+		},
+	}
+}
+
+// Load builds the config from defaults, CONFIG_FILE and the environment.
+// It only fails on values that can't be parsed; call Validate before
+// serving. Commands that just need the database can skip it.
+func Load() (Config, error) {
+	// A missing .env is normal outside local development.
+	_ = godotenv.Load()
+
+	cfg := Default()
+
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		err := cfg.loadFile(path)
+		if err != nil {
+			return Config{}, err
+		}
+	}
+
+	err := cfg.loadEnv()
+	if err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c *Config) loadFile(path string) error {
+	dat, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(string(dat)))
+	decoder.KnownFields(true)
+	err = decoder.Decode(c)
+	if err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) loadEnv() error {
+	strs := []struct {
+		name string
+		dst  *string
+	}{
+		{"DB_URL", &c.DBURL},
+		{"PLATFORM", &c.Platform},
+		{"SECRET", &c.Secret},
+		{"POLKA_KEY", &c.PolkaKey},
+		{"ADMIN_KEY", &c.AdminKey},
+		{"PUBLIC_URL", &c.PublicURL},
+		{"ADDR", &c.Server.Addr},
+		{"LOG_LEVEL", &c.Log.Level},
+		{"LOG_FORMAT", &c.Log.Format},
+		{"TRACE_EXPORTER", &c.Tracing.Exporter},
+		{"OTEL_SERVICE_NAME", &c.Tracing.ServiceName},
+	}
+	for _, s := range strs {
+		if v, ok := os.LookupEnv(s.name); ok {
+			*s.dst = v
+		}
+	}
+
+	durations := []struct {
+		name string
+		dst  *time.Duration
+	}{
+		{"READ_HEADER_TIMEOUT", &c.Server.ReadHeaderTimeout},
+		{"READ_TIMEOUT", &c.Server.ReadTimeout},
+		{"WRITE_TIMEOUT", &c.Server.WriteTimeout},
+		{"IDLE_TIMEOUT", &c.Server.IdleTimeout},
+		{"STREAM_WRITE_TIMEOUT", &c.Server.StreamWriteTimeout},
+		{"SHUTDOWN_TIMEOUT", &c.Server.ShutdownTimeout},
+		{"DRAIN_DELAY", &c.Server.DrainDelay},
+	}
+	for _, d := range durations {
+		v, ok := os.LookupEnv(d.name)
+		if !ok || v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("%s: invalid duration %q", d.name, v)
+		}
+		*d.dst = parsed
+	}
+
+	if v := os.Getenv("AUTO_MIGRATE"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("AUTO_MIGRATE: invalid boolean %q", v)
+		}
+		c.AutoMigrate = b
+	}
+
+	if v := os.Getenv("TRACE_SAMPLE_RATIO"); v != "" {
+		ratio, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("TRACE_SAMPLE_RATIO: invalid number %q", v)
+		}
+		c.Tracing.SampleRatio = ratio
+	}
+
+	// OIDC_PROVIDERS=google,gitlab reads OIDC_GOOGLE_ISSUER and so on,
+	// replacing any provider of the same name from the file.
+	if v := os.Getenv("OIDC_PROVIDERS"); v != "" {
+		if c.OIDC == nil {
+			c.OIDC = make(map[string]OIDCProvider)
+		}
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			prefix := "OIDC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_"
+			c.OIDC[name] = OIDCProvider{
+				Issuer:       os.Getenv(prefix + "ISSUER"),
+				ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+				ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			}
+		}
+	}
+	return nil
+}
+
+// Validate reports every problem with c at once.
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.DBURL == "" {
+		errs = append(errs, errors.New("DB_URL is required"))
+	}
+	if len(c.Secret) < MinSecretLength {
+		errs = append(errs, fmt.Errorf("SECRET must be at least %d characters", MinSecretLength))
+	}
+	if c.Platform != "dev" && c.Platform != "prod" {
+		errs = append(errs, fmt.Errorf("PLATFORM must be dev or prod, got %q", c.Platform))
+	}
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("ADDR is required"))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", c.Log.Level))
+	}
+	if c.Log.Format != "json" && c.Log.Format != "text" {
+		errs = append(errs, fmt.Errorf("LOG_FORMAT must be json or text, got %q", c.Log.Format))
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("TRACE_EXPORTER must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, errors.New("TRACE_SAMPLE_RATIO must be between 0 and 1"))
+	}
+
+	timeouts := map[string]time.Duration{
+		"READ_HEADER_TIMEOUT":  c.Server.ReadHeaderTimeout,
+		"READ_TIMEOUT":         c.Server.ReadTimeout,
+		"WRITE_TIMEOUT":        c.Server.WriteTimeout,
+		"IDLE_TIMEOUT":         c.Server.IdleTimeout,
+		"STREAM_WRITE_TIMEOUT": c.Server.StreamWriteTimeout,
+		"SHUTDOWN_TIMEOUT":     c.Server.ShutdownTimeout,
+	}
+	for name, d := range timeouts {
+		if d <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", name))
+		}
+	}
+	if c.Server.DrainDelay < 0 {
+		errs = append(errs, errors.New("DRAIN_DELAY must not be negative"))
+	}
+
+	if c.PublicURL != "" {
+		if u, err := url.Parse(c.PublicURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("PUBLIC_URL must be an http or https URL, got %q", c.PublicURL))
+		}
+	}
+	if len(c.OIDC) > 0 && c.PublicURL == "" {
+		errs = append(errs, errors.New("PUBLIC_URL is required when OIDC providers are configured"))
+	}
+	for _, name := range slices.Sorted(maps.Keys(c.OIDC)) {
+		p := c.OIDC[name]
+		if !validProviderName(name) {
+			errs = append(errs, fmt.Errorf("OIDC provider name %q must be lowercase letters, digits and dashes", name))
+		}
+		if u, err := url.Parse(p.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("OIDC provider %s: issuer must be an http or https URL, got %q", name, p.Issuer))
+		}
+		if p.ClientID == "" {
+			errs = append(errs, fmt.Errorf("OIDC provider %s: client_id is required", name))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid config: %w", errors.Join(errs...))
+	}
+	return nil
+}
+
+func validProviderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// Redacted returns a copy of c that is safe to log.
+func (c Config) Redacted() Config {
+	out := c
+	for _, s := range []*string{&out.Secret, &out.PolkaKey, &out.AdminKey} {
+		if *s != "" {
+			*s = redacted
+		}
+	}
+	if c.OIDC != nil {
+		out.OIDC = make(map[string]OIDCProvider, len(c.OIDC))
+		for name, p := range c.OIDC {
+			if p.ClientSecret != "" {
+				p.ClientSecret = redacted
+			}
+			out.OIDC[name] = p
+		}
+	}
+	if c.DBURL != "" {
+		out.DBURL = redactDSN(c.DBURL)
+	}
+	return out
+}
+
+// dsnPassword matches a password in a key/value connection string such as
+// "host=db password='s3 cret'", where values may be single-quoted.
+var dsnPassword = regexp.MustCompile(`\b((?:ssl)?password)\s*=\s*(?:'(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactDSN masks the passwords in dsn, which is either a URL, with the
+// password in the userinfo or a password query parameter, or a key/value
+// connection string. It masks all of dsn if it doesn't parse.
+func redactDSN(dsn string) string {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return redacted
+	}
+	if u.Scheme == "" {
+		return dsnPassword.ReplaceAllString(dsn, "${1}="+redacted)
+	}
+	if u.User != nil {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+		}
+	}
+	query := u.Query()
+	for _, key := range []string{"password", "sslpassword"} {
+		if query.Has(key) {
+			query.Set(key, redacted)
+			u.RawQuery = query.Encode()
+		}
+	}
+	return u.String()
+}
+
+// String renders the redacted config as YAML, so printing a Config can
+// never leak a secret.
+func (c Config) String() string {
+	dat, err := yaml.Marshal(c.Redacted())
+	if err != nil {
+		return "<config: " + err.Error() + ">"
+	}
+	return string(dat)
+}
+
+// LogValue keeps structured loggers, which would otherwise marshal every
+// field, on the redacted form too.
+func (c Config) LogValue() slog.Value {
+	return slog.StringValue(c.String())
+}
@@ -41,14 +41,17 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 	return i, err
 }
 
-const deleteChirpByID = `-- name: DeleteChirpByID :exec
+const deleteChirpByID = `-- name: DeleteChirpByID :execrows
 DELETE FROM chirp 
 WHERE id = $1
 `
 
-func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, deleteChirpByID, id)
-	return err
+func (q *Queries) DeleteChirpByID(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteChirpByID, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 const getChirpByID = `-- name: GetChirpByID :one
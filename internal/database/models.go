@@ -6,11 +6,43 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type ApiKey struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UserID     uuid.UUID
+	Name       string
+	Prefix     string
+	HashedKey  string
+	Scopes     string
+	LastUsedAt sql.NullTime
+}
+
+type Block struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type Bookmark struct {
+	UserID       uuid.UUID
+	ChirpID      uuid.UUID
+	CollectionID uuid.NullUUID
+	CreatedAt    time.Time
+}
+
+type BookmarkCollection struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Name      string
+}
+
 type Chirp struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
@@ -19,6 +51,80 @@ type Chirp struct {
 	UserID    uuid.UUID
 }
 
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	Status     string
+	CreatedAt  time.Time
+}
+
+type Identity struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Provider  string
+	Subject   string
+	Email     string
+}
+
+type Job struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Kind        string
+	Payload     json.RawMessage
+	Status      string
+	Attempts    int32
+	MaxAttempts int32
+	RunAt       time.Time
+	LockedAt    sql.NullTime
+	LastError   sql.NullString
+	UniqueKey   sql.NullString
+}
+
+type Mute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	Type      string
+	ActorID   uuid.NullUUID
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type NotificationPreference struct {
+	UserID    uuid.UUID
+	Type      string
+	Enabled   bool
+	UpdatedAt time.Time
+}
+
+type OauthClient struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	Name         string
+	RedirectUris string
+	HashedSecret sql.NullString
+}
+
+type OauthCode struct {
+	Code          string
+	CreatedAt     time.Time
+	ClientID      uuid.UUID
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        string
+	CodeChallenge string
+	ExpiresAt     time.Time
+}
+
 type RefreshToken struct {
 	Token     string
 	CreatedAt time.Time
@@ -26,6 +132,8 @@ type RefreshToken struct {
 	UserID    uuid.UUID
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
+	ClientID  uuid.NullUUID
+	Scopes    string
 }
 
 type User struct {
@@ -35,4 +143,39 @@ type User struct {
 	Email          string
 	HashedPassword string
 	IsChirpyRed    sql.NullBool
+	IsPrivate      bool
+}
+
+type WebhookDelivery struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	EndpointID uuid.UUID
+	Event      string
+	Payload    json.RawMessage
+	Status     string
+	Attempts   int32
+	LastError  sql.NullString
+}
+
+type WebhookDeliveryAttempt struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	DeliveryID uuid.UUID
+	Attempt    int32
+	StatusCode sql.NullInt32
+	Error      sql.NullString
+	DurationMs int32
+}
+
+type WebhookEndpoint struct {
+	ID                  uuid.UUID
+	CreatedAt           time.Time
+	UpdatedAt           time.Time
+	UserID              uuid.UUID
+	Url                 string
+	Secret              string
+	Events              []string
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
 }
@@ -12,6 +12,33 @@ import (
 	"github.com/google/uuid"
 )
 
+const createClientRefreshToken = `-- name: CreateClientRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes)
+VALUES (
+   $1,
+   NOW(),
+   NOW(),
+   $2,
+   $3,
+   NULL,
+   $4,
+   $5
+   )
+`
+
+type CreateClientRefreshTokenParams struct {
+	Token     string
+	UserID    uuid.UUID
+	ExpiresAt time.Time
+	ClientID  uuid.NullUUID
+	Scopes    string
+}
+
+func (q *Queries) CreateClientRefreshToken(ctx context.Context, arg CreateClientRefreshTokenParams) error {
+	_, err := q.db.ExecContext(ctx, createClientRefreshToken, arg.Token, arg.UserID, arg.ExpiresAt, arg.ClientID, arg.Scopes)
+	return err
+}
+
 const createTokenDB = `-- name: CreateTokenDB :exec
 INSERT INTO refresh_tokens (token, created_at, updated_at, user_id,expires_at,revoked_at)
 VALUES (
@@ -22,7 +49,7 @@ VALUES (
    $3,
    NULL
    )
-   RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at
+   RETURNING token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes
 `
 
 type CreateTokenDBParams struct {
@@ -36,10 +63,49 @@ func (q *Queries) CreateTokenDB(ctx context.Context, arg CreateTokenDBParams) er
 	return err
 }
 
+const deleteExpiredRefreshTokens = `-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < NOW()
+   OR revoked_at < NOW() - INTERVAL '30 days'
+`
+
+func (q *Queries) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteExpiredRefreshTokens)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const getClientRefreshToken = `-- name: GetClientRefreshToken :one
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes FROM refresh_tokens
+WHERE token = $1
+   AND client_id IS NOT NULL
+   AND expires_at > NOW()
+   AND revoked_at IS NULL
+`
+
+func (q *Queries) GetClientRefreshToken(ctx context.Context, token string) (RefreshToken, error) {
+	row := q.db.QueryRowContext(ctx, getClientRefreshToken, token)
+	var i RefreshToken
+	err := row.Scan(
+		&i.Token,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.ExpiresAt,
+		&i.RevokedAt,
+		&i.ClientID,
+		&i.Scopes,
+	)
+	return i, err
+}
+
 const getUserFromRefreshToken = `-- name: GetUserFromRefreshToken :one
-SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red FROM users
+SELECT users.id, users.created_at, users.updated_at, users.email, users.hashed_password, users.is_chirpy_red, users.is_private FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1
+   AND refresh_tokens.client_id IS NULL
    AND refresh_tokens.expires_at > NOW()
    AND refresh_tokens.revoked_at IS NULL
 `
@@ -54,10 +120,25 @@ func (q *Queries) GetUserFromRefreshToken(ctx context.Context, token string) (Us
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsPrivate,
 	)
 	return i, err
 }
 
+const revokeAllRefreshTokens = `-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE revoked_at IS NULL
+`
+
+func (q *Queries) RevokeAllRefreshTokens(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeAllRefreshTokens)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const revokeToken = `-- name: RevokeToken :exec
 UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
@@ -68,3 +149,18 @@ func (q *Queries) RevokeToken(ctx context.Context, token string) error {
 	_, err := q.db.ExecContext(ctx, revokeToken, token)
 	return err
 }
+
+const revokeUserRefreshTokens = `-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+   AND revoked_at IS NULL
+`
+
+func (q *Queries) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, revokeUserRefreshTokens, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -20,7 +20,7 @@ VALUES (
    $1,
    $2
    )
-   RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+   RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_private
 `
 
 type CreateUserParams struct {
@@ -38,12 +38,26 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsPrivate,
 	)
 	return i, err
 }
 
+const deleteUser = `-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1
+`
+
+func (q *Queries) DeleteUser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users 
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_private FROM users 
 WHERE email = $1
 `
 
@@ -57,12 +71,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsPrivate,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_private FROM users
 WHERE id = $1
 `
 
@@ -76,6 +91,7 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsPrivate,
 	)
 	return i, err
 }
@@ -89,11 +105,38 @@ func (q *Queries) ResetTable(ctx context.Context) error {
 	return err
 }
 
+const setUserPrivate = `-- name: SetUserPrivate :one
+UPDATE users
+SET is_private = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_private
+`
+
+type SetUserPrivateParams struct {
+	IsPrivate bool
+	ID        uuid.UUID
+}
+
+func (q *Queries) SetUserPrivate(ctx context.Context, arg SetUserPrivateParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, setUserPrivate, arg.IsPrivate, arg.ID)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.IsPrivate,
+	)
+	return i, err
+}
+
 const updateEmailAndPass = `-- name: UpdateEmailAndPass :one
 UPDATE users 
 SET email = $1, hashed_password = $2, updated_at = NOW()
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, is_private
 `
 
 type UpdateEmailAndPassParams struct {
@@ -112,17 +155,21 @@ func (q *Queries) UpdateEmailAndPass(ctx context.Context, arg UpdateEmailAndPass
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.IsPrivate,
 	)
 	return i, err
 }
 
-const upgradeuser = `-- name: Upgradeuser :exec
+const upgradeuser = `-- name: Upgradeuser :execrows
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
 `
 
-func (q *Queries) Upgradeuser(ctx context.Context, id uuid.UUID) error {
-	_, err := q.db.ExecContext(ctx, upgradeuser, id)
-	return err
+func (q *Queries) Upgradeuser(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, upgradeuser, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
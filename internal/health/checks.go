@@ -0,0 +1,13 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Ping checks that the database accepts connections.
+func Ping(db *sql.DB) CheckFunc {
+	return func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	}
+}
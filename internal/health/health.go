@@ -0,0 +1,129 @@
+// Package health serves liveness and readiness probes.
+//
+// Liveness only says the process is up and serving HTTP. Readiness runs
+// every registered dependency check and fails if any of them does, or if
+// the server has started draining for shutdown, so load balancers stop
+// routing to it before connections are closed.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component statuses reported by Ready.
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+)
+
+// CheckFunc returns nil when the dependency is usable.
+type CheckFunc func(ctx context.Context) error
+
+// Component is one check's result.
+type Component struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report is the body of a readiness response.
+type Report struct {
+	Status     string               `json:"status"`
+	Components map[string]Component `json:"components"`
+}
+
+// Checker holds the readiness checks.
+type Checker struct {
+	checks   map[string]CheckFunc
+	draining atomic.Bool
+
+	// Timeout bounds each readiness check.
+	Timeout time.Duration
+}
+
+// NewChecker -
+func NewChecker() *Checker {
+	return &Checker{
+		checks:  make(map[string]CheckFunc),
+		Timeout: 2 * time.Second,
+	}
+}
+
+// Register adds a readiness check. It must be called before serving.
+func (c *Checker) Register(name string, check CheckFunc) {
+	c.checks[name] = check
+}
+
+// SetDraining makes every later readiness probe fail.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Check runs every check concurrently and reports the results.
+func (c *Checker) Check(ctx context.Context) Report {
+	report := Report{Status: StatusOK, Components: make(map[string]Component, len(c.checks)+1)}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range c.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := check(checkCtx)
+			comp := Component{Status: StatusOK, Duration: time.Since(start).String()}
+			if err != nil {
+				comp.Status = StatusFail
+				comp.Error = err.Error()
+			}
+
+			mu.Lock()
+			report.Components[name] = comp
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	server := Component{Status: StatusOK, Duration: "0s"}
+	if c.draining.Load() {
+		server = Component{Status: StatusFail, Error: "draining for shutdown", Duration: "0s"}
+	}
+	report.Components["server"] = server
+
+	for _, comp := range report.Components {
+		if comp.Status != StatusOK {
+			report.Status = StatusFail
+		}
+	}
+	return report
+}
+
+// Live always answers 200 while the process can serve requests.
+func (c *Checker) Live(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// Ready answers 200 when every check passes and 503 otherwise.
+func (c *Checker) Ready(w http.ResponseWriter, r *http.Request) {
+	report := c.Check(r.Context())
+	code := http.StatusOK
+	if report.Status != StatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
+
+func writeJSON(w http.ResponseWriter, code int, payload any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(payload)
+}
@@ -0,0 +1,72 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ready(t *testing.T, c *Checker) (int, Report) {
+	rec := httptest.NewRecorder()
+	c.Ready(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report Report
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	return rec.Code, report
+}
+
+func TestReady(t *testing.T) {
+	c := NewChecker()
+	c.Register("database", func(ctx context.Context) error { return nil })
+
+	code, report := ready(t, c)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, StatusOK, report.Status)
+	assert.Equal(t, StatusOK, report.Components["database"].Status)
+	assert.Equal(t, StatusOK, report.Components["server"].Status)
+}
+
+func TestReadyFailingCheck(t *testing.T) {
+	c := NewChecker()
+	c.Register("database", func(ctx context.Context) error { return nil })
+	c.Register("migrations", func(ctx context.Context) error {
+		return errors.New("schema is at version 7, want 8")
+	})
+
+	code, report := ready(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Status)
+	assert.Equal(t, StatusOK, report.Components["database"].Status)
+	assert.Equal(t, "schema is at version 7, want 8", report.Components["migrations"].Error)
+}
+
+func TestReadyTimesOutSlowCheck(t *testing.T) {
+	c := NewChecker()
+	c.Timeout = 10 * time.Millisecond
+	c.Register("database", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	code, report := ready(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["database"].Error)
+}
+
+func TestReadyWhileDraining(t *testing.T) {
+	c := NewChecker()
+	c.SetDraining()
+
+	code, report := ready(t, c)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, StatusFail, report.Components["server"].Status)
+
+	rec := httptest.NewRecorder()
+	c.Live(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month,
+// month and day of week. Each field accepts "*", numbers, ranges ("1-5"),
+// steps ("*/15", "0-30/10") and comma-separated lists of those.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar follow cron's rule that when both day fields are
+	// restricted a time matches if either does.
+	domStar, dowStar bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 6},
+}
+
+// ParseCron parses spec or returns an error naming the bad field.
+func ParseCron(spec string) (Cron, error) {
+	parts := strings.Fields(spec)
+	if len(parts) != len(cronFields) {
+		return Cron{}, fmt.Errorf("cron %q: want 5 fields, got %d", spec, len(parts))
+	}
+
+	var bits [5]uint64
+	for i, part := range parts {
+		f := cronFields[i]
+		b, err := parseCronField(part, f.min, f.max)
+		if err != nil {
+			return Cron{}, fmt.Errorf("cron %q: %s: %w", spec, f.name, err)
+		}
+		bits[i] = b
+	}
+
+	return Cron{
+		minute:  bits[0],
+		hour:    bits[1],
+		dom:     bits[2],
+		month:   bits[3],
+		dow:     bits[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepPart)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("bad step %q", stepPart)
+			}
+			step = n
+		}
+
+		lo, hi := min, max
+		if rangePart != "*" {
+			loStr, hiStr, isRange := strings.Cut(rangePart, "-")
+			var err error
+			lo, err = strconv.Atoi(loStr)
+			if err != nil {
+				return 0, fmt.Errorf("bad value %q", loStr)
+			}
+			hi = lo
+			if isRange {
+				hi, err = strconv.Atoi(hiStr)
+				if err != nil {
+					return 0, fmt.Errorf("bad value %q", hiStr)
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", item, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Matches reports whether t, truncated to the minute, is a firing time.
+func (c Cron) Matches(t time.Time) bool {
+	if c.minute&(1<<uint(t.Minute())) == 0 ||
+		c.hour&(1<<uint(t.Hour())) == 0 ||
+		c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	domOK := c.dom&(1<<uint(t.Day())) != 0
+	dowOK := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return domOK && dowOK
+	}
+	return domOK || dowOK
+}
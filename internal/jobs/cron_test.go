@@ -0,0 +1,45 @@
+package jobs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, spec := range []string{"* * * * *", "0 * * * *", "*/15 9-17 * * 1-5", "0,30 0 1 1,7 *"} {
+		_, err := ParseCron(spec)
+		assert.NoError(t, err, spec)
+	}
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseCron(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	// Monday 2024-01-15 09:30 UTC
+	monday := time.Date(2024, 1, 15, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		at   time.Time
+		want bool
+	}{
+		{spec: "* * * * *", at: monday, want: true},
+		{spec: "0 * * * *", at: monday, want: false},
+		{spec: "*/15 9-17 * * 1-5", at: monday, want: true},
+		{spec: "*/15 9-17 * * 1-5", at: monday.AddDate(0, 0, 5), want: false},
+		{spec: "30 9 15 * *", at: monday, want: true},
+		// Both day fields restricted: either may match.
+		{spec: "30 9 1 * 1", at: monday, want: true},
+		{spec: "30 9 1 * 0", at: monday, want: false},
+	}
+
+	for _, tt := range tests {
+		c, err := ParseCron(tt.spec)
+		assert.NoError(t, err)
+		assert.Equal(t, tt.want, c.Matches(tt.at), "%s at %s", tt.spec, tt.at)
+	}
+}
@@ -0,0 +1,377 @@
+// Package jobs is a durable background job queue kept in the store.
+//
+// Jobs are rows in the jobs table. Workers claim due rows atomically, with
+// FOR UPDATE SKIP LOCKED on Postgres and in a single write on SQLite, so
+// processes sharing a database never hand the same job to two workers. A job that returns an
+// error is retried with backoff until it runs out of attempts, at which
+// point it is parked in the "dead" state for an admin to inspect and retry.
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// Job statuses stored in jobs.status.
+const (
+	StatusPending   = store.JobPending
+	StatusRunning   = store.JobRunning
+	StatusSucceeded = store.JobSucceeded
+	StatusDead      = store.JobDead
+)
+
+// Options tunes how jobs of one kind are run.
+type Options struct {
+	// MaxAttempts is how many times a job runs before it is marked dead.
+	MaxAttempts int32
+	// Timeout bounds a single attempt.
+	Timeout time.Duration
+	// Backoff returns the wait before the next attempt, given how many
+	// attempts have already been made.
+	Backoff func(attempts int32) time.Duration
+}
+
+// DefaultOptions are used for any zero field of a kind's Options.
+var DefaultOptions = Options{
+	MaxAttempts: 5,
+	Timeout:     time.Minute,
+	Backoff:     ExponentialBackoff(15*time.Second, time.Hour),
+}
+
+// ExponentialBackoff doubles base for every attempt, caps the wait at max
+// and adds up to 10% jitter.
+func ExponentialBackoff(base, max time.Duration) func(int32) time.Duration {
+	return func(attempts int32) time.Duration {
+		wait := base
+		for i := int32(1); i < attempts && wait < max; i++ {
+			wait *= 2
+		}
+		if wait > max {
+			wait = max
+		}
+		return wait + time.Duration(rand.Int64N(int64(wait)/10+1))
+	}
+}
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying; the job goes straight to dead.
+func Permanent(err error) error {
+	return permanentError{err: err}
+}
+
+type attemptKey struct{}
+
+// Attempt describes the attempt a handler is running as.
+type Attempt struct {
+	JobID       uuid.UUID
+	Number      int32
+	MaxAttempts int32
+}
+
+// Last reports whether a failure now will mark the job dead.
+func (a Attempt) Last() bool {
+	return a.Number >= a.MaxAttempts
+}
+
+// CurrentAttempt returns the attempt a handler's ctx belongs to.
+func CurrentAttempt(ctx context.Context) (Attempt, bool) {
+	a, ok := ctx.Value(attemptKey{}).(Attempt)
+	return a, ok
+}
+
+type handler struct {
+	run  func(ctx context.Context, payload json.RawMessage) error
+	opts Options
+}
+
+type schedule struct {
+	cron    Cron
+	spec    string
+	kind    string
+	payload json.RawMessage
+}
+
+// DB is the part of store.Store the queue uses.
+type DB interface {
+	EnqueueJob(ctx context.Context, job database.Job) (database.Job, error)
+	ClaimJobs(ctx context.Context, kinds []string, limit int) ([]database.Job, error)
+	CompleteJob(ctx context.Context, id uuid.UUID) error
+	RetryJob(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error
+	KillJob(ctx context.Context, id uuid.UUID, lastError string) error
+	RequeueStaleJobs(ctx context.Context, lockedBefore time.Time, lastError string) (int64, error)
+}
+
+// Queue enqueues jobs and runs the workers that execute them.
+type Queue struct {
+	db        DB
+	handlers  map[string]handler
+	schedules []schedule
+
+	Workers      int
+	PollInterval time.Duration
+	// StaleAfter is how long a job may stay running before it is assumed
+	// to belong to a crashed worker and handed out again. The lost run
+	// counts as an attempt, so a job that keeps crashing its worker ends up
+	// dead.
+	StaleAfter time.Duration
+}
+
+// New -
+func New(db DB) *Queue {
+	return &Queue{
+		db:           db,
+		handlers:     make(map[string]handler),
+		Workers:      4,
+		PollInterval: time.Second,
+		StaleAfter:   15 * time.Minute,
+	}
+}
+
+// Register installs fn as the handler for kind. Payloads are decoded into T,
+// and a payload that doesn't decode is a permanent failure.
+func Register[T any](q *Queue, kind string, opts Options, fn func(ctx context.Context, args T) error) {
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = DefaultOptions.MaxAttempts
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = DefaultOptions.Timeout
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = DefaultOptions.Backoff
+	}
+
+	q.handlers[kind] = handler{
+		opts: opts,
+		run: func(ctx context.Context, payload json.RawMessage) error {
+			var args T
+			err := json.Unmarshal(payload, &args)
+			if err != nil {
+				return Permanent(fmt.Errorf("decoding %s payload: %w", kind, err))
+			}
+			return fn(ctx, args)
+		},
+	}
+}
+
+// EnqueueOption customises a single Enqueue call.
+type EnqueueOption func(*database.Job)
+
+// RunAt delays the job until t.
+func RunAt(t time.Time) EnqueueOption {
+	return func(j *database.Job) { j.RunAt = t.UTC() }
+}
+
+// MaxAttempts overrides the kind's attempt limit for this job.
+func MaxAttempts(n int32) EnqueueOption {
+	return func(j *database.Job) { j.MaxAttempts = n }
+}
+
+// UniqueKey makes Enqueue a no-op if a job with key already exists.
+func UniqueKey(key string) EnqueueOption {
+	return func(j *database.Job) { j.UniqueKey = sql.NullString{String: key, Valid: true} }
+}
+
+// errStale is recorded on jobs whose worker stopped before finishing them.
+var errStale = errors.New("worker stopped before the job finished")
+
+// ErrDuplicate is returned by Enqueue when UniqueKey matched an existing job.
+var ErrDuplicate = errors.New("job with this unique key already exists")
+
+// Enqueue stores a job of kind with args as its payload.
+func (q *Queue) Enqueue(ctx context.Context, kind string, args any, opts ...EnqueueOption) (database.Job, error) {
+	h, ok := q.handlers[kind]
+	if !ok {
+		return database.Job{}, fmt.Errorf("no handler registered for job kind %q", kind)
+	}
+
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return database.Job{}, err
+	}
+
+	job := database.Job{
+		Kind:        kind,
+		Payload:     payload,
+		MaxAttempts: h.opts.MaxAttempts,
+		RunAt:       time.Now().UTC(),
+	}
+	for _, opt := range opts {
+		opt(&job)
+	}
+
+	job, err = q.db.EnqueueJob(ctx, job)
+	if errors.Is(err, store.ErrConflict) {
+		return database.Job{}, ErrDuplicate
+	}
+	return job, err
+}
+
+// Schedule enqueues a kind job with args every time spec fires. Running
+// several processes is fine: each firing gets a unique key, so only one of
+// them actually inserts the job.
+func (q *Queue) Schedule(spec, kind string, args any) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	if _, ok := q.handlers[kind]; !ok {
+		return fmt.Errorf("no handler registered for job kind %q", kind)
+	}
+	payload, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	q.schedules = append(q.schedules, schedule{cron: cron, spec: spec, kind: kind, payload: payload})
+	return nil
+}
+
+// Run starts the workers and scheduler and blocks until ctx is cancelled and
+// every in-flight job has finished. Jobs already running get to complete
+// (within their own timeout) rather than being cut off by shutdown.
+func (q *Queue) Run(ctx context.Context) {
+	kinds := make([]string, 0, len(q.handlers))
+	for kind := range q.handlers {
+		kinds = append(kinds, kind)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < q.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx, kinds)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		q.schedule(ctx)
+	}()
+
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context, kinds []string) {
+	ticker := time.NewTicker(q.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		for ctx.Err() == nil {
+			claimed, err := q.db.ClaimJobs(ctx, kinds, 1)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("jobs: claiming: %s", err)
+				}
+				break
+			}
+			if len(claimed) == 0 {
+				break
+			}
+			q.execute(context.WithoutCancel(ctx), claimed[0])
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) execute(ctx context.Context, job database.Job) {
+	h := q.handlers[job.Kind]
+
+	runCtx, cancel := context.WithTimeout(ctx, h.opts.Timeout)
+	runCtx = context.WithValue(runCtx, attemptKey{}, Attempt{
+		JobID:       job.ID,
+		Number:      job.Attempts,
+		MaxAttempts: job.MaxAttempts,
+	})
+	err := safeRun(runCtx, h, job.Payload)
+	cancel()
+
+	switch {
+	case err == nil:
+		err = q.db.CompleteJob(ctx, job.ID)
+	case errors.As(err, new(permanentError)) || job.Attempts >= job.MaxAttempts:
+		log.Printf("jobs: %s job %s is dead after %d attempts: %s", job.Kind, job.ID, job.Attempts, err)
+		err = q.db.KillJob(ctx, job.ID, err.Error())
+	default:
+		err = q.db.RetryJob(ctx, job.ID, time.Now().UTC().Add(h.opts.Backoff(job.Attempts)), err.Error())
+	}
+	if err != nil {
+		log.Printf("jobs: recording result of job %s: %s", job.ID, err)
+	}
+}
+
+// safeRun turns a panicking handler into a failed attempt.
+func safeRun(ctx context.Context, h handler, payload json.RawMessage) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h.run(ctx, payload)
+}
+
+// schedule fires cron schedules once a minute and requeues jobs orphaned by
+// crashed workers.
+func (q *Queue) schedule(ctx context.Context) {
+	for {
+		now := time.Now().UTC()
+		q.tick(ctx, now)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(now.Truncate(time.Minute).Add(time.Minute).Sub(time.Now().UTC())):
+		}
+	}
+}
+
+// tick enqueues the cron jobs due in now's minute and requeues jobs locked
+// longer than StaleAfter. Every process ticks; the unique key makes sure each
+// scheduled run is enqueued once.
+func (q *Queue) tick(ctx context.Context, now time.Time) {
+	minute := now.Truncate(time.Minute)
+
+	for _, s := range q.schedules {
+		if !s.cron.Matches(minute) {
+			continue
+		}
+		key := fmt.Sprintf("cron:%s:%s:%s", s.kind, s.spec, minute.Format(time.RFC3339))
+		_, err := q.db.EnqueueJob(ctx, database.Job{
+			Kind:        s.kind,
+			Payload:     s.payload,
+			MaxAttempts: q.handlers[s.kind].opts.MaxAttempts,
+			RunAt:       minute,
+			UniqueKey:   sql.NullString{String: key, Valid: true},
+		})
+		if err != nil && !errors.Is(err, store.ErrConflict) && ctx.Err() == nil {
+			log.Printf("jobs: scheduling %s: %s", s.kind, err)
+		}
+	}
+
+	stale, err := q.db.RequeueStaleJobs(ctx, now.Add(-q.StaleAfter), errStale.Error())
+	if err != nil && ctx.Err() == nil {
+		log.Printf("jobs: requeueing stale jobs: %s", err)
+	} else if stale > 0 {
+		log.Printf("jobs: requeued or killed %d stale jobs", stale)
+	}
+}
@@ -0,0 +1,59 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Second, time.Minute)
+
+	tests := []struct {
+		attempts int32
+		min      time.Duration
+	}{
+		{attempts: 1, min: 10 * time.Second},
+		{attempts: 2, min: 20 * time.Second},
+		{attempts: 3, min: 40 * time.Second},
+		{attempts: 4, min: time.Minute},
+		{attempts: 30, min: time.Minute},
+	}
+
+	for _, tt := range tests {
+		got := backoff(tt.attempts)
+		assert.GreaterOrEqual(t, got, tt.min)
+		assert.LessOrEqual(t, got, tt.min+tt.min/10)
+	}
+}
+
+func TestRegisterDecodesPayload(t *testing.T) {
+	type args struct {
+		Name string `json:"name"`
+	}
+
+	q := New(nil)
+	var got args
+	Register(q, "greet", Options{}, func(ctx context.Context, a args) error {
+		got = a
+		return nil
+	})
+
+	h := q.handlers["greet"]
+	assert.Equal(t, DefaultOptions.MaxAttempts, h.opts.MaxAttempts)
+
+	assert.NoError(t, safeRun(context.Background(), h, []byte(`{"name":"chirpy"}`)))
+	assert.Equal(t, "chirpy", got.Name)
+
+	err := safeRun(context.Background(), h, []byte(`not json`))
+	assert.True(t, errors.As(err, new(permanentError)))
+}
+
+func TestSafeRunRecoversPanics(t *testing.T) {
+	h := handler{run: func(context.Context, json.RawMessage) error { panic("boom") }}
+	assert.EqualError(t, safeRun(context.Background(), h, nil), "panic: boom")
+}
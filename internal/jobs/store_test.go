@@ -0,0 +1,265 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/store/storetest"
+)
+
+// forEachStore runs fn against memory, SQLite and Postgres. The Postgres
+// run is skipped unless storetest.PostgresURLEnv is set.
+func forEachStore(t *testing.T, fn func(t *testing.T, s store.Store)) {
+	backends := []storetest.Backend{
+		{Name: store.DriverMemory, New: storetest.NewMemory},
+		{Name: store.DriverSQLite, New: storetest.NewSQLite},
+		{Name: store.DriverPostgres, New: storetest.NewPostgres},
+	}
+	for _, b := range backends {
+		t.Run(b.Name, func(t *testing.T) {
+			fn(t, b.New(t))
+		})
+	}
+}
+
+// run starts q in the background and returns a function that stops it and
+// waits for Run to return.
+func run(q *Queue) (stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// waitForJobs polls until n jobs have status.
+func waitForJobs(t *testing.T, s store.Store, status string, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		jobs, err := s.ListJobs(context.Background(), status, n+1)
+		return err == nil && len(jobs) == n
+	}, 10*time.Second, 10*time.Millisecond, "waiting for %d %s jobs", n, status)
+}
+
+type countArgs struct {
+	N int `json:"n"`
+}
+
+func TestWorkersClaimEachJobOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.Store) {
+		const jobCount = 40
+
+		var mu sync.Mutex
+		runs := make(map[int]int)
+
+		// Several queues on one store stand in for several processes,
+		// each with its own workers competing for the same rows.
+		var stops []func()
+		for p := 0; p < 3; p++ {
+			q := New(s)
+			q.Workers = 4
+			q.PollInterval = 5 * time.Millisecond
+			Register(q, "count", Options{}, func(ctx context.Context, args countArgs) error {
+				mu.Lock()
+				runs[args.N]++
+				mu.Unlock()
+				return nil
+			})
+			if len(stops) == 0 {
+				for i := 0; i < jobCount; i++ {
+					_, err := q.Enqueue(context.Background(), "count", countArgs{N: i})
+					require.NoError(t, err)
+				}
+			}
+			stops = append(stops, run(q))
+		}
+
+		waitForJobs(t, s, StatusSucceeded, jobCount)
+		for _, stop := range stops {
+			stop()
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		require.Len(t, runs, jobCount)
+		for n, count := range runs {
+			assert.Equal(t, 1, count, "job %d", n)
+		}
+	})
+}
+
+func TestFailingJobsEndDead(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.Store) {
+		var mu sync.Mutex
+		calls := 0
+
+		q := New(s)
+		q.Workers = 2
+		q.PollInterval = 5 * time.Millisecond
+		Register(q, "fail", Options{
+			MaxAttempts: 3,
+			Backoff:     func(int32) time.Duration { return 0 },
+		}, func(ctx context.Context, args struct{}) error {
+			mu.Lock()
+			calls++
+			mu.Unlock()
+			return errors.New("boom")
+		})
+		Register(q, "reject", Options{MaxAttempts: 3}, func(ctx context.Context, args struct{}) error {
+			return Permanent(errors.New("bad payload"))
+		})
+
+		failing, err := q.Enqueue(context.Background(), "fail", struct{}{})
+		require.NoError(t, err)
+		rejected, err := q.Enqueue(context.Background(), "reject", struct{}{})
+		require.NoError(t, err)
+
+		stop := run(q)
+		waitForJobs(t, s, StatusDead, 2)
+		stop()
+
+		got, err := s.GetJob(context.Background(), failing.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), got.Attempts)
+		assert.Equal(t, "boom", got.LastError.String)
+		mu.Lock()
+		assert.Equal(t, 3, calls)
+		mu.Unlock()
+
+		got, err = s.GetJob(context.Background(), rejected.ID)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), got.Attempts, "a permanent failure isn't retried")
+		assert.Equal(t, "bad payload", got.LastError.String)
+	})
+}
+
+func TestScheduleEnqueuesEachRunOnce(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.Store) {
+		ctx := context.Background()
+		newQueue := func() *Queue {
+			q := New(s)
+			Register(q, "tick", Options{}, func(ctx context.Context, args struct{}) error { return nil })
+			require.NoError(t, q.Schedule("*/5 * * * *", "tick", struct{}{}))
+			return q
+		}
+		a, b := newQueue(), newQueue()
+
+		minute := time.Date(2030, 1, 1, 12, 5, 0, 0, time.UTC)
+		a.tick(ctx, minute)
+		b.tick(ctx, minute.Add(30*time.Second))
+		a.tick(ctx, minute.Add(time.Minute))
+
+		jobs, err := s.ListJobs(ctx, "", 10)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1, "both processes fire the same minute but only one job is enqueued")
+		assert.Equal(t, "tick", jobs[0].Kind)
+		assert.True(t, jobs[0].RunAt.Equal(minute))
+
+		b.tick(ctx, minute.Add(5*time.Minute))
+		jobs, err = s.ListJobs(ctx, "", 10)
+		require.NoError(t, err)
+		assert.Len(t, jobs, 2)
+	})
+}
+
+func TestTickRequeuesStaleJobs(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.Store) {
+		ctx := context.Background()
+		q := New(s)
+		Register(q, "crash", Options{MaxAttempts: 2}, func(ctx context.Context, args struct{}) error { return nil })
+
+		job, err := q.Enqueue(ctx, "crash", struct{}{})
+		require.NoError(t, err)
+
+		// Claiming without finishing is what a crashed worker leaves behind.
+		claim := func() {
+			t.Helper()
+			claimed, err := s.ClaimJobs(ctx, []string{"crash"}, 1)
+			require.NoError(t, err)
+			require.Len(t, claimed, 1)
+		}
+
+		claim()
+		q.tick(ctx, time.Now().UTC())
+		got, err := s.GetJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusRunning, got.Status, "a job running for less than StaleAfter is left alone")
+
+		q.tick(ctx, time.Now().UTC().Add(q.StaleAfter+time.Minute))
+		got, err = s.GetJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusPending, got.Status)
+		assert.Equal(t, int32(1), got.Attempts)
+		assert.Equal(t, errStale.Error(), got.LastError.String)
+
+		claim()
+		q.tick(ctx, time.Now().UTC().Add(q.StaleAfter+time.Minute))
+		got, err = s.GetJob(ctx, job.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusDead, got.Status, "the second lost run uses the last attempt")
+		assert.Equal(t, int32(2), got.Attempts)
+	})
+}
+
+func TestRunFinishesInFlightJobsOnShutdown(t *testing.T) {
+	forEachStore(t, func(t *testing.T, s store.Store) {
+		started := make(chan struct{}, 1)
+		release := make(chan struct{})
+
+		q := New(s)
+		q.Workers = 1
+		q.PollInterval = 5 * time.Millisecond
+		Register(q, "slow", Options{}, func(ctx context.Context, args countArgs) error {
+			started <- struct{}{}
+			<-release
+			return ctx.Err()
+		})
+
+		first, err := q.Enqueue(context.Background(), "slow", countArgs{N: 1})
+		require.NoError(t, err)
+		second, err := q.Enqueue(context.Background(), "slow", countArgs{N: 2}, RunAt(time.Now().UTC().Add(10*time.Millisecond)))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			q.Run(ctx)
+			close(done)
+		}()
+
+		<-started
+		cancel()
+		select {
+		case <-done:
+			t.Fatal("Run returned before the in-flight job finished")
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		close(release)
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run didn't return after shutdown")
+		}
+
+		got, err := s.GetJob(context.Background(), first.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusSucceeded, got.Status, "shutdown doesn't cancel a running job")
+
+		got, err = s.GetJob(context.Background(), second.ID)
+		require.NoError(t, err)
+		assert.Equal(t, StatusPending, got.Status, "no job is claimed after shutdown")
+		assert.Equal(t, int32(0), got.Attempts)
+	})
+}
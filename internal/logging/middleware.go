@@ -0,0 +1,190 @@
+package logging
+
+import (
+	"bufio"
+	"context"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader carries the request ID in and out.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs accepted from clients.
+const maxRequestIDLength = 128
+
+type requestInfoKey struct{}
+
+// requestInfo is shared by pointer so handlers can fill in what the access
+// log should report once they know it.
+type requestInfo struct {
+	id     string
+	userID uuid.UUID
+}
+
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	info, _ := ctx.Value(requestInfoKey{}).(*requestInfo)
+	return info
+}
+
+// RequestID returns the ID of the request ctx belongs to, or "".
+func RequestID(ctx context.Context) string {
+	if info := requestInfoFrom(ctx); info != nil {
+		return info.id
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user for the access log.
+func SetUserID(ctx context.Context, userID uuid.UUID) {
+	if info := requestInfoFrom(ctx); info != nil {
+		info.userID = userID
+	}
+}
+
+// RecordError attaches the server-side cause of a failed response to the
+// access log line. w must be, or wrap, a writer handed out by Middleware.
+func RecordError(w http.ResponseWriter, err error) {
+	if rw := findWriter(w); rw != nil {
+		rw.err = err
+	}
+}
+
+// TraceID returns the trace ID of the request w is responding to, or "" if
+// it isn't being traced, so error bodies can point at the trace.
+func TraceID(w http.ResponseWriter) string {
+	if rw := findWriter(w); rw != nil {
+		return rw.traceID
+	}
+	return ""
+}
+
+func findWriter(w http.ResponseWriter) *responseWriter {
+	for {
+		if rw, ok := w.(*responseWriter); ok {
+			return rw
+		}
+		u, ok := w.(interface{ Unwrap() http.ResponseWriter })
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+}
+
+// Middleware assigns each request an ID, reusing a well-formed
+// X-Request-ID from the client, and writes one access log line when the
+// request finishes. 5xx responses are logged at error level, and any cause
+// passed to RecordError is included.
+func Middleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		info := &requestInfo{id: id}
+		r = r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info))
+		rw := &responseWriter{ResponseWriter: w}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+			rw.traceID = sc.TraceID().String()
+		}
+		next.ServeHTTP(rw, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		attrs := []slog.Attr{
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("route", route),
+			slog.Int("status", rw.Status()),
+			slog.Duration("latency", time.Since(start)),
+			slog.Int64("bytes", rw.bytes),
+		}
+		if info.userID != uuid.Nil {
+			attrs = append(attrs, slog.String("user_id", info.userID.String()))
+		}
+
+		if rw.err != nil {
+			attrs = append(attrs, slog.String("error", rw.err.Error()))
+		}
+
+		level := slog.LevelInfo
+		if rw.Status() >= 500 {
+			level = slog.LevelError
+		}
+		logger.LogAttrs(r.Context(), level, "request", attrs...)
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// responseWriter records the status and size of the response. It passes
+// Flush and Hijack through so SSE and WebSocket handlers keep working.
+type responseWriter struct {
+	http.ResponseWriter
+	status  int
+	bytes   int64
+	err     error
+	traceID string
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Status is the code sent, or 200 if the handler never wrote one.
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *responseWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,121 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newTestLogger(t *testing.T) (*slog.Logger, *bytes.Buffer) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, FormatJSON, slog.LevelDebug)
+	assert.NoError(t, err)
+	return logger, &buf
+}
+
+func lastRecord(t *testing.T, buf *bytes.Buffer) map[string]any {
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	var rec map[string]any
+	assert.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &rec))
+	return rec
+}
+
+// wrapper stands in for other middleware between Middleware and the handler.
+type wrapper struct{ http.ResponseWriter }
+
+func (w wrapper) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+func TestMiddlewareLogsRequest(t *testing.T) {
+	logger, buf := newTestLogger(t)
+	userID := uuid.New()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/chirps", func(w http.ResponseWriter, r *http.Request) {
+		SetUserID(r.Context(), userID)
+		slog.New(logger.Handler()).InfoContext(r.Context(), "inside handler")
+		RecordError(wrapper{w}, errors.New("db is down"))
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("oops"))
+	})
+
+	rec := httptest.NewRecorder()
+	Middleware(logger, mux).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/chirps", nil))
+
+	id := rec.Header().Get(RequestIDHeader)
+	assert.NotEmpty(t, id)
+
+	first := strings.SplitN(buf.String(), "\n", 2)[0]
+	assert.Contains(t, first, `"request_id":"`+id+`"`)
+
+	entry := lastRecord(t, buf)
+	assert.Equal(t, "ERROR", entry["level"])
+	assert.Equal(t, id, entry["request_id"])
+	assert.Equal(t, "POST /api/chirps", entry["route"])
+	assert.Equal(t, 500.0, entry["status"])
+	assert.Equal(t, 4.0, entry["bytes"])
+	assert.Equal(t, userID.String(), entry["user_id"])
+	assert.Equal(t, "db is down", entry["error"])
+}
+
+func TestMiddlewareRequestIDs(t *testing.T) {
+	logger, _ := newTestLogger(t)
+	var seen string
+	handler := Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"propagates client ID", "abc-123", true},
+		{"replaces ID with spaces", "abc 123", false},
+		{"replaces overlong ID", strings.Repeat("a", maxRequestIDLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(RequestIDHeader, tt.header)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, seen, rec.Header().Get(RequestIDHeader))
+			if tt.keep {
+				assert.Equal(t, tt.header, seen)
+			} else {
+				assert.NotEqual(t, tt.header, seen)
+				assert.NoError(t, uuid.Validate(seen))
+			}
+		})
+	}
+}
+
+func TestMiddlewareTraceIDs(t *testing.T) {
+	logger, buf := newTestLogger(t)
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	sc := trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+
+	var seen string
+	handler := Middleware(logger, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = TraceID(wrapper{w})
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(trace.ContextWithSpanContext(req.Context(), sc))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Equal(t, traceID.String(), seen)
+	entry := lastRecord(t, buf)
+	assert.Equal(t, traceID.String(), entry["trace_id"])
+	assert.Equal(t, spanID.String(), entry["span_id"])
+}
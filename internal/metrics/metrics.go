@@ -0,0 +1,183 @@
+// Package metrics owns chirpy's Prometheus registry: HTTP request metrics,
+// database pool stats and a handful of business counters.
+package metrics
+
+import (
+	"bufio"
+	"database/sql"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const namespace = "chirpy"
+
+// Login results used as the "result" label of LoginsTotal.
+const (
+	LoginSucceeded = "succeeded"
+	LoginFailed    = "failed"
+)
+
+// Webhook directions used as the "direction" label of WebhookEvents.
+const (
+	WebhookInbound  = "inbound"
+	WebhookOutbound = "outbound"
+)
+
+// Metrics is every collector chirpy exports, registered on one registry.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+
+	FileserverHits prometheus.Counter
+	ChirpsCreated  prometheus.Counter
+	Logins         *prometheus.CounterVec
+	WebhookEvents  *prometheus.CounterVec
+}
+
+// New builds the registry. db may be nil, in which case pool stats are
+// not exported.
+func New(db *sql.DB) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by route pattern and status code.",
+		}, []string{"route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route pattern.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route"}),
+		FileserverHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "fileserver_hits_total",
+			Help:      "Requests served from /app/.",
+		}),
+		ChirpsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "chirps_created_total",
+			Help:      "Chirps created.",
+		}),
+		Logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "logins_total",
+			Help:      "Login attempts by result.",
+		}, []string{"result"}),
+		WebhookEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_events_total",
+			Help:      "Webhook events received from Polka (inbound) or emitted to endpoints (outbound).",
+		}, []string{"direction", "event"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.duration,
+		m.FileserverHits,
+		m.ChirpsCreated,
+		m.Logins,
+		m.WebhookEvents,
+	)
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+	}
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Gather exposes the registry for tests and other readers.
+func (m *Metrics) Gather() ([]*dto.MetricFamily, error) {
+	return m.registry.Gather()
+}
+
+// Hits returns the current value of FileserverHits.
+func (m *Metrics) Hits() int64 {
+	var metric dto.Metric
+	err := m.FileserverHits.Write(&metric)
+	if err != nil {
+		return 0
+	}
+	return int64(metric.GetCounter().GetValue())
+}
+
+// Instrument records the count and latency of every request next serves,
+// labelled with the ServeMux pattern that matched. Requests that matched no
+// pattern share one "unmatched" label so stray paths can't blow up
+// cardinality.
+func (m *Metrics) Instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		// ServeMux fills in r.Pattern on the request it was handed.
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		m.requests.WithLabelValues(route, strconv.Itoa(sw.Status())).Inc()
+		m.duration.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter remembers the status code written through it. It passes
+// Flush and Hijack through so SSE and WebSocket handlers keep working.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Status is the code sent, or 200 if the handler never wrote one.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *statusWriter) Flush() {
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, brw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
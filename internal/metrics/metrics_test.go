@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentLabelsByPattern(t *testing.T) {
+	m := New(nil)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	handler := m.Instrument(mux)
+
+	for _, path := range []string{"/api/chirps/1", "/api/chirps/2", "/nope"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.requests.WithLabelValues("GET /api/chirps/{chirpID}", "404")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("unmatched", "404")))
+	assert.Equal(t, 2, testutil.CollectAndCount(m.duration))
+}
+
+func TestInstrumentPassesFlushThrough(t *testing.T) {
+	m := New(nil)
+	handler := m.Instrument(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := w.(http.Flusher)
+		assert.True(t, ok)
+		_, ok = w.(http.Hijacker)
+		assert.True(t, ok)
+		w.Write([]byte("data: hi\n\n"))
+		http.NewResponseController(w).Flush()
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.requests.WithLabelValues("unmatched", "200")))
+}
+
+func TestHandlerExposesRegistry(t *testing.T) {
+	m := New(nil)
+	m.FileserverHits.Inc()
+	m.FileserverHits.Inc()
+	m.Logins.WithLabelValues(LoginFailed).Inc()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	assert.Equal(t, int64(2), m.Hits())
+	assert.True(t, strings.Contains(body, "chirpy_fileserver_hits_total 2"))
+	assert.True(t, strings.Contains(body, `chirpy_logins_total{result="failed"} 1`))
+}
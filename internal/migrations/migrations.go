@@ -0,0 +1,90 @@
+// Package migrations applies the embedded goose migrations in sql/schema
+// and checks that a database is at the schema version this binary expects.
+// Postgres and SQLite each have their own migration set.
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+	"github.com/willmelton21/chirpy/sql/schema"
+)
+
+// ErrVersionMismatch is wrapped by Check when the database isn't at Latest.
+var ErrVersionMismatch = errors.New("database schema version mismatch")
+
+// Migrator runs migrations against one database.
+type Migrator struct {
+	provider *goose.Provider
+}
+
+// New loads the migrations for driver, which is "postgres" or "sqlite".
+func New(db *sql.DB, driver string) (*Migrator, error) {
+	var provider *goose.Provider
+	var err error
+	switch driver {
+	case "postgres":
+		provider, err = goose.NewProvider(goose.DialectPostgres, db, schema.FS)
+	case "sqlite":
+		// SQLite shipped without 006-008 at first, so databases made then
+		// are past them and need them applied out of order.
+		provider, err = goose.NewProvider(goose.DialectSQLite3, db, schema.SQLite, goose.WithAllowOutofOrder(true))
+	default:
+		return nil, fmt.Errorf("no migrations for driver %q", driver)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	return &Migrator{provider: provider}, nil
+}
+
+// Latest is the newest migration embedded in the binary.
+func (m *Migrator) Latest() int64 {
+	sources := m.provider.ListSources()
+	return sources[len(sources)-1].Version
+}
+
+// Version is the migration the database is currently at.
+func (m *Migrator) Version(ctx context.Context) (int64, error) {
+	current, _, err := m.provider.GetVersions(ctx)
+	return current, err
+}
+
+// Up applies every pending migration.
+func (m *Migrator) Up(ctx context.Context) ([]*goose.MigrationResult, error) {
+	return m.provider.Up(ctx)
+}
+
+// Down rolls back the most recent migration.
+func (m *Migrator) Down(ctx context.Context) (*goose.MigrationResult, error) {
+	return m.provider.Down(ctx)
+}
+
+// Status lists every migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]*goose.MigrationStatus, error) {
+	return m.provider.Status(ctx)
+}
+
+// Check returns an error wrapping ErrVersionMismatch unless the database is
+// exactly at Latest with nothing left to apply. Its signature fits a
+// readiness check.
+func (m *Migrator) Check(ctx context.Context) error {
+	current, err := m.Version(ctx)
+	if err != nil {
+		return fmt.Errorf("reading schema version: %w", err)
+	}
+	if current != m.Latest() {
+		return fmt.Errorf("%w: database is at %d, binary expects %d", ErrVersionMismatch, current, m.Latest())
+	}
+	pending, err := m.provider.HasPending(ctx)
+	if err != nil {
+		return fmt.Errorf("checking for pending migrations: %w", err)
+	}
+	if pending {
+		return fmt.Errorf("%w: database is at %d but is missing older migrations", ErrVersionMismatch, current)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package migrations
+
+import (
+	"context"
+	"database/sql"
+	"io/fs"
+	"path/filepath"
+	"testing"
+
+	_ "github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/sql/schema"
+	_ "modernc.org/sqlite"
+)
+
+func TestLatestMatchesEmbeddedFiles(t *testing.T) {
+	files, err := fs.Glob(schema.FS, "*.sql")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	// sql.Open doesn't connect, so this needs no database.
+	db, err := sql.Open("postgres", "")
+	assert.NoError(t, err)
+	m, err := New(db, "postgres")
+	assert.NoError(t, err)
+
+	assert.Equal(t, int64(len(files)), m.Latest())
+}
+
+func TestSQLiteUpDownCheck(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chirpy.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, "sqlite")
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Check(ctx), ErrVersionMismatch)
+
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.NoError(t, m.Check(ctx))
+
+	_, err = m.Down(ctx)
+	require.NoError(t, err)
+	assert.ErrorIs(t, m.Check(ctx), ErrVersionMismatch)
+}
+
+// TestSQLiteAppliesMissingMigrations covers databases created before the
+// SQLite set had 006-008, which are at the latest version without them.
+func TestSQLiteAppliesMissingMigrations(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "chirpy.db"))
+	require.NoError(t, err)
+	defer db.Close()
+
+	m, err := New(db, "sqlite")
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	for _, stmt := range []string{
+		"DROP TABLE jobs",
+		"DROP TABLE notification_preferences",
+		"DROP TABLE notifications",
+		"DROP TABLE webhook_delivery_attempts",
+		"DROP TABLE webhook_deliveries",
+		"DROP TABLE webhook_endpoints",
+		"DELETE FROM goose_db_version WHERE version_id IN (6, 7, 8)",
+	} {
+		_, err = db.ExecContext(ctx, stmt)
+		require.NoError(t, err, stmt)
+	}
+	assert.ErrorIs(t, m.Check(ctx), ErrVersionMismatch)
+
+	results, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+	assert.NoError(t, m.Check(ctx))
+	_, err = db.ExecContext(ctx, "SELECT COUNT(*) FROM jobs")
+	assert.NoError(t, err)
+}
@@ -0,0 +1,263 @@
+// Package notify turns domain events into per-user in-app notifications.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// Type is the kind of a notification. Users can switch each one off.
+type Type string
+
+const (
+	TypeFollow    Type = "follow"
+	TypeLike      Type = "like"
+	TypeReply     Type = "reply"
+	TypeMention   Type = "mention"
+	TypeChirpyRed Type = "chirpy_red"
+)
+
+// Types lists every notification type, in the order preferences are shown.
+// Chirps can't be liked or replied to yet, so nothing sends TypeLike or
+// TypeReply; users can still set their preference for them.
+var Types = []Type{TypeFollow, TypeLike, TypeReply, TypeMention, TypeChirpyRed}
+
+// IsKnownType reports whether name is one of Types.
+func IsKnownType(name string) bool {
+	for _, t := range Types {
+		if string(t) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// maxMentions caps how many users a single chirp can notify.
+const maxMentions = 10
+
+// PublishedType is the pubsub message type pushed to live clients.
+const PublishedType = "notification.created"
+
+// Notification is a stored notification as returned to clients.
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      Type       `json:"type"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+	Read      bool       `json:"read"`
+}
+
+// FromDB converts a database row to its API shape.
+func FromDB(n database.Notification) Notification {
+	out := Notification{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      Type(n.Type),
+		Read:      n.ReadAt.Valid,
+	}
+	if n.ActorID.Valid {
+		out.ActorID = &n.ActorID.UUID
+	}
+	if n.ChirpID.Valid {
+		out.ChirpID = &n.ChirpID.UUID
+	}
+	return out
+}
+
+// Domain events handlers pass to Emit.
+type (
+	// ChirpCreated notifies everyone mentioned as @<email> in the body.
+	ChirpCreated struct {
+		ChirpID  uuid.UUID
+		AuthorID uuid.UUID
+		Body     string
+	}
+	// UserUpgraded confirms a Chirpy Red upgrade to the user.
+	UserUpgraded struct {
+		UserID uuid.UUID
+	}
+	// UserFollowed tells the followee about a new follower or, for private
+	// accounts, a new follow request.
+	UserFollowed struct {
+		FollowerID uuid.UUID
+		FolloweeID uuid.UUID
+	}
+)
+
+// DB is the part of store.Store the service uses.
+type DB interface {
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error)
+	CreateNotification(ctx context.Context, userID uuid.UUID, typ string, actorID, chirpID uuid.UUID) (database.Notification, error)
+	ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]database.Notification, error)
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error)
+	GetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string) (bool, error)
+	ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error)
+	SetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string, enabled bool) error
+}
+
+// Service records notifications and pushes them to connected clients.
+type Service struct {
+	db     DB
+	broker *pubsub.Broker
+}
+
+// NewService -
+func NewService(db DB, broker *pubsub.Broker) *Service {
+	return &Service{db: db, broker: broker}
+}
+
+// List returns up to limit of userID's notifications, newest first.
+func (s *Service) List(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]Notification, error) {
+	stored, err := s.db.ListNotifications(ctx, userID, unreadOnly, limit)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Notification, 0, len(stored))
+	for _, n := range stored {
+		out = append(out, FromDB(n))
+	}
+	return out, nil
+}
+
+// UnreadCount counts userID's unread notifications.
+func (s *Service) UnreadCount(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return s.db.CountUnreadNotifications(ctx, userID)
+}
+
+// MarkRead marks the listed notifications of userID's read, or all of them
+// if ids is nil, and returns how many were unread.
+func (s *Service) MarkRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if ids == nil {
+		return s.db.MarkAllNotificationsRead(ctx, userID)
+	}
+	return s.db.MarkNotificationsRead(ctx, userID, ids)
+}
+
+// Preferences returns whether each type is on for userID. Types are on
+// until turned off.
+func (s *Service) Preferences(ctx context.Context, userID uuid.UUID) (map[Type]bool, error) {
+	stored, err := s.db.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs := make(map[Type]bool, len(Types))
+	for _, t := range Types {
+		prefs[t] = true
+	}
+	for _, p := range stored {
+		// Skip settings for types that have since been removed.
+		if IsKnownType(p.Type) {
+			prefs[Type(p.Type)] = p.Enabled
+		}
+	}
+	return prefs, nil
+}
+
+// SetPreference turns t on or off for userID.
+func (s *Service) SetPreference(ctx context.Context, userID uuid.UUID, t Type, enabled bool) error {
+	return s.db.SetNotificationPreference(ctx, userID, string(t), enabled)
+}
+
+// Emit works out who cares about event and notifies them.
+func (s *Service) Emit(ctx context.Context, event any) error {
+	switch e := event.(type) {
+	case ChirpCreated:
+		return s.chirpCreated(ctx, e)
+	case UserUpgraded:
+		return s.notify(ctx, e.UserID, TypeChirpyRed, uuid.Nil, uuid.Nil)
+	case UserFollowed:
+		return s.notify(ctx, e.FolloweeID, TypeFollow, e.FollowerID, uuid.Nil)
+	default:
+		return fmt.Errorf("notify: unknown event %T", event)
+	}
+}
+
+func (s *Service) chirpCreated(ctx context.Context, e ChirpCreated) error {
+	var errs []error
+	for _, email := range Mentions(e.Body) {
+		user, err := s.db.GetUserByEmail(ctx, email)
+		if errors.Is(err, store.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		// Only notify users who can see the chirp: nobody across a block,
+		// and only approved followers of a private account.
+		visible, err := s.db.CanSeeChirps(ctx, user.ID, e.AuthorID)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !visible {
+			continue
+		}
+		err = s.notify(ctx, user.ID, TypeMention, e.AuthorID, e.ChirpID)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// notify stores one notification unless the recipient is the actor or has
+// turned the type off. A zero actorID or chirpID is stored as NULL.
+func (s *Service) notify(ctx context.Context, userID uuid.UUID, t Type, actorID, chirpID uuid.UUID) error {
+	if actorID == userID {
+		return nil
+	}
+
+	enabled, err := s.db.GetNotificationPreference(ctx, userID, string(t))
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	if err == nil && !enabled {
+		return nil
+	}
+
+	n, err := s.db.CreateNotification(ctx, userID, string(t), actorID, chirpID)
+	if err != nil {
+		return err
+	}
+
+	if s.broker != nil {
+		_, err = s.broker.Publish(pubsub.NotificationTopic(userID), PublishedType, FromDB(n))
+	}
+	return err
+}
+
+// Mentions returns the distinct addresses mentioned as @<email> in body.
+func Mentions(body string) []string {
+	var out []string
+	seen := map[string]bool{}
+	for _, word := range strings.Fields(body) {
+		email, ok := strings.CutPrefix(word, "@")
+		if !ok {
+			continue
+		}
+		email = strings.TrimRight(email, ".,!?:;)")
+		if !strings.Contains(email, "@") || seen[email] {
+			continue
+		}
+		seen[email] = true
+		out = append(out, email)
+		if len(out) == maxMentions {
+			break
+		}
+	}
+	return out
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+func TestMentions(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{body: "no mentions here", want: nil},
+		{body: "hi @alice@example.com!", want: []string{"alice@example.com"}},
+		{body: "@bob@example.com and @bob@example.com, again", want: []string{"bob@example.com"}},
+		{body: "@handle isn't an email", want: nil},
+		{body: "email@example.com without the at sign", want: nil},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Mentions(tt.body), tt.body)
+	}
+}
+
+func newTestService(t *testing.T) (*Service, store.Store, *pubsub.Broker) {
+	t.Helper()
+	st := store.NewMemory()
+	broker := pubsub.NewBroker(16)
+	return NewService(st, broker), st, broker
+}
+
+func addUser(t *testing.T, st store.Store, email string) uuid.UUID {
+	t.Helper()
+	user, err := st.CreateUser(context.Background(), email, "hash")
+	require.NoError(t, err)
+	return user.ID
+}
+
+func TestEmitMentions(t *testing.T) {
+	s, st, broker := newTestService(t)
+	ctx := context.Background()
+	alice := addUser(t, st, "alice@example.com")
+	bob := addUser(t, st, "bob@example.com")
+	carol := addUser(t, st, "carol@example.com")
+	require.NoError(t, st.BlockUser(ctx, carol, alice))
+	sub := broker.Subscribe(pubsub.Topic(pubsub.NotificationTopic(bob)), 0, 16)
+	defer broker.Unsubscribe(sub)
+
+	// Bob can see the chirp, Carol can't, Alice wrote it and nobody is
+	// unknown.
+	body := "@alice@example.com @bob@example.com @carol@example.com @nobody@example.com"
+	chirp, err := st.CreateChirp(ctx, alice, body)
+	require.NoError(t, err)
+	chirpID := chirp.ID
+	require.NoError(t, s.Emit(ctx, ChirpCreated{ChirpID: chirpID, AuthorID: alice, Body: body}))
+
+	got, err := s.List(ctx, bob, false, 10)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, TypeMention, got[0].Type)
+	assert.Equal(t, &alice, got[0].ActorID)
+	assert.Equal(t, &chirpID, got[0].ChirpID)
+	for _, id := range []uuid.UUID{alice, carol} {
+		got, err := s.List(ctx, id, false, 10)
+		require.NoError(t, err)
+		assert.Empty(t, got)
+	}
+
+	// Bob's connected clients hear about it too.
+	msg := <-sub.C
+	assert.Equal(t, PublishedType, msg.Type)
+	var pushed Notification
+	require.NoError(t, json.Unmarshal(msg.Data, &pushed))
+	assert.Equal(t, got[0].ID, pushed.ID)
+}
+
+func TestEmitRespectsPreferences(t *testing.T) {
+	s, st, _ := newTestService(t)
+	ctx := context.Background()
+	alice := addUser(t, st, "alice@example.com")
+	bob := addUser(t, st, "bob@example.com")
+
+	require.NoError(t, s.SetPreference(ctx, alice, TypeFollow, false))
+	require.NoError(t, s.Emit(ctx, UserFollowed{FollowerID: bob, FolloweeID: alice}))
+	require.NoError(t, s.Emit(ctx, UserUpgraded{UserID: alice}))
+
+	got, err := s.List(ctx, alice, false, 10)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, TypeChirpyRed, got[0].Type)
+
+	prefs, err := s.Preferences(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, map[Type]bool{TypeFollow: false, TypeLike: true, TypeReply: true, TypeMention: true, TypeChirpyRed: true}, prefs)
+
+	assert.Error(t, s.Emit(ctx, "chirp liked"))
+}
+
+func TestPreferencesSkipUnknownTypes(t *testing.T) {
+	s, st, _ := newTestService(t)
+	ctx := context.Background()
+	alice := addUser(t, st, "alice@example.com")
+
+	// A setting stored for a type that has since been removed.
+	require.NoError(t, s.SetPreference(ctx, alice, "digest", false))
+	require.NoError(t, s.SetPreference(ctx, alice, TypeLike, false))
+	prefs, err := s.Preferences(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, map[Type]bool{TypeFollow: true, TypeLike: false, TypeReply: true, TypeMention: true, TypeChirpyRed: true}, prefs)
+	assert.False(t, IsKnownType("digest"))
+	assert.True(t, IsKnownType("reply"))
+}
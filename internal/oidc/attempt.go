@@ -0,0 +1,115 @@
+package oidc
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/auth"
+)
+
+// AttemptTTL is how long a user has to finish signing in at the provider.
+const AttemptTTL = 10 * time.Minute
+
+// attemptIssuer keeps sealed attempts from being mistaken for access
+// tokens, which are signed with the same secret.
+const attemptIssuer = "chirpy-oidc"
+
+// Attempt is one sign-in in progress. It rides in a cookie between
+// sending the browser to the provider and the provider sending it back,
+// so the callback can check it is finishing the sign-in this browser
+// started.
+type Attempt struct {
+	Provider     string
+	State        string
+	Nonce        string
+	CodeVerifier string
+	// LinkUserID is set when a signed-in user is linking the provider to
+	// their account rather than signing in with it.
+	LinkUserID uuid.UUID
+	ExpiresAt  time.Time
+}
+
+// NewAttempt starts a sign-in with provider, with fresh random state,
+// nonce and PKCE verifier.
+func NewAttempt(provider string, linkUserID uuid.UUID) (Attempt, error) {
+	a := Attempt{Provider: provider, LinkUserID: linkUserID, ExpiresAt: time.Now().Add(AttemptTTL)}
+	for _, dst := range []*string{&a.State, &a.Nonce, &a.CodeVerifier} {
+		// MakeRefreshToken is 256 random bits, hex encoded, which also
+		// makes a valid PKCE verifier.
+		s, err := auth.MakeRefreshToken()
+		if err != nil {
+			return Attempt{}, err
+		}
+		*dst = s
+	}
+	return a, nil
+}
+
+// CheckState reports whether state is the one the provider was sent.
+func (a Attempt) CheckState(state string) bool {
+	return state != "" && subtle.ConstantTimeCompare([]byte(a.State), []byte(state)) == 1
+}
+
+type attemptClaims struct {
+	jwt.RegisteredClaims
+	Provider     string `json:"provider"`
+	State        string `json:"state"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+	LinkUserID   string `json:"link_user_id,omitempty"`
+}
+
+// Seal signs a so it can be handed to the browser. The browser can read
+// it but not change it; none of it is useful without the provider's code.
+func (a Attempt) Seal(secret string) (string, error) {
+	claims := attemptClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    attemptIssuer,
+			ExpiresAt: jwt.NewNumericDate(a.ExpiresAt),
+		},
+		Provider:     a.Provider,
+		State:        a.State,
+		Nonce:        a.Nonce,
+		CodeVerifier: a.CodeVerifier,
+	}
+	if a.LinkUserID != uuid.Nil {
+		claims.LinkUserID = a.LinkUserID.String()
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+}
+
+// OpenAttempt checks and decodes an attempt sealed with secret.
+func OpenAttempt(sealed, secret string) (Attempt, error) {
+	claims := attemptClaims{}
+	_, err := jwt.ParseWithClaims(sealed, &claims,
+		func(token *jwt.Token) (any, error) { return []byte(secret), nil },
+		jwt.WithValidMethods([]string{"HS256"}),
+		jwt.WithIssuer(attemptIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return Attempt{}, fmt.Errorf("invalid sign-in attempt: %w", err)
+	}
+	if claims.State == "" || claims.Nonce == "" || claims.CodeVerifier == "" {
+		return Attempt{}, errors.New("invalid sign-in attempt: missing fields")
+	}
+
+	a := Attempt{
+		Provider:     claims.Provider,
+		State:        claims.State,
+		Nonce:        claims.Nonce,
+		CodeVerifier: claims.CodeVerifier,
+		ExpiresAt:    claims.ExpiresAt.Time,
+	}
+	if claims.LinkUserID != "" {
+		a.LinkUserID, err = uuid.Parse(claims.LinkUserID)
+		if err != nil {
+			return Attempt{}, fmt.Errorf("invalid sign-in attempt: %w", err)
+		}
+	}
+	return a, nil
+}
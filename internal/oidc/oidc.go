@@ -0,0 +1,344 @@
+// Package oidc signs users in with external OpenID Connect providers.
+//
+// It implements the part of the protocol Chirpy needs: discovery, the
+// authorization-code flow with PKCE, and verifying the ID token against
+// the provider's published keys (JWKS). The provider's user info endpoint
+// and access tokens are never used; the ID token says who the user is.
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/willmelton21/chirpy/internal/auth"
+)
+
+var (
+	// ErrUnavailable means the provider couldn't be reached or answered
+	// with a server error. Trying again later may work.
+	ErrUnavailable = errors.New("oidc: provider unavailable")
+	// ErrRejected means the provider refused the code, or its ID token
+	// failed verification.
+	ErrRejected = errors.New("oidc: sign-in rejected")
+)
+
+// keyRefreshInterval limits how often an unknown key ID makes the
+// provider's JWKS be fetched again, so forged tokens can't make Chirpy
+// hammer the provider.
+const keyRefreshInterval = time.Minute
+
+// Config is one provider's client registration.
+type Config struct {
+	// Issuer is the provider's issuer URL. Its discovery document is at
+	// Issuer + "/.well-known/openid-configuration".
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is Chirpy's callback, as registered with the provider.
+	RedirectURL string
+	// HTTPClient defaults to a client with a 10 second timeout.
+	HTTPClient *http.Client
+}
+
+// Claims is who an ID token says the user is.
+type Claims struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Provider is an OpenID Connect provider. Its discovery document and keys
+// are fetched on first use and cached.
+type Provider struct {
+	Name string
+	cfg  Config
+
+	mu        sync.Mutex
+	meta      *metadata
+	keys      map[string]crypto.PublicKey
+	keysFetch time.Time
+}
+
+type metadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// New returns the provider called name.
+func New(name string, cfg Config) *Provider {
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	cfg.Issuer = strings.TrimSuffix(cfg.Issuer, "/")
+	return &Provider{Name: name, cfg: cfg}
+}
+
+// AuthCodeURL returns where to send the user's browser to sign in for a.
+func (p *Provider) AuthCodeURL(ctx context.Context, a Attempt) (string, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: bad authorization_endpoint: %w", ErrUnavailable, err)
+	}
+	query := u.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", "openid email")
+	query.Set("state", a.State)
+	query.Set("nonce", a.Nonce)
+	query.Set("code_challenge", auth.PKCEChallenge(a.CodeVerifier))
+	query.Set("code_challenge_method", auth.PKCEMethodS256)
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// Exchange trades the code the provider redirected back with for an ID
+// token, and returns its claims once it is verified for a.
+func (p *Provider) Exchange(ctx context.Context, code string, a Attempt) (Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {a.CodeVerifier},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+
+	var tokens struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(req, &tokens)
+	if err != nil {
+		return Claims{}, err
+	}
+	if status != http.StatusOK {
+		return Claims{}, fmt.Errorf("%w: token endpoint returned %d: %s %s", ErrRejected, status, tokens.Error, tokens.ErrorDescription)
+	}
+	if tokens.IDToken == "" {
+		return Claims{}, fmt.Errorf("%w: token response has no id_token", ErrRejected)
+	}
+	return p.Verify(ctx, tokens.IDToken, a.Nonce)
+}
+
+// idClaims are the ID token claims Chirpy reads.
+type idClaims struct {
+	jwt.RegisteredClaims
+	Nonce         string `json:"nonce"`
+	Email         string `json:"email"`
+	EmailVerified any    `json:"email_verified"`
+}
+
+// Verify checks that rawIDToken was signed by the provider for this
+// client, hasn't expired and carries nonce.
+func (p *Provider) Verify(ctx context.Context, rawIDToken, nonce string) (Claims, error) {
+	meta, err := p.metadata(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	claims := idClaims{}
+	_, err = jwt.ParseWithClaims(rawIDToken, &claims,
+		func(token *jwt.Token) (any, error) {
+			kid, _ := token.Header["kid"].(string)
+			return p.key(ctx, kid)
+		},
+		jwt.WithValidMethods([]string{"RS256", "ES256"}),
+		jwt.WithIssuer(meta.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if errors.Is(err, ErrUnavailable) {
+		return Claims{}, err
+	}
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %w", ErrRejected, err)
+	}
+	if claims.Nonce == "" || claims.Nonce != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce doesn't match", ErrRejected)
+	}
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: no subject", ErrRejected)
+	}
+
+	// Some providers send email_verified as a string.
+	verified := claims.EmailVerified == true || claims.EmailVerified == "true"
+	return Claims{Subject: claims.Subject, Email: claims.Email, EmailVerified: verified}, nil
+}
+
+func (p *Provider) metadata(ctx context.Context) (*metadata, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.meta != nil {
+		return p.meta, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.cfg.Issuer+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	var meta metadata
+	status, err := p.do(req, &meta)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery returned %d", ErrUnavailable, status)
+	}
+	// The issuer must match exactly, or tokens from a provider that
+	// impersonates another could be accepted.
+	if meta.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q doesn't match %q", ErrUnavailable, meta.Issuer, p.cfg.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is missing endpoints", ErrUnavailable)
+	}
+	p.meta = &meta
+	return p.meta, nil
+}
+
+// key returns the provider's signing key with ID kid, fetching the JWKS
+// again if the provider may have rotated its keys.
+func (p *Provider) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	if time.Since(p.keysFetch) < keyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.meta.JWKSURI, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := p.do(req, &set)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: JWKS returned %d", ErrUnavailable, status)
+	}
+
+	p.keys = make(map[string]crypto.PublicKey)
+	p.keysFetch = time.Now()
+	for _, k := range set.Keys {
+		// Keys for encryption, or of types Chirpy doesn't verify, are
+		// skipped rather than failing the whole set.
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		if key, err := k.publicKey(); err == nil {
+			p.keys[k.Kid] = key
+		}
+	}
+
+	if key, ok := p.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// do sends req and decodes its JSON body into out. Only failures to reach
+// the provider or 5xx responses are errors; other statuses are returned
+// for the caller to judge.
+func (p *Provider) do(req *http.Request, out any) (int, error) {
+	req.Header.Set("Accept", "application/json")
+	resp, err := p.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return resp.StatusCode, fmt.Errorf("%w: %s returned %d", ErrUnavailable, req.URL, resp.StatusCode)
+	}
+
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	if err := json.Unmarshal(dat, out); err != nil && resp.StatusCode == http.StatusOK {
+		return 0, fmt.Errorf("%w: %s returned invalid JSON: %w", ErrUnavailable, req.URL, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// jwk is a JSON Web Key from RFC 7517, limited to the RSA and P-256 keys
+// that RS256 and ES256 use.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+	}
+}
@@ -0,0 +1,153 @@
+package oidc_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/oidc"
+	"github.com/willmelton21/chirpy/internal/oidc/oidctest"
+)
+
+const (
+	clientID     = "chirpy"
+	clientSecret = "provider-secret"
+	redirectURL  = "http://chirpy.test/api/auth/oidc/test/callback"
+)
+
+func newProvider(t *testing.T) (*oidc.Provider, *oidctest.Server) {
+	t.Helper()
+	server := oidctest.NewServer(t, clientID, clientSecret)
+	p := oidc.New("test", oidc.Config{
+		Issuer:       server.Issuer(),
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+	})
+	return p, server
+}
+
+// signIn runs the browser's side of the flow and returns the code the
+// provider redirected back with.
+func signIn(t *testing.T, p *oidc.Provider, a oidc.Attempt) string {
+	t.Helper()
+	authURL, err := p.AuthCodeURL(context.Background(), a)
+	require.NoError(t, err)
+
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := browser.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	back, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, redirectURL, back.Scheme+"://"+back.Host+back.Path)
+	require.True(t, a.CheckState(back.Query().Get("state")))
+	return back.Query().Get("code")
+}
+
+func TestExchange(t *testing.T) {
+	p, server := newProvider(t)
+	server.SignInAs(oidctest.User{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true})
+
+	a, err := oidc.NewAttempt("test", uuid.Nil)
+	require.NoError(t, err)
+	code := signIn(t, p, a)
+
+	claims, err := p.Exchange(context.Background(), code, a)
+	require.NoError(t, err)
+	assert.Equal(t, oidc.Claims{Subject: "alice-1", Email: "alice@example.com", EmailVerified: true}, claims)
+
+	// Codes are single use.
+	_, err = p.Exchange(context.Background(), code, a)
+	assert.ErrorIs(t, err, oidc.ErrRejected)
+
+	// A code is useless without the verifier it was issued for.
+	code = signIn(t, p, a)
+	other, err := oidc.NewAttempt("test", uuid.Nil)
+	require.NoError(t, err)
+	_, err = p.Exchange(context.Background(), code, other)
+	assert.ErrorIs(t, err, oidc.ErrRejected)
+}
+
+func TestVerify(t *testing.T) {
+	p, server := newProvider(t)
+	ctx := context.Background()
+	const nonce = "the-nonce"
+
+	claims, err := p.Verify(ctx, server.IDToken(jwt.MapClaims{"sub": "bob", "nonce": nonce, "email_verified": "true"}), nonce)
+	require.NoError(t, err)
+	assert.Equal(t, "bob", claims.Subject)
+	assert.True(t, claims.EmailVerified)
+
+	unknownKey := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "bob", "nonce": nonce})
+	unknownKey.Header["kid"] = "other"
+	forged, err := unknownKey.SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	cases := map[string]string{
+		"wrong nonce":    server.IDToken(jwt.MapClaims{"sub": "bob", "nonce": "other"}),
+		"no nonce":       server.IDToken(jwt.MapClaims{"sub": "bob"}),
+		"wrong audience": server.IDToken(jwt.MapClaims{"sub": "bob", "nonce": nonce, "aud": "another-app"}),
+		"wrong issuer":   server.IDToken(jwt.MapClaims{"sub": "bob", "nonce": nonce, "iss": "https://evil.example.com"}),
+		"expired":        server.IDToken(jwt.MapClaims{"sub": "bob", "nonce": nonce, "exp": time.Now().Add(-time.Minute).Unix()}),
+		"no subject":     server.IDToken(jwt.MapClaims{"nonce": nonce}),
+		"symmetric alg":  forged,
+		"garbage":        "not.a.jwt",
+	}
+	for name, token := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := p.Verify(ctx, token, nonce)
+			assert.ErrorIs(t, err, oidc.ErrRejected)
+		})
+	}
+}
+
+func TestUnavailable(t *testing.T) {
+	server := oidctest.NewServer(t, clientID, clientSecret)
+	issuer := server.Issuer()
+	server.Close()
+
+	p := oidc.New("test", oidc.Config{Issuer: issuer, ClientID: clientID, RedirectURL: redirectURL})
+	a, err := oidc.NewAttempt("test", uuid.Nil)
+	require.NoError(t, err)
+	_, err = p.AuthCodeURL(context.Background(), a)
+	assert.ErrorIs(t, err, oidc.ErrUnavailable)
+}
+
+func TestAttemptSeal(t *testing.T) {
+	const secret = "a-secret-that-is-long-enough-for-hs256"
+	link := uuid.New()
+	a, err := oidc.NewAttempt("test", link)
+	require.NoError(t, err)
+
+	sealed, err := a.Seal(secret)
+	require.NoError(t, err)
+	opened, err := oidc.OpenAttempt(sealed, secret)
+	require.NoError(t, err)
+	assert.Equal(t, a.Provider, opened.Provider)
+	assert.Equal(t, a.State, opened.State)
+	assert.Equal(t, a.Nonce, opened.Nonce)
+	assert.Equal(t, a.CodeVerifier, opened.CodeVerifier)
+	assert.Equal(t, link, opened.LinkUserID)
+
+	assert.True(t, opened.CheckState(a.State))
+	assert.False(t, opened.CheckState(""))
+	assert.False(t, opened.CheckState("other"))
+
+	_, err = oidc.OpenAttempt(sealed, "a-different-secret-of-the-same-size")
+	assert.Error(t, err)
+
+	a.ExpiresAt = time.Now().Add(-time.Second)
+	expired, err := a.Seal(secret)
+	require.NoError(t, err)
+	_, err = oidc.OpenAttempt(expired, secret)
+	assert.Error(t, err)
+}
@@ -0,0 +1,210 @@
+// Package oidctest runs a minimal OpenID Connect provider for tests.
+//
+// It implements discovery, JWKS, and the authorization-code flow with
+// PKCE. The authorization endpoint doesn't show a login page: it signs in
+// whoever SignInAs last named and redirects straight back.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/willmelton21/chirpy/internal/auth"
+)
+
+// KeyID is the ID of the key the server signs ID tokens with.
+const KeyID = "oidctest-key"
+
+// User is who the provider signs in.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+}
+
+// Server is a running provider.
+type Server struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	user  User
+	codes map[string]grant
+}
+
+// grant is what the provider remembers about a code it issued.
+type grant struct {
+	user        User
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+// NewServer starts a provider with one registered client. It is closed
+// when the test ends.
+func NewServer(t testing.TB, clientID, clientSecret string) *Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Server{ClientID: clientID, ClientSecret: clientSecret, key: key, codes: make(map[string]grant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /jwks", s.jwks)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Issuer is the provider's issuer URL.
+func (s *Server) Issuer() string {
+	return s.URL
+}
+
+// SignInAs makes the authorization endpoint sign in u.
+func (s *Server) SignInAs(u User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = u
+}
+
+// IDToken signs claims with the provider's key, filling in the issuer,
+// audience and lifetime unless claims sets them. Tests use it to make
+// tokens the provider would never issue.
+func (s *Server) IDToken(claims jwt.MapClaims) string {
+	full := jwt.MapClaims{
+		"iss": s.Issuer(),
+		"aud": s.ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		full[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, full)
+	token.Header["kid"] = KeyID
+	signed, err := token.SignedString(s.key)
+	if err != nil {
+		panic(err)
+	}
+	return signed
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{
+		"issuer":                 s.Issuer(),
+		"authorization_endpoint": s.URL + "/authorize",
+		"token_endpoint":         s.URL + "/token",
+		"jwks_uri":               s.URL + "/jwks",
+	})
+}
+
+func (s *Server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": KeyID,
+			"use": "sig",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}},
+	})
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if query.Get("client_id") != s.ClientID || query.Get("response_type") != "code" ||
+		query.Get("code_challenge_method") != auth.PKCEMethodS256 || query.Get("code_challenge") == "" {
+		http.Error(w, "bad authorization request", http.StatusBadRequest)
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	s.codes[code] = grant{
+		user:        s.user,
+		clientID:    query.Get("client_id"),
+		redirectURI: query.Get("redirect_uri"),
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+	}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "bad redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", query.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	clientID, clientSecret, ok := r.BasicAuth()
+	if ok {
+		clientID, _ = url.QueryUnescape(clientID)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+	}
+	if clientID != s.ClientID || clientSecret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	code := r.PostFormValue("code")
+	s.mu.Lock()
+	g, ok := s.codes[code]
+	delete(s.codes, code)
+	s.mu.Unlock()
+
+	if !ok || r.PostFormValue("grant_type") != "authorization_code" ||
+		r.PostFormValue("redirect_uri") != g.redirectURI ||
+		!auth.VerifyPKCE(r.PostFormValue("code_verifier"), g.challenge) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": "unused",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token": s.IDToken(jwt.MapClaims{
+			"sub":            g.user.Subject,
+			"email":          g.user.Email,
+			"email_verified": g.user.EmailVerified,
+			"nonce":          g.nonce,
+		}),
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,134 @@
+// Package pubsub is the in-process fan-out used by the streaming endpoints.
+package pubsub
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Message is one published event. IDs increase monotonically for the life
+// of the process so clients can resume with Last-Event-ID.
+type Message struct {
+	ID    uint64
+	Topic string
+	Type  string
+	Data  json.RawMessage
+}
+
+// Filter decides which topics a subscription receives.
+type Filter func(topic string) bool
+
+// Subscription receives matching messages on C until it is closed, either by
+// Unsubscribe or because the broker dropped it for falling behind.
+type Subscription struct {
+	C <-chan Message
+
+	ch      chan Message
+	filter  Filter
+	dropped bool
+}
+
+// Dropped reports whether the broker closed the subscription because its
+// buffer filled up. Only meaningful once C has been closed.
+func (s *Subscription) Dropped() bool {
+	return s.dropped
+}
+
+// Broker fans published messages out to subscribers without ever blocking
+// the publisher: a subscriber whose buffer is full is dropped instead.
+type Broker struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Message
+	histCap int
+	subs    map[*Subscription]struct{}
+}
+
+// NewBroker returns a broker that keeps the last historySize messages for
+// resuming subscribers.
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		histCap: historySize,
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish marshals data once and delivers it to every matching subscriber.
+func (b *Broker) Publish(topic, msgType string, data any) (Message, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.nextID++
+	msg := Message{ID: b.nextID, Topic: topic, Type: msgType, Data: raw}
+
+	if b.histCap > 0 {
+		if len(b.history) == b.histCap {
+			copy(b.history, b.history[1:])
+			b.history = b.history[:len(b.history)-1]
+		}
+		b.history = append(b.history, msg)
+	}
+
+	for sub := range b.subs {
+		if !sub.filter(topic) {
+			continue
+		}
+		select {
+		case sub.ch <- msg:
+		default:
+			b.drop(sub)
+		}
+	}
+	return msg, nil
+}
+
+// Subscribe registers a subscription with room for buffer pending messages.
+// When lastID is non-zero, retained messages after it that match filter are
+// queued first, so a reconnecting client doesn't miss anything published
+// while it was away (as long as it is still in the history window).
+func (b *Broker) Subscribe(filter Filter, lastID uint64, buffer int) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var backlog []Message
+	if lastID > 0 {
+		for _, msg := range b.history {
+			if msg.ID > lastID && filter(msg.Topic) {
+				backlog = append(backlog, msg)
+			}
+		}
+	}
+
+	ch := make(chan Message, buffer+len(backlog))
+	for _, msg := range backlog {
+		ch <- msg
+	}
+
+	sub := &Subscription{C: ch, ch: ch, filter: filter}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Unsubscribe removes sub and closes its channel. It is safe to call more
+// than once and after the broker has dropped sub.
+func (b *Broker) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// drop must be called with b.mu held.
+func (b *Broker) drop(sub *Subscription) {
+	sub.dropped = true
+	delete(b.subs, sub)
+	close(sub.ch)
+}
@@ -0,0 +1,83 @@
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishFiltersByTopic(t *testing.T) {
+	b := NewBroker(10)
+	author := uuid.New()
+
+	all := b.Subscribe(AllChirps, 0, 4)
+	one := b.Subscribe(Topic(ChirpTopic(author)), 0, 4)
+
+	_, err := b.Publish(ChirpTopic(uuid.New()), "chirp.created", "other")
+	assert.NoError(t, err)
+	_, err = b.Publish(ChirpTopic(author), "chirp.created", "mine")
+	assert.NoError(t, err)
+
+	assert.Len(t, all.C, 2)
+	assert.Len(t, one.C, 1)
+	msg := <-one.C
+	assert.Equal(t, `"mine"`, string(msg.Data))
+}
+
+func TestSubscribeResumesFromLastID(t *testing.T) {
+	b := NewBroker(3)
+	for i := 0; i < 5; i++ {
+		_, err := b.Publish("chirps/x", "chirp.created", i)
+		assert.NoError(t, err)
+	}
+
+	// IDs 3, 4 and 5 are still retained.
+	sub := b.Subscribe(AllChirps, 3, 1)
+	assert.Len(t, sub.C, 2)
+	assert.Equal(t, uint64(4), (<-sub.C).ID)
+	assert.Equal(t, uint64(5), (<-sub.C).ID)
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := NewBroker(0)
+	slow := b.Subscribe(AllChirps, 0, 1)
+	fast := b.Subscribe(AllChirps, 0, 8)
+
+	for i := 0; i < 3; i++ {
+		_, err := b.Publish("chirps/x", "chirp.created", i)
+		assert.NoError(t, err)
+	}
+
+	<-slow.C
+	_, open := <-slow.C
+	assert.False(t, open)
+	assert.True(t, slow.Dropped())
+	assert.Len(t, fast.C, 3)
+
+	// Unsubscribing an already dropped subscription is a no-op.
+	b.Unsubscribe(slow)
+	b.Unsubscribe(fast)
+	assert.False(t, fast.Dropped())
+}
+
+func TestExceptAuthors(t *testing.T) {
+	blocked, other := uuid.New(), uuid.New()
+	filter := ExceptAuthors(AllChirps, blocked)
+
+	assert.False(t, filter(ChirpTopic(blocked)))
+	assert.True(t, filter(ChirpTopic(other)))
+	assert.False(t, filter(NotificationTopic(other)))
+}
+
+func TestChirpAuthor(t *testing.T) {
+	author := uuid.New()
+	got, ok := ChirpAuthor(ChirpTopic(author))
+	assert.True(t, ok)
+	assert.Equal(t, author, got)
+
+	_, ok = ChirpAuthor(NotificationTopic(author))
+	assert.False(t, ok)
+	_, ok = ChirpAuthor("chirps/nope")
+	assert.False(t, ok)
+}
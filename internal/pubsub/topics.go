@@ -0,0 +1,59 @@
+package pubsub
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+const (
+	chirpsPrefix        = "chirps/"
+	notificationsPrefix = "notifications/"
+)
+
+// ChirpTopic is the topic chirp events by authorID are published on.
+func ChirpTopic(authorID uuid.UUID) string {
+	return chirpsPrefix + authorID.String()
+}
+
+// ChirpAuthor returns the author whose chirp events are published on topic,
+// or false if topic isn't a chirp topic.
+func ChirpAuthor(topic string) (uuid.UUID, bool) {
+	rest, ok := strings.CutPrefix(topic, chirpsPrefix)
+	if !ok {
+		return uuid.Nil, false
+	}
+	authorID, err := uuid.Parse(rest)
+	return authorID, err == nil
+}
+
+// AllChirps matches chirp events from every author.
+func AllChirps(topic string) bool {
+	return strings.HasPrefix(topic, chirpsPrefix)
+}
+
+// Topic matches exactly one topic.
+func Topic(name string) Filter {
+	return func(topic string) bool {
+		return topic == name
+	}
+}
+
+// ExceptAuthors wraps filter to skip chirp events by any of authors.
+func ExceptAuthors(filter Filter, authors ...uuid.UUID) Filter {
+	if len(authors) == 0 {
+		return filter
+	}
+	skip := make(map[string]bool, len(authors))
+	for _, id := range authors {
+		skip[ChirpTopic(id)] = true
+	}
+	return func(topic string) bool {
+		return !skip[topic] && filter(topic)
+	}
+}
+
+// NotificationTopic is the topic userID's notifications are published on.
+func NotificationTopic(userID uuid.UUID) string {
+	return notificationsPrefix + userID.String()
+}
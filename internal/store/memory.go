@@ -0,0 +1,1581 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/database"
+)
+
+// Memory is a Store that keeps everything in maps. It is safe for
+// concurrent use and loses its contents when the process exits.
+type Memory struct {
+	mu     sync.RWMutex
+	users  map[uuid.UUID]database.User
+	chirps map[uuid.UUID]database.Chirp
+	tokens map[string]database.RefreshToken
+	// apiKeys, oauthClients, identities, blocks, mutes, follows,
+	// bookmarks, collections, jobs, the webhook slices and notifications
+	// are in creation order.
+	apiKeys           []database.ApiKey
+	oauthClients      []database.OauthClient
+	oauthCodes        map[string]database.OauthCode
+	identities        []database.Identity
+	blocks            []database.Block
+	mutes             []database.Mute
+	follows           []database.Follow
+	bookmarks         []database.Bookmark
+	collections       []database.BookmarkCollection
+	jobs              []database.Job
+	endpoints         []database.WebhookEndpoint
+	deliveries        []database.WebhookDelivery
+	deliveryAttempts  []database.WebhookDeliveryAttempt
+	notifications     []database.Notification
+	notificationPrefs []database.NotificationPreference
+
+	// seq orders chirps created within the same clock tick.
+	seq      int64
+	chirpSeq map[uuid.UUID]int64
+}
+
+var _ Store = (*Memory)(nil)
+
+// NewMemory -
+func NewMemory() *Memory {
+	m := &Memory{}
+	m.reset()
+	return m
+}
+
+func (m *Memory) reset() {
+	m.users = make(map[uuid.UUID]database.User)
+	m.chirps = make(map[uuid.UUID]database.Chirp)
+	m.tokens = make(map[string]database.RefreshToken)
+	m.apiKeys = nil
+	m.oauthClients = nil
+	m.oauthCodes = make(map[string]database.OauthCode)
+	m.identities = nil
+	m.blocks = nil
+	m.mutes = nil
+	m.follows = nil
+	m.bookmarks = nil
+	m.collections = nil
+	m.jobs = nil
+	m.endpoints = nil
+	m.deliveries = nil
+	m.deliveryAttempts = nil
+	m.notifications = nil
+	m.notificationPrefs = nil
+	m.chirpSeq = make(map[uuid.UUID]int64)
+}
+
+// emailTaken reports whether a user other than except has email. Postgres
+// compares emails exactly, so this does too.
+func (m *Memory) emailTaken(email string, except uuid.UUID) bool {
+	for _, u := range m.users {
+		if u.Email == email && u.ID != except {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) CreateUser(ctx context.Context, email, hashedPassword string) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.emailTaken(email, uuid.Nil) {
+		return database.User{}, ErrConflict
+	}
+	t := now()
+	user := database.User{
+		ID:             uuid.New(),
+		CreatedAt:      t,
+		UpdatedAt:      t,
+		Email:          email,
+		HashedPassword: hashedPassword,
+		IsChirpyRed:    sql.NullBool{Bool: false, Valid: true},
+	}
+	m.users[user.ID] = user
+	return user, nil
+}
+
+func (m *Memory) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m *Memory) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return database.User{}, ErrNotFound
+}
+
+func (m *Memory) UpdateUserCredentials(ctx context.Context, id uuid.UUID, email, hashedPassword string) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, ErrNotFound
+	}
+	if m.emailTaken(email, id) {
+		return database.User{}, ErrConflict
+	}
+	user.Email = email
+	user.HashedPassword = hashedPassword
+	user.UpdatedAt = now()
+	m.users[id] = user
+	return user, nil
+}
+
+func (m *Memory) SetUserPrivate(ctx context.Context, id uuid.UUID, private bool) (database.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return database.User{}, ErrNotFound
+	}
+	user.IsPrivate = private
+	user.UpdatedAt = now()
+	m.users[id] = user
+	if !private {
+		for i, f := range m.follows {
+			if f.FolloweeID == id {
+				m.follows[i].Status = FollowAccepted
+			}
+		}
+	}
+	return user, nil
+}
+
+func (m *Memory) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.users, id)
+	for chirpID, c := range m.chirps {
+		if c.UserID == id {
+			delete(m.chirps, chirpID)
+			delete(m.chirpSeq, chirpID)
+		}
+	}
+	for token, rt := range m.tokens {
+		if rt.UserID == id {
+			delete(m.tokens, token)
+		}
+	}
+	m.apiKeys = slices.DeleteFunc(m.apiKeys, func(k database.ApiKey) bool { return k.UserID == id })
+	for _, c := range m.oauthClients {
+		if c.UserID == id {
+			m.deleteOAuthClient(c.ID)
+		}
+	}
+	for code, oc := range m.oauthCodes {
+		if oc.UserID == id {
+			delete(m.oauthCodes, code)
+		}
+	}
+	m.identities = slices.DeleteFunc(m.identities, func(i database.Identity) bool { return i.UserID == id })
+	m.blocks = slices.DeleteFunc(m.blocks, func(b database.Block) bool { return b.BlockerID == id || b.BlockedID == id })
+	m.mutes = slices.DeleteFunc(m.mutes, func(mu database.Mute) bool { return mu.MuterID == id || mu.MutedID == id })
+	m.follows = slices.DeleteFunc(m.follows, func(f database.Follow) bool { return f.FollowerID == id || f.FolloweeID == id })
+	m.collections = slices.DeleteFunc(m.collections, func(c database.BookmarkCollection) bool { return c.UserID == id })
+	m.bookmarks = slices.DeleteFunc(m.bookmarks, func(b database.Bookmark) bool {
+		_, ok := m.chirps[b.ChirpID]
+		return b.UserID == id || !ok
+	})
+	for _, e := range m.endpoints {
+		if e.UserID == id {
+			m.deleteWebhookEndpoint(e.ID)
+		}
+	}
+	m.notifications = slices.DeleteFunc(m.notifications, func(n database.Notification) bool {
+		_, ok := m.chirps[n.ChirpID.UUID]
+		return n.UserID == id || n.ActorID.UUID == id || (n.ChirpID.Valid && !ok)
+	})
+	m.notificationPrefs = slices.DeleteFunc(m.notificationPrefs, func(p database.NotificationPreference) bool { return p.UserID == id })
+	return nil
+}
+
+func (m *Memory) CreateChirp(ctx context.Context, userID uuid.UUID, body string) (database.Chirp, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return database.Chirp{}, ErrNotFound
+	}
+	t := now()
+	chirp := database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		Body:      body,
+		UserID:    userID,
+	}
+	m.seq++
+	m.chirps[chirp.ID] = chirp
+	m.chirpSeq[chirp.ID] = m.seq
+	return chirp, nil
+}
+
+func (m *Memory) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	chirp, ok := m.chirps[id]
+	if !ok {
+		return database.Chirp{}, ErrNotFound
+	}
+	return chirp, nil
+}
+
+func (m *Memory) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+	return m.listChirps(func(database.Chirp) bool { return true }), nil
+}
+
+func (m *Memory) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return m.listChirps(func(c database.Chirp) bool { return c.UserID == userID }), nil
+}
+
+// listChirps returns the matching chirps oldest first. Like sqlc, it
+// returns nil rather than an empty slice when nothing matches.
+func (m *Memory) listChirps(match func(database.Chirp) bool) []database.Chirp {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var chirps []database.Chirp
+	for _, c := range m.chirps {
+		if match(c) {
+			chirps = append(chirps, c)
+		}
+	}
+	sort.Slice(chirps, func(i, j int) bool {
+		if !chirps[i].CreatedAt.Equal(chirps[j].CreatedAt) {
+			return chirps[i].CreatedAt.Before(chirps[j].CreatedAt)
+		}
+		return m.chirpSeq[chirps[i].ID] < m.chirpSeq[chirps[j].ID]
+	})
+	return chirps
+}
+
+func (m *Memory) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.chirps[id]; !ok {
+		return ErrNotFound
+	}
+	delete(m.chirps, id)
+	delete(m.chirpSeq, id)
+	m.bookmarks = slices.DeleteFunc(m.bookmarks, func(b database.Bookmark) bool { return b.ChirpID == id })
+	m.notifications = slices.DeleteFunc(m.notifications, func(n database.Notification) bool { return n.ChirpID.Valid && n.ChirpID.UUID == id })
+	return nil
+}
+
+func (m *Memory) CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if _, ok := m.tokens[token]; ok {
+		return ErrConflict
+	}
+	t := now()
+	m.tokens[token] = database.RefreshToken{
+		Token:     token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    userID,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Microsecond),
+	}
+	return nil
+}
+
+func (m *Memory) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rt, ok := m.tokens[token]
+	if !ok || rt.ClientID.Valid || rt.RevokedAt.Valid || !rt.ExpiresAt.After(now()) {
+		return database.User{}, ErrNotFound
+	}
+	user, ok := m.users[rt.UserID]
+	if !ok {
+		return database.User{}, ErrNotFound
+	}
+	return user, nil
+}
+
+func (m *Memory) CreateClientRefreshToken(ctx context.Context, token string, userID, clientID uuid.UUID, scopes string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return ErrNotFound
+	}
+	if !slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == clientID }) {
+		return ErrNotFound
+	}
+	if _, ok := m.tokens[token]; ok {
+		return ErrConflict
+	}
+	t := now()
+	m.tokens[token] = database.RefreshToken{
+		Token:     token,
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    userID,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Microsecond),
+		ClientID:  uuid.NullUUID{UUID: clientID, Valid: true},
+		Scopes:    scopes,
+	}
+	return nil
+}
+
+func (m *Memory) GetClientRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	rt, ok := m.tokens[token]
+	if !ok || !rt.ClientID.Valid || rt.RevokedAt.Valid || !rt.ExpiresAt.After(now()) {
+		return database.RefreshToken{}, ErrNotFound
+	}
+	return rt, nil
+}
+
+func (m *Memory) RevokeRefreshToken(ctx context.Context, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	rt, ok := m.tokens[token]
+	if !ok {
+		return nil
+	}
+	t := now()
+	rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+	rt.UpdatedAt = t
+	m.tokens[token] = rt
+	return nil
+}
+
+func (m *Memory) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.revokeTokens(func(rt database.RefreshToken) bool { return rt.UserID == userID }), nil
+}
+
+func (m *Memory) RevokeAllRefreshTokens(ctx context.Context) (int64, error) {
+	return m.revokeTokens(func(database.RefreshToken) bool { return true }), nil
+}
+
+// revokeTokens revokes every unrevoked token that matches and returns how
+// many it changed.
+func (m *Memory) revokeTokens(match func(database.RefreshToken) bool) int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := now()
+	var revoked int64
+	for token, rt := range m.tokens {
+		if rt.RevokedAt.Valid || !match(rt) {
+			continue
+		}
+		rt.RevokedAt = sql.NullTime{Time: t, Valid: true}
+		rt.UpdatedAt = t
+		m.tokens[token] = rt
+		revoked++
+	}
+	return revoked
+}
+
+func (m *Memory) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := now()
+	var purged int64
+	for token, rt := range m.tokens {
+		expired := rt.ExpiresAt.Before(t)
+		stale := rt.RevokedAt.Valid && rt.RevokedAt.Time.Before(t.Add(-revokedRetention))
+		if expired || stale {
+			delete(m.tokens, token)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+func (m *Memory) UpgradeUser(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user, ok := m.users[id]
+	if !ok {
+		return ErrNotFound
+	}
+	user.IsChirpyRed = sql.NullBool{Bool: true, Valid: true}
+	m.users[id] = user
+	return nil
+}
+
+func (m *Memory) Reset(ctx context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.reset()
+	return nil
+}
+
+func (m *Memory) CreateAPIKey(ctx context.Context, userID uuid.UUID, name, prefix, hashedKey, scopes string) (database.ApiKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return database.ApiKey{}, ErrNotFound
+	}
+	for _, k := range m.apiKeys {
+		if k.HashedKey == hashedKey {
+			return database.ApiKey{}, ErrConflict
+		}
+	}
+	key := database.ApiKey{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		HashedKey: hashedKey,
+		Scopes:    scopes,
+	}
+	m.apiKeys = append(m.apiKeys, key)
+	return key, nil
+}
+
+func (m *Memory) GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, k := range m.apiKeys {
+		if k.HashedKey == hashedKey {
+			return k, nil
+		}
+	}
+	return database.ApiKey{}, ErrNotFound
+}
+
+func (m *Memory) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var keys []database.ApiKey
+	for _, k := range m.apiKeys {
+		if k.UserID == userID {
+			keys = append(keys, k)
+		}
+	}
+	return keys, nil
+}
+
+func (m *Memory) DeleteAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.apiKeys, func(k database.ApiKey) bool { return k.ID == id && k.UserID == userID })
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.apiKeys = slices.Delete(m.apiKeys, i, i+1)
+	return nil
+}
+
+func (m *Memory) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.apiKeys, func(k database.ApiKey) bool { return k.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.apiKeys[i].LastUsedAt = sql.NullTime{Time: now(), Valid: true}
+	return nil
+}
+
+func (m *Memory) CreateOAuthClient(ctx context.Context, userID uuid.UUID, name, redirectURIs string, hashedSecret sql.NullString) (database.OauthClient, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return database.OauthClient{}, ErrNotFound
+	}
+	client := database.OauthClient{
+		ID:           uuid.New(),
+		CreatedAt:    now(),
+		UserID:       userID,
+		Name:         name,
+		RedirectUris: redirectURIs,
+		HashedSecret: hashedSecret,
+	}
+	m.oauthClients = append(m.oauthClients, client)
+	return client, nil
+}
+
+func (m *Memory) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := slices.IndexFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == id })
+	if i < 0 {
+		return database.OauthClient{}, ErrNotFound
+	}
+	return m.oauthClients[i], nil
+}
+
+func (m *Memory) ListOAuthClients(ctx context.Context, userID uuid.UUID) ([]database.OauthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var clients []database.OauthClient
+	for _, c := range m.oauthClients {
+		if c.UserID == userID {
+			clients = append(clients, c)
+		}
+	}
+	return clients, nil
+}
+
+func (m *Memory) DeleteOAuthClient(ctx context.Context, id, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == id && c.UserID == userID }) {
+		return ErrNotFound
+	}
+	m.deleteOAuthClient(id)
+	return nil
+}
+
+// deleteOAuthClient deletes a client and cascades to its codes and
+// tokens. The caller must hold m.mu.
+func (m *Memory) deleteOAuthClient(id uuid.UUID) {
+	m.oauthClients = slices.DeleteFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == id })
+	for code, oc := range m.oauthCodes {
+		if oc.ClientID == id {
+			delete(m.oauthCodes, code)
+		}
+	}
+	for token, rt := range m.tokens {
+		if rt.ClientID.Valid && rt.ClientID.UUID == id {
+			delete(m.tokens, token)
+		}
+	}
+}
+
+func (m *Memory) CreateAuthorizationCode(ctx context.Context, code database.OauthCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[code.UserID]; !ok {
+		return ErrNotFound
+	}
+	if !slices.ContainsFunc(m.oauthClients, func(c database.OauthClient) bool { return c.ID == code.ClientID }) {
+		return ErrNotFound
+	}
+	if _, ok := m.oauthCodes[code.Code]; ok {
+		return ErrConflict
+	}
+	code.CreatedAt = now()
+	code.ExpiresAt = code.ExpiresAt.UTC().Truncate(time.Microsecond)
+	m.oauthCodes[code.Code] = code
+	return nil
+}
+
+func (m *Memory) ConsumeAuthorizationCode(ctx context.Context, code string) (database.OauthCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	oc, ok := m.oauthCodes[code]
+	if !ok {
+		return database.OauthCode{}, ErrNotFound
+	}
+	delete(m.oauthCodes, code)
+	if !oc.ExpiresAt.After(now()) {
+		return database.OauthCode{}, ErrNotFound
+	}
+	return oc, nil
+}
+
+func (m *Memory) CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) (database.Identity, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.users[userID]; !ok {
+		return database.Identity{}, ErrNotFound
+	}
+	for _, i := range m.identities {
+		if i.Provider == provider && (i.Subject == subject || i.UserID == userID) {
+			return database.Identity{}, ErrConflict
+		}
+	}
+	identity := database.Identity{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    userID,
+		Provider:  provider,
+		Subject:   subject,
+		Email:     email,
+	}
+	m.identities = append(m.identities, identity)
+	return identity, nil
+}
+
+func (m *Memory) GetIdentity(ctx context.Context, provider, subject string) (database.Identity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, i := range m.identities {
+		if i.Provider == provider && i.Subject == subject {
+			return i, nil
+		}
+	}
+	return database.Identity{}, ErrNotFound
+}
+
+func (m *Memory) ListIdentities(ctx context.Context, userID uuid.UUID) ([]database.Identity, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var identities []database.Identity
+	for _, i := range m.identities {
+		if i.UserID == userID {
+			identities = append(identities, i)
+		}
+	}
+	return identities, nil
+}
+
+func (m *Memory) DeleteIdentity(ctx context.Context, userID uuid.UUID, provider string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.identities, func(i database.Identity) bool { return i.UserID == userID && i.Provider == provider })
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.identities = slices.Delete(m.identities, i, i+1)
+	return nil
+}
+
+func (m *Memory) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(blockerID, blockedID) {
+		return ErrNotFound
+	}
+	m.follows = slices.DeleteFunc(m.follows, func(f database.Follow) bool {
+		return f.FollowerID == blockerID && f.FolloweeID == blockedID || f.FollowerID == blockedID && f.FolloweeID == blockerID
+	})
+	if m.blockIndex(blockerID, blockedID) >= 0 {
+		return nil
+	}
+	m.blocks = append(m.blocks, database.Block{BlockerID: blockerID, BlockedID: blockedID, CreatedAt: now()})
+	return nil
+}
+
+func (m *Memory) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.blockIndex(blockerID, blockedID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.blocks = slices.Delete(m.blocks, i, i+1)
+	return nil
+}
+
+func (m *Memory) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var blocks []database.Block
+	for _, b := range m.blocks {
+		if b.BlockerID == blockerID {
+			blocks = append(blocks, b)
+		}
+	}
+	return blocks, nil
+}
+
+func (m *Memory) BlockedUsers(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for _, b := range m.blocks {
+		switch {
+		case b.BlockerID == userID && !slices.Contains(ids, b.BlockedID):
+			ids = append(ids, b.BlockedID)
+		case b.BlockedID == userID && !slices.Contains(ids, b.BlockerID):
+			ids = append(ids, b.BlockerID)
+		}
+	}
+	return ids, nil
+}
+
+func (m *Memory) Blocked(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.blockIndex(userID, otherID) >= 0 || m.blockIndex(otherID, userID) >= 0, nil
+}
+
+func (m *Memory) blockIndex(blockerID, blockedID uuid.UUID) int {
+	return slices.IndexFunc(m.blocks, func(b database.Block) bool { return b.BlockerID == blockerID && b.BlockedID == blockedID })
+}
+
+func (m *Memory) MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(muterID, mutedID) {
+		return ErrNotFound
+	}
+	if m.muteIndex(muterID, mutedID) >= 0 {
+		return nil
+	}
+	m.mutes = append(m.mutes, database.Mute{MuterID: muterID, MutedID: mutedID, CreatedAt: now()})
+	return nil
+}
+
+func (m *Memory) UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.muteIndex(muterID, mutedID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.mutes = slices.Delete(m.mutes, i, i+1)
+	return nil
+}
+
+func (m *Memory) ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var mutes []database.Mute
+	for _, mu := range m.mutes {
+		if mu.MuterID == muterID {
+			mutes = append(mutes, mu)
+		}
+	}
+	return mutes, nil
+}
+
+func (m *Memory) muteIndex(muterID, mutedID uuid.UUID) int {
+	return slices.IndexFunc(m.mutes, func(mu database.Mute) bool { return mu.MuterID == muterID && mu.MutedID == mutedID })
+}
+
+func (m *Memory) Follow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(followerID, followeeID) {
+		return database.Follow{}, ErrNotFound
+	}
+	if i := m.followIndex(followerID, followeeID); i >= 0 {
+		return m.follows[i], nil
+	}
+	status := FollowAccepted
+	if m.users[followeeID].IsPrivate {
+		status = FollowPending
+	}
+	follow := database.Follow{FollowerID: followerID, FolloweeID: followeeID, Status: status, CreatedAt: now()}
+	m.follows = append(m.follows, follow)
+	return follow, nil
+}
+
+func (m *Memory) GetFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.followIndex(followerID, followeeID)
+	if i < 0 {
+		return database.Follow{}, ErrNotFound
+	}
+	return m.follows[i], nil
+}
+
+func (m *Memory) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.followIndex(followerID, followeeID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.follows = slices.Delete(m.follows, i, i+1)
+	return nil
+}
+
+func (m *Memory) ListFollowing(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var follows []database.Follow
+	for _, f := range m.follows {
+		if f.FollowerID == followerID {
+			follows = append(follows, f)
+		}
+	}
+	return follows, nil
+}
+
+func (m *Memory) ListFollowers(ctx context.Context, followeeID uuid.UUID, status string) ([]database.Follow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var follows []database.Follow
+	for _, f := range m.follows {
+		if f.FolloweeID == followeeID && f.Status == status {
+			follows = append(follows, f)
+		}
+	}
+	return follows, nil
+}
+
+func (m *Memory) AcceptFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.followIndex(followerID, followeeID)
+	if i < 0 || m.follows[i].Status != FollowPending {
+		return database.Follow{}, ErrNotFound
+	}
+	m.follows[i].Status = FollowAccepted
+	return m.follows[i], nil
+}
+
+func (m *Memory) DenyFollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.followIndex(followerID, followeeID)
+	if i < 0 || m.follows[i].Status != FollowPending {
+		return ErrNotFound
+	}
+	m.follows = slices.Delete(m.follows, i, i+1)
+	return nil
+}
+
+func (m *Memory) HiddenPrivateUsers(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var ids []uuid.UUID
+	for id, user := range m.users {
+		if user.IsPrivate && !m.follower(viewerID, id) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (m *Memory) CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if _, ok := m.users[authorID]; !ok {
+		return false, ErrNotFound
+	}
+	return m.canSee(viewerID, authorID), nil
+}
+
+// canSee is CanSeeChirps for an author known to exist.
+func (m *Memory) canSee(viewerID, authorID uuid.UUID) bool {
+	if m.blockIndex(viewerID, authorID) >= 0 || m.blockIndex(authorID, viewerID) >= 0 {
+		return false
+	}
+	return !m.users[authorID].IsPrivate || m.follower(viewerID, authorID)
+}
+
+func (m *Memory) followIndex(followerID, followeeID uuid.UUID) int {
+	return slices.IndexFunc(m.follows, func(f database.Follow) bool { return f.FollowerID == followerID && f.FolloweeID == followeeID })
+}
+
+// follower reports whether viewerID sees followeeID's private chirps: it
+// is followeeID or an accepted follower.
+func (m *Memory) follower(viewerID, followeeID uuid.UUID) bool {
+	if viewerID == followeeID {
+		return true
+	}
+	i := m.followIndex(viewerID, followeeID)
+	return i >= 0 && m.follows[i].Status == FollowAccepted
+}
+
+func (m *Memory) BookmarkChirp(ctx context.Context, userID, chirpID, collectionID uuid.UUID) (database.Bookmark, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.chirps[chirpID]; !ok || !m.usersExist(userID) {
+		return database.Bookmark{}, ErrNotFound
+	}
+	if collectionID != uuid.Nil && m.collectionIndex(collectionID, userID) < 0 {
+		return database.Bookmark{}, ErrNotFound
+	}
+	collection := uuid.NullUUID{UUID: collectionID, Valid: collectionID != uuid.Nil}
+	if i := m.bookmarkIndex(userID, chirpID); i >= 0 {
+		m.bookmarks[i].CollectionID = collection
+		return m.bookmarks[i], nil
+	}
+	bookmark := database.Bookmark{UserID: userID, ChirpID: chirpID, CollectionID: collection, CreatedAt: now()}
+	m.bookmarks = append(m.bookmarks, bookmark)
+	return bookmark, nil
+}
+
+func (m *Memory) RemoveBookmark(ctx context.Context, userID, chirpID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.bookmarkIndex(userID, chirpID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.bookmarks = slices.Delete(m.bookmarks, i, i+1)
+	return nil
+}
+
+func (m *Memory) ListBookmarks(ctx context.Context, userID, collectionID uuid.UUID, after BookmarkCursor, limit int) ([]database.ListBookmarksRow, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rows []database.ListBookmarksRow
+	for _, b := range m.bookmarks {
+		if b.UserID != userID || (collectionID != uuid.Nil && b.CollectionID.UUID != collectionID) {
+			continue
+		}
+		if after != (BookmarkCursor{}) && compareBookmarks(b.CreatedAt, b.ChirpID, after.CreatedAt, after.ChirpID) <= 0 {
+			continue
+		}
+		chirp := m.chirps[b.ChirpID]
+		if !m.canSee(userID, chirp.UserID) {
+			continue
+		}
+		rows = append(rows, database.ListBookmarksRow{Bookmark: b, Chirp: chirp})
+	}
+	slices.SortFunc(rows, func(a, b database.ListBookmarksRow) int {
+		return compareBookmarks(a.Bookmark.CreatedAt, a.Bookmark.ChirpID, b.Bookmark.CreatedAt, b.Bookmark.ChirpID)
+	})
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	return rows, nil
+}
+
+func (m *Memory) CreateBookmarkCollection(ctx context.Context, userID uuid.UUID, name string) (database.BookmarkCollection, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(userID) {
+		return database.BookmarkCollection{}, ErrNotFound
+	}
+	for _, c := range m.collections {
+		if c.UserID == userID && c.Name == name {
+			return database.BookmarkCollection{}, ErrConflict
+		}
+	}
+	collection := database.BookmarkCollection{ID: uuid.New(), CreatedAt: now(), UserID: userID, Name: name}
+	m.collections = append(m.collections, collection)
+	return collection, nil
+}
+
+func (m *Memory) ListBookmarkCollections(ctx context.Context, userID uuid.UUID) ([]database.BookmarkCollection, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var collections []database.BookmarkCollection
+	for _, c := range m.collections {
+		if c.UserID == userID {
+			collections = append(collections, c)
+		}
+	}
+	return collections, nil
+}
+
+func (m *Memory) DeleteBookmarkCollection(ctx context.Context, id, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.collectionIndex(id, userID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	m.collections = slices.Delete(m.collections, i, i+1)
+	for i, b := range m.bookmarks {
+		if b.CollectionID.UUID == id {
+			m.bookmarks[i].CollectionID = uuid.NullUUID{}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) bookmarkIndex(userID, chirpID uuid.UUID) int {
+	return slices.IndexFunc(m.bookmarks, func(b database.Bookmark) bool { return b.UserID == userID && b.ChirpID == chirpID })
+}
+
+func (m *Memory) collectionIndex(id, userID uuid.UUID) int {
+	return slices.IndexFunc(m.collections, func(c database.BookmarkCollection) bool { return c.ID == id && c.UserID == userID })
+}
+
+// compareBookmarks orders bookmarks newest first, breaking ties by chirp
+// ID, as the ORDER BY in the SQL backends does.
+func compareBookmarks(aTime time.Time, aChirp uuid.UUID, bTime time.Time, bChirp uuid.UUID) int {
+	if c := bTime.Compare(aTime); c != 0 {
+		return c
+	}
+	return bytes.Compare(bChirp[:], aChirp[:])
+}
+
+// usersExist reports whether every one of ids is a user, as the foreign
+// keys in Postgres check.
+func (m *Memory) usersExist(ids ...uuid.UUID) bool {
+	for _, id := range ids {
+		if _, ok := m.users[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *Memory) EnqueueJob(ctx context.Context, job database.Job) (database.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.UniqueKey.Valid && slices.ContainsFunc(m.jobs, func(j database.Job) bool { return j.UniqueKey == job.UniqueKey }) {
+		return database.Job{}, ErrConflict
+	}
+	t := now()
+	job = database.Job{
+		ID:          uuid.New(),
+		CreatedAt:   t,
+		UpdatedAt:   t,
+		Kind:        job.Kind,
+		Payload:     slices.Clone(job.Payload),
+		Status:      JobPending,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt.UTC().Truncate(time.Microsecond),
+		UniqueKey:   job.UniqueKey,
+	}
+	m.jobs = append(m.jobs, job)
+	return job, nil
+}
+
+func (m *Memory) ClaimJobs(ctx context.Context, kinds []string, limit int) ([]database.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := now()
+	var due []int
+	for i, j := range m.jobs {
+		if j.Status == JobPending && !j.RunAt.After(t) && slices.Contains(kinds, j.Kind) {
+			due = append(due, i)
+		}
+	}
+	sort.SliceStable(due, func(a, b int) bool { return m.jobs[due[a]].RunAt.Before(m.jobs[due[b]].RunAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	var claimed []database.Job
+	for _, i := range due {
+		j := &m.jobs[i]
+		j.Status = JobRunning
+		j.Attempts++
+		j.LockedAt = sql.NullTime{Time: t, Valid: true}
+		j.UpdatedAt = t
+		claimed = append(claimed, *j)
+	}
+	return claimed, nil
+}
+
+func (m *Memory) CompleteJob(ctx context.Context, id uuid.UUID) error {
+	return m.updateJob(id, func(j *database.Job) {
+		j.Status = JobSucceeded
+		j.LockedAt = sql.NullTime{}
+		j.LastError = sql.NullString{}
+	})
+}
+
+func (m *Memory) RetryJob(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	return m.updateJob(id, func(j *database.Job) {
+		j.Status = JobPending
+		j.LockedAt = sql.NullTime{}
+		j.RunAt = runAt.UTC().Truncate(time.Microsecond)
+		j.LastError = sql.NullString{String: lastError, Valid: true}
+	})
+}
+
+func (m *Memory) KillJob(ctx context.Context, id uuid.UUID, lastError string) error {
+	return m.updateJob(id, func(j *database.Job) {
+		j.Status = JobDead
+		j.LockedAt = sql.NullTime{}
+		j.LastError = sql.NullString{String: lastError, Valid: true}
+	})
+}
+
+// updateJob applies fn to the job with id, if there is one, like an
+// UPDATE that matches no rows.
+func (m *Memory) updateJob(id uuid.UUID, fn func(j *database.Job)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.jobs, func(j database.Job) bool { return j.ID == id })
+	if i >= 0 {
+		fn(&m.jobs[i])
+		m.jobs[i].UpdatedAt = now()
+	}
+	return nil
+}
+
+func (m *Memory) RequeueStaleJobs(ctx context.Context, lockedBefore time.Time, lastError string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var requeued int64
+	for i, j := range m.jobs {
+		if j.Status == JobRunning && j.LockedAt.Time.Before(lockedBefore) {
+			m.jobs[i].Status = JobPending
+			if j.Attempts >= j.MaxAttempts {
+				m.jobs[i].Status = JobDead
+			}
+			m.jobs[i].LockedAt = sql.NullTime{}
+			m.jobs[i].LastError = sql.NullString{String: lastError, Valid: true}
+			m.jobs[i].UpdatedAt = now()
+			requeued++
+		}
+	}
+	return requeued, nil
+}
+
+func (m *Memory) GetJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, j := range m.jobs {
+		if j.ID == id {
+			return j, nil
+		}
+	}
+	return database.Job{}, ErrNotFound
+}
+
+func (m *Memory) ListJobs(ctx context.Context, status string, limit int) ([]database.Job, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var jobs []database.Job
+	for _, j := range slices.Backward(m.jobs) {
+		if len(jobs) == limit {
+			break
+		}
+		if status == "" || j.Status == status {
+			jobs = append(jobs, j)
+		}
+	}
+	return jobs, nil
+}
+
+func (m *Memory) RetryDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.jobs, func(j database.Job) bool { return j.ID == id && j.Status == JobDead })
+	if i < 0 {
+		return database.Job{}, ErrNotFound
+	}
+	t := now()
+	j := &m.jobs[i]
+	j.Status = JobPending
+	j.Attempts = 0
+	j.RunAt = t
+	j.LastError = sql.NullString{}
+	j.UpdatedAt = t
+	return *j, nil
+}
+
+func (m *Memory) DeleteFinishedJobsBefore(ctx context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	n := len(m.jobs)
+	m.jobs = slices.DeleteFunc(m.jobs, func(j database.Job) bool {
+		return (j.Status == JobSucceeded || j.Status == JobDead) && j.UpdatedAt.Before(before)
+	})
+	return int64(n - len(m.jobs)), nil
+}
+
+func (m *Memory) CreateWebhookEndpoint(ctx context.Context, userID uuid.UUID, url, secret string, events []string) (database.WebhookEndpoint, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(userID) {
+		return database.WebhookEndpoint{}, ErrNotFound
+	}
+	t := now()
+	endpoint := database.WebhookEndpoint{
+		ID:        uuid.New(),
+		CreatedAt: t,
+		UpdatedAt: t,
+		UserID:    userID,
+		Url:       url,
+		Secret:    secret,
+		Events:    slices.Clone(events),
+	}
+	m.endpoints = append(m.endpoints, endpoint)
+	return endpoint, nil
+}
+
+func (m *Memory) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.endpointIndex(id)
+	if i < 0 {
+		return database.WebhookEndpoint{}, ErrNotFound
+	}
+	return m.endpoints[i], nil
+}
+
+func (m *Memory) ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var endpoints []database.WebhookEndpoint
+	for _, e := range m.endpoints {
+		if e.UserID == userID {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints, nil
+}
+
+func (m *Memory) ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]database.WebhookEndpoint, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var endpoints []database.WebhookEndpoint
+	for _, e := range m.endpoints {
+		if !e.DisabledAt.Valid && slices.Contains(e.Events, event) {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints, nil
+}
+
+func (m *Memory) DeleteWebhookEndpoint(ctx context.Context, id, userID uuid.UUID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.endpointIndex(id)
+	if i < 0 || m.endpoints[i].UserID != userID {
+		return ErrNotFound
+	}
+	m.deleteWebhookEndpoint(id)
+	return nil
+}
+
+// deleteWebhookEndpoint deletes the endpoint with its deliveries and their
+// attempts, as the foreign keys in Postgres cascade.
+func (m *Memory) deleteWebhookEndpoint(id uuid.UUID) {
+	m.endpoints = slices.DeleteFunc(m.endpoints, func(e database.WebhookEndpoint) bool { return e.ID == id })
+	deleted := map[uuid.UUID]bool{}
+	m.deliveries = slices.DeleteFunc(m.deliveries, func(d database.WebhookDelivery) bool {
+		if d.EndpointID == id {
+			deleted[d.ID] = true
+		}
+		return deleted[d.ID]
+	})
+	m.deliveryAttempts = slices.DeleteFunc(m.deliveryAttempts, func(a database.WebhookDeliveryAttempt) bool { return deleted[a.DeliveryID] })
+}
+
+func (m *Memory) RecordWebhookEndpointFailure(ctx context.Context, id uuid.UUID) (int32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.endpointIndex(id)
+	if i < 0 {
+		return 0, ErrNotFound
+	}
+	m.endpoints[i].ConsecutiveFailures++
+	m.endpoints[i].UpdatedAt = now()
+	return m.endpoints[i].ConsecutiveFailures, nil
+}
+
+func (m *Memory) ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	return m.updateEndpoint(id, func(e *database.WebhookEndpoint) { e.ConsecutiveFailures = 0 })
+}
+
+func (m *Memory) DisableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return m.updateEndpoint(id, func(e *database.WebhookEndpoint) { e.DisabledAt = sql.NullTime{Time: now(), Valid: true} })
+}
+
+func (m *Memory) EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return m.updateEndpoint(id, func(e *database.WebhookEndpoint) {
+		e.DisabledAt = sql.NullTime{}
+		e.ConsecutiveFailures = 0
+	})
+}
+
+// updateEndpoint applies fn to the endpoint with id, if there is one, like
+// an UPDATE that matches no rows.
+func (m *Memory) updateEndpoint(id uuid.UUID, fn func(e *database.WebhookEndpoint)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.endpointIndex(id)
+	if i >= 0 {
+		fn(&m.endpoints[i])
+		m.endpoints[i].UpdatedAt = now()
+	}
+	return nil
+}
+
+func (m *Memory) endpointIndex(id uuid.UUID) int {
+	return slices.IndexFunc(m.endpoints, func(e database.WebhookEndpoint) bool { return e.ID == id })
+}
+
+func (m *Memory) CreateWebhookDelivery(ctx context.Context, endpointID uuid.UUID, event string, payload json.RawMessage) (database.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.endpointIndex(endpointID) < 0 {
+		return database.WebhookDelivery{}, ErrNotFound
+	}
+	t := now()
+	delivery := database.WebhookDelivery{
+		ID:         uuid.New(),
+		CreatedAt:  t,
+		UpdatedAt:  t,
+		EndpointID: endpointID,
+		Event:      event,
+		Payload:    slices.Clone(payload),
+		Status:     DeliveryPending,
+	}
+	m.deliveries = append(m.deliveries, delivery)
+	return delivery, nil
+}
+
+func (m *Memory) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.deliveryIndex(id)
+	if i < 0 {
+		return database.WebhookDelivery{}, ErrNotFound
+	}
+	return m.deliveries[i], nil
+}
+
+func (m *Memory) UpdateWebhookDelivery(ctx context.Context, id uuid.UUID, status string, attempts int32, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.deliveryIndex(id)
+	if i >= 0 {
+		d := &m.deliveries[i]
+		d.Status = status
+		d.Attempts = attempts
+		d.LastError = sql.NullString{String: lastError, Valid: lastError != ""}
+		d.UpdatedAt = now()
+	}
+	return nil
+}
+
+func (m *Memory) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := m.deliveryIndex(id)
+	if i < 0 || m.deliveries[i].Status != DeliveryFailed {
+		return database.WebhookDelivery{}, ErrNotFound
+	}
+	m.deliveries[i].Status = DeliveryPending
+	m.deliveries[i].UpdatedAt = now()
+	return m.deliveries[i], nil
+}
+
+func (m *Memory) deliveryIndex(id uuid.UUID) int {
+	return slices.IndexFunc(m.deliveries, func(d database.WebhookDelivery) bool { return d.ID == id })
+}
+
+func (m *Memory) CreateWebhookDeliveryAttempt(ctx context.Context, attempt database.WebhookDeliveryAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.deliveryIndex(attempt.DeliveryID) < 0 {
+		return ErrNotFound
+	}
+	attempt.ID = uuid.New()
+	attempt.CreatedAt = now()
+	m.deliveryAttempts = append(m.deliveryAttempts, attempt)
+	return nil
+}
+
+func (m *Memory) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var attempts []database.WebhookDeliveryAttempt
+	for _, a := range m.deliveryAttempts {
+		if a.DeliveryID == deliveryID {
+			attempts = append(attempts, a)
+		}
+	}
+	slices.SortStableFunc(attempts, func(a, b database.WebhookDeliveryAttempt) int { return int(a.Attempt - b.Attempt) })
+	return attempts, nil
+}
+
+func (m *Memory) CreateNotification(ctx context.Context, userID uuid.UUID, typ string, actorID, chirpID uuid.UUID) (database.Notification, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(userID) || (actorID != uuid.Nil && !m.usersExist(actorID)) {
+		return database.Notification{}, ErrNotFound
+	}
+	if _, ok := m.chirps[chirpID]; chirpID != uuid.Nil && !ok {
+		return database.Notification{}, ErrNotFound
+	}
+	n := database.Notification{
+		ID:        uuid.New(),
+		CreatedAt: now(),
+		UserID:    userID,
+		Type:      typ,
+		ActorID:   uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		ChirpID:   uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	}
+	m.notifications = append(m.notifications, n)
+	return n, nil
+}
+
+func (m *Memory) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]database.Notification, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var notifications []database.Notification
+	for _, n := range slices.Backward(m.notifications) {
+		if len(notifications) == limit {
+			break
+		}
+		if n.UserID == userID && !(unreadOnly && n.ReadAt.Valid) {
+			notifications = append(notifications, n)
+		}
+	}
+	return notifications, nil
+}
+
+func (m *Memory) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var unread int64
+	for _, n := range m.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+func (m *Memory) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	return m.markRead(userID, func(n database.Notification) bool { return slices.Contains(ids, n.ID) })
+}
+
+func (m *Memory) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	return m.markRead(userID, func(database.Notification) bool { return true })
+}
+
+// markRead marks userID's unread notifications that match read, and
+// returns how many there were.
+func (m *Memory) markRead(userID uuid.UUID, match func(database.Notification) bool) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := now()
+	var marked int64
+	for i, n := range m.notifications {
+		if n.UserID == userID && !n.ReadAt.Valid && match(n) {
+			m.notifications[i].ReadAt = sql.NullTime{Time: t, Valid: true}
+			marked++
+		}
+	}
+	return marked, nil
+}
+
+func (m *Memory) GetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	i := m.notificationPrefIndex(userID, typ)
+	if i < 0 {
+		return false, ErrNotFound
+	}
+	return m.notificationPrefs[i].Enabled, nil
+}
+
+func (m *Memory) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var prefs []database.NotificationPreference
+	for _, p := range m.notificationPrefs {
+		if p.UserID == userID {
+			prefs = append(prefs, p)
+		}
+	}
+	return prefs, nil
+}
+
+func (m *Memory) SetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string, enabled bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.usersExist(userID) {
+		return ErrNotFound
+	}
+	pref := database.NotificationPreference{UserID: userID, Type: typ, Enabled: enabled, UpdatedAt: now()}
+	if i := m.notificationPrefIndex(userID, typ); i >= 0 {
+		m.notificationPrefs[i] = pref
+		return nil
+	}
+	m.notificationPrefs = append(m.notificationPrefs, pref)
+	return nil
+}
+
+func (m *Memory) notificationPrefIndex(userID uuid.UUID, typ string) int {
+	return slices.IndexFunc(m.notificationPrefs, func(p database.NotificationPreference) bool { return p.UserID == userID && p.Type == typ })
+}
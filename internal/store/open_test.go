@@ -0,0 +1,36 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		driver  string
+		dsn     string
+		wantErr bool
+	}{
+		{url: "postgres://chirpy@localhost:5432/chirpy?sslmode=disable", driver: DriverPostgres, dsn: "postgres://chirpy@localhost:5432/chirpy?sslmode=disable"},
+		{url: "postgresql://localhost/chirpy", driver: DriverPostgres, dsn: "postgresql://localhost/chirpy"},
+		{url: "memory://", driver: DriverMemory},
+		{url: "sqlite:///var/lib/chirpy.db", driver: DriverSQLite, dsn: "file:/var/lib/chirpy.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_pragma=journal_mode%28WAL%29&_time_format=sqlite"},
+		{url: "sqlite://chirpy.db?mode=ro", driver: DriverSQLite, dsn: "file:chirpy.db?_pragma=foreign_keys%281%29&_pragma=busy_timeout%285000%29&_pragma=journal_mode%28WAL%29&_time_format=sqlite&mode=ro"},
+		{url: "sqlite://", wantErr: true},
+		{url: "mysql://localhost/chirpy", wantErr: true},
+		{url: "localhost", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		driver, dsn, err := ParseURL(tt.url)
+		if tt.wantErr {
+			assert.Error(t, err, tt.url)
+			continue
+		}
+		assert.NoError(t, err, tt.url)
+		assert.Equal(t, tt.driver, driver, tt.url)
+		assert.Equal(t, tt.dsn, dsn, tt.url)
+	}
+}
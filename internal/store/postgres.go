@@ -0,0 +1,639 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/willmelton21/chirpy/internal/database"
+)
+
+// Postgres error codes, from
+// https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgForeignKeyViolation = "23503"
+	pgUniqueViolation     = "23505"
+)
+
+// Postgres is the Store backed by the sqlc queries in internal/database.
+type Postgres struct {
+	db DB
+	q  *database.Queries
+}
+
+var _ Store = (*Postgres)(nil)
+
+// NewPostgres -
+func NewPostgres(db DB) *Postgres {
+	return &Postgres{db: db, q: database.New(db)}
+}
+
+// tx runs fn with queries in a transaction, which commits if fn returns
+// nil. Errors come back from fn as it returned them.
+func (p *Postgres) tx(ctx context.Context, fn func(q *database.Queries) error) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(p.q.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// pgErr maps driver errors onto the store's sentinel errors.
+func pgErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case pgUniqueViolation:
+			return ErrConflict
+		case pgForeignKeyViolation:
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+// affected turns a zero row count into ErrNotFound.
+func affected(rows int64, err error) error {
+	if err != nil {
+		return pgErr(err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (p *Postgres) CreateUser(ctx context.Context, email, hashedPassword string) (database.User, error) {
+	user, err := p.q.CreateUser(ctx, database.CreateUserParams{Email: email, HashedPassword: hashedPassword})
+	return user, pgErr(err)
+}
+
+func (p *Postgres) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	user, err := p.q.GetUserByID(ctx, id)
+	return user, pgErr(err)
+}
+
+func (p *Postgres) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	user, err := p.q.GetUserByEmail(ctx, email)
+	return user, pgErr(err)
+}
+
+func (p *Postgres) UpdateUserCredentials(ctx context.Context, id uuid.UUID, email, hashedPassword string) (database.User, error) {
+	user, err := p.q.UpdateEmailAndPass(ctx, database.UpdateEmailAndPassParams{Email: email, HashedPassword: hashedPassword, ID: id})
+	return user, pgErr(err)
+}
+
+func (p *Postgres) SetUserPrivate(ctx context.Context, id uuid.UUID, private bool) (database.User, error) {
+	var user database.User
+	err := p.tx(ctx, func(q *database.Queries) error {
+		var err error
+		user, err = q.SetUserPrivate(ctx, database.SetUserPrivateParams{IsPrivate: private, ID: id})
+		if err != nil || private {
+			return err
+		}
+		// Requests made while the account was private are still pending.
+		return q.AcceptPendingFollows(ctx, id)
+	})
+	if err != nil {
+		return database.User{}, pgErr(err)
+	}
+	return user, nil
+}
+
+func (p *Postgres) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return affected(p.q.DeleteUser(ctx, id))
+}
+
+func (p *Postgres) CreateChirp(ctx context.Context, userID uuid.UUID, body string) (database.Chirp, error) {
+	chirp, err := p.q.CreateChirp(ctx, database.CreateChirpParams{Body: body, UserID: userID})
+	return chirp, pgErr(err)
+}
+
+func (p *Postgres) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := p.q.GetChirpByID(ctx, id)
+	return chirp, pgErr(err)
+}
+
+func (p *Postgres) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+	chirps, err := p.q.GetChirps(ctx)
+	return chirps, pgErr(err)
+}
+
+func (p *Postgres) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	chirps, err := p.q.GetChirpsByID(ctx, userID)
+	return chirps, pgErr(err)
+}
+
+func (p *Postgres) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return affected(p.q.DeleteChirpByID(ctx, id))
+}
+
+func (p *Postgres) CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) error {
+	return pgErr(p.q.CreateTokenDB(ctx, database.CreateTokenDBParams{Token: token, UserID: userID, ExpiresAt: expiresAt}))
+}
+
+func (p *Postgres) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	user, err := p.q.GetUserFromRefreshToken(ctx, token)
+	return user, pgErr(err)
+}
+
+func (p *Postgres) CreateClientRefreshToken(ctx context.Context, token string, userID, clientID uuid.UUID, scopes string, expiresAt time.Time) error {
+	return pgErr(p.q.CreateClientRefreshToken(ctx, database.CreateClientRefreshTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		ClientID:  uuid.NullUUID{UUID: clientID, Valid: true},
+		Scopes:    scopes,
+	}))
+}
+
+func (p *Postgres) GetClientRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	rt, err := p.q.GetClientRefreshToken(ctx, token)
+	return rt, pgErr(err)
+}
+
+func (p *Postgres) RevokeRefreshToken(ctx context.Context, token string) error {
+	return pgErr(p.q.RevokeToken(ctx, token))
+}
+
+func (p *Postgres) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	revoked, err := p.q.RevokeUserRefreshTokens(ctx, userID)
+	return revoked, pgErr(err)
+}
+
+func (p *Postgres) RevokeAllRefreshTokens(ctx context.Context) (int64, error) {
+	revoked, err := p.q.RevokeAllRefreshTokens(ctx)
+	return revoked, pgErr(err)
+}
+
+func (p *Postgres) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	purged, err := p.q.DeleteExpiredRefreshTokens(ctx)
+	return purged, pgErr(err)
+}
+
+func (p *Postgres) CreateAPIKey(ctx context.Context, userID uuid.UUID, name, prefix, hashedKey, scopes string) (database.ApiKey, error) {
+	key, err := p.q.CreateAPIKey(ctx, database.CreateAPIKeyParams{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		HashedKey: hashedKey,
+		Scopes:    scopes,
+	})
+	return key, pgErr(err)
+}
+
+func (p *Postgres) GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error) {
+	key, err := p.q.GetAPIKeyByHash(ctx, hashedKey)
+	return key, pgErr(err)
+}
+
+func (p *Postgres) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	keys, err := p.q.ListAPIKeysByUser(ctx, userID)
+	return keys, pgErr(err)
+}
+
+func (p *Postgres) DeleteAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	return affected(p.q.DeleteAPIKey(ctx, database.DeleteAPIKeyParams{ID: id, UserID: userID}))
+}
+
+func (p *Postgres) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	return pgErr(p.q.TouchAPIKey(ctx, id))
+}
+
+func (p *Postgres) CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) (database.Identity, error) {
+	identity, err := p.q.CreateIdentity(ctx, database.CreateIdentityParams{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	return identity, pgErr(err)
+}
+
+func (p *Postgres) GetIdentity(ctx context.Context, provider, subject string) (database.Identity, error) {
+	identity, err := p.q.GetIdentity(ctx, database.GetIdentityParams{Provider: provider, Subject: subject})
+	return identity, pgErr(err)
+}
+
+func (p *Postgres) ListIdentities(ctx context.Context, userID uuid.UUID) ([]database.Identity, error) {
+	identities, err := p.q.ListIdentitiesByUser(ctx, userID)
+	return identities, pgErr(err)
+}
+
+func (p *Postgres) DeleteIdentity(ctx context.Context, userID uuid.UUID, provider string) error {
+	return affected(p.q.DeleteIdentity(ctx, database.DeleteIdentityParams{UserID: userID, Provider: provider}))
+}
+
+func (p *Postgres) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return pgErr(p.tx(ctx, func(q *database.Queries) error {
+		err := q.CreateBlock(ctx, database.CreateBlockParams{BlockerID: blockerID, BlockedID: blockedID})
+		if err != nil {
+			return err
+		}
+		return q.DeleteFollowsBetween(ctx, database.DeleteFollowsBetweenParams{UserID: blockerID, OtherID: blockedID})
+	}))
+}
+
+func (p *Postgres) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return affected(p.q.DeleteBlock(ctx, database.DeleteBlockParams{BlockerID: blockerID, BlockedID: blockedID}))
+}
+
+func (p *Postgres) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	blocks, err := p.q.ListBlocksByUser(ctx, blockerID)
+	return blocks, pgErr(err)
+}
+
+func (p *Postgres) BlockedUsers(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := p.q.ListBlockedUserIDs(ctx, userID)
+	return ids, pgErr(err)
+}
+
+func (p *Postgres) Blocked(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	blocked, err := p.q.BlockExists(ctx, database.BlockExistsParams{UserID: userID, OtherID: otherID})
+	return blocked, pgErr(err)
+}
+
+func (p *Postgres) MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return pgErr(p.q.CreateMute(ctx, database.CreateMuteParams{MuterID: muterID, MutedID: mutedID}))
+}
+
+func (p *Postgres) UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return affected(p.q.DeleteMute(ctx, database.DeleteMuteParams{MuterID: muterID, MutedID: mutedID}))
+}
+
+func (p *Postgres) ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	mutes, err := p.q.ListMutesByUser(ctx, muterID)
+	return mutes, pgErr(err)
+}
+
+func (p *Postgres) Follow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	follow, err := p.q.CreateFollow(ctx, database.CreateFollowParams{FollowerID: followerID, FolloweeID: followeeID})
+	return follow, pgErr(err)
+}
+
+func (p *Postgres) GetFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	follow, err := p.q.GetFollow(ctx, database.GetFollowParams{FollowerID: followerID, FolloweeID: followeeID})
+	return follow, pgErr(err)
+}
+
+func (p *Postgres) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	return affected(p.q.DeleteFollow(ctx, database.DeleteFollowParams{FollowerID: followerID, FolloweeID: followeeID}))
+}
+
+func (p *Postgres) ListFollowing(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error) {
+	follows, err := p.q.ListFollowing(ctx, followerID)
+	return follows, pgErr(err)
+}
+
+func (p *Postgres) ListFollowers(ctx context.Context, followeeID uuid.UUID, status string) ([]database.Follow, error) {
+	follows, err := p.q.ListFollowers(ctx, database.ListFollowersParams{FolloweeID: followeeID, Status: status})
+	return follows, pgErr(err)
+}
+
+func (p *Postgres) AcceptFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	follow, err := p.q.AcceptFollow(ctx, database.AcceptFollowParams{FollowerID: followerID, FolloweeID: followeeID})
+	return follow, pgErr(err)
+}
+
+func (p *Postgres) DenyFollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	return affected(p.q.DeletePendingFollow(ctx, database.DeletePendingFollowParams{FollowerID: followerID, FolloweeID: followeeID}))
+}
+
+func (p *Postgres) HiddenPrivateUsers(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := p.q.ListHiddenPrivateUserIDs(ctx, viewerID)
+	return ids, pgErr(err)
+}
+
+func (p *Postgres) CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	visible, err := p.q.CanSeeChirps(ctx, database.CanSeeChirpsParams{ViewerID: viewerID, AuthorID: authorID})
+	return visible, pgErr(err)
+}
+
+func (p *Postgres) BookmarkChirp(ctx context.Context, userID, chirpID, collectionID uuid.UUID) (database.Bookmark, error) {
+	if collectionID != uuid.Nil {
+		_, err := p.q.GetBookmarkCollection(ctx, database.GetBookmarkCollectionParams{ID: collectionID, UserID: userID})
+		if err != nil {
+			return database.Bookmark{}, pgErr(err)
+		}
+	}
+	bookmark, err := p.q.CreateBookmark(ctx, database.CreateBookmarkParams{
+		UserID:       userID,
+		ChirpID:      chirpID,
+		CollectionID: uuid.NullUUID{UUID: collectionID, Valid: collectionID != uuid.Nil},
+	})
+	return bookmark, pgErr(err)
+}
+
+func (p *Postgres) RemoveBookmark(ctx context.Context, userID, chirpID uuid.UUID) error {
+	return affected(p.q.DeleteBookmark(ctx, database.DeleteBookmarkParams{UserID: userID, ChirpID: chirpID}))
+}
+
+func (p *Postgres) ListBookmarks(ctx context.Context, userID, collectionID uuid.UUID, after BookmarkCursor, limit int) ([]database.ListBookmarksRow, error) {
+	bookmarks, err := p.q.ListBookmarks(ctx, database.ListBookmarksParams{
+		UserID:         userID,
+		InCollection:   collectionID != uuid.Nil,
+		CollectionID:   collectionID,
+		Paged:          after != BookmarkCursor{},
+		AfterCreatedAt: after.CreatedAt,
+		AfterChirpID:   after.ChirpID,
+		MaxResults:     int32(limit),
+	})
+	return bookmarks, pgErr(err)
+}
+
+func (p *Postgres) CreateBookmarkCollection(ctx context.Context, userID uuid.UUID, name string) (database.BookmarkCollection, error) {
+	collection, err := p.q.CreateBookmarkCollection(ctx, database.CreateBookmarkCollectionParams{UserID: userID, Name: name})
+	return collection, pgErr(err)
+}
+
+func (p *Postgres) ListBookmarkCollections(ctx context.Context, userID uuid.UUID) ([]database.BookmarkCollection, error) {
+	collections, err := p.q.ListBookmarkCollections(ctx, userID)
+	return collections, pgErr(err)
+}
+
+func (p *Postgres) DeleteBookmarkCollection(ctx context.Context, id, userID uuid.UUID) error {
+	return affected(p.q.DeleteBookmarkCollection(ctx, database.DeleteBookmarkCollectionParams{ID: id, UserID: userID}))
+}
+
+func (p *Postgres) CreateOAuthClient(ctx context.Context, userID uuid.UUID, name, redirectURIs string, hashedSecret sql.NullString) (database.OauthClient, error) {
+	client, err := p.q.CreateOAuthClient(ctx, database.CreateOAuthClientParams{
+		UserID:       userID,
+		Name:         name,
+		RedirectUris: redirectURIs,
+		HashedSecret: hashedSecret,
+	})
+	return client, pgErr(err)
+}
+
+func (p *Postgres) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	client, err := p.q.GetOAuthClient(ctx, id)
+	return client, pgErr(err)
+}
+
+func (p *Postgres) ListOAuthClients(ctx context.Context, userID uuid.UUID) ([]database.OauthClient, error) {
+	clients, err := p.q.ListOAuthClientsByUser(ctx, userID)
+	return clients, pgErr(err)
+}
+
+func (p *Postgres) DeleteOAuthClient(ctx context.Context, id, userID uuid.UUID) error {
+	return affected(p.q.DeleteOAuthClient(ctx, database.DeleteOAuthClientParams{ID: id, UserID: userID}))
+}
+
+func (p *Postgres) CreateAuthorizationCode(ctx context.Context, code database.OauthCode) error {
+	return pgErr(p.q.CreateAuthorizationCode(ctx, database.CreateAuthorizationCodeParams{
+		Code:          code.Code,
+		ClientID:      code.ClientID,
+		UserID:        code.UserID,
+		RedirectUri:   code.RedirectUri,
+		Scopes:        code.Scopes,
+		CodeChallenge: code.CodeChallenge,
+		// TIMESTAMP drops the zone, and lib/pq reads it back as UTC.
+		ExpiresAt: code.ExpiresAt.UTC(),
+	}))
+}
+
+func (p *Postgres) ConsumeAuthorizationCode(ctx context.Context, code string) (database.OauthCode, error) {
+	consumed, err := p.q.ConsumeAuthorizationCode(ctx, code)
+	if err != nil {
+		return database.OauthCode{}, pgErr(err)
+	}
+	if !consumed.ExpiresAt.After(time.Now()) {
+		return database.OauthCode{}, ErrNotFound
+	}
+	return consumed, nil
+}
+
+func (p *Postgres) UpgradeUser(ctx context.Context, id uuid.UUID) error {
+	return affected(p.q.Upgradeuser(ctx, id))
+}
+
+func (p *Postgres) EnqueueJob(ctx context.Context, job database.Job) (database.Job, error) {
+	enqueued, err := p.q.EnqueueJob(ctx, database.EnqueueJobParams{
+		Kind:        job.Kind,
+		Payload:     job.Payload,
+		MaxAttempts: job.MaxAttempts,
+		RunAt:       job.RunAt.UTC(),
+		UniqueKey:   job.UniqueKey,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// ON CONFLICT DO NOTHING inserted nothing to return.
+		return database.Job{}, ErrConflict
+	}
+	return enqueued, pgErr(err)
+}
+
+func (p *Postgres) ClaimJobs(ctx context.Context, kinds []string, limit int) ([]database.Job, error) {
+	claimed, err := p.q.ClaimJobs(ctx, database.ClaimJobsParams{Kinds: kinds, MaxJobs: int32(limit)})
+	return claimed, pgErr(err)
+}
+
+func (p *Postgres) CompleteJob(ctx context.Context, id uuid.UUID) error {
+	return pgErr(p.q.CompleteJob(ctx, id))
+}
+
+func (p *Postgres) RetryJob(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	return pgErr(p.q.RetryJob(ctx, database.RetryJobParams{
+		ID:        id,
+		RunAt:     runAt.UTC(),
+		LastError: sql.NullString{String: lastError, Valid: true},
+	}))
+}
+
+func (p *Postgres) KillJob(ctx context.Context, id uuid.UUID, lastError string) error {
+	return pgErr(p.q.KillJob(ctx, database.KillJobParams{
+		ID:        id,
+		LastError: sql.NullString{String: lastError, Valid: true},
+	}))
+}
+
+func (p *Postgres) RequeueStaleJobs(ctx context.Context, lockedBefore time.Time, lastError string) (int64, error) {
+	requeued, err := p.q.RequeueStaleJobs(ctx, database.RequeueStaleJobsParams{
+		LastError:    sql.NullString{String: lastError, Valid: true},
+		LockedBefore: sql.NullTime{Time: lockedBefore.UTC(), Valid: true},
+	})
+	return requeued, pgErr(err)
+}
+
+func (p *Postgres) GetJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	job, err := p.q.GetJob(ctx, id)
+	return job, pgErr(err)
+}
+
+func (p *Postgres) ListJobs(ctx context.Context, status string, limit int) ([]database.Job, error) {
+	jobs, err := p.q.ListJobs(ctx, database.ListJobsParams{Status: status, MaxResults: int32(limit)})
+	return jobs, pgErr(err)
+}
+
+func (p *Postgres) RetryDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	job, err := p.q.RetryDeadJob(ctx, id)
+	return job, pgErr(err)
+}
+
+func (p *Postgres) DeleteFinishedJobsBefore(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := p.q.DeleteFinishedJobsBefore(ctx, before.UTC())
+	return deleted, pgErr(err)
+}
+
+func (p *Postgres) CreateWebhookEndpoint(ctx context.Context, userID uuid.UUID, url, secret string, events []string) (database.WebhookEndpoint, error) {
+	endpoint, err := p.q.CreateWebhookEndpoint(ctx, database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    url,
+		Secret: secret,
+		Events: events,
+	})
+	return endpoint, pgErr(err)
+}
+
+func (p *Postgres) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	endpoint, err := p.q.GetWebhookEndpoint(ctx, id)
+	return endpoint, pgErr(err)
+}
+
+func (p *Postgres) ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	endpoints, err := p.q.ListWebhookEndpointsByUser(ctx, userID)
+	return endpoints, pgErr(err)
+}
+
+func (p *Postgres) ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]database.WebhookEndpoint, error) {
+	endpoints, err := p.q.ListWebhookEndpointsForEvent(ctx, event)
+	return endpoints, pgErr(err)
+}
+
+func (p *Postgres) DeleteWebhookEndpoint(ctx context.Context, id, userID uuid.UUID) error {
+	return affected(p.q.DeleteWebhookEndpoint(ctx, database.DeleteWebhookEndpointParams{ID: id, UserID: userID}))
+}
+
+func (p *Postgres) RecordWebhookEndpointFailure(ctx context.Context, id uuid.UUID) (int32, error) {
+	failures, err := p.q.RecordWebhookEndpointFailure(ctx, id)
+	return failures, pgErr(err)
+}
+
+func (p *Postgres) ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	return pgErr(p.q.ResetWebhookEndpointFailures(ctx, id))
+}
+
+func (p *Postgres) DisableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return pgErr(p.q.DisableWebhookEndpoint(ctx, id))
+}
+
+func (p *Postgres) EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return pgErr(p.q.EnableWebhookEndpoint(ctx, id))
+}
+
+func (p *Postgres) CreateWebhookDelivery(ctx context.Context, endpointID uuid.UUID, event string, payload json.RawMessage) (database.WebhookDelivery, error) {
+	delivery, err := p.q.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		EndpointID: endpointID,
+		Event:      event,
+		Payload:    payload,
+	})
+	return delivery, pgErr(err)
+}
+
+func (p *Postgres) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	delivery, err := p.q.GetWebhookDelivery(ctx, id)
+	return delivery, pgErr(err)
+}
+
+func (p *Postgres) UpdateWebhookDelivery(ctx context.Context, id uuid.UUID, status string, attempts int32, lastError string) error {
+	return pgErr(p.q.UpdateWebhookDeliveryState(ctx, database.UpdateWebhookDeliveryStateParams{
+		ID:        id,
+		Status:    status,
+		Attempts:  attempts,
+		LastError: sql.NullString{String: lastError, Valid: lastError != ""},
+	}))
+}
+
+func (p *Postgres) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	delivery, err := p.q.ReplayWebhookDelivery(ctx, id)
+	return delivery, pgErr(err)
+}
+
+func (p *Postgres) CreateWebhookDeliveryAttempt(ctx context.Context, attempt database.WebhookDeliveryAttempt) error {
+	return pgErr(p.q.CreateWebhookDeliveryAttempt(ctx, database.CreateWebhookDeliveryAttemptParams{
+		DeliveryID: attempt.DeliveryID,
+		Attempt:    attempt.Attempt,
+		StatusCode: attempt.StatusCode,
+		Error:      attempt.Error,
+		DurationMs: attempt.DurationMs,
+	}))
+}
+
+func (p *Postgres) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error) {
+	attempts, err := p.q.ListWebhookDeliveryAttempts(ctx, deliveryID)
+	return attempts, pgErr(err)
+}
+
+func (p *Postgres) CreateNotification(ctx context.Context, userID uuid.UUID, typ string, actorID, chirpID uuid.UUID) (database.Notification, error) {
+	n, err := p.q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:  userID,
+		Type:    typ,
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	})
+	return n, pgErr(err)
+}
+
+func (p *Postgres) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]database.Notification, error) {
+	notifications, err := p.q.ListNotifications(ctx, database.ListNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: unreadOnly,
+		MaxResults: int32(limit),
+	})
+	return notifications, pgErr(err)
+}
+
+func (p *Postgres) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	unread, err := p.q.CountUnreadNotifications(ctx, userID)
+	return unread, pgErr(err)
+}
+
+func (p *Postgres) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	marked, err := p.q.MarkNotificationsRead(ctx, database.MarkNotificationsReadParams{UserID: userID, Ids: ids})
+	return marked, pgErr(err)
+}
+
+func (p *Postgres) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	marked, err := p.q.MarkAllNotificationsRead(ctx, userID)
+	return marked, pgErr(err)
+}
+
+func (p *Postgres) GetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string) (bool, error) {
+	enabled, err := p.q.GetNotificationPreference(ctx, database.GetNotificationPreferenceParams{UserID: userID, Type: typ})
+	return enabled, pgErr(err)
+}
+
+func (p *Postgres) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	prefs, err := p.q.ListNotificationPreferences(ctx, userID)
+	return prefs, pgErr(err)
+}
+
+func (p *Postgres) SetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string, enabled bool) error {
+	return pgErr(p.q.UpsertNotificationPreference(ctx, database.UpsertNotificationPreferenceParams{
+		UserID:  userID,
+		Type:    typ,
+		Enabled: enabled,
+	}))
+}
+
+func (p *Postgres) Reset(ctx context.Context) error {
+	return pgErr(p.tx(ctx, func(q *database.Queries) error {
+		err := q.ResetTable(ctx)
+		if err != nil {
+			return err
+		}
+		return q.DeleteAllJobs(ctx)
+	}))
+}
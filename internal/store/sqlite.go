@@ -0,0 +1,893 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/database/sqlite"
+	sqlitedriver "modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLite is the Store backed by the sqlc queries in
+// internal/database/sqlite. SQLite has no gen_random_uuid() or NOW(), so
+// IDs and timestamps are made here. Timestamps are stored as UTC text,
+// which keeps their string order the same as their time order.
+type SQLite struct {
+	db DB
+	q  *sqlite.Queries
+}
+
+var _ Store = (*SQLite)(nil)
+
+// NewSQLite -
+func NewSQLite(db DB) *SQLite {
+	return &SQLite{db: db, q: sqlite.New(db)}
+}
+
+// tx runs fn with queries in a transaction, which commits if fn returns
+// nil. Errors come back from fn as it returned them.
+func (s *SQLite) tx(ctx context.Context, fn func(q *sqlite.Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = fn(s.q.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteErr maps driver errors onto the store's sentinel errors.
+func sqliteErr(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	var liteErr *sqlitedriver.Error
+	if errors.As(err, &liteErr) {
+		switch liteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return ErrConflict
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+func sqliteAffected(rows int64, err error) error {
+	if err != nil {
+		return sqliteErr(err)
+	}
+	if rows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// The sqlite models have the same fields as the Postgres ones, so they
+// convert directly.
+
+func liteUser(u sqlite.User, err error) (database.User, error) {
+	return database.User(u), sqliteErr(err)
+}
+
+func liteChirps(rows []sqlite.Chirp, err error) ([]database.Chirp, error) {
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var chirps []database.Chirp
+	for _, c := range rows {
+		chirps = append(chirps, database.Chirp(c))
+	}
+	return chirps, nil
+}
+
+func liteFollows(rows []sqlite.Follow, err error) ([]database.Follow, error) {
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var follows []database.Follow
+	for _, f := range rows {
+		follows = append(follows, database.Follow(f))
+	}
+	return follows, nil
+}
+
+func (s *SQLite) CreateUser(ctx context.Context, email, hashedPassword string) (database.User, error) {
+	return liteUser(s.q.CreateUser(ctx, sqlite.CreateUserParams{
+		ID:             uuid.New(),
+		Now:            now(),
+		Email:          email,
+		HashedPassword: hashedPassword,
+	}))
+}
+
+func (s *SQLite) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	return liteUser(s.q.GetUserByID(ctx, id))
+}
+
+func (s *SQLite) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	return liteUser(s.q.GetUserByEmail(ctx, email))
+}
+
+func (s *SQLite) UpdateUserCredentials(ctx context.Context, id uuid.UUID, email, hashedPassword string) (database.User, error) {
+	return liteUser(s.q.UpdateEmailAndPass(ctx, sqlite.UpdateEmailAndPassParams{
+		Email:          email,
+		HashedPassword: hashedPassword,
+		Now:            now(),
+		ID:             id,
+	}))
+}
+
+func (s *SQLite) SetUserPrivate(ctx context.Context, id uuid.UUID, private bool) (database.User, error) {
+	var user database.User
+	err := s.tx(ctx, func(q *sqlite.Queries) error {
+		var err error
+		user, err = liteUser(q.SetUserPrivate(ctx, sqlite.SetUserPrivateParams{IsPrivate: private, Now: now(), ID: id}))
+		if err != nil || private {
+			return err
+		}
+		// Requests made while the account was private are still pending.
+		return q.AcceptPendingFollows(ctx, id)
+	})
+	if err != nil {
+		return database.User{}, sqliteErr(err)
+	}
+	return user, nil
+}
+
+func (s *SQLite) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteUser(ctx, id))
+}
+
+func (s *SQLite) CreateChirp(ctx context.Context, userID uuid.UUID, body string) (database.Chirp, error) {
+	chirp, err := s.q.CreateChirp(ctx, sqlite.CreateChirpParams{
+		ID:     uuid.New(),
+		Now:    now(),
+		Body:   body,
+		UserID: userID,
+	})
+	return database.Chirp(chirp), sqliteErr(err)
+}
+
+func (s *SQLite) GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	chirp, err := s.q.GetChirpByID(ctx, id)
+	return database.Chirp(chirp), sqliteErr(err)
+}
+
+func (s *SQLite) ListChirps(ctx context.Context) ([]database.Chirp, error) {
+	return liteChirps(s.q.GetChirps(ctx))
+}
+
+func (s *SQLite) ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	return liteChirps(s.q.GetChirpsByID(ctx, userID))
+}
+
+func (s *SQLite) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteChirpByID(ctx, id))
+}
+
+func (s *SQLite) CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) error {
+	return sqliteErr(s.q.CreateTokenDB(ctx, sqlite.CreateTokenDBParams{
+		Token:     token,
+		Now:       now(),
+		UserID:    userID,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Microsecond),
+	}))
+}
+
+func (s *SQLite) GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error) {
+	return liteUser(s.q.GetUserFromRefreshToken(ctx, sqlite.GetUserFromRefreshTokenParams{Token: token, Now: now()}))
+}
+
+func (s *SQLite) CreateClientRefreshToken(ctx context.Context, token string, userID, clientID uuid.UUID, scopes string, expiresAt time.Time) error {
+	return sqliteErr(s.q.CreateClientRefreshToken(ctx, sqlite.CreateClientRefreshTokenParams{
+		Token:     token,
+		Now:       now(),
+		UserID:    userID,
+		ExpiresAt: expiresAt.UTC().Truncate(time.Microsecond),
+		ClientID:  uuid.NullUUID{UUID: clientID, Valid: true},
+		Scopes:    scopes,
+	}))
+}
+
+func (s *SQLite) GetClientRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	rt, err := s.q.GetClientRefreshToken(ctx, sqlite.GetClientRefreshTokenParams{Token: token, Now: now()})
+	return database.RefreshToken(rt), sqliteErr(err)
+}
+
+func (s *SQLite) RevokeRefreshToken(ctx context.Context, token string) error {
+	return sqliteErr(s.q.RevokeToken(ctx, sqlite.RevokeTokenParams{Now: now(), Token: token}))
+}
+
+func (s *SQLite) RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error) {
+	revoked, err := s.q.RevokeUserRefreshTokens(ctx, sqlite.RevokeUserRefreshTokensParams{Now: now(), UserID: userID})
+	return revoked, sqliteErr(err)
+}
+
+func (s *SQLite) RevokeAllRefreshTokens(ctx context.Context) (int64, error) {
+	revoked, err := s.q.RevokeAllRefreshTokens(ctx, now())
+	return revoked, sqliteErr(err)
+}
+
+func (s *SQLite) DeleteExpiredRefreshTokens(ctx context.Context) (int64, error) {
+	t := now()
+	purged, err := s.q.DeleteExpiredRefreshTokens(ctx, sqlite.DeleteExpiredRefreshTokensParams{
+		Now:           t,
+		RevokedBefore: sql.NullTime{Time: t.Add(-revokedRetention), Valid: true},
+	})
+	return purged, sqliteErr(err)
+}
+
+func (s *SQLite) CreateAPIKey(ctx context.Context, userID uuid.UUID, name, prefix, hashedKey, scopes string) (database.ApiKey, error) {
+	key, err := s.q.CreateAPIKey(ctx, sqlite.CreateAPIKeyParams{
+		ID:        uuid.New(),
+		Now:       now(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		HashedKey: hashedKey,
+		Scopes:    scopes,
+	})
+	return database.ApiKey(key), sqliteErr(err)
+}
+
+func (s *SQLite) GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error) {
+	key, err := s.q.GetAPIKeyByHash(ctx, hashedKey)
+	return database.ApiKey(key), sqliteErr(err)
+}
+
+func (s *SQLite) ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error) {
+	rows, err := s.q.ListAPIKeysByUser(ctx, userID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var keys []database.ApiKey
+	for _, k := range rows {
+		keys = append(keys, database.ApiKey(k))
+	}
+	return keys, nil
+}
+
+func (s *SQLite) DeleteAPIKey(ctx context.Context, id, userID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteAPIKey(ctx, sqlite.DeleteAPIKeyParams{ID: id, UserID: userID}))
+}
+
+func (s *SQLite) TouchAPIKey(ctx context.Context, id uuid.UUID) error {
+	return sqliteErr(s.q.TouchAPIKey(ctx, sqlite.TouchAPIKeyParams{Now: now(), ID: id}))
+}
+
+func (s *SQLite) CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) (database.Identity, error) {
+	identity, err := s.q.CreateIdentity(ctx, sqlite.CreateIdentityParams{
+		ID:       uuid.New(),
+		Now:      now(),
+		UserID:   userID,
+		Provider: provider,
+		Subject:  subject,
+		Email:    email,
+	})
+	return database.Identity(identity), sqliteErr(err)
+}
+
+func (s *SQLite) GetIdentity(ctx context.Context, provider, subject string) (database.Identity, error) {
+	identity, err := s.q.GetIdentity(ctx, sqlite.GetIdentityParams{Provider: provider, Subject: subject})
+	return database.Identity(identity), sqliteErr(err)
+}
+
+func (s *SQLite) ListIdentities(ctx context.Context, userID uuid.UUID) ([]database.Identity, error) {
+	rows, err := s.q.ListIdentitiesByUser(ctx, userID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var identities []database.Identity
+	for _, i := range rows {
+		identities = append(identities, database.Identity(i))
+	}
+	return identities, nil
+}
+
+func (s *SQLite) DeleteIdentity(ctx context.Context, userID uuid.UUID, provider string) error {
+	return sqliteAffected(s.q.DeleteIdentity(ctx, sqlite.DeleteIdentityParams{UserID: userID, Provider: provider}))
+}
+
+func (s *SQLite) BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return sqliteErr(s.tx(ctx, func(q *sqlite.Queries) error {
+		err := q.CreateBlock(ctx, sqlite.CreateBlockParams{BlockerID: blockerID, BlockedID: blockedID, Now: now()})
+		if err != nil {
+			return err
+		}
+		return q.DeleteFollowsBetween(ctx, sqlite.DeleteFollowsBetweenParams{UserID: blockerID, OtherID: blockedID})
+	}))
+}
+
+func (s *SQLite) UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteBlock(ctx, sqlite.DeleteBlockParams{BlockerID: blockerID, BlockedID: blockedID}))
+}
+
+func (s *SQLite) ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	rows, err := s.q.ListBlocksByUser(ctx, blockerID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var blocks []database.Block
+	for _, b := range rows {
+		blocks = append(blocks, database.Block(b))
+	}
+	return blocks, nil
+}
+
+func (s *SQLite) BlockedUsers(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := s.q.ListBlockedUserIDs(ctx, userID)
+	return ids, sqliteErr(err)
+}
+
+func (s *SQLite) Blocked(ctx context.Context, userID, otherID uuid.UUID) (bool, error) {
+	blocked, err := s.q.BlockExists(ctx, sqlite.BlockExistsParams{UserID: userID, OtherID: otherID})
+	return blocked, sqliteErr(err)
+}
+
+func (s *SQLite) MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return sqliteErr(s.q.CreateMute(ctx, sqlite.CreateMuteParams{MuterID: muterID, MutedID: mutedID, Now: now()}))
+}
+
+func (s *SQLite) UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteMute(ctx, sqlite.DeleteMuteParams{MuterID: muterID, MutedID: mutedID}))
+}
+
+func (s *SQLite) ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	rows, err := s.q.ListMutesByUser(ctx, muterID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var mutes []database.Mute
+	for _, m := range rows {
+		mutes = append(mutes, database.Mute(m))
+	}
+	return mutes, nil
+}
+
+func (s *SQLite) Follow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	follow, err := s.q.CreateFollow(ctx, sqlite.CreateFollowParams{FollowerID: followerID, Now: now(), FolloweeID: followeeID})
+	return database.Follow(follow), sqliteErr(err)
+}
+
+func (s *SQLite) GetFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	follow, err := s.q.GetFollow(ctx, sqlite.GetFollowParams{FollowerID: followerID, FolloweeID: followeeID})
+	return database.Follow(follow), sqliteErr(err)
+}
+
+func (s *SQLite) Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteFollow(ctx, sqlite.DeleteFollowParams{FollowerID: followerID, FolloweeID: followeeID}))
+}
+
+func (s *SQLite) ListFollowing(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error) {
+	return liteFollows(s.q.ListFollowing(ctx, followerID))
+}
+
+func (s *SQLite) ListFollowers(ctx context.Context, followeeID uuid.UUID, status string) ([]database.Follow, error) {
+	return liteFollows(s.q.ListFollowers(ctx, sqlite.ListFollowersParams{FolloweeID: followeeID, Status: status}))
+}
+
+func (s *SQLite) AcceptFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error) {
+	follow, err := s.q.AcceptFollow(ctx, sqlite.AcceptFollowParams{FollowerID: followerID, FolloweeID: followeeID})
+	return database.Follow(follow), sqliteErr(err)
+}
+
+func (s *SQLite) DenyFollow(ctx context.Context, followerID, followeeID uuid.UUID) error {
+	return sqliteAffected(s.q.DeletePendingFollow(ctx, sqlite.DeletePendingFollowParams{FollowerID: followerID, FolloweeID: followeeID}))
+}
+
+func (s *SQLite) HiddenPrivateUsers(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error) {
+	ids, err := s.q.ListHiddenPrivateUserIDs(ctx, viewerID)
+	return ids, sqliteErr(err)
+}
+
+func (s *SQLite) CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	visible, err := s.q.CanSeeChirps(ctx, sqlite.CanSeeChirpsParams{ViewerID: viewerID, AuthorID: authorID})
+	return visible, sqliteErr(err)
+}
+
+func (s *SQLite) BookmarkChirp(ctx context.Context, userID, chirpID, collectionID uuid.UUID) (database.Bookmark, error) {
+	if collectionID != uuid.Nil {
+		_, err := s.q.GetBookmarkCollection(ctx, sqlite.GetBookmarkCollectionParams{ID: collectionID, UserID: userID})
+		if err != nil {
+			return database.Bookmark{}, sqliteErr(err)
+		}
+	}
+	bookmark, err := s.q.CreateBookmark(ctx, sqlite.CreateBookmarkParams{
+		UserID:       userID,
+		ChirpID:      chirpID,
+		CollectionID: uuid.NullUUID{UUID: collectionID, Valid: collectionID != uuid.Nil},
+		Now:          now(),
+	})
+	return database.Bookmark(bookmark), sqliteErr(err)
+}
+
+func (s *SQLite) RemoveBookmark(ctx context.Context, userID, chirpID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteBookmark(ctx, sqlite.DeleteBookmarkParams{UserID: userID, ChirpID: chirpID}))
+}
+
+func (s *SQLite) ListBookmarks(ctx context.Context, userID, collectionID uuid.UUID, after BookmarkCursor, limit int) ([]database.ListBookmarksRow, error) {
+	rows, err := s.q.ListBookmarks(ctx, sqlite.ListBookmarksParams{
+		UserID:         userID,
+		InCollection:   collectionID != uuid.Nil,
+		CollectionID:   uuid.NullUUID{UUID: collectionID, Valid: collectionID != uuid.Nil},
+		Paged:          after != BookmarkCursor{},
+		AfterCreatedAt: after.CreatedAt,
+		AfterChirpID:   after.ChirpID,
+		MaxResults:     int64(limit),
+	})
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var bookmarks []database.ListBookmarksRow
+	for _, row := range rows {
+		bookmarks = append(bookmarks, database.ListBookmarksRow{
+			Bookmark: database.Bookmark(row.Bookmark),
+			Chirp:    database.Chirp(row.Chirp),
+		})
+	}
+	return bookmarks, nil
+}
+
+func (s *SQLite) CreateBookmarkCollection(ctx context.Context, userID uuid.UUID, name string) (database.BookmarkCollection, error) {
+	collection, err := s.q.CreateBookmarkCollection(ctx, sqlite.CreateBookmarkCollectionParams{
+		ID:     uuid.New(),
+		Now:    now(),
+		UserID: userID,
+		Name:   name,
+	})
+	return database.BookmarkCollection(collection), sqliteErr(err)
+}
+
+func (s *SQLite) ListBookmarkCollections(ctx context.Context, userID uuid.UUID) ([]database.BookmarkCollection, error) {
+	rows, err := s.q.ListBookmarkCollections(ctx, userID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var collections []database.BookmarkCollection
+	for _, c := range rows {
+		collections = append(collections, database.BookmarkCollection(c))
+	}
+	return collections, nil
+}
+
+func (s *SQLite) DeleteBookmarkCollection(ctx context.Context, id, userID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteBookmarkCollection(ctx, sqlite.DeleteBookmarkCollectionParams{ID: id, UserID: userID}))
+}
+
+func (s *SQLite) CreateOAuthClient(ctx context.Context, userID uuid.UUID, name, redirectURIs string, hashedSecret sql.NullString) (database.OauthClient, error) {
+	client, err := s.q.CreateOAuthClient(ctx, sqlite.CreateOAuthClientParams{
+		ID:           uuid.New(),
+		Now:          now(),
+		UserID:       userID,
+		Name:         name,
+		RedirectUris: redirectURIs,
+		HashedSecret: hashedSecret,
+	})
+	return database.OauthClient(client), sqliteErr(err)
+}
+
+func (s *SQLite) GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	client, err := s.q.GetOAuthClient(ctx, id)
+	return database.OauthClient(client), sqliteErr(err)
+}
+
+func (s *SQLite) ListOAuthClients(ctx context.Context, userID uuid.UUID) ([]database.OauthClient, error) {
+	rows, err := s.q.ListOAuthClientsByUser(ctx, userID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var clients []database.OauthClient
+	for _, c := range rows {
+		clients = append(clients, database.OauthClient(c))
+	}
+	return clients, nil
+}
+
+func (s *SQLite) DeleteOAuthClient(ctx context.Context, id, userID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteOAuthClient(ctx, sqlite.DeleteOAuthClientParams{ID: id, UserID: userID}))
+}
+
+func (s *SQLite) CreateAuthorizationCode(ctx context.Context, code database.OauthCode) error {
+	return sqliteErr(s.q.CreateAuthorizationCode(ctx, sqlite.CreateAuthorizationCodeParams{
+		Code:          code.Code,
+		Now:           now(),
+		ClientID:      code.ClientID,
+		UserID:        code.UserID,
+		RedirectUri:   code.RedirectUri,
+		Scopes:        code.Scopes,
+		CodeChallenge: code.CodeChallenge,
+		ExpiresAt:     code.ExpiresAt.UTC().Truncate(time.Microsecond),
+	}))
+}
+
+func (s *SQLite) ConsumeAuthorizationCode(ctx context.Context, code string) (database.OauthCode, error) {
+	consumed, err := s.q.ConsumeAuthorizationCode(ctx, code)
+	if err != nil {
+		return database.OauthCode{}, sqliteErr(err)
+	}
+	if !consumed.ExpiresAt.After(now()) {
+		return database.OauthCode{}, ErrNotFound
+	}
+	return database.OauthCode(consumed), nil
+}
+
+func (s *SQLite) UpgradeUser(ctx context.Context, id uuid.UUID) error {
+	return sqliteAffected(s.q.Upgradeuser(ctx, id))
+}
+
+// Jobs, webhook endpoints, deliveries and attempts differ from their
+// Postgres models: SQLite integers are int64, payloads are text, and an
+// endpoint's events are space-separated.
+
+func liteJob(j sqlite.Job) database.Job {
+	return database.Job{
+		ID:          j.ID,
+		CreatedAt:   j.CreatedAt,
+		UpdatedAt:   j.UpdatedAt,
+		Kind:        j.Kind,
+		Payload:     json.RawMessage(j.Payload),
+		Status:      j.Status,
+		Attempts:    int32(j.Attempts),
+		MaxAttempts: int32(j.MaxAttempts),
+		RunAt:       j.RunAt,
+		LockedAt:    j.LockedAt,
+		LastError:   j.LastError,
+		UniqueKey:   j.UniqueKey,
+	}
+}
+
+func liteJobs(rows []sqlite.Job, err error) ([]database.Job, error) {
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var jobs []database.Job
+	for _, j := range rows {
+		jobs = append(jobs, liteJob(j))
+	}
+	return jobs, nil
+}
+
+func liteEndpoint(e sqlite.WebhookEndpoint) database.WebhookEndpoint {
+	return database.WebhookEndpoint{
+		ID:                  e.ID,
+		CreatedAt:           e.CreatedAt,
+		UpdatedAt:           e.UpdatedAt,
+		UserID:              e.UserID,
+		Url:                 e.Url,
+		Secret:              e.Secret,
+		Events:              strings.Fields(e.Events),
+		ConsecutiveFailures: int32(e.ConsecutiveFailures),
+		DisabledAt:          e.DisabledAt,
+	}
+}
+
+func liteEndpoints(rows []sqlite.WebhookEndpoint, err error) ([]database.WebhookEndpoint, error) {
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var endpoints []database.WebhookEndpoint
+	for _, e := range rows {
+		endpoints = append(endpoints, liteEndpoint(e))
+	}
+	return endpoints, nil
+}
+
+func liteDelivery(d sqlite.WebhookDelivery) database.WebhookDelivery {
+	return database.WebhookDelivery{
+		ID:         d.ID,
+		CreatedAt:  d.CreatedAt,
+		UpdatedAt:  d.UpdatedAt,
+		EndpointID: d.EndpointID,
+		Event:      d.Event,
+		Payload:    json.RawMessage(d.Payload),
+		Status:     d.Status,
+		Attempts:   int32(d.Attempts),
+		LastError:  d.LastError,
+	}
+}
+
+func (s *SQLite) EnqueueJob(ctx context.Context, job database.Job) (database.Job, error) {
+	t := now()
+	enqueued, err := s.q.EnqueueJob(ctx, sqlite.EnqueueJobParams{
+		ID:          uuid.New(),
+		Now:         t,
+		Kind:        job.Kind,
+		Payload:     string(job.Payload),
+		MaxAttempts: int64(job.MaxAttempts),
+		RunAt:       job.RunAt.UTC().Truncate(time.Microsecond),
+		UniqueKey:   job.UniqueKey,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// ON CONFLICT DO NOTHING inserted nothing to return.
+		return database.Job{}, ErrConflict
+	}
+	if err != nil {
+		return database.Job{}, sqliteErr(err)
+	}
+	return liteJob(enqueued), nil
+}
+
+func (s *SQLite) ClaimJobs(ctx context.Context, kinds []string, limit int) ([]database.Job, error) {
+	t := now()
+	return liteJobs(s.q.ClaimJobs(ctx, sqlite.ClaimJobsParams{
+		LockedAt:  sql.NullTime{Time: t, Valid: true},
+		UpdatedAt: t,
+		RunAt:     t,
+		Kinds:     kinds,
+		Limit:     int64(limit),
+	}))
+}
+
+func (s *SQLite) CompleteJob(ctx context.Context, id uuid.UUID) error {
+	return sqliteErr(s.q.CompleteJob(ctx, sqlite.CompleteJobParams{Now: now(), ID: id}))
+}
+
+func (s *SQLite) RetryJob(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	return sqliteErr(s.q.RetryJob(ctx, sqlite.RetryJobParams{
+		RunAt:     runAt.UTC().Truncate(time.Microsecond),
+		LastError: sql.NullString{String: lastError, Valid: true},
+		Now:       now(),
+		ID:        id,
+	}))
+}
+
+func (s *SQLite) KillJob(ctx context.Context, id uuid.UUID, lastError string) error {
+	return sqliteErr(s.q.KillJob(ctx, sqlite.KillJobParams{
+		LastError: sql.NullString{String: lastError, Valid: true},
+		Now:       now(),
+		ID:        id,
+	}))
+}
+
+func (s *SQLite) RequeueStaleJobs(ctx context.Context, lockedBefore time.Time, lastError string) (int64, error) {
+	requeued, err := s.q.RequeueStaleJobs(ctx, sqlite.RequeueStaleJobsParams{
+		LastError:    sql.NullString{String: lastError, Valid: true},
+		Now:          now(),
+		LockedBefore: sql.NullTime{Time: lockedBefore.UTC().Truncate(time.Microsecond), Valid: true},
+	})
+	return requeued, sqliteErr(err)
+}
+
+func (s *SQLite) GetJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	job, err := s.q.GetJob(ctx, id)
+	if err != nil {
+		return database.Job{}, sqliteErr(err)
+	}
+	return liteJob(job), nil
+}
+
+func (s *SQLite) ListJobs(ctx context.Context, status string, limit int) ([]database.Job, error) {
+	return liteJobs(s.q.ListJobs(ctx, sqlite.ListJobsParams{Status: status, MaxResults: int64(limit)}))
+}
+
+func (s *SQLite) RetryDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error) {
+	job, err := s.q.RetryDeadJob(ctx, sqlite.RetryDeadJobParams{Now: now(), ID: id})
+	if err != nil {
+		return database.Job{}, sqliteErr(err)
+	}
+	return liteJob(job), nil
+}
+
+func (s *SQLite) DeleteFinishedJobsBefore(ctx context.Context, before time.Time) (int64, error) {
+	deleted, err := s.q.DeleteFinishedJobsBefore(ctx, before.UTC().Truncate(time.Microsecond))
+	return deleted, sqliteErr(err)
+}
+
+func (s *SQLite) CreateWebhookEndpoint(ctx context.Context, userID uuid.UUID, url, secret string, events []string) (database.WebhookEndpoint, error) {
+	endpoint, err := s.q.CreateWebhookEndpoint(ctx, sqlite.CreateWebhookEndpointParams{
+		ID:     uuid.New(),
+		Now:    now(),
+		UserID: userID,
+		Url:    url,
+		Secret: secret,
+		Events: strings.Join(events, " "),
+	})
+	if err != nil {
+		return database.WebhookEndpoint{}, sqliteErr(err)
+	}
+	return liteEndpoint(endpoint), nil
+}
+
+func (s *SQLite) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	endpoint, err := s.q.GetWebhookEndpoint(ctx, id)
+	if err != nil {
+		return database.WebhookEndpoint{}, sqliteErr(err)
+	}
+	return liteEndpoint(endpoint), nil
+}
+
+func (s *SQLite) ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	return liteEndpoints(s.q.ListWebhookEndpointsByUser(ctx, userID))
+}
+
+func (s *SQLite) ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]database.WebhookEndpoint, error) {
+	return liteEndpoints(s.q.ListWebhookEndpointsForEvent(ctx, event))
+}
+
+func (s *SQLite) DeleteWebhookEndpoint(ctx context.Context, id, userID uuid.UUID) error {
+	return sqliteAffected(s.q.DeleteWebhookEndpoint(ctx, sqlite.DeleteWebhookEndpointParams{ID: id, UserID: userID}))
+}
+
+func (s *SQLite) RecordWebhookEndpointFailure(ctx context.Context, id uuid.UUID) (int32, error) {
+	failures, err := s.q.RecordWebhookEndpointFailure(ctx, sqlite.RecordWebhookEndpointFailureParams{Now: now(), ID: id})
+	return int32(failures), sqliteErr(err)
+}
+
+func (s *SQLite) ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	return sqliteErr(s.q.ResetWebhookEndpointFailures(ctx, sqlite.ResetWebhookEndpointFailuresParams{Now: now(), ID: id}))
+}
+
+func (s *SQLite) DisableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return sqliteErr(s.q.DisableWebhookEndpoint(ctx, sqlite.DisableWebhookEndpointParams{Now: now(), ID: id}))
+}
+
+func (s *SQLite) EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	return sqliteErr(s.q.EnableWebhookEndpoint(ctx, sqlite.EnableWebhookEndpointParams{Now: now(), ID: id}))
+}
+
+func (s *SQLite) CreateWebhookDelivery(ctx context.Context, endpointID uuid.UUID, event string, payload json.RawMessage) (database.WebhookDelivery, error) {
+	delivery, err := s.q.CreateWebhookDelivery(ctx, sqlite.CreateWebhookDeliveryParams{
+		ID:         uuid.New(),
+		Now:        now(),
+		EndpointID: endpointID,
+		Event:      event,
+		Payload:    string(payload),
+	})
+	if err != nil {
+		return database.WebhookDelivery{}, sqliteErr(err)
+	}
+	return liteDelivery(delivery), nil
+}
+
+func (s *SQLite) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	delivery, err := s.q.GetWebhookDelivery(ctx, id)
+	if err != nil {
+		return database.WebhookDelivery{}, sqliteErr(err)
+	}
+	return liteDelivery(delivery), nil
+}
+
+func (s *SQLite) UpdateWebhookDelivery(ctx context.Context, id uuid.UUID, status string, attempts int32, lastError string) error {
+	return sqliteErr(s.q.UpdateWebhookDeliveryState(ctx, sqlite.UpdateWebhookDeliveryStateParams{
+		Status:    status,
+		Attempts:  int64(attempts),
+		LastError: sql.NullString{String: lastError, Valid: lastError != ""},
+		Now:       now(),
+		ID:        id,
+	}))
+}
+
+func (s *SQLite) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	delivery, err := s.q.ReplayWebhookDelivery(ctx, sqlite.ReplayWebhookDeliveryParams{Now: now(), ID: id})
+	if err != nil {
+		return database.WebhookDelivery{}, sqliteErr(err)
+	}
+	return liteDelivery(delivery), nil
+}
+
+func (s *SQLite) CreateWebhookDeliveryAttempt(ctx context.Context, attempt database.WebhookDeliveryAttempt) error {
+	return sqliteErr(s.q.CreateWebhookDeliveryAttempt(ctx, sqlite.CreateWebhookDeliveryAttemptParams{
+		ID:         uuid.New(),
+		Now:        now(),
+		DeliveryID: attempt.DeliveryID,
+		Attempt:    int64(attempt.Attempt),
+		StatusCode: sql.NullInt64{Int64: int64(attempt.StatusCode.Int32), Valid: attempt.StatusCode.Valid},
+		Error:      attempt.Error,
+		DurationMs: int64(attempt.DurationMs),
+	}))
+}
+
+func (s *SQLite) ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error) {
+	rows, err := s.q.ListWebhookDeliveryAttempts(ctx, deliveryID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var attempts []database.WebhookDeliveryAttempt
+	for _, a := range rows {
+		attempts = append(attempts, database.WebhookDeliveryAttempt{
+			ID:         a.ID,
+			CreatedAt:  a.CreatedAt,
+			DeliveryID: a.DeliveryID,
+			Attempt:    int32(a.Attempt),
+			StatusCode: sql.NullInt32{Int32: int32(a.StatusCode.Int64), Valid: a.StatusCode.Valid},
+			Error:      a.Error,
+			DurationMs: int32(a.DurationMs),
+		})
+	}
+	return attempts, nil
+}
+
+func (s *SQLite) CreateNotification(ctx context.Context, userID uuid.UUID, typ string, actorID, chirpID uuid.UUID) (database.Notification, error) {
+	n, err := s.q.CreateNotification(ctx, sqlite.CreateNotificationParams{
+		ID:      uuid.New(),
+		Now:     now(),
+		UserID:  userID,
+		Type:    typ,
+		ActorID: uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil},
+		ChirpID: uuid.NullUUID{UUID: chirpID, Valid: chirpID != uuid.Nil},
+	})
+	return database.Notification(n), sqliteErr(err)
+}
+
+func (s *SQLite) ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]database.Notification, error) {
+	rows, err := s.q.ListNotifications(ctx, sqlite.ListNotificationsParams{
+		UserID:     userID,
+		UnreadOnly: unreadOnly,
+		MaxResults: int64(limit),
+	})
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var notifications []database.Notification
+	for _, n := range rows {
+		notifications = append(notifications, database.Notification(n))
+	}
+	return notifications, nil
+}
+
+func (s *SQLite) CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error) {
+	unread, err := s.q.CountUnreadNotifications(ctx, userID)
+	return unread, sqliteErr(err)
+}
+
+func (s *SQLite) MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	marked, err := s.q.MarkNotificationsRead(ctx, sqlite.MarkNotificationsReadParams{
+		ReadAt: sql.NullTime{Time: now(), Valid: true},
+		UserID: userID,
+		Ids:    ids,
+	})
+	return marked, sqliteErr(err)
+}
+
+func (s *SQLite) MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error) {
+	marked, err := s.q.MarkAllNotificationsRead(ctx, sqlite.MarkAllNotificationsReadParams{Now: now(), UserID: userID})
+	return marked, sqliteErr(err)
+}
+
+func (s *SQLite) GetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string) (bool, error) {
+	enabled, err := s.q.GetNotificationPreference(ctx, sqlite.GetNotificationPreferenceParams{UserID: userID, Type: typ})
+	return enabled, sqliteErr(err)
+}
+
+func (s *SQLite) ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error) {
+	rows, err := s.q.ListNotificationPreferences(ctx, userID)
+	if err != nil {
+		return nil, sqliteErr(err)
+	}
+	var prefs []database.NotificationPreference
+	for _, p := range rows {
+		prefs = append(prefs, database.NotificationPreference(p))
+	}
+	return prefs, nil
+}
+
+func (s *SQLite) SetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string, enabled bool) error {
+	return sqliteErr(s.q.UpsertNotificationPreference(ctx, sqlite.UpsertNotificationPreferenceParams{
+		UserID:  userID,
+		Type:    typ,
+		Enabled: enabled,
+		Now:     now(),
+	}))
+}
+
+func (s *SQLite) Reset(ctx context.Context) error {
+	return sqliteErr(s.tx(ctx, func(q *sqlite.Queries) error {
+		err := q.ResetTable(ctx)
+		if err != nil {
+			return err
+		}
+		return q.DeleteAllJobs(ctx)
+	}))
+}
@@ -0,0 +1,362 @@
+// Package store is the data layer behind the API: users, chirps, refresh
+// tokens, API keys, OAuth clients, linked OpenID Connect identities,
+// blocks and mutes, follows, bookmarks, Chirpy Red subscriptions, and the
+// background jobs, webhooks and notifications built on them.
+//
+// Postgres is the production backend. SQLite runs a single server from a
+// file, and Memory keeps everything in process for tests and local
+// development. All of them must pass the contract suite in storetest, so
+// handlers can't tell them apart.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/database"
+)
+
+var (
+	// ErrNotFound means the row doesn't exist, or for refresh tokens, that
+	// the token has expired or been revoked.
+	ErrNotFound = errors.New("store: not found")
+	// ErrConflict means a unique value, such as an email, is already taken.
+	ErrConflict = errors.New("store: already exists")
+)
+
+// Store is everything the HTTP handlers need from a backend.
+type Store interface {
+	Users
+	Chirps
+	Tokens
+	APIKeys
+	OAuth
+	Identities
+	Relations
+	Follows
+	Bookmarks
+	Subscriptions
+	Jobs
+	Webhooks
+	Notifications
+
+	// Reset deletes every user along with everything they own, and every
+	// job.
+	Reset(ctx context.Context) error
+}
+
+// Users stores accounts. Emails are unique.
+type Users interface {
+	CreateUser(ctx context.Context, email, hashedPassword string) (database.User, error)
+	GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error)
+	GetUserByEmail(ctx context.Context, email string) (database.User, error)
+	UpdateUserCredentials(ctx context.Context, id uuid.UUID, email, hashedPassword string) (database.User, error)
+	// SetUserPrivate makes an account private or public. Making it public
+	// accepts its pending follow requests.
+	SetUserPrivate(ctx context.Context, id uuid.UUID, private bool) (database.User, error)
+	// DeleteUser also deletes the user's chirps, refresh tokens, API
+	// keys, OAuth clients, identities, bookmarks and collections, webhook
+	// endpoints, notifications and notification preferences,
+	// notifications they caused, and blocks, mutes and follows either way.
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+}
+
+// Chirps stores chirps. Lists are ordered oldest first.
+type Chirps interface {
+	CreateChirp(ctx context.Context, userID uuid.UUID, body string) (database.Chirp, error)
+	GetChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error)
+	ListChirps(ctx context.Context) ([]database.Chirp, error)
+	ListChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error)
+	// DeleteChirp also deletes every bookmark of the chirp and every
+	// notification about it.
+	DeleteChirp(ctx context.Context, id uuid.UUID) error
+}
+
+// Tokens stores refresh tokens. Tokens from login belong to the user;
+// client tokens were issued to an OAuth client and carry the scopes the
+// user granted it. Neither kind is accepted in place of the other.
+type Tokens interface {
+	CreateRefreshToken(ctx context.Context, token string, userID uuid.UUID, expiresAt time.Time) error
+	// GetUserFromRefreshToken returns ErrNotFound unless the token is
+	// known, unexpired, unrevoked and not a client token.
+	GetUserFromRefreshToken(ctx context.Context, token string) (database.User, error)
+	// CreateClientRefreshToken stores a token issued to clientID. scopes
+	// is space-separated.
+	CreateClientRefreshToken(ctx context.Context, token string, userID, clientID uuid.UUID, scopes string, expiresAt time.Time) error
+	// GetClientRefreshToken returns ErrNotFound unless the token is a
+	// known, unexpired and unrevoked client token.
+	GetClientRefreshToken(ctx context.Context, token string) (database.RefreshToken, error)
+	// RevokeRefreshToken is a no-op for unknown tokens.
+	RevokeRefreshToken(ctx context.Context, token string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID uuid.UUID) (int64, error)
+	RevokeAllRefreshTokens(ctx context.Context) (int64, error)
+	// DeleteExpiredRefreshTokens purges expired tokens and those revoked
+	// more than 30 days ago.
+	DeleteExpiredRefreshTokens(ctx context.Context) (int64, error)
+}
+
+// APIKeys stores personal API keys. Only a hash of each key is kept, so
+// keys are looked up by hash.
+type APIKeys interface {
+	// CreateAPIKey stores a key for userID. scopes is space-separated.
+	CreateAPIKey(ctx context.Context, userID uuid.UUID, name, prefix, hashedKey, scopes string) (database.ApiKey, error)
+	GetAPIKeyByHash(ctx context.Context, hashedKey string) (database.ApiKey, error)
+	ListAPIKeys(ctx context.Context, userID uuid.UUID) ([]database.ApiKey, error)
+	// DeleteAPIKey returns ErrNotFound unless userID owns the key.
+	DeleteAPIKey(ctx context.Context, id, userID uuid.UUID) error
+	// TouchAPIKey records that the key was just used.
+	TouchAPIKey(ctx context.Context, id uuid.UUID) error
+}
+
+// OAuth stores the OAuth clients users register and the authorization
+// codes issued to them.
+type OAuth interface {
+	// CreateOAuthClient registers a client owned by userID. redirectURIs
+	// is space-separated. Public clients have no secret.
+	CreateOAuthClient(ctx context.Context, userID uuid.UUID, name, redirectURIs string, hashedSecret sql.NullString) (database.OauthClient, error)
+	GetOAuthClient(ctx context.Context, id uuid.UUID) (database.OauthClient, error)
+	ListOAuthClients(ctx context.Context, userID uuid.UUID) ([]database.OauthClient, error)
+	// DeleteOAuthClient returns ErrNotFound unless userID owns the
+	// client. It also deletes the client's codes and refresh tokens.
+	DeleteOAuthClient(ctx context.Context, id, userID uuid.UUID) error
+	// CreateAuthorizationCode stores code. Its CreatedAt is ignored.
+	CreateAuthorizationCode(ctx context.Context, code database.OauthCode) error
+	// ConsumeAuthorizationCode deletes the code, so it can only be used
+	// once, and returns it. It returns ErrNotFound if the code is unknown
+	// or has expired.
+	ConsumeAuthorizationCode(ctx context.Context, code string) (database.OauthCode, error)
+}
+
+// Identities links users to accounts at external OpenID Connect
+// providers, which are named in the config. A provider account links to
+// one user, and a user has at most one identity per provider.
+type Identities interface {
+	// CreateIdentity returns ErrConflict if the provider account is
+	// already linked, to anyone, or userID already has an identity at
+	// provider.
+	CreateIdentity(ctx context.Context, userID uuid.UUID, provider, subject, email string) (database.Identity, error)
+	GetIdentity(ctx context.Context, provider, subject string) (database.Identity, error)
+	ListIdentities(ctx context.Context, userID uuid.UUID) ([]database.Identity, error)
+	// DeleteIdentity returns ErrNotFound unless userID has an identity at
+	// provider.
+	DeleteIdentity(ctx context.Context, userID uuid.UUID, provider string) error
+}
+
+// Relations stores the blocks and mutes between users. Lists are ordered
+// oldest first. Callers keep users from blocking or muting themselves.
+type Relations interface {
+	// BlockUser is a no-op if blockerID already blocks blockedID. It
+	// returns ErrNotFound if either user doesn't exist. Blocking ends any
+	// follow or follow request between the two.
+	BlockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	// UnblockUser returns ErrNotFound unless blockerID blocks blockedID.
+	UnblockUser(ctx context.Context, blockerID, blockedID uuid.UUID) error
+	ListBlocks(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error)
+	// BlockedUsers lists everyone userID has blocked or been blocked by.
+	BlockedUsers(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+	// Blocked reports whether either user has blocked the other.
+	Blocked(ctx context.Context, userID, otherID uuid.UUID) (bool, error)
+
+	// MuteUser is a no-op if muterID already mutes mutedID. It returns
+	// ErrNotFound if either user doesn't exist.
+	MuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error
+	// UnmuteUser returns ErrNotFound unless muterID mutes mutedID.
+	UnmuteUser(ctx context.Context, muterID, mutedID uuid.UUID) error
+	ListMutes(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error)
+}
+
+// Follow statuses stored in follows.status.
+const (
+	FollowPending  = "pending"
+	FollowAccepted = "accepted"
+)
+
+// Follows stores who follows whom. Following a private account makes a
+// pending request that its owner accepts or denies. Lists are ordered
+// oldest first.
+type Follows interface {
+	// Follow makes followerID follow followeeID, at once if the followee
+	// is public or as a pending request if private. Following again
+	// returns the existing follow. It returns ErrNotFound if either user
+	// doesn't exist.
+	Follow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error)
+	GetFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error)
+	// Unfollow ends a follow or withdraws a request.
+	Unfollow(ctx context.Context, followerID, followeeID uuid.UUID) error
+	// ListFollowing lists who followerID follows or has asked to.
+	ListFollowing(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error)
+	// ListFollowers lists the follows of followeeID with status.
+	ListFollowers(ctx context.Context, followeeID uuid.UUID, status string) ([]database.Follow, error)
+	// AcceptFollow and DenyFollow return ErrNotFound unless followerID
+	// has a pending request to follow followeeID.
+	AcceptFollow(ctx context.Context, followerID, followeeID uuid.UUID) (database.Follow, error)
+	DenyFollow(ctx context.Context, followerID, followeeID uuid.UUID) error
+
+	// HiddenPrivateUsers lists the private accounts whose chirps viewerID
+	// can't see, which is every one of them for uuid.Nil.
+	HiddenPrivateUsers(ctx context.Context, viewerID uuid.UUID) ([]uuid.UUID, error)
+	// CanSeeChirps reports whether viewerID may see authorID's chirps:
+	// neither has blocked the other, and authorID is public, is viewerID,
+	// or has accepted viewerID as a follower. It returns ErrNotFound if
+	// authorID doesn't exist.
+	CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error)
+}
+
+// BookmarkCursor marks a place in a user's bookmarks: listing resumes with
+// the bookmark after this one. The zero value is the start of the list.
+type BookmarkCursor struct {
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+}
+
+// Bookmarks stores the chirps users save for themselves and the named
+// collections they file them in. A user bookmarks a chirp at most once,
+// in at most one collection. Collection names are unique per user.
+type Bookmarks interface {
+	// BookmarkChirp saves chirpID for userID in collectionID, or unfiled
+	// for uuid.Nil. Bookmarking a chirp again moves it to collectionID
+	// and keeps its place in the list. It returns ErrNotFound if the
+	// chirp doesn't exist or userID has no such collection.
+	BookmarkChirp(ctx context.Context, userID, chirpID, collectionID uuid.UUID) (database.Bookmark, error)
+	// RemoveBookmark returns ErrNotFound unless userID bookmarked chirpID.
+	RemoveBookmark(ctx context.Context, userID, chirpID uuid.UUID) error
+	// ListBookmarks lists up to limit of userID's bookmarks after the
+	// cursor with their chirps, newest first. A collectionID other than
+	// uuid.Nil lists only that collection. Bookmarks of chirps userID
+	// can't see, by the rules of CanSeeChirps, are left out.
+	ListBookmarks(ctx context.Context, userID, collectionID uuid.UUID, after BookmarkCursor, limit int) ([]database.ListBookmarksRow, error)
+
+	// CreateBookmarkCollection returns ErrConflict if userID already has
+	// a collection called name.
+	CreateBookmarkCollection(ctx context.Context, userID uuid.UUID, name string) (database.BookmarkCollection, error)
+	// ListBookmarkCollections is ordered oldest first.
+	ListBookmarkCollections(ctx context.Context, userID uuid.UUID) ([]database.BookmarkCollection, error)
+	// DeleteBookmarkCollection returns ErrNotFound unless userID owns the
+	// collection. Its bookmarks are kept, unfiled.
+	DeleteBookmarkCollection(ctx context.Context, id, userID uuid.UUID) error
+}
+
+// Subscriptions tracks Chirpy Red membership.
+type Subscriptions interface {
+	UpgradeUser(ctx context.Context, id uuid.UUID) error
+}
+
+// Job statuses stored in jobs.status.
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobDead      = "dead"
+)
+
+// Jobs stores the background job queue. Claiming a job marks it running
+// and counts the attempt; the worker then completes, retries or kills it.
+type Jobs interface {
+	// EnqueueJob stores a pending job. Only its Kind, Payload,
+	// MaxAttempts, RunAt and UniqueKey are used. It returns ErrConflict
+	// if another job has the same UniqueKey.
+	EnqueueJob(ctx context.Context, job database.Job) (database.Job, error)
+	// ClaimJobs marks up to limit pending jobs of kinds that are due
+	// running, earliest RunAt first, and returns them. No job is handed to
+	// two callers, even from different processes.
+	ClaimJobs(ctx context.Context, kinds []string, limit int) ([]database.Job, error)
+	CompleteJob(ctx context.Context, id uuid.UUID) error
+	// RetryJob makes a job pending again from runAt.
+	RetryJob(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error
+	KillJob(ctx context.Context, id uuid.UUID, lastError string) error
+	// RequeueStaleJobs makes jobs that have been running since before
+	// lockedBefore pending again, or dead if that was their last attempt,
+	// records lastError on them and returns how many there were.
+	RequeueStaleJobs(ctx context.Context, lockedBefore time.Time, lastError string) (int64, error)
+	GetJob(ctx context.Context, id uuid.UUID) (database.Job, error)
+	// ListJobs lists up to limit jobs with status, or of every status for
+	// "", newest first.
+	ListJobs(ctx context.Context, status string, limit int) ([]database.Job, error)
+	// RetryDeadJob makes a dead job pending again, due now and with no
+	// attempts. It returns ErrNotFound unless the job is dead.
+	RetryDeadJob(ctx context.Context, id uuid.UUID) (database.Job, error)
+	// DeleteFinishedJobsBefore deletes succeeded and dead jobs last
+	// updated before before, and returns how many there were.
+	DeleteFinishedJobsBefore(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Delivery statuses stored in webhook_deliveries.status.
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhooks stores the endpoints users subscribe to events, each delivery
+// of an event to an endpoint, and the log of every delivery attempt.
+type Webhooks interface {
+	CreateWebhookEndpoint(ctx context.Context, userID uuid.UUID, url, secret string, events []string) (database.WebhookEndpoint, error)
+	GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error)
+	// ListWebhookEndpoints is ordered oldest first.
+	ListWebhookEndpoints(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error)
+	// ListWebhookEndpointsForEvent lists the enabled endpoints subscribed
+	// to event.
+	ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]database.WebhookEndpoint, error)
+	// DeleteWebhookEndpoint returns ErrNotFound unless userID owns the
+	// endpoint. It also deletes the endpoint's deliveries.
+	DeleteWebhookEndpoint(ctx context.Context, id, userID uuid.UUID) error
+	// RecordWebhookEndpointFailure counts a failed attempt and returns
+	// how many in a row have failed.
+	RecordWebhookEndpointFailure(ctx context.Context, id uuid.UUID) (int32, error)
+	ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error
+	DisableWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+	// EnableWebhookEndpoint also resets its failure count.
+	EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+
+	// CreateWebhookDelivery records a pending delivery of payload.
+	CreateWebhookDelivery(ctx context.Context, endpointID uuid.UUID, event string, payload json.RawMessage) (database.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error)
+	// UpdateWebhookDelivery records how a delivery stands. An empty
+	// lastError is stored as NULL.
+	UpdateWebhookDelivery(ctx context.Context, id uuid.UUID, status string, attempts int32, lastError string) error
+	// ReplayWebhookDelivery makes a failed delivery pending again. It
+	// returns ErrNotFound unless the delivery failed.
+	ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error)
+	// CreateWebhookDeliveryAttempt logs attempt. Its ID and CreatedAt are
+	// ignored.
+	CreateWebhookDeliveryAttempt(ctx context.Context, attempt database.WebhookDeliveryAttempt) error
+	// ListWebhookDeliveryAttempts is ordered by attempt number.
+	ListWebhookDeliveryAttempts(ctx context.Context, deliveryID uuid.UUID) ([]database.WebhookDeliveryAttempt, error)
+}
+
+// Notifications stores in-app notifications and the types each user has
+// turned on or off.
+type Notifications interface {
+	// CreateNotification stores an unread notification for userID. A
+	// uuid.Nil actorID or chirpID is stored as NULL.
+	CreateNotification(ctx context.Context, userID uuid.UUID, typ string, actorID, chirpID uuid.UUID) (database.Notification, error)
+	// ListNotifications lists up to limit of userID's notifications,
+	// newest first.
+	ListNotifications(ctx context.Context, userID uuid.UUID, unreadOnly bool, limit int) ([]database.Notification, error)
+	CountUnreadNotifications(ctx context.Context, userID uuid.UUID) (int64, error)
+	// MarkNotificationsRead marks the listed notifications of userID's
+	// read and returns how many were unread. IDs of other users'
+	// notifications are skipped.
+	MarkNotificationsRead(ctx context.Context, userID uuid.UUID, ids []uuid.UUID) (int64, error)
+	MarkAllNotificationsRead(ctx context.Context, userID uuid.UUID) (int64, error)
+
+	// GetNotificationPreference returns ErrNotFound if userID has never
+	// set typ.
+	GetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string) (bool, error)
+	ListNotificationPreferences(ctx context.Context, userID uuid.UUID) ([]database.NotificationPreference, error)
+	SetNotificationPreference(ctx context.Context, userID uuid.UUID, typ string, enabled bool) error
+}
+
+// revokedRetention mirrors the 30 day interval in the Postgres
+// DeleteExpiredRefreshTokens query.
+const revokedRetention = 30 * 24 * time.Hour
+
+// now is the current time in UTC at the microsecond precision Postgres
+// stores timestamps at.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
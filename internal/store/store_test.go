@@ -0,0 +1,15 @@
+package store_test
+
+import (
+	"testing"
+
+	"github.com/willmelton21/chirpy/internal/store/storetest"
+)
+
+func TestBackends(t *testing.T) {
+	for _, backend := range storetest.Backends() {
+		t.Run(backend.Name, func(t *testing.T) {
+			storetest.Run(t, backend.New)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package storetest
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/migrations"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// PostgresURLEnv names a scratch Postgres database for tests. Tests
+// migrate it and delete every user in it, and packages sharing it must not
+// run in parallel, so use go test -p 1.
+const PostgresURLEnv = "CHIRPY_TEST_DB_URL"
+
+// Backend is a named constructor for an empty store.
+type Backend struct {
+	Name string
+	New  func(t *testing.T) store.Store
+}
+
+// Backends lists the stores to test against: memory and SQLite always,
+// and Postgres when PostgresURLEnv is set.
+func Backends() []Backend {
+	backends := []Backend{
+		{Name: store.DriverMemory, New: NewMemory},
+		{Name: store.DriverSQLite, New: NewSQLite},
+	}
+	if os.Getenv(PostgresURLEnv) != "" {
+		backends = append(backends, Backend{Name: store.DriverPostgres, New: NewPostgres})
+	}
+	return backends
+}
+
+// NewMemory -
+func NewMemory(t *testing.T) store.Store {
+	return store.NewMemory()
+}
+
+// NewSQLite returns a store on a migrated SQLite file in t.TempDir().
+func NewSQLite(t *testing.T) store.Store {
+	return open(t, "sqlite://"+filepath.Join(t.TempDir(), "chirpy.db"))
+}
+
+// NewPostgres returns a store on the database PostgresURLEnv names, and
+// skips t when it is unset.
+func NewPostgres(t *testing.T) store.Store {
+	dbURL := os.Getenv(PostgresURLEnv)
+	if dbURL == "" {
+		t.Skipf("set %s to run against Postgres", PostgresURLEnv)
+	}
+	return open(t, dbURL)
+}
+
+// open migrates dbURL's database and empties it.
+func open(t *testing.T, dbURL string) store.Store {
+	t.Helper()
+	ctx := context.Background()
+
+	driver, db, err := store.Open(dbURL)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := migrations.New(db, driver)
+	require.NoError(t, err)
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	s, err := store.New(driver, db)
+	require.NoError(t, err)
+	require.NoError(t, s.Reset(ctx))
+	return s
+}
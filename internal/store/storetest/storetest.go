@@ -0,0 +1,1341 @@
+// Package storetest is the contract every store.Store backend must meet.
+// Each backend's tests call Run with a constructor for an empty store.
+package storetest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// Run runs the contract suite. newStore must return an empty store; it is
+// called once per subtest.
+func Run(t *testing.T, newStore func(t *testing.T) store.Store) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, s store.Store)
+	}{
+		{"Users", testUsers},
+		{"UserEmailsAreUnique", testUserEmailsAreUnique},
+		{"DeleteUserCascades", testDeleteUserCascades},
+		{"Chirps", testChirps},
+		{"ChirpsNeedAnAuthor", testChirpsNeedAnAuthor},
+		{"RefreshTokens", testRefreshTokens},
+		{"RevokeManyRefreshTokens", testRevokeManyRefreshTokens},
+		{"DeleteExpiredRefreshTokens", testDeleteExpiredRefreshTokens},
+		{"APIKeys", testAPIKeys},
+		{"OAuthClients", testOAuthClients},
+		{"AuthorizationCodes", testAuthorizationCodes},
+		{"ClientRefreshTokens", testClientRefreshTokens},
+		{"Identities", testIdentities},
+		{"Blocks", testBlocks},
+		{"Mutes", testMutes},
+		{"Follows", testFollows},
+		{"PrivateAccounts", testPrivateAccounts},
+		{"Bookmarks", testBookmarks},
+		{"BookmarkCollections", testBookmarkCollections},
+		{"Subscriptions", testSubscriptions},
+		{"Jobs", testJobs},
+		{"JobUniqueKeys", testJobUniqueKeys},
+		{"RequeueStaleJobs", testRequeueStaleJobs},
+		{"DeleteFinishedJobs", testDeleteFinishedJobs},
+		{"WebhookEndpoints", testWebhookEndpoints},
+		{"WebhookDeliveries", testWebhookDeliveries},
+		{"Notifications", testNotifications},
+		{"NotificationPreferences", testNotificationPreferences},
+		{"Reset", testReset},
+		{"ConcurrentWrites", testConcurrentWrites},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newStore(t))
+		})
+	}
+}
+
+func createUser(t *testing.T, s store.Store, email string) uuid.UUID {
+	t.Helper()
+	user, err := s.CreateUser(context.Background(), email, "hash-"+email)
+	require.NoError(t, err)
+	return user.ID
+}
+
+func testUsers(t *testing.T, s store.Store) {
+	ctx := context.Background()
+
+	created, err := s.CreateUser(ctx, "alice@example.com", "hash")
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.Equal(t, "alice@example.com", created.Email)
+	assert.Equal(t, "hash", created.HashedPassword)
+	assert.False(t, created.IsChirpyRed.Bool)
+	assert.False(t, created.CreatedAt.IsZero())
+
+	byID, err := s.GetUserByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.Email, byID.Email)
+
+	byEmail, err := s.GetUserByEmail(ctx, "alice@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, byEmail.ID)
+
+	updated, err := s.UpdateUserCredentials(ctx, created.ID, "alice@example.org", "new-hash")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, updated.ID)
+	assert.Equal(t, "alice@example.org", updated.Email)
+	assert.Equal(t, "new-hash", updated.HashedPassword)
+	assert.False(t, updated.UpdatedAt.Before(created.UpdatedAt))
+
+	_, err = s.GetUserByEmail(ctx, "alice@example.com")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetUserByID(ctx, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.UpdateUserCredentials(ctx, uuid.New(), "nobody@example.com", "hash")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testUserEmailsAreUnique(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	_, err := s.CreateUser(ctx, "alice@example.com", "hash")
+	assert.ErrorIs(t, err, store.ErrConflict)
+
+	_, err = s.UpdateUserCredentials(ctx, bob, "alice@example.com", "hash")
+	assert.ErrorIs(t, err, store.ErrConflict)
+
+	// Keeping your own email isn't a conflict.
+	_, err = s.UpdateUserCredentials(ctx, bob, "bob@example.com", "new-hash")
+	assert.NoError(t, err)
+}
+
+func testDeleteUserCascades(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	alices, err := s.CreateChirp(ctx, alice, "from alice")
+	require.NoError(t, err)
+	bobs, err := s.CreateChirp(ctx, bob, "from bob")
+	require.NoError(t, err)
+	require.NoError(t, s.CreateRefreshToken(ctx, "alice-token", alice, time.Now().Add(time.Hour)))
+	_, err = s.CreateAPIKey(ctx, alice, "bot", "chirpy_ab", "alice-key-hash", "chirps:write")
+	require.NoError(t, err)
+	_, err = s.CreateIdentity(ctx, alice, "example", "alice-sub", "alice@example.com")
+	require.NoError(t, err)
+	require.NoError(t, s.BlockUser(ctx, bob, alice))
+	require.NoError(t, s.MuteUser(ctx, bob, alice))
+	_, err = s.Follow(ctx, bob, alice)
+	require.NoError(t, err)
+	_, err = s.BookmarkChirp(ctx, bob, alices.ID, uuid.Nil)
+	require.NoError(t, err)
+	collection, err := s.CreateBookmarkCollection(ctx, alice, "later")
+	require.NoError(t, err)
+	_, err = s.BookmarkChirp(ctx, alice, bobs.ID, collection.ID)
+	require.NoError(t, err)
+	endpoint, err := s.CreateWebhookEndpoint(ctx, alice, "https://example.com/hook", "secret", []string{"chirp.created"})
+	require.NoError(t, err)
+	delivery, err := s.CreateWebhookDelivery(ctx, endpoint.ID, "chirp.created", json.RawMessage(`{}`))
+	require.NoError(t, err)
+	require.NoError(t, s.CreateWebhookDeliveryAttempt(ctx, database.WebhookDeliveryAttempt{DeliveryID: delivery.ID, Attempt: 1}))
+	_, err = s.CreateNotification(ctx, alice, "follow", bob, uuid.Nil)
+	require.NoError(t, err)
+	_, err = s.CreateNotification(ctx, bob, "follow", alice, uuid.Nil)
+	require.NoError(t, err)
+	_, err = s.CreateNotification(ctx, bob, "mention", uuid.Nil, alices.ID)
+	require.NoError(t, err)
+	require.NoError(t, s.SetNotificationPreference(ctx, alice, "follow", false))
+
+	require.NoError(t, s.DeleteUser(ctx, alice))
+
+	_, err = s.GetUserByID(ctx, alice)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetUserFromRefreshToken(ctx, "alice-token")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetAPIKeyByHash(ctx, "alice-key-hash")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetIdentity(ctx, "example", "alice-sub")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	blocks, err := s.ListBlocks(ctx, bob)
+	require.NoError(t, err)
+	assert.Empty(t, blocks)
+	mutes, err := s.ListMutes(ctx, bob)
+	require.NoError(t, err)
+	assert.Empty(t, mutes)
+	following, err := s.ListFollowing(ctx, bob)
+	require.NoError(t, err)
+	assert.Empty(t, following)
+	bookmarks, err := s.ListBookmarks(ctx, bob, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, bookmarks)
+	bookmarks, err = s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, bookmarks)
+	collections, err := s.ListBookmarkCollections(ctx, alice)
+	require.NoError(t, err)
+	assert.Empty(t, collections)
+	chirps, err := s.ListChirps(ctx)
+	require.NoError(t, err)
+	if assert.Len(t, chirps, 1) {
+		assert.Equal(t, bobs.ID, chirps[0].ID)
+	}
+	_, err = s.GetWebhookEndpoint(ctx, endpoint.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetWebhookDelivery(ctx, delivery.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	attempts, err := s.ListWebhookDeliveryAttempts(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Empty(t, attempts)
+	// Bob's notifications were all caused by alice or her chirp.
+	notifications, err := s.ListNotifications(ctx, bob, false, 10)
+	require.NoError(t, err)
+	assert.Empty(t, notifications)
+	prefs, err := s.ListNotificationPreferences(ctx, alice)
+	require.NoError(t, err)
+	assert.Empty(t, prefs)
+
+	assert.ErrorIs(t, s.DeleteUser(ctx, alice), store.ErrNotFound)
+}
+
+func testChirps(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	empty, err := s.ListChirps(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	var ids []uuid.UUID
+	for i, author := range []uuid.UUID{alice, bob, alice} {
+		chirp, err := s.CreateChirp(ctx, author, fmt.Sprintf("chirp %d", i))
+		require.NoError(t, err)
+		assert.Equal(t, author, chirp.UserID)
+		ids = append(ids, chirp.ID)
+		// Postgres orders by created_at alone, so keep the timestamps apart.
+		time.Sleep(time.Millisecond)
+	}
+
+	got, err := s.GetChirp(ctx, ids[1])
+	require.NoError(t, err)
+	assert.Equal(t, "chirp 1", got.Body)
+	assert.Equal(t, bob, got.UserID)
+
+	all, err := s.ListChirps(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, ids, chirpIDs(all))
+
+	byAlice, err := s.ListChirpsByAuthor(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{ids[0], ids[2]}, chirpIDs(byAlice))
+
+	require.NoError(t, s.DeleteChirp(ctx, ids[0]))
+	_, err = s.GetChirp(ctx, ids[0])
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteChirp(ctx, ids[0]), store.ErrNotFound)
+}
+
+func chirpIDs(chirps []database.Chirp) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func testChirpsNeedAnAuthor(t *testing.T, s store.Store) {
+	_, err := s.CreateChirp(context.Background(), uuid.New(), "orphan")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+
+	require.NoError(t, s.CreateRefreshToken(ctx, "live", alice, time.Now().Add(time.Hour)))
+	require.NoError(t, s.CreateRefreshToken(ctx, "expired", alice, time.Now().Add(-time.Hour)))
+
+	user, err := s.GetUserFromRefreshToken(ctx, "live")
+	require.NoError(t, err)
+	assert.Equal(t, alice, user.ID)
+
+	_, err = s.GetUserFromRefreshToken(ctx, "expired")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetUserFromRefreshToken(ctx, "unknown")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.RevokeRefreshToken(ctx, "live"))
+	_, err = s.GetUserFromRefreshToken(ctx, "live")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	assert.NoError(t, s.RevokeRefreshToken(ctx, "unknown"))
+
+	err = s.CreateRefreshToken(ctx, "orphan", uuid.New(), time.Now().Add(time.Hour))
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testRevokeManyRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	expires := time.Now().Add(time.Hour)
+	for _, token := range []string{"alice-1", "alice-2"} {
+		require.NoError(t, s.CreateRefreshToken(ctx, token, alice, expires))
+	}
+	require.NoError(t, s.CreateRefreshToken(ctx, "bob-1", bob, expires))
+
+	revoked, err := s.RevokeUserRefreshTokens(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), revoked)
+
+	_, err = s.GetUserFromRefreshToken(ctx, "bob-1")
+	assert.NoError(t, err)
+
+	// Tokens that are already revoked aren't counted again.
+	revoked, err = s.RevokeAllRefreshTokens(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), revoked)
+
+	_, err = s.GetUserFromRefreshToken(ctx, "bob-1")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testDeleteExpiredRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	require.NoError(t, s.CreateRefreshToken(ctx, "live", alice, time.Now().Add(time.Hour)))
+	require.NoError(t, s.CreateRefreshToken(ctx, "revoked", alice, time.Now().Add(time.Hour)))
+	require.NoError(t, s.CreateRefreshToken(ctx, "expired", alice, time.Now().Add(-time.Hour)))
+	require.NoError(t, s.RevokeRefreshToken(ctx, "revoked"))
+
+	// Recently revoked tokens are kept, so only the expired one goes.
+	purged, err := s.DeleteExpiredRefreshTokens(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = s.GetUserFromRefreshToken(ctx, "live")
+	assert.NoError(t, err)
+}
+
+func testAPIKeys(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	created, err := s.CreateAPIKey(ctx, alice, "poster", "chirpy_ab", "hash-1", "chirps:read chirps:write")
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.Equal(t, alice, created.UserID)
+	assert.Equal(t, "poster", created.Name)
+	assert.Equal(t, "chirpy_ab", created.Prefix)
+	assert.Equal(t, "chirps:read chirps:write", created.Scopes)
+	assert.False(t, created.LastUsedAt.Valid)
+	time.Sleep(time.Millisecond)
+	second, err := s.CreateAPIKey(ctx, alice, "reader", "chirpy_cd", "hash-2", "chirps:read")
+	require.NoError(t, err)
+
+	_, err = s.CreateAPIKey(ctx, bob, "copy", "chirpy_ab", "hash-1", "chirps:read")
+	assert.ErrorIs(t, err, store.ErrConflict)
+	_, err = s.CreateAPIKey(ctx, uuid.New(), "orphan", "chirpy_ef", "hash-3", "chirps:read")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	got, err := s.GetAPIKeyByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+	_, err = s.GetAPIKeyByHash(ctx, "unknown")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.TouchAPIKey(ctx, created.ID))
+	got, err = s.GetAPIKeyByHash(ctx, "hash-1")
+	require.NoError(t, err)
+	assert.True(t, got.LastUsedAt.Valid)
+
+	keys, err := s.ListAPIKeys(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, keys, 2) {
+		assert.Equal(t, created.ID, keys[0].ID)
+		assert.Equal(t, second.ID, keys[1].ID)
+	}
+
+	// Only the owner can delete a key.
+	assert.ErrorIs(t, s.DeleteAPIKey(ctx, created.ID, bob), store.ErrNotFound)
+	require.NoError(t, s.DeleteAPIKey(ctx, created.ID, alice))
+	_, err = s.GetAPIKeyByHash(ctx, "hash-1")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteAPIKey(ctx, created.ID, alice), store.ErrNotFound)
+}
+
+func createOAuthClient(t *testing.T, s store.Store, owner uuid.UUID) uuid.UUID {
+	t.Helper()
+	client, err := s.CreateOAuthClient(context.Background(), owner, "App", "https://app.example/callback", sql.NullString{})
+	require.NoError(t, err)
+	return client.ID
+}
+
+func testOAuthClients(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	secret := sql.NullString{String: "secret-hash", Valid: true}
+	created, err := s.CreateOAuthClient(ctx, alice, "Confidential", "https://a.example/cb https://b.example/cb", secret)
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.Equal(t, alice, created.UserID)
+	assert.Equal(t, "https://a.example/cb https://b.example/cb", created.RedirectUris)
+	assert.Equal(t, secret, created.HashedSecret)
+	time.Sleep(time.Millisecond)
+	public, err := s.CreateOAuthClient(ctx, alice, "Public", "http://127.0.0.1/cb", sql.NullString{})
+	require.NoError(t, err)
+	assert.False(t, public.HashedSecret.Valid)
+
+	_, err = s.CreateOAuthClient(ctx, uuid.New(), "Orphan", "https://c.example/cb", sql.NullString{})
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	got, err := s.GetOAuthClient(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Confidential", got.Name)
+	_, err = s.GetOAuthClient(ctx, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	clients, err := s.ListOAuthClients(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, clients, 2) {
+		assert.Equal(t, created.ID, clients[0].ID)
+		assert.Equal(t, public.ID, clients[1].ID)
+	}
+
+	// Deleting a client revokes everything issued to it.
+	require.NoError(t, s.CreateClientRefreshToken(ctx, "client-token", bob, created.ID, "chirps:read", time.Now().Add(time.Hour)))
+	assert.ErrorIs(t, s.DeleteOAuthClient(ctx, created.ID, bob), store.ErrNotFound)
+	require.NoError(t, s.DeleteOAuthClient(ctx, created.ID, alice))
+	_, err = s.GetOAuthClient(ctx, created.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetClientRefreshToken(ctx, "client-token")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.DeleteUser(ctx, alice))
+	_, err = s.GetOAuthClient(ctx, public.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testAuthorizationCodes(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	client := createOAuthClient(t, s, alice)
+
+	code := database.OauthCode{
+		Code:          "live",
+		ClientID:      client,
+		UserID:        alice,
+		RedirectUri:   "https://app.example/callback",
+		Scopes:        "chirps:read",
+		CodeChallenge: "challenge",
+		ExpiresAt:     time.Now().Add(time.Minute),
+	}
+	require.NoError(t, s.CreateAuthorizationCode(ctx, code))
+	expired := code
+	expired.Code = "expired"
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	require.NoError(t, s.CreateAuthorizationCode(ctx, expired))
+
+	got, err := s.ConsumeAuthorizationCode(ctx, "live")
+	require.NoError(t, err)
+	assert.Equal(t, client, got.ClientID)
+	assert.Equal(t, alice, got.UserID)
+	assert.Equal(t, "https://app.example/callback", got.RedirectUri)
+	assert.Equal(t, "chirps:read", got.Scopes)
+	assert.Equal(t, "challenge", got.CodeChallenge)
+
+	// Codes work once.
+	_, err = s.ConsumeAuthorizationCode(ctx, "live")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.ConsumeAuthorizationCode(ctx, "expired")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.ConsumeAuthorizationCode(ctx, "unknown")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	orphan := code
+	orphan.Code = "orphan"
+	orphan.ClientID = uuid.New()
+	assert.ErrorIs(t, s.CreateAuthorizationCode(ctx, orphan), store.ErrNotFound)
+}
+
+func testClientRefreshTokens(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	client := createOAuthClient(t, s, alice)
+	expires := time.Now().Add(time.Hour)
+
+	require.NoError(t, s.CreateClientRefreshToken(ctx, "client", alice, client, "chirps:read chirps:write", expires))
+	require.NoError(t, s.CreateRefreshToken(ctx, "first-party", alice, expires))
+
+	rt, err := s.GetClientRefreshToken(ctx, "client")
+	require.NoError(t, err)
+	assert.Equal(t, alice, rt.UserID)
+	assert.Equal(t, uuid.NullUUID{UUID: client, Valid: true}, rt.ClientID)
+	assert.Equal(t, "chirps:read chirps:write", rt.Scopes)
+
+	// Neither kind of token is accepted as the other.
+	_, err = s.GetUserFromRefreshToken(ctx, "client")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetClientRefreshToken(ctx, "first-party")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.RevokeRefreshToken(ctx, "client"))
+	_, err = s.GetClientRefreshToken(ctx, "client")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	err = s.CreateClientRefreshToken(ctx, "orphan", alice, uuid.New(), "chirps:read", expires)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testIdentities(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	created, err := s.CreateIdentity(ctx, alice, "example", "sub-1", "alice@example.org")
+	require.NoError(t, err)
+	assert.NotEqual(t, uuid.Nil, created.ID)
+	assert.Equal(t, alice, created.UserID)
+	assert.Equal(t, "example", created.Provider)
+	assert.Equal(t, "sub-1", created.Subject)
+	assert.Equal(t, "alice@example.org", created.Email)
+	time.Sleep(time.Millisecond)
+	other, err := s.CreateIdentity(ctx, alice, "other", "sub-1", "alice@example.org")
+	require.NoError(t, err)
+
+	// A provider account links to one user, and a user to one account per
+	// provider.
+	_, err = s.CreateIdentity(ctx, bob, "example", "sub-1", "bob@example.org")
+	assert.ErrorIs(t, err, store.ErrConflict)
+	_, err = s.CreateIdentity(ctx, alice, "example", "sub-2", "alice@example.org")
+	assert.ErrorIs(t, err, store.ErrConflict)
+	_, err = s.CreateIdentity(ctx, uuid.New(), "example", "sub-3", "nobody@example.org")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	got, err := s.GetIdentity(ctx, "example", "sub-1")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, got.ID)
+	_, err = s.GetIdentity(ctx, "example", "sub-2")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	identities, err := s.ListIdentities(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, identities, 2) {
+		assert.Equal(t, created.ID, identities[0].ID)
+		assert.Equal(t, other.ID, identities[1].ID)
+	}
+
+	assert.ErrorIs(t, s.DeleteIdentity(ctx, bob, "example"), store.ErrNotFound)
+	require.NoError(t, s.DeleteIdentity(ctx, alice, "example"))
+	_, err = s.GetIdentity(ctx, "example", "sub-1")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteIdentity(ctx, alice, "example"), store.ErrNotFound)
+
+	// The account can be linked again once it is free.
+	_, err = s.CreateIdentity(ctx, bob, "example", "sub-1", "bob@example.org")
+	require.NoError(t, err)
+}
+
+func testBlocks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	carol := createUser(t, s, "carol@example.com")
+
+	require.NoError(t, s.BlockUser(ctx, alice, bob))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, s.BlockUser(ctx, alice, carol))
+	// Blocking twice is fine.
+	require.NoError(t, s.BlockUser(ctx, alice, bob))
+	require.NoError(t, s.BlockUser(ctx, carol, bob))
+	assert.ErrorIs(t, s.BlockUser(ctx, alice, uuid.New()), store.ErrNotFound)
+
+	blocks, err := s.ListBlocks(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, blocks, 2) {
+		assert.Equal(t, alice, blocks[0].BlockerID)
+		assert.Equal(t, bob, blocks[0].BlockedID)
+		assert.Equal(t, carol, blocks[1].BlockedID)
+	}
+
+	// Blocks hide users from each other whoever blocked whom.
+	blocked, err := s.BlockedUsers(ctx, bob)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{alice, carol}, blocked)
+	for _, pair := range [][2]uuid.UUID{{alice, bob}, {bob, alice}} {
+		isBlocked, err := s.Blocked(ctx, pair[0], pair[1])
+		require.NoError(t, err)
+		assert.True(t, isBlocked)
+	}
+
+	assert.ErrorIs(t, s.UnblockUser(ctx, bob, alice), store.ErrNotFound)
+	require.NoError(t, s.UnblockUser(ctx, alice, bob))
+	assert.ErrorIs(t, s.UnblockUser(ctx, alice, bob), store.ErrNotFound)
+	isBlocked, err := s.Blocked(ctx, bob, alice)
+	require.NoError(t, err)
+	assert.False(t, isBlocked)
+	blocked, err = s.BlockedUsers(ctx, bob)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{carol}, blocked)
+}
+
+func testMutes(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	carol := createUser(t, s, "carol@example.com")
+
+	require.NoError(t, s.MuteUser(ctx, alice, bob))
+	time.Sleep(time.Millisecond)
+	require.NoError(t, s.MuteUser(ctx, alice, carol))
+	require.NoError(t, s.MuteUser(ctx, alice, bob))
+	assert.ErrorIs(t, s.MuteUser(ctx, uuid.New(), bob), store.ErrNotFound)
+
+	mutes, err := s.ListMutes(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, mutes, 2) {
+		assert.Equal(t, alice, mutes[0].MuterID)
+		assert.Equal(t, bob, mutes[0].MutedID)
+		assert.Equal(t, carol, mutes[1].MutedID)
+	}
+	// Mutes are one way and private to the muter.
+	mutes, err = s.ListMutes(ctx, bob)
+	require.NoError(t, err)
+	assert.Empty(t, mutes)
+
+	assert.ErrorIs(t, s.UnmuteUser(ctx, bob, alice), store.ErrNotFound)
+	require.NoError(t, s.UnmuteUser(ctx, alice, bob))
+	assert.ErrorIs(t, s.UnmuteUser(ctx, alice, bob), store.ErrNotFound)
+}
+
+func testFollows(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	carol := createUser(t, s, "carol@example.com")
+
+	follow, err := s.Follow(ctx, alice, bob)
+	require.NoError(t, err)
+	assert.Equal(t, alice, follow.FollowerID)
+	assert.Equal(t, bob, follow.FolloweeID)
+	assert.Equal(t, store.FollowAccepted, follow.Status)
+	time.Sleep(time.Millisecond)
+	_, err = s.Follow(ctx, alice, carol)
+	require.NoError(t, err)
+	_, err = s.Follow(ctx, carol, bob)
+	require.NoError(t, err)
+
+	// Following again changes nothing.
+	again, err := s.Follow(ctx, alice, bob)
+	require.NoError(t, err)
+	assert.Equal(t, follow.CreatedAt, again.CreatedAt)
+	_, err = s.Follow(ctx, alice, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.Follow(ctx, uuid.New(), alice)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	following, err := s.ListFollowing(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, following, 2) {
+		assert.Equal(t, bob, following[0].FolloweeID)
+		assert.Equal(t, carol, following[1].FolloweeID)
+	}
+	followers, err := s.ListFollowers(ctx, bob, store.FollowAccepted)
+	require.NoError(t, err)
+	assert.Len(t, followers, 2)
+
+	// Blocking ends follows either way.
+	require.NoError(t, s.BlockUser(ctx, bob, alice))
+	_, err = s.GetFollow(ctx, alice, bob)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.GetFollow(ctx, carol, bob)
+	require.NoError(t, err)
+
+	assert.ErrorIs(t, s.Unfollow(ctx, bob, carol), store.ErrNotFound)
+	require.NoError(t, s.Unfollow(ctx, alice, carol))
+	assert.ErrorIs(t, s.Unfollow(ctx, alice, carol), store.ErrNotFound)
+}
+
+func testPrivateAccounts(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	carol := createUser(t, s, "carol@example.com")
+
+	user, err := s.SetUserPrivate(ctx, alice, true)
+	require.NoError(t, err)
+	assert.True(t, user.IsPrivate)
+	_, err = s.SetUserPrivate(ctx, uuid.New(), true)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// Following a private account asks to.
+	request, err := s.Follow(ctx, bob, alice)
+	require.NoError(t, err)
+	assert.Equal(t, store.FollowPending, request.Status)
+	_, err = s.Follow(ctx, carol, alice)
+	require.NoError(t, err)
+	pending, err := s.ListFollowers(ctx, alice, store.FollowPending)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+
+	canSee := func(viewer uuid.UUID) bool {
+		t.Helper()
+		visible, err := s.CanSeeChirps(ctx, viewer, alice)
+		require.NoError(t, err)
+		return visible
+	}
+	hidden := func(viewer uuid.UUID) []uuid.UUID {
+		t.Helper()
+		ids, err := s.HiddenPrivateUsers(ctx, viewer)
+		require.NoError(t, err)
+		return ids
+	}
+	assert.True(t, canSee(alice))
+	assert.False(t, canSee(bob))
+	assert.False(t, canSee(uuid.Nil))
+	assert.Empty(t, hidden(alice))
+	assert.Equal(t, []uuid.UUID{alice}, hidden(bob))
+	assert.Equal(t, []uuid.UUID{alice}, hidden(uuid.Nil))
+
+	accepted, err := s.AcceptFollow(ctx, bob, alice)
+	require.NoError(t, err)
+	assert.Equal(t, store.FollowAccepted, accepted.Status)
+	_, err = s.AcceptFollow(ctx, bob, alice)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, s.DenyFollow(ctx, bob, alice), store.ErrNotFound)
+	assert.True(t, canSee(bob))
+	assert.Empty(t, hidden(bob))
+
+	require.NoError(t, s.DenyFollow(ctx, carol, alice))
+	_, err = s.GetFollow(ctx, carol, alice)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.False(t, canSee(carol))
+
+	// A block hides chirps even from followers and from public accounts.
+	require.NoError(t, s.BlockUser(ctx, carol, bob))
+	visible, err := s.CanSeeChirps(ctx, bob, carol)
+	require.NoError(t, err)
+	assert.False(t, visible)
+	_, err = s.CanSeeChirps(ctx, bob, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// Going public accepts pending requests.
+	_, err = s.Follow(ctx, carol, alice)
+	require.NoError(t, err)
+	user, err = s.SetUserPrivate(ctx, alice, false)
+	require.NoError(t, err)
+	assert.False(t, user.IsPrivate)
+	follow, err := s.GetFollow(ctx, carol, alice)
+	require.NoError(t, err)
+	assert.Equal(t, store.FollowAccepted, follow.Status)
+	assert.Empty(t, hidden(uuid.Nil))
+}
+
+func testBookmarks(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	var chirps []database.Chirp
+	for i := 0; i < 3; i++ {
+		chirp, err := s.CreateChirp(ctx, bob, fmt.Sprintf("chirp %d", i))
+		require.NoError(t, err)
+		chirps = append(chirps, chirp)
+	}
+	for _, c := range chirps {
+		bookmark, err := s.BookmarkChirp(ctx, alice, c.ID, uuid.Nil)
+		require.NoError(t, err)
+		assert.Equal(t, c.ID, bookmark.ChirpID)
+		assert.False(t, bookmark.CollectionID.Valid)
+		time.Sleep(time.Millisecond)
+	}
+	_, err := s.BookmarkChirp(ctx, alice, uuid.New(), uuid.Nil)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// Bookmarking again keeps the bookmark's place.
+	again, err := s.BookmarkChirp(ctx, alice, chirps[0].ID, uuid.Nil)
+	require.NoError(t, err)
+	all, err := s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{chirps[2].ID, chirps[1].ID, chirps[0].ID}, bookmarkedChirps(all))
+	assert.Equal(t, all[2].Bookmark.CreatedAt, again.CreatedAt)
+	assert.Equal(t, chirps[0].Body, all[2].Chirp.Body)
+
+	// Pages pick up after the cursor.
+	page, err := s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{chirps[2].ID, chirps[1].ID}, bookmarkedChirps(page))
+	last := page[len(page)-1]
+	page, err = s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{CreatedAt: last.Bookmark.CreatedAt, ChirpID: last.Bookmark.ChirpID}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{chirps[0].ID}, bookmarkedChirps(page))
+
+	others, err := s.ListBookmarks(ctx, bob, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, others)
+
+	// Deleting a chirp deletes its bookmarks.
+	require.NoError(t, s.DeleteChirp(ctx, chirps[1].ID))
+	all, err = s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{chirps[2].ID, chirps[0].ID}, bookmarkedChirps(all))
+
+	// Bookmarks of chirps alice can't see are left out.
+	require.NoError(t, s.BlockUser(ctx, bob, alice))
+	all, err = s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	require.NoError(t, s.UnblockUser(ctx, bob, alice))
+	_, err = s.SetUserPrivate(ctx, bob, true)
+	require.NoError(t, err)
+	all, err = s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+	_, err = s.Follow(ctx, alice, bob)
+	require.NoError(t, err)
+	_, err = s.AcceptFollow(ctx, alice, bob)
+	require.NoError(t, err)
+	all, err = s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{chirps[2].ID, chirps[0].ID}, bookmarkedChirps(all))
+
+	require.NoError(t, s.RemoveBookmark(ctx, alice, chirps[2].ID))
+	assert.ErrorIs(t, s.RemoveBookmark(ctx, alice, chirps[2].ID), store.ErrNotFound)
+	assert.ErrorIs(t, s.RemoveBookmark(ctx, bob, chirps[0].ID), store.ErrNotFound)
+}
+
+func testBookmarkCollections(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	first, err := s.CreateChirp(ctx, bob, "first")
+	require.NoError(t, err)
+	second, err := s.CreateChirp(ctx, bob, "second")
+	require.NoError(t, err)
+
+	reading, err := s.CreateBookmarkCollection(ctx, alice, "reading")
+	require.NoError(t, err)
+	assert.Equal(t, "reading", reading.Name)
+	time.Sleep(time.Millisecond)
+	funny, err := s.CreateBookmarkCollection(ctx, alice, "funny")
+	require.NoError(t, err)
+	_, err = s.CreateBookmarkCollection(ctx, alice, "reading")
+	assert.ErrorIs(t, err, store.ErrConflict)
+	_, err = s.CreateBookmarkCollection(ctx, bob, "reading")
+	require.NoError(t, err)
+
+	collections, err := s.ListBookmarkCollections(ctx, alice)
+	require.NoError(t, err)
+	if assert.Len(t, collections, 2) {
+		assert.Equal(t, reading.ID, collections[0].ID)
+		assert.Equal(t, funny.ID, collections[1].ID)
+	}
+
+	bookmark, err := s.BookmarkChirp(ctx, alice, first.ID, reading.ID)
+	require.NoError(t, err)
+	assert.Equal(t, uuid.NullUUID{UUID: reading.ID, Valid: true}, bookmark.CollectionID)
+	_, err = s.BookmarkChirp(ctx, alice, second.ID, uuid.Nil)
+	require.NoError(t, err)
+	// Users can only file bookmarks in their own collections.
+	_, err = s.BookmarkChirp(ctx, bob, first.ID, reading.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	inReading, err := s.ListBookmarks(ctx, alice, reading.ID, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{first.ID}, bookmarkedChirps(inReading))
+
+	// Bookmarking again moves the bookmark.
+	_, err = s.BookmarkChirp(ctx, alice, first.ID, funny.ID)
+	require.NoError(t, err)
+	inReading, err = s.ListBookmarks(ctx, alice, reading.ID, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, inReading)
+
+	// Deleting a collection keeps its bookmarks, unfiled.
+	assert.ErrorIs(t, s.DeleteBookmarkCollection(ctx, funny.ID, bob), store.ErrNotFound)
+	require.NoError(t, s.DeleteBookmarkCollection(ctx, funny.ID, alice))
+	assert.ErrorIs(t, s.DeleteBookmarkCollection(ctx, funny.ID, alice), store.ErrNotFound)
+	all, err := s.ListBookmarks(ctx, alice, uuid.Nil, store.BookmarkCursor{}, 10)
+	require.NoError(t, err)
+	if assert.Len(t, all, 2) {
+		assert.False(t, all[0].Bookmark.CollectionID.Valid)
+		assert.False(t, all[1].Bookmark.CollectionID.Valid)
+	}
+}
+
+func bookmarkedChirps(rows []database.ListBookmarksRow) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.Chirp.ID)
+	}
+	return ids
+}
+
+func testSubscriptions(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+
+	require.NoError(t, s.UpgradeUser(ctx, alice))
+	user, err := s.GetUserByID(ctx, alice)
+	require.NoError(t, err)
+	assert.True(t, user.IsChirpyRed.Bool)
+
+	// Upgrading twice is fine.
+	assert.NoError(t, s.UpgradeUser(ctx, alice))
+	assert.ErrorIs(t, s.UpgradeUser(ctx, uuid.New()), store.ErrNotFound)
+}
+
+func enqueueJob(t *testing.T, s store.Store, kind string, runAt time.Time) database.Job {
+	t.Helper()
+	job, err := s.EnqueueJob(context.Background(), database.Job{
+		Kind:        kind,
+		Payload:     json.RawMessage(`{"n":1}`),
+		MaxAttempts: 3,
+		RunAt:       runAt,
+	})
+	require.NoError(t, err)
+	return job
+}
+
+func jobIDs(jobs []database.Job) []uuid.UUID {
+	ids := make([]uuid.UUID, len(jobs))
+	for i, j := range jobs {
+		ids[i] = j.ID
+	}
+	return ids
+}
+
+func testJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+
+	later := enqueueJob(t, s, "email", past.Add(time.Minute))
+	first := enqueueJob(t, s, "email", past)
+	other := enqueueJob(t, s, "report", past)
+	future := enqueueJob(t, s, "email", time.Now().Add(time.Hour))
+	assert.Equal(t, store.JobPending, first.Status)
+	assert.Equal(t, int32(3), first.MaxAttempts)
+	assert.JSONEq(t, `{"n":1}`, string(first.Payload))
+
+	// Due jobs of the asked-for kinds, oldest run_at first.
+	claimed, err := s.ClaimJobs(ctx, []string{"email"}, 1)
+	require.NoError(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, first.ID, claimed[0].ID)
+		assert.Equal(t, store.JobRunning, claimed[0].Status)
+		assert.Equal(t, int32(1), claimed[0].Attempts)
+		assert.True(t, claimed[0].LockedAt.Valid)
+	}
+	claimed, err = s.ClaimJobs(ctx, []string{"email"}, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{later.ID}, jobIDs(claimed))
+	claimed, err = s.ClaimJobs(ctx, []string{"email"}, 10)
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	require.NoError(t, s.CompleteJob(ctx, first.ID))
+	job, err := s.GetJob(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.JobSucceeded, job.Status)
+	assert.False(t, job.LockedAt.Valid)
+
+	require.NoError(t, s.RetryJob(ctx, later.ID, past, "boom"))
+	job, err = s.GetJob(ctx, later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.JobPending, job.Status)
+	assert.Equal(t, "boom", job.LastError.String)
+	claimed, err = s.ClaimJobs(ctx, []string{"email", "report"}, 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{later.ID, other.ID}, jobIDs(claimed))
+
+	require.NoError(t, s.KillJob(ctx, later.ID, "gave up"))
+	dead, err := s.ListJobs(ctx, store.JobDead, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{later.ID}, jobIDs(dead))
+	all, err := s.ListJobs(ctx, "", 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{future.ID, other.ID, first.ID, later.ID}, jobIDs(all))
+	all, err = s.ListJobs(ctx, "", 2)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	retried, err := s.RetryDeadJob(ctx, later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.JobPending, retried.Status)
+	assert.Zero(t, retried.Attempts)
+	assert.False(t, retried.LastError.Valid)
+	_, err = s.RetryDeadJob(ctx, later.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	_, err = s.GetJob(ctx, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func testJobUniqueKeys(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	job := database.Job{
+		Kind:        "report",
+		Payload:     json.RawMessage(`{}`),
+		MaxAttempts: 1,
+		RunAt:       time.Now(),
+		UniqueKey:   sql.NullString{String: "report:today", Valid: true},
+	}
+
+	_, err := s.EnqueueJob(ctx, job)
+	require.NoError(t, err)
+	_, err = s.EnqueueJob(ctx, job)
+	assert.ErrorIs(t, err, store.ErrConflict)
+
+	// Jobs without a key never conflict.
+	job.UniqueKey = sql.NullString{}
+	_, err = s.EnqueueJob(ctx, job)
+	require.NoError(t, err)
+	_, err = s.EnqueueJob(ctx, job)
+	assert.NoError(t, err)
+}
+
+func testRequeueStaleJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	job, err := s.EnqueueJob(ctx, database.Job{
+		Kind:        "email",
+		Payload:     json.RawMessage(`{}`),
+		MaxAttempts: 2,
+		RunAt:       time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+	claim := func() {
+		t.Helper()
+		claimed, err := s.ClaimJobs(ctx, []string{"email"}, 1)
+		require.NoError(t, err)
+		require.Len(t, claimed, 1)
+	}
+	claim()
+
+	requeued, err := s.RequeueStaleJobs(ctx, time.Now().Add(-time.Minute), "lost")
+	require.NoError(t, err)
+	assert.Zero(t, requeued)
+
+	requeued, err = s.RequeueStaleJobs(ctx, time.Now().Add(time.Minute), "lost")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requeued)
+	stale, err := s.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.JobPending, stale.Status)
+	assert.Equal(t, int32(1), stale.Attempts)
+	assert.Equal(t, "lost", stale.LastError.String)
+	assert.False(t, stale.LockedAt.Valid)
+
+	// Losing the last attempt kills the job.
+	claim()
+	requeued, err = s.RequeueStaleJobs(ctx, time.Now().Add(time.Minute), "lost")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), requeued)
+	stale, err = s.GetJob(ctx, job.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.JobDead, stale.Status)
+	assert.Equal(t, int32(2), stale.Attempts)
+}
+
+func testDeleteFinishedJobs(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	past := time.Now().Add(-time.Hour)
+	succeeded := enqueueJob(t, s, "email", past)
+	dead := enqueueJob(t, s, "email", past)
+	running := enqueueJob(t, s, "email", past)
+	_, err := s.ClaimJobs(ctx, []string{"email"}, 3)
+	require.NoError(t, err)
+	require.NoError(t, s.CompleteJob(ctx, succeeded.ID))
+	require.NoError(t, s.KillJob(ctx, dead.ID, "gave up"))
+	pending := enqueueJob(t, s, "email", time.Now().Add(time.Hour))
+
+	deleted, err := s.DeleteFinishedJobsBefore(ctx, time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+
+	deleted, err = s.DeleteFinishedJobsBefore(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	left, err := s.ListJobs(ctx, "", 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{running.ID, pending.ID}, jobIDs(left))
+}
+
+func testWebhookEndpoints(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+
+	created, err := s.CreateWebhookEndpoint(ctx, alice, "https://example.com/hook", "secret", []string{"chirp.created", "user.upgraded"})
+	require.NoError(t, err)
+	assert.Equal(t, alice, created.UserID)
+	assert.Equal(t, []string{"chirp.created", "user.upgraded"}, created.Events)
+	_, err = s.CreateWebhookEndpoint(ctx, bob, "https://example.com/bob", "secret", []string{"chirp.created"})
+	require.NoError(t, err)
+	_, err = s.CreateWebhookEndpoint(ctx, uuid.New(), "https://example.com/nobody", "secret", []string{"chirp.created"})
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	got, err := s.GetWebhookEndpoint(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, created.Url, got.Url)
+	assert.Equal(t, created.Events, got.Events)
+	endpoints, err := s.ListWebhookEndpoints(ctx, alice)
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+
+	// Events match whole names only.
+	endpoints, err = s.ListWebhookEndpointsForEvent(ctx, "chirp.created")
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 2)
+	endpoints, err = s.ListWebhookEndpointsForEvent(ctx, "user.upgraded")
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	endpoints, err = s.ListWebhookEndpointsForEvent(ctx, "chirp")
+	require.NoError(t, err)
+	assert.Empty(t, endpoints)
+
+	failures, err := s.RecordWebhookEndpointFailure(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), failures)
+	failures, err = s.RecordWebhookEndpointFailure(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), failures)
+	require.NoError(t, s.ResetWebhookEndpointFailures(ctx, created.ID))
+	got, err = s.GetWebhookEndpoint(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Zero(t, got.ConsecutiveFailures)
+
+	// Disabled endpoints get no events.
+	require.NoError(t, s.DisableWebhookEndpoint(ctx, created.ID))
+	endpoints, err = s.ListWebhookEndpointsForEvent(ctx, "user.upgraded")
+	require.NoError(t, err)
+	assert.Empty(t, endpoints)
+	require.NoError(t, s.EnableWebhookEndpoint(ctx, created.ID))
+	endpoints, err = s.ListWebhookEndpointsForEvent(ctx, "user.upgraded")
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+
+	assert.ErrorIs(t, s.DeleteWebhookEndpoint(ctx, created.ID, bob), store.ErrNotFound)
+	require.NoError(t, s.DeleteWebhookEndpoint(ctx, created.ID, alice))
+	_, err = s.GetWebhookEndpoint(ctx, created.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	assert.ErrorIs(t, s.DeleteWebhookEndpoint(ctx, created.ID, alice), store.ErrNotFound)
+}
+
+func testWebhookDeliveries(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	endpoint, err := s.CreateWebhookEndpoint(ctx, alice, "https://example.com/hook", "secret", []string{"chirp.created"})
+	require.NoError(t, err)
+
+	delivery, err := s.CreateWebhookDelivery(ctx, endpoint.ID, "chirp.created", json.RawMessage(`{"id":"1"}`))
+	require.NoError(t, err)
+	assert.Equal(t, store.DeliveryPending, delivery.Status)
+	assert.JSONEq(t, `{"id":"1"}`, string(delivery.Payload))
+	_, err = s.CreateWebhookDelivery(ctx, uuid.New(), "chirp.created", json.RawMessage(`{}`))
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// Only failed deliveries can be replayed.
+	_, err = s.ReplayWebhookDelivery(ctx, delivery.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.CreateWebhookDeliveryAttempt(ctx, database.WebhookDeliveryAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    2,
+		Error:      sql.NullString{String: "timeout", Valid: true},
+		DurationMs: 5000,
+	}))
+	require.NoError(t, s.CreateWebhookDeliveryAttempt(ctx, database.WebhookDeliveryAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    1,
+		StatusCode: sql.NullInt32{Int32: 500, Valid: true},
+		DurationMs: 12,
+	}))
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, delivery.ID, store.DeliveryFailed, 2, "timeout"))
+
+	got, err := s.GetWebhookDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.DeliveryFailed, got.Status)
+	assert.Equal(t, int32(2), got.Attempts)
+	assert.Equal(t, "timeout", got.LastError.String)
+	attempts, err := s.ListWebhookDeliveryAttempts(ctx, delivery.ID)
+	require.NoError(t, err)
+	if assert.Len(t, attempts, 2) {
+		assert.Equal(t, int32(1), attempts[0].Attempt)
+		assert.Equal(t, int32(500), attempts[0].StatusCode.Int32)
+		assert.Equal(t, int32(2), attempts[1].Attempt)
+		assert.False(t, attempts[1].StatusCode.Valid)
+		assert.Equal(t, "timeout", attempts[1].Error.String)
+	}
+
+	replayed, err := s.ReplayWebhookDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, store.DeliveryPending, replayed.Status)
+
+	// An empty error clears the last one.
+	require.NoError(t, s.UpdateWebhookDelivery(ctx, delivery.ID, store.DeliverySucceeded, 3, ""))
+	got, err = s.GetWebhookDelivery(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.False(t, got.LastError.Valid)
+
+	_, err = s.GetWebhookDelivery(ctx, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+}
+
+func notificationIDs(notifications []database.Notification) []uuid.UUID {
+	ids := make([]uuid.UUID, len(notifications))
+	for i, n := range notifications {
+		ids[i] = n.ID
+	}
+	return ids
+}
+
+func testNotifications(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	bob := createUser(t, s, "bob@example.com")
+	bobs, err := s.CreateChirp(ctx, bob, "hi @alice")
+	require.NoError(t, err)
+
+	follow, err := s.CreateNotification(ctx, alice, "follow", bob, uuid.Nil)
+	require.NoError(t, err)
+	assert.Equal(t, bob, follow.ActorID.UUID)
+	assert.False(t, follow.ChirpID.Valid)
+	mention, err := s.CreateNotification(ctx, alice, "mention", bob, bobs.ID)
+	require.NoError(t, err)
+	system, err := s.CreateNotification(ctx, alice, "system", uuid.Nil, uuid.Nil)
+	require.NoError(t, err)
+	assert.False(t, system.ActorID.Valid)
+	_, err = s.CreateNotification(ctx, bob, "follow", alice, uuid.Nil)
+	require.NoError(t, err)
+	_, err = s.CreateNotification(ctx, uuid.New(), "follow", alice, uuid.Nil)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	_, err = s.CreateNotification(ctx, alice, "mention", bob, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	notifications, err := s.ListNotifications(ctx, alice, false, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{system.ID, mention.ID, follow.ID}, notificationIDs(notifications))
+	notifications, err = s.ListNotifications(ctx, alice, false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{system.ID}, notificationIDs(notifications))
+	unread, err := s.CountUnreadNotifications(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), unread)
+
+	// Other users' notifications aren't marked.
+	marked, err := s.MarkNotificationsRead(ctx, bob, []uuid.UUID{follow.ID})
+	require.NoError(t, err)
+	assert.Zero(t, marked)
+	marked, err = s.MarkNotificationsRead(ctx, alice, []uuid.UUID{follow.ID, mention.ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), marked)
+	marked, err = s.MarkNotificationsRead(ctx, alice, []uuid.UUID{follow.ID})
+	require.NoError(t, err)
+	assert.Zero(t, marked)
+	notifications, err = s.ListNotifications(ctx, alice, true, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{system.ID}, notificationIDs(notifications))
+
+	marked, err = s.MarkAllNotificationsRead(ctx, alice)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), marked)
+	unread, err = s.CountUnreadNotifications(ctx, alice)
+	require.NoError(t, err)
+	assert.Zero(t, unread)
+	unread, err = s.CountUnreadNotifications(ctx, bob)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), unread)
+
+	// Deleting the chirp deletes the notifications about it.
+	require.NoError(t, s.DeleteChirp(ctx, bobs.ID))
+	notifications, err = s.ListNotifications(ctx, alice, false, 10)
+	require.NoError(t, err)
+	assert.Equal(t, []uuid.UUID{system.ID, follow.ID}, notificationIDs(notifications))
+}
+
+func testNotificationPreferences(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+
+	_, err := s.GetNotificationPreference(ctx, alice, "follow")
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	require.NoError(t, s.SetNotificationPreference(ctx, alice, "follow", false))
+	require.NoError(t, s.SetNotificationPreference(ctx, alice, "mention", false))
+	require.NoError(t, s.SetNotificationPreference(ctx, alice, "mention", true))
+	enabled, err := s.GetNotificationPreference(ctx, alice, "follow")
+	require.NoError(t, err)
+	assert.False(t, enabled)
+	enabled, err = s.GetNotificationPreference(ctx, alice, "mention")
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	prefs, err := s.ListNotificationPreferences(ctx, alice)
+	require.NoError(t, err)
+	assert.Len(t, prefs, 2)
+
+	assert.ErrorIs(t, s.SetNotificationPreference(ctx, uuid.New(), "follow", false), store.ErrNotFound)
+}
+
+func testReset(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	alice := createUser(t, s, "alice@example.com")
+	_, err := s.CreateChirp(ctx, alice, "hello")
+	require.NoError(t, err)
+	job := enqueueJob(t, s, "email", time.Now())
+
+	require.NoError(t, s.Reset(ctx))
+
+	_, err = s.GetUserByID(ctx, alice)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+	chirps, err := s.ListChirps(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, chirps)
+	_, err = s.GetJob(ctx, job.ID)
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// The email is free again.
+	createUser(t, s, "alice@example.com")
+}
+
+func testConcurrentWrites(t *testing.T, s store.Store) {
+	ctx := context.Background()
+	const writers = 8
+	const chirpsEach = 5
+
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := s.CreateUser(ctx, fmt.Sprintf("user%d@example.com", i), "hash")
+			if !assert.NoError(t, err) {
+				return
+			}
+			for j := range chirpsEach {
+				_, err := s.CreateChirp(ctx, user.ID, fmt.Sprintf("chirp %d", j))
+				assert.NoError(t, err)
+			}
+			_, err = s.ListChirps(ctx)
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	chirps, err := s.ListChirps(ctx)
+	require.NoError(t, err)
+	assert.Len(t, chirps, writers*chirpsEach)
+}
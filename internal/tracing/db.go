@@ -0,0 +1,96 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/willmelton21/chirpy/internal/store"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DB wraps db so every query made through database.Queries gets a client
+// span named after the sqlc query, e.g. "db GetChirp".
+//
+// Spans cover executing the statement. For QueryContext that excludes the
+// time the caller then spends iterating the rows. Statements run in a
+// transaction from BeginTx go through the *sql.Tx and aren't traced.
+func DB(db store.DB) store.DB {
+	return tracedDB{db: db}
+}
+
+type tracedDB struct {
+	db store.DB
+}
+
+func (t tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	res, err := t.db.ExecContext(ctx, query, args...)
+	recordError(span, err)
+	return res, err
+}
+
+func (t tracedDB) PrepareContext(ctx context.Context, query string) (*sql.Stmt, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	stmt, err := t.db.PrepareContext(ctx, query)
+	recordError(span, err)
+	return stmt, err
+}
+
+func (t tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	rows, err := t.db.QueryContext(ctx, query, args...)
+	recordError(span, err)
+	return rows, err
+}
+
+func (t tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	ctx, span := startQuery(ctx, query)
+	defer span.End()
+	row := t.db.QueryRowContext(ctx, query, args...)
+	recordError(span, row.Err())
+	return row
+}
+
+func (t tracedDB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	return t.db.BeginTx(ctx, opts)
+}
+
+func startQuery(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := QueryName(query)
+	return Tracer().Start(ctx, "db "+name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system.name", "postgresql"),
+			attribute.String("db.operation.name", name),
+			attribute.String("db.query.text", query),
+		),
+	)
+}
+
+// recordError marks the span failed. sql.ErrNoRows is an answer, not a
+// failure, so it is left alone.
+func recordError(span trace.Span, err error) {
+	if err == nil || errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// QueryName returns the name from sqlc's "-- name: GetChirp :one" header,
+// or "query" for SQL without one.
+func QueryName(query string) string {
+	rest, ok := strings.CutPrefix(query, "-- name: ")
+	if !ok {
+		return "query"
+	}
+	name, _, _ := strings.Cut(rest, " ")
+	return name
+}
@@ -0,0 +1,48 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler starts a server span for every request, continuing any trace
+// passed in the traceparent header.
+func Handler(next http.Handler) http.Handler {
+	return otelhttp.NewHandler(next, "http.server", otelhttp.WithSpanNameFormatter(
+		func(_ string, r *http.Request) string { return spanName(r) },
+	))
+}
+
+// Routes names the request's server span after the ServeMux pattern that
+// matched, e.g. "GET /api/chirps/{chirpID}". It must wrap the mux directly,
+// since the mux only records the pattern on the request it is handed.
+func Routes(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if r.Pattern == "" {
+			return
+		}
+		span := trace.SpanFromContext(r.Context())
+		span.SetName(spanName(r))
+		span.SetAttributes(attribute.String("http.route", route(r.Pattern)))
+	})
+}
+
+func spanName(r *http.Request) string {
+	if r.Pattern == "" {
+		return r.Method
+	}
+	return r.Method + " " + route(r.Pattern)
+}
+
+// route drops the method from patterns like "GET /api/chirps".
+func route(pattern string) string {
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return strings.TrimLeft(path, " \t")
+	}
+	return pattern
+}
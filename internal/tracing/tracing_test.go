@@ -0,0 +1,95 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return recorder
+}
+
+type fakeDB struct {
+	err error
+}
+
+func (f fakeDB) ExecContext(context.Context, string, ...interface{}) (sql.Result, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) PrepareContext(context.Context, string) (*sql.Stmt, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryContext(context.Context, string, ...interface{}) (*sql.Rows, error) {
+	return nil, f.err
+}
+
+func (f fakeDB) QueryRowContext(context.Context, string, ...interface{}) *sql.Row {
+	return nil
+}
+
+func (f fakeDB) BeginTx(context.Context, *sql.TxOptions) (*sql.Tx, error) {
+	return nil, f.err
+}
+
+func TestQueryName(t *testing.T) {
+	assert.Equal(t, "GetChirp", QueryName("-- name: GetChirp :one\nSELECT 1"))
+	assert.Equal(t, "query", QueryName("SELECT 1"))
+}
+
+func TestDBSpans(t *testing.T) {
+	recorder := recordSpans(t)
+
+	DB(fakeDB{}).ExecContext(context.Background(), "-- name: DeleteChirp :exec\nDELETE FROM chirp")
+	DB(fakeDB{err: sql.ErrNoRows}).QueryContext(context.Background(), "-- name: GetChirps :many\nSELECT 1")
+	DB(fakeDB{err: errors.New("connection refused")}).ExecContext(context.Background(), "-- name: ResetTable :exec\nDELETE FROM users")
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	assert.Equal(t, "db DeleteChirp", spans[0].Name())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("db.operation.name", "DeleteChirp"))
+	assert.Equal(t, codes.Unset, spans[1].Status().Code)
+	assert.Equal(t, codes.Error, spans[2].Status().Code)
+}
+
+func TestHandlerNamesSpanByRoute(t *testing.T) {
+	recorder := recordSpans(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps/{chirpID}", func(w http.ResponseWriter, r *http.Request) {})
+	// Middleware between Handler and Routes that copies the request, as
+	// the logging middleware does, hides the pattern from otelhttp.
+	copying := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Routes(mux).ServeHTTP(w, r.WithContext(r.Context()))
+	})
+	handler := Handler(copying)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/123", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 1)
+	assert.Equal(t, "GET /api/chirps/{chirpID}", spans[0].Name())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Contains(t, spans[0].Attributes(), attribute.String("http.route", "/api/chirps/{chirpID}"))
+}
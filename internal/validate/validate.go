@@ -0,0 +1,127 @@
+// Package validate checks decoded request bodies and collects every
+// invalid field, so a client can fix them all in one round trip.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MinPasswordLength is counted in characters.
+	MinPasswordLength = 8
+	// MaxPasswordBytes is bcrypt's input limit; it ignores anything longer.
+	MaxPasswordBytes = 72
+)
+
+// commonPasswords are rejected outright. They are long enough to pass the
+// length check but are among the first guesses in any attack.
+var commonPasswords = map[string]bool{
+	"password":    true,
+	"password1":   true,
+	"password123": true,
+	"12345678":    true,
+	"123456789":   true,
+	"1234567890":  true,
+	"qwertyuiop":  true,
+	"qwerty123":   true,
+	"iloveyou":    true,
+	"sunshine":    true,
+	"football":    true,
+	"baseball":    true,
+	"letmein1":    true,
+	"11111111":    true,
+	"00000000":    true,
+	"abc12345":    true,
+	"chirpy123":   true,
+}
+
+// FieldError is one invalid field. Field is its JSON name.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Error is returned by Validator.Err when any field is invalid.
+type Error struct {
+	Fields []FieldError
+}
+
+func (e *Error) Error() string {
+	msgs := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		msgs = append(msgs, f.Field+" "+f.Message)
+	}
+	return "invalid request: " + strings.Join(msgs, "; ")
+}
+
+// Validator collects field errors. Its zero value is ready to use.
+type Validator struct {
+	fields []FieldError
+}
+
+// Add records that field is invalid.
+func (v *Validator) Add(field, message string) {
+	v.fields = append(v.fields, FieldError{Field: field, Message: message})
+}
+
+// Check records message against field unless ok.
+func (v *Validator) Check(ok bool, field, message string) {
+	if !ok {
+		v.Add(field, message)
+	}
+}
+
+// Required checks that value isn't empty and reports whether it isn't.
+func (v *Validator) Required(field, value string) bool {
+	if strings.TrimSpace(value) == "" {
+		v.Add(field, "is required")
+		return false
+	}
+	return true
+}
+
+// Email checks that value is a bare address such as "a@example.com",
+// without a display name.
+func (v *Validator) Email(field, value string) {
+	if !v.Required(field, value) {
+		return
+	}
+	addr, err := mail.ParseAddress(value)
+	if err != nil || addr.Address != value {
+		v.Add(field, "must be an email address")
+	}
+}
+
+// Password checks that value is long enough, fits in bcrypt's input and
+// isn't a well-known password.
+func (v *Validator) Password(field, value string) {
+	if !v.Required(field, value) {
+		return
+	}
+	switch {
+	case utf8.RuneCountInString(value) < MinPasswordLength:
+		v.Add(field, fmt.Sprintf("must be at least %d characters", MinPasswordLength))
+	case len(value) > MaxPasswordBytes:
+		v.Add(field, fmt.Sprintf("must be at most %d bytes", MaxPasswordBytes))
+	case commonPasswords[strings.ToLower(value)]:
+		v.Add(field, "is too common")
+	}
+}
+
+// MaxLength checks that value has at most max characters.
+func (v *Validator) MaxLength(field, value string, max int) {
+	if utf8.RuneCountInString(value) > max {
+		v.Add(field, fmt.Sprintf("must be at most %d characters", max))
+	}
+}
+
+// Err returns an *Error listing every invalid field, or nil.
+func (v *Validator) Err() error {
+	if len(v.fields) == 0 {
+		return nil
+	}
+	return &Error{Fields: v.fields}
+}
@@ -0,0 +1,72 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fieldErrors(t *testing.T, v *Validator) []FieldError {
+	t.Helper()
+	err := v.Err()
+	if err == nil {
+		return nil
+	}
+	var verr *Error
+	require.True(t, errors.As(err, &verr))
+	return verr.Fields
+}
+
+func TestValidReturnsNil(t *testing.T) {
+	var v Validator
+	v.Email("email", "alice@example.com")
+	v.Password("password", "correct horse battery staple")
+	v.Required("body", "hello")
+	v.MaxLength("body", "hello", 140)
+	assert.NoError(t, v.Err())
+}
+
+func TestEmail(t *testing.T) {
+	for _, email := range []string{"", "alice", "@example.com", "Alice <alice@example.com>", "alice@"} {
+		var v Validator
+		v.Email("email", email)
+		assert.Len(t, fieldErrors(t, &v), 1, email)
+	}
+}
+
+func TestPassword(t *testing.T) {
+	tests := map[string]string{
+		"":                      "is required",
+		"short":                 "must be at least 8 characters",
+		strings.Repeat("é", 40): "must be at most 72 bytes",
+		"Password123":           "is too common",
+	}
+	for password, want := range tests {
+		var v Validator
+		v.Password("password", password)
+		assert.Equal(t, []FieldError{{Field: "password", Message: want}}, fieldErrors(t, &v), password)
+	}
+}
+
+func TestMaxLengthCountsCharacters(t *testing.T) {
+	var v Validator
+	v.MaxLength("body", strings.Repeat("🐦", 140), 140)
+	assert.NoError(t, v.Err())
+
+	v.MaxLength("body", strings.Repeat("a", 141), 140)
+	assert.Equal(t, []FieldError{{Field: "body", Message: "must be at most 140 characters"}}, fieldErrors(t, &v))
+}
+
+func TestErrListsEveryField(t *testing.T) {
+	var v Validator
+	v.Email("email", "nope")
+	v.Password("password", "")
+	assert.Equal(t, []FieldError{
+		{Field: "email", Message: "must be an email address"},
+		{Field: "password", Message: "is required"},
+	}, fieldErrors(t, &v))
+	assert.EqualError(t, v.Err(), "invalid request: email must be an email address; password is required")
+}
@@ -0,0 +1,90 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress is returned for endpoints that resolve to loopback,
+// private, link-local or other addresses that aren't reachable from the
+// public internet, so webhooks can't be aimed at internal services.
+var ErrPrivateAddress = errors.New("address is not public")
+
+// reservedPrefixes are non-public ranges netip.Addr has no predicate for.
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"), // benchmarking
+	netip.MustParsePrefix("64:ff9b::/96"),  // NAT64 reaches any IPv4 address
+}
+
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, prefix := range reservedPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckURL resolves rawURL's host and returns ErrPrivateAddress if any of
+// its addresses isn't public. Deliveries check the address again when they
+// connect, since the name may resolve differently by then.
+func (d *Dispatcher) CheckURL(ctx context.Context, rawURL string) error {
+	if d.AllowPrivateNetworks {
+		return nil
+	}
+	target, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := target.Hostname()
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if !isPublic(addr) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrPrivateAddress, host, addr)
+		}
+	}
+	return nil
+}
+
+// newClient returns the client deliveries are sent with. It refuses to
+// connect to non-public addresses, which covers redirects and names that
+// are rebound after the endpoint was created. Proxies are ignored so the
+// check sees the endpoint's own address.
+func (d *Dispatcher) newClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: d.checkDial}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+func (d *Dispatcher) checkDial(_, address string, _ syscall.RawConn) error {
+	if d.AllowPrivateNetworks {
+		return nil
+	}
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !isPublic(addrPort.Addr()) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, addrPort.Addr())
+	}
+	return nil
+}
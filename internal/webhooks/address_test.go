@@ -0,0 +1,61 @@
+package webhooks
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckURL(t *testing.T) {
+	d := &Dispatcher{}
+	for _, rawURL := range []string{
+		"http://127.0.0.1/hook",
+		"http://localhost:8080/hook",
+		"http://[::1]/hook",
+		"http://10.1.2.3/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://[fd00::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://100.64.0.1/hook",
+		"http://[::ffff:10.0.0.1]/hook",
+		"http://[64:ff9b::a00:1]/hook",
+		"http://224.0.0.1/hook",
+	} {
+		assert.ErrorIs(t, d.CheckURL(context.Background(), rawURL), ErrPrivateAddress, rawURL)
+	}
+
+	assert.NoError(t, d.CheckURL(context.Background(), "https://93.184.215.14/hook"))
+	assert.NoError(t, d.CheckURL(context.Background(), "https://[2606:4700::1111]/hook"))
+
+	d.AllowPrivateNetworks = true
+	assert.NoError(t, d.CheckURL(context.Background(), "http://127.0.0.1/hook"))
+}
+
+func TestDeliveryRefusesPrivateAddress(t *testing.T) {
+	f := newFakeDB()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+	}))
+	defer srv.Close()
+	// An endpoint that passed the check when it was created but now
+	// resolves to loopback.
+	f.addEndpoint(srv.URL, EventChirpCreated)
+	d := startDispatcher(t, f)
+	d.AllowPrivateNetworks = false
+
+	require.NoError(t, d.Enqueue(context.Background(), EventChirpCreated, map[string]string{}))
+	f.settle(t)
+
+	assert.Zero(t, calls.Load())
+	delivery := f.onlyDelivery(t)
+	assert.Contains(t, delivery.LastError.String, ErrPrivateAddress.Error())
+}
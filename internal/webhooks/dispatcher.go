@@ -0,0 +1,336 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// Event types developers can subscribe an endpoint to.
+const (
+	EventChirpCreated = "chirp.created"
+	EventChirpDeleted = "chirp.deleted"
+	EventUserUpgraded = "user.upgraded"
+)
+
+// Events lists every event type an endpoint may subscribe to.
+var Events = []string{
+	EventChirpCreated,
+	EventChirpDeleted,
+	EventUserUpgraded,
+}
+
+// JobDeliver is the job kind that performs one delivery attempt.
+const JobDeliver = "webhooks.deliver"
+
+// Delivery statuses stored in webhook_deliveries.status.
+const (
+	StatusPending   = store.DeliveryPending
+	StatusSucceeded = store.DeliverySucceeded
+	StatusFailed    = store.DeliveryFailed
+)
+
+// IsKnownEvent reports whether name is one of Events.
+func IsKnownEvent(name string) bool {
+	for _, e := range Events {
+		if e == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Envelope is the JSON body POSTed to every endpoint.
+type Envelope struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// DB is the part of store.Store the dispatcher uses.
+type DB interface {
+	ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]database.WebhookEndpoint, error)
+	GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error)
+	RecordWebhookEndpointFailure(ctx context.Context, id uuid.UUID) (int32, error)
+	ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error
+	DisableWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+	EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) error
+	CreateWebhookDelivery(ctx context.Context, endpointID uuid.UUID, event string, payload json.RawMessage) (database.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, id uuid.UUID, status string, attempts int32, lastError string) error
+	ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error)
+	CreateWebhookDeliveryAttempt(ctx context.Context, attempt database.WebhookDeliveryAttempt) error
+	CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error)
+}
+
+// Dispatcher fans events out to subscribed endpoints. Every delivery is a
+// row in webhook_deliveries plus a job on the background queue, which takes
+// care of retrying with backoff; the delivery row keeps the outcome and the
+// attempt log.
+type Dispatcher struct {
+	db     DB
+	queue  *jobs.Queue
+	client *http.Client
+
+	DisableAfter int32
+	// AllowPrivateNetworks turns off the check that endpoints resolve to
+	// public addresses, for local development and tests.
+	AllowPrivateNetworks bool
+}
+
+type deliverArgs struct {
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
+
+// NewDispatcher registers the delivery job on queue.
+func NewDispatcher(db DB, queue *jobs.Queue) *Dispatcher {
+	d := &Dispatcher{
+		db:           db,
+		queue:        queue,
+		DisableAfter: 20,
+	}
+	d.client = d.newClient()
+	jobs.Register(queue, JobDeliver, jobs.Options{
+		MaxAttempts: 8,
+		Timeout:     30 * time.Second,
+		Backoff:     jobs.ExponentialBackoff(10*time.Second, 6*time.Hour),
+	}, d.deliver)
+	return d
+}
+
+// Enqueue records a delivery for every active endpoint subscribed to
+// eventType. It only touches the database; the HTTP calls happen on the job
+// queue's workers.
+func (d *Dispatcher) Enqueue(ctx context.Context, eventType string, data any) error {
+	endpoints, err := d.db.ListWebhookEndpointsForEvent(ctx, eventType)
+	if err != nil {
+		return fmt.Errorf("listing endpoints for %s: %w", eventType, err)
+	}
+	return d.enqueue(ctx, eventType, data, endpoints)
+}
+
+// EnqueueChirp is Enqueue for an event about a chirp by authorID. Endpoints
+// whose owner can't see the author's chirps, because of a block or a private
+// account, are skipped.
+func (d *Dispatcher) EnqueueChirp(ctx context.Context, eventType string, authorID uuid.UUID, data any) error {
+	endpoints, err := d.db.ListWebhookEndpointsForEvent(ctx, eventType)
+	if err != nil {
+		return fmt.Errorf("listing endpoints for %s: %w", eventType, err)
+	}
+
+	visible := endpoints[:0]
+	for _, endpoint := range endpoints {
+		ok, err := d.db.CanSeeChirps(ctx, endpoint.UserID, authorID)
+		if err != nil {
+			return fmt.Errorf("checking visibility for endpoint %s: %w", endpoint.ID, err)
+		}
+		if ok {
+			visible = append(visible, endpoint)
+		}
+	}
+	return d.enqueue(ctx, eventType, data, visible)
+}
+
+// EnqueueUser is Enqueue for an event about userID's own account, such as
+// an upgrade. Only userID's endpoints receive it.
+func (d *Dispatcher) EnqueueUser(ctx context.Context, eventType string, userID uuid.UUID, data any) error {
+	endpoints, err := d.db.ListWebhookEndpointsForEvent(ctx, eventType)
+	if err != nil {
+		return fmt.Errorf("listing endpoints for %s: %w", eventType, err)
+	}
+
+	owned := endpoints[:0]
+	for _, endpoint := range endpoints {
+		if endpoint.UserID == userID {
+			owned = append(owned, endpoint)
+		}
+	}
+	return d.enqueue(ctx, eventType, data, owned)
+}
+
+func (d *Dispatcher) enqueue(ctx context.Context, eventType string, data any, endpoints []database.WebhookEndpoint) error {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(Envelope{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      rawData,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, endpoint := range endpoints {
+		delivery, err := d.db.CreateWebhookDelivery(ctx, endpoint.ID, eventType, payload)
+		if err != nil {
+			return fmt.Errorf("recording delivery for endpoint %s: %w", endpoint.ID, err)
+		}
+		_, err = d.queue.Enqueue(ctx, JobDeliver, deliverArgs{DeliveryID: delivery.ID})
+		if err != nil {
+			return fmt.Errorf("queueing delivery %s: %w", delivery.ID, err)
+		}
+	}
+	return nil
+}
+
+// ErrNotReplayable is returned by Replay for a delivery that is still
+// pending or already succeeded, so replaying it would send it twice.
+var ErrNotReplayable = errors.New("only failed deliveries can be replayed")
+
+// Replay re-enables a failed delivery's endpoint and queues the delivery
+// again.
+func (d *Dispatcher) Replay(ctx context.Context, deliveryID uuid.UUID) (database.WebhookDelivery, error) {
+	delivery, err := d.db.GetWebhookDelivery(ctx, deliveryID)
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	if delivery.Status != StatusFailed {
+		return database.WebhookDelivery{}, ErrNotReplayable
+	}
+	err = d.db.EnableWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	delivery, err = d.db.ReplayWebhookDelivery(ctx, deliveryID)
+	if errors.Is(err, store.ErrNotFound) {
+		// Someone else replayed it first.
+		return database.WebhookDelivery{}, ErrNotReplayable
+	}
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+	_, err = d.queue.Enqueue(ctx, JobDeliver, deliverArgs{DeliveryID: delivery.ID})
+	return delivery, err
+}
+
+// deliver makes one attempt. Returning an error asks the job queue to retry.
+func (d *Dispatcher) deliver(ctx context.Context, args deliverArgs) error {
+	delivery, err := d.db.GetWebhookDelivery(ctx, args.DeliveryID)
+	if errors.Is(err, store.ErrNotFound) {
+		// The endpoint was deleted along with its deliveries.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delivery.Status != StatusPending {
+		return nil
+	}
+
+	endpoint, err := d.db.GetWebhookEndpoint(ctx, delivery.EndpointID)
+	if err != nil {
+		return err
+	}
+
+	attempt := delivery.Attempts + 1
+	if endpoint.DisabledAt.Valid {
+		d.finish(ctx, delivery, StatusFailed, attempt, errors.New("endpoint disabled"))
+		return nil
+	}
+
+	start := time.Now()
+	statusCode, sendErr := d.send(ctx, endpoint, delivery)
+	elapsed := time.Since(start)
+
+	err = d.db.CreateWebhookDeliveryAttempt(ctx, database.WebhookDeliveryAttempt{
+		DeliveryID: delivery.ID,
+		Attempt:    attempt,
+		StatusCode: sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+		Error:      nullString(sendErr),
+		DurationMs: int32(elapsed.Milliseconds()),
+	})
+	if err != nil {
+		log.Printf("webhooks: logging attempt for delivery %s: %s", delivery.ID, err)
+	}
+
+	if sendErr == nil {
+		d.finish(ctx, delivery, StatusSucceeded, attempt, nil)
+		err = d.db.ResetWebhookEndpointFailures(ctx, endpoint.ID)
+		if err != nil {
+			log.Printf("webhooks: resetting failures for endpoint %s: %s", endpoint.ID, err)
+		}
+		return nil
+	}
+
+	failures, err := d.db.RecordWebhookEndpointFailure(ctx, endpoint.ID)
+	if err != nil {
+		log.Printf("webhooks: recording failure for endpoint %s: %s", endpoint.ID, err)
+	} else if failures >= d.DisableAfter {
+		log.Printf("webhooks: disabling endpoint %s after %d consecutive failures", endpoint.ID, failures)
+		err = d.db.DisableWebhookEndpoint(ctx, endpoint.ID)
+		if err != nil {
+			log.Printf("webhooks: disabling endpoint %s: %s", endpoint.ID, err)
+		}
+	}
+
+	status := StatusPending
+	if a, ok := jobs.CurrentAttempt(ctx); !ok || a.Last() {
+		status = StatusFailed
+	}
+	d.finish(ctx, delivery, status, attempt, sendErr)
+	return sendErr
+}
+
+// send POSTs the delivery and returns the response status, or 0 if no
+// response was received.
+func (d *Dispatcher) send(ctx context.Context, endpoint database.WebhookEndpoint, delivery database.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1")
+	req.Header.Set(EventHeader, delivery.Event)
+	req.Header.Set(DeliveryHeader, delivery.ID.String())
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, time.Now(), delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) finish(ctx context.Context, delivery database.WebhookDelivery, status string, attempts int32, cause error) {
+	var lastError string
+	if cause != nil {
+		lastError = cause.Error()
+	}
+	err := d.db.UpdateWebhookDelivery(ctx, delivery.ID, status, attempts, lastError)
+	if err != nil {
+		log.Printf("webhooks: updating delivery %s: %s", delivery.ID, err)
+	}
+}
+
+func nullString(err error) sql.NullString {
+	if err == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: err.Error(), Valid: true}
+}
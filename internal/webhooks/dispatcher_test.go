@@ -0,0 +1,468 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// fakeDB keeps the webhook and job tables in memory. Its clock runs skew
+// ahead of the real one, so tests can skip a retry's backoff instead of
+// waiting it out.
+type fakeDB struct {
+	mu         sync.Mutex
+	skew       time.Duration
+	endpoints  map[uuid.UUID]database.WebhookEndpoint
+	deliveries map[uuid.UUID]database.WebhookDelivery
+	attempts   []database.WebhookDeliveryAttempt
+	jobs       []database.Job
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{
+		endpoints:  make(map[uuid.UUID]database.WebhookEndpoint),
+		deliveries: make(map[uuid.UUID]database.WebhookDelivery),
+	}
+}
+
+func (f *fakeDB) now() time.Time { return time.Now().UTC().Add(f.skew) }
+
+func (f *fakeDB) addEndpoint(url string, events ...string) database.WebhookEndpoint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	endpoint := database.WebhookEndpoint{ID: uuid.New(), UserID: uuid.New(), Url: url, Secret: "whsec_test", Events: events}
+	f.endpoints[endpoint.ID] = endpoint
+	return endpoint
+}
+
+func (f *fakeDB) ListWebhookEndpointsForEvent(ctx context.Context, event string) ([]database.WebhookEndpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var out []database.WebhookEndpoint
+	for _, e := range f.endpoints {
+		if !e.DisabledAt.Valid && slices.Contains(e.Events, event) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeDB) GetWebhookEndpoint(ctx context.Context, id uuid.UUID) (database.WebhookEndpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.endpoints[id]
+	if !ok {
+		return database.WebhookEndpoint{}, store.ErrNotFound
+	}
+	return e, nil
+}
+
+func (f *fakeDB) updateEndpoint(id uuid.UUID, fn func(*database.WebhookEndpoint)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e := f.endpoints[id]
+	fn(&e)
+	f.endpoints[id] = e
+}
+
+func (f *fakeDB) RecordWebhookEndpointFailure(ctx context.Context, id uuid.UUID) (int32, error) {
+	var failures int32
+	f.updateEndpoint(id, func(e *database.WebhookEndpoint) {
+		e.ConsecutiveFailures++
+		failures = e.ConsecutiveFailures
+	})
+	return failures, nil
+}
+
+func (f *fakeDB) ResetWebhookEndpointFailures(ctx context.Context, id uuid.UUID) error {
+	f.updateEndpoint(id, func(e *database.WebhookEndpoint) { e.ConsecutiveFailures = 0 })
+	return nil
+}
+
+func (f *fakeDB) DisableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	f.updateEndpoint(id, func(e *database.WebhookEndpoint) { e.DisabledAt = sql.NullTime{Time: f.now(), Valid: true} })
+	return nil
+}
+
+func (f *fakeDB) EnableWebhookEndpoint(ctx context.Context, id uuid.UUID) error {
+	f.updateEndpoint(id, func(e *database.WebhookEndpoint) {
+		e.DisabledAt = sql.NullTime{}
+		e.ConsecutiveFailures = 0
+	})
+	return nil
+}
+
+func (f *fakeDB) CreateWebhookDelivery(ctx context.Context, endpointID uuid.UUID, event string, payload json.RawMessage) (database.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delivery := database.WebhookDelivery{
+		ID:         uuid.New(),
+		CreatedAt:  f.now(),
+		EndpointID: endpointID,
+		Event:      event,
+		Payload:    payload,
+		Status:     StatusPending,
+	}
+	f.deliveries[delivery.ID] = delivery
+	return delivery, nil
+}
+
+func (f *fakeDB) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.deliveries[id]
+	if !ok {
+		return database.WebhookDelivery{}, store.ErrNotFound
+	}
+	return d, nil
+}
+
+func (f *fakeDB) UpdateWebhookDelivery(ctx context.Context, id uuid.UUID, status string, attempts int32, lastError string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d := f.deliveries[id]
+	d.Status, d.Attempts = status, attempts
+	d.LastError = sql.NullString{String: lastError, Valid: lastError != ""}
+	f.deliveries[id] = d
+	return nil
+}
+
+func (f *fakeDB) ReplayWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	d, ok := f.deliveries[id]
+	if !ok || d.Status != StatusFailed {
+		return database.WebhookDelivery{}, store.ErrNotFound
+	}
+	d.Status = StatusPending
+	f.deliveries[id] = d
+	return d, nil
+}
+
+func (f *fakeDB) CreateWebhookDeliveryAttempt(ctx context.Context, attempt database.WebhookDeliveryAttempt) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	attempt.ID = uuid.New()
+	attempt.CreatedAt = f.now()
+	f.attempts = append(f.attempts, attempt)
+	return nil
+}
+
+func (f *fakeDB) CanSeeChirps(ctx context.Context, viewerID, authorID uuid.UUID) (bool, error) {
+	return true, nil
+}
+
+func (f *fakeDB) EnqueueJob(ctx context.Context, job database.Job) (database.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	job.ID = uuid.New()
+	job.CreatedAt = f.now()
+	job.Status = jobs.StatusPending
+	f.jobs = append(f.jobs, job)
+	return job, nil
+}
+
+func (f *fakeDB) ClaimJobs(ctx context.Context, kinds []string, limit int) ([]database.Job, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var claimed []database.Job
+	for i, job := range f.jobs {
+		if len(claimed) == limit {
+			break
+		}
+		if job.Status != jobs.StatusPending || job.RunAt.After(f.now()) || !slices.Contains(kinds, job.Kind) {
+			continue
+		}
+		job.Status = jobs.StatusRunning
+		job.Attempts++
+		f.jobs[i] = job
+		claimed = append(claimed, job)
+	}
+	return claimed, nil
+}
+
+func (f *fakeDB) updateJob(id uuid.UUID, fn func(*database.Job)) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	i := slices.IndexFunc(f.jobs, func(j database.Job) bool { return j.ID == id })
+	fn(&f.jobs[i])
+}
+
+func (f *fakeDB) CompleteJob(ctx context.Context, id uuid.UUID) error {
+	f.updateJob(id, func(j *database.Job) { j.Status = jobs.StatusSucceeded })
+	return nil
+}
+
+func (f *fakeDB) RetryJob(ctx context.Context, id uuid.UUID, runAt time.Time, lastError string) error {
+	f.updateJob(id, func(j *database.Job) {
+		j.Status, j.RunAt, j.LastError = jobs.StatusPending, runAt, sql.NullString{String: lastError, Valid: true}
+	})
+	return nil
+}
+
+func (f *fakeDB) KillJob(ctx context.Context, id uuid.UUID, lastError string) error {
+	f.updateJob(id, func(j *database.Job) {
+		j.Status, j.LastError = jobs.StatusDead, sql.NullString{String: lastError, Valid: true}
+	})
+	return nil
+}
+
+func (f *fakeDB) RequeueStaleJobs(ctx context.Context, lockedBefore time.Time, lastError string) (int64, error) {
+	return 0, nil
+}
+
+// settle waits until no job is running or due and returns the jobs.
+func (f *fakeDB) settle(t *testing.T) []database.Job {
+	t.Helper()
+	var out []database.Job
+	require.Eventually(t, func() bool {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		out = slices.Clone(f.jobs)
+		return !slices.ContainsFunc(out, func(j database.Job) bool {
+			return j.Status == jobs.StatusRunning || (j.Status == jobs.StatusPending && !j.RunAt.After(f.now()))
+		})
+	}, 5*time.Second, time.Millisecond)
+	return out
+}
+
+// skipBackoff moves the clock to when job is due again and returns the
+// backoff it skipped.
+func (f *fakeDB) skipBackoff(job database.Job) time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// The queue schedules retries by the real clock.
+	wait := time.Until(job.RunAt)
+	f.skew = wait
+	return wait
+}
+
+func (f *fakeDB) delivery(id uuid.UUID) database.WebhookDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.deliveries[id]
+}
+
+func (f *fakeDB) onlyDelivery(t *testing.T) database.WebhookDelivery {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.Len(t, f.deliveries, 1)
+	for _, d := range f.deliveries {
+		return d
+	}
+	return database.WebhookDelivery{}
+}
+
+// startDispatcher runs a dispatcher with one worker on f until the test
+// ends.
+func startDispatcher(t *testing.T, f *fakeDB) *Dispatcher {
+	t.Helper()
+	queue := jobs.New(f)
+	queue.Workers = 1
+	queue.PollInterval = time.Millisecond
+	d := NewDispatcher(f, queue)
+	d.AllowPrivateNetworks = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		queue.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return d
+}
+
+func TestDeliverySignature(t *testing.T) {
+	f := newFakeDB()
+	requests := make(chan *http.Request, 1)
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- r
+		bodies <- body
+	}))
+	defer srv.Close()
+	endpoint := f.addEndpoint(srv.URL, EventChirpCreated)
+	d := startDispatcher(t, f)
+
+	require.NoError(t, d.Enqueue(context.Background(), EventChirpCreated, map[string]string{"body": "hello"}))
+	r, body := <-requests, <-bodies
+
+	assert.NoError(t, Verify(endpoint.Secret, r.Header.Get(SignatureHeader), body, time.Minute, time.Now()))
+	assert.ErrorIs(t, Verify("whsec_other", r.Header.Get(SignatureHeader), body, time.Minute, time.Now()), ErrInvalidSignature)
+	assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+	assert.Equal(t, EventChirpCreated, r.Header.Get(EventHeader))
+
+	var envelope Envelope
+	require.NoError(t, json.Unmarshal(body, &envelope))
+	assert.Equal(t, EventChirpCreated, envelope.Type)
+	assert.JSONEq(t, `{"body": "hello"}`, string(envelope.Data))
+
+	f.settle(t)
+	delivery := f.onlyDelivery(t)
+	assert.Equal(t, delivery.ID.String(), r.Header.Get(DeliveryHeader))
+	assert.Equal(t, StatusSucceeded, delivery.Status)
+	assert.Equal(t, int32(1), delivery.Attempts)
+}
+
+func TestDeliveryRetriesWithBackoff(t *testing.T) {
+	f := newFakeDB()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+	endpoint := f.addEndpoint(srv.URL, EventChirpCreated)
+	d := startDispatcher(t, f)
+
+	require.NoError(t, d.Enqueue(context.Background(), EventChirpCreated, map[string]string{}))
+
+	// Each failure waits about twice as long as the one before.
+	job := f.settle(t)[0]
+	assert.Equal(t, jobs.StatusPending, job.Status)
+	assert.InDelta(t, 10*time.Second, f.skipBackoff(job), float64(2*time.Second))
+	assert.Equal(t, StatusPending, f.onlyDelivery(t).Status)
+
+	job = f.settle(t)[0]
+	assert.InDelta(t, 20*time.Second, f.skipBackoff(job), float64(3*time.Second))
+
+	job = f.settle(t)[0]
+	assert.Equal(t, jobs.StatusSucceeded, job.Status)
+	assert.Equal(t, int32(3), calls.Load())
+
+	delivery := f.onlyDelivery(t)
+	assert.Equal(t, StatusSucceeded, delivery.Status)
+	assert.Equal(t, int32(3), delivery.Attempts)
+	var codes []int32
+	for _, a := range f.attempts {
+		codes = append(codes, a.StatusCode.Int32)
+	}
+	assert.Equal(t, []int32{503, 503, 200}, codes)
+
+	// Success clears the endpoint's failure count.
+	endpoint, err := f.GetWebhookEndpoint(context.Background(), endpoint.ID)
+	require.NoError(t, err)
+	assert.Zero(t, endpoint.ConsecutiveFailures)
+}
+
+func TestDeliveryDisablesFailingEndpoint(t *testing.T) {
+	f := newFakeDB()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+	endpoint := f.addEndpoint(srv.URL, EventChirpCreated)
+	d := startDispatcher(t, f)
+	assert.Equal(t, int32(20), d.DisableAfter)
+	d.DisableAfter = 3
+
+	require.NoError(t, d.Enqueue(context.Background(), EventChirpCreated, map[string]string{}))
+	for {
+		job := f.settle(t)[0]
+		if job.Status != jobs.StatusPending {
+			break
+		}
+		f.skipBackoff(job)
+	}
+
+	// The attempt after the third failure finds the endpoint disabled and
+	// gives up without calling it.
+	assert.Equal(t, int32(3), calls.Load())
+	endpoint, err := f.GetWebhookEndpoint(context.Background(), endpoint.ID)
+	require.NoError(t, err)
+	assert.True(t, endpoint.DisabledAt.Valid)
+	delivery := f.onlyDelivery(t)
+	assert.Equal(t, StatusFailed, delivery.Status)
+	assert.Equal(t, "endpoint disabled", delivery.LastError.String)
+
+	// Disabled endpoints get no new deliveries.
+	require.NoError(t, d.Enqueue(context.Background(), EventChirpCreated, map[string]string{}))
+	assert.Len(t, f.settle(t), 1)
+	assert.Equal(t, delivery, f.delivery(delivery.ID))
+}
+
+func TestReplay(t *testing.T) {
+	f := newFakeDB()
+	var failing atomic.Bool
+	failing.Store(true)
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if failing.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	endpoint := f.addEndpoint(srv.URL, EventChirpCreated)
+	d := startDispatcher(t, f)
+	d.DisableAfter = 1
+	ctx := context.Background()
+
+	_, err := d.Replay(ctx, uuid.New())
+	assert.ErrorIs(t, err, store.ErrNotFound)
+
+	// A pending delivery is still being retried.
+	require.NoError(t, d.Enqueue(ctx, EventChirpCreated, map[string]string{}))
+	job := f.settle(t)[0]
+	delivery := f.onlyDelivery(t)
+	assert.Equal(t, StatusPending, delivery.Status)
+	_, err = d.Replay(ctx, delivery.ID)
+	assert.ErrorIs(t, err, ErrNotReplayable)
+
+	// The endpoint was disabled, so the next attempt fails the delivery.
+	f.skipBackoff(job)
+	f.settle(t)
+	assert.Equal(t, StatusFailed, f.delivery(delivery.ID).Status)
+
+	failing.Store(false)
+	replayed, err := d.Replay(ctx, delivery.ID)
+	require.NoError(t, err)
+	assert.Equal(t, StatusPending, replayed.Status)
+	f.settle(t)
+	assert.Equal(t, StatusSucceeded, f.delivery(delivery.ID).Status)
+	assert.Equal(t, int32(2), calls.Load())
+	endpoint, err = f.GetWebhookEndpoint(ctx, endpoint.ID)
+	require.NoError(t, err)
+	assert.False(t, endpoint.DisabledAt.Valid)
+
+	// Replaying a delivery that succeeded would send it twice.
+	_, err = d.Replay(ctx, delivery.ID)
+	assert.ErrorIs(t, err, ErrNotReplayable)
+	f.settle(t)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestEnqueueUserOnlyReachesTheirEndpoints(t *testing.T) {
+	f := newFakeDB()
+	mine := f.addEndpoint("http://example.com/mine", EventUserUpgraded)
+	f.addEndpoint("http://example.com/theirs", EventUserUpgraded)
+	d := NewDispatcher(f, jobs.New(f))
+
+	require.NoError(t, d.EnqueueUser(context.Background(), EventUserUpgraded, mine.UserID, map[string]uuid.UUID{"user_id": mine.UserID}))
+	assert.Equal(t, mine.ID, f.onlyDelivery(t).EndpointID)
+	assert.Len(t, f.jobs, 1)
+}
@@ -0,0 +1,87 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries the timestamp and HMAC of a delivery body.
+	SignatureHeader = "X-Chirpy-Signature"
+	// EventHeader carries the event type of a delivery.
+	EventHeader = "X-Chirpy-Event"
+	// DeliveryHeader carries the delivery ID so receivers can dedupe retries.
+	DeliveryHeader = "X-Chirpy-Delivery"
+)
+
+// ErrInvalidSignature -
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// NewSecret returns a random signing secret for a new endpoint.
+func NewSecret() (string, error) {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(key), nil
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+// The MAC covers "<unix timestamp>.<body>" so a captured payload can't be
+// replayed later with a fresh timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	ts := strconv.FormatInt(timestamp.Unix(), 10)
+	return fmt.Sprintf("t=%s,v1=%s", ts, computeMAC(secret, ts, body))
+}
+
+// Verify checks a signature header produced by Sign and rejects it if the
+// timestamp is further than tolerance from now.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	var ts, mac string
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return ErrInvalidSignature
+		}
+		switch key {
+		case "t":
+			ts = value
+		case "v1":
+			mac = value
+		}
+	}
+	if ts == "" || mac == "" {
+		return ErrInvalidSignature
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	age := now.Sub(time.Unix(unix, 0))
+	if age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+
+	expected := computeMAC(secret, ts, body)
+	if !hmac.Equal([]byte(mac), []byte(expected)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func computeMAC(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts))
+	h.Write([]byte("."))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	secret := "whsec_test"
+	body := []byte(`{"type":"chirp.created"}`)
+	now := time.Unix(1700000000, 0)
+
+	header := Sign(secret, now, body)
+	assert.NoError(t, Verify(secret, header, body, 5*time.Minute, now))
+
+	// Tampered body
+	assert.ErrorIs(t, Verify(secret, header, []byte(`{"type":"user.upgraded"}`), 5*time.Minute, now), ErrInvalidSignature)
+
+	// Wrong secret
+	assert.ErrorIs(t, Verify("whsec_other", header, body, 5*time.Minute, now), ErrInvalidSignature)
+
+	// Stale timestamp
+	assert.ErrorIs(t, Verify(secret, header, body, 5*time.Minute, now.Add(time.Hour)), ErrInvalidSignature)
+
+	// Malformed header
+	assert.ErrorIs(t, Verify(secret, "garbage", body, 5*time.Minute, now), ErrInvalidSignature)
+}
@@ -0,0 +1,143 @@
+// Package wsapi defines the message protocol spoken on /api/ws.
+//
+// Every frame in either direction is a single JSON text message wrapped in an
+// Envelope. The "v" field carries the protocol version; the server rejects
+// frames whose version it doesn't speak with an "error" envelope and keeps
+// the connection open.
+//
+// # Connecting
+//
+// The upgrade request must carry an access token, either as
+// "Authorization: Bearer <jwt>" or, for browsers that can't set headers on a
+// WebSocket, as the access_token query parameter. The server answers with a
+// "welcome" envelope whose data holds the caller's user_id and the token's
+// expires_at. When the token expires the server sends an "error" envelope
+// with code "token_expired" and closes the connection, unless the client has
+// sent an "auth" envelope with a fresh token for the same user first.
+//
+// Bots may connect with "Authorization: ApiKey <key>" instead, using a
+// personal API key with the chirps:read scope. The welcome then has no
+// expires_at. Third-party apps may connect with an OAuth access token that
+// has the chirps:read scope. Either way the notifications channel is
+// refused until the client sends an "auth" envelope with the user's own
+// access token.
+//
+// # Client to server
+//
+//	{"v":1,"type":"subscribe","id":"1","channel":"chirps"}
+//	{"v":1,"type":"subscribe","id":"2","channel":"chirps:<author uuid>","last_event_id":41}
+//	{"v":1,"type":"subscribe","id":"3","channel":"notifications"}
+//	{"v":1,"type":"unsubscribe","id":"4","channel":"chirps"}
+//	{"v":1,"type":"auth","id":"5","token":"<new access token>"}
+//	{"v":1,"type":"ping","id":"6"}
+//
+// "id" is chosen by the client and echoed back in the matching "ack",
+// "pong" or "error" so requests can be correlated. "last_event_id" resumes
+// a channel from the server's short in-memory history.
+//
+// # Server to client
+//
+//	{"v":1,"type":"welcome","data":{"user_id":"...","expires_at":"..."}}
+//	{"v":1,"type":"ack","id":"1","channel":"chirps"}
+//	{"v":1,"type":"event","channel":"chirps","event_id":42,"event":"chirp.created","data":{...}}
+//	{"v":1,"type":"pong","id":"6"}
+//	{"v":1,"type":"error","id":"2","code":"bad_channel","error":"..."}
+//
+// A channel whose client falls behind is dropped with an "error" envelope
+// (code "dropped") naming the channel; resubscribe with the last event_id
+// seen to catch up. WebSocket ping/pong control frames are also used as a
+// keepalive and the connection is closed if pongs stop arriving.
+package wsapi
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Version is the protocol version this server speaks.
+const Version = 1
+
+// Envelope types.
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypeAuth        = "auth"
+	TypePing        = "ping"
+
+	TypeWelcome = "welcome"
+	TypeAck     = "ack"
+	TypeEvent   = "event"
+	TypePong    = "pong"
+	TypeError   = "error"
+)
+
+// Error codes sent in "error" envelopes.
+const (
+	CodeBadRequest   = "bad_request"
+	CodeBadVersion   = "unsupported_version"
+	CodeBadChannel   = "bad_channel"
+	CodeUnauthorized = "unauthorized"
+	CodeTokenExpired = "token_expired"
+	CodeDropped      = "dropped"
+	CodeInternal     = "internal"
+)
+
+// Channel names a client can subscribe to. Author channels are
+// ChannelChirps + ":" + the author's UUID.
+const (
+	ChannelChirps        = "chirps"
+	ChannelNotifications = "notifications"
+)
+
+// Envelope is the versioned wrapper around every frame.
+type Envelope struct {
+	V           int             `json:"v"`
+	Type        string          `json:"type"`
+	ID          string          `json:"id,omitempty"`
+	Channel     string          `json:"channel,omitempty"`
+	Token       string          `json:"token,omitempty"`
+	LastEventID uint64          `json:"last_event_id,omitempty"`
+	EventID     uint64          `json:"event_id,omitempty"`
+	Event       string          `json:"event,omitempty"`
+	Data        json.RawMessage `json:"data,omitempty"`
+	Code        string          `json:"code,omitempty"`
+	Error       string          `json:"error,omitempty"`
+}
+
+// Welcome is the data of a "welcome" envelope.
+type Welcome struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at,omitzero"`
+}
+
+// ErrBadChannel -
+var ErrBadChannel = errors.New("unknown channel")
+
+// Channel is a parsed channel name.
+type Channel struct {
+	Name string
+	// Author is set for single-author chirp channels.
+	Author uuid.UUID
+}
+
+// ParseChannel validates a channel name from a subscribe message.
+func ParseChannel(name string) (Channel, error) {
+	switch name {
+	case ChannelChirps, ChannelNotifications:
+		return Channel{Name: name}, nil
+	}
+
+	rest, ok := strings.CutPrefix(name, ChannelChirps+":")
+	if !ok {
+		return Channel{}, ErrBadChannel
+	}
+	author, err := uuid.Parse(rest)
+	if err != nil {
+		return Channel{}, ErrBadChannel
+	}
+	return Channel{Name: name, Author: author}, nil
+}
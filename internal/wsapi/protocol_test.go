@@ -0,0 +1,37 @@
+package wsapi
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChannel(t *testing.T) {
+	author := uuid.New()
+
+	tests := []struct {
+		name    string
+		want    Channel
+		wantErr bool
+	}{
+		{name: "chirps", want: Channel{Name: "chirps"}},
+		{name: "notifications", want: Channel{Name: "notifications"}},
+		{name: "chirps:" + author.String(), want: Channel{Name: "chirps:" + author.String(), Author: author}},
+		{name: "chirps:not-a-uuid", wantErr: true},
+		{name: "notifications:" + author.String(), wantErr: true},
+		{name: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseChannel(tt.name)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrBadChannel)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -1,21 +1,38 @@
 package main
 
 import (
-	"database/sql"
+	"context"
 	"encoding/json"
 	"fmt"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
-	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/joho/godotenv"
+	"github.com/willmelton21/chirpy/internal/apierror"
 	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/config"
 	"github.com/willmelton21/chirpy/internal/database"
+	"github.com/willmelton21/chirpy/internal/health"
+	"github.com/willmelton21/chirpy/internal/jobs"
+	"github.com/willmelton21/chirpy/internal/logging"
+	"github.com/willmelton21/chirpy/internal/metrics"
+	"github.com/willmelton21/chirpy/internal/migrations"
+	"github.com/willmelton21/chirpy/internal/notify"
+	"github.com/willmelton21/chirpy/internal/oidc"
+	"github.com/willmelton21/chirpy/internal/pubsub"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/tracing"
+	"github.com/willmelton21/chirpy/internal/validate"
+	"github.com/willmelton21/chirpy/internal/webhooks"
 
 	_ "github.com/lib/pq"
 )
@@ -36,6 +53,7 @@ type User struct {
 	Password  string    `json:"password"`
 	Token     string    `json:"Token"`
 	Is_Chirpy_Red bool  `json:"is_chirpy_red"`
+	IsPrivate bool  `json:"is_private"`
 }
 
 type LoginRequest struct {
@@ -44,10 +62,31 @@ type LoginRequest struct {
 }
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	dbs            *database.Queries
+	store          store.Store
+	jobs           *jobs.Queue
+	webhooks       *webhooks.Dispatcher
+	broker         *pubsub.Broker
+	notifications  *notify.Service
+	metrics        *metrics.Metrics
 	Platform       string
 	Secret         string
+	PolkaKey       string
+	AdminKey       string
+	// PublicURL is where browsers reach the server.
+	PublicURL string
+	// oidc holds the OpenID Connect providers users can sign in with,
+	// keyed by name.
+	oidc map[string]*oidc.Provider
+
+	// StreamWriteTimeout bounds each write on SSE and WebSocket
+	// connections, which are exempt from the server's WriteTimeout.
+	StreamWriteTimeout time.Duration
+	// wsPongWait overrides how long WebSocket clients have to answer a
+	// ping when set; tests shorten it.
+	wsPongWait time.Duration
+	// shutdown is closed when the server starts draining so long-lived
+	// streams can end instead of holding shutdown up.
+	shutdown chan struct{}
 }
 
 type parameters struct {
@@ -55,7 +94,26 @@ type parameters struct {
 }
 
 type ErrorResponse struct {
-	Error string `json:"error"`
+	Error   string                `json:"error"`
+	Code    apierror.Code         `json:"code"`
+	Fields  []validate.FieldError `json:"fields,omitempty"`
+	TraceID string                `json:"trace_id,omitempty"`
+}
+
+// problemTypePrefix makes a ProblemDetails type URI from an error code.
+const problemTypePrefix = "urn:chirpy:error:"
+
+// ProblemDetails is an RFC 7807 error, sent instead of ErrorResponse to
+// clients that accept application/problem+json.
+type ProblemDetails struct {
+	Type     string                `json:"type"`
+	Title    string                `json:"title"`
+	Status   int                   `json:"status"`
+	Detail   string                `json:"detail,omitempty"`
+	Instance string                `json:"instance,omitempty"`
+	Code     apierror.Code         `json:"code"`
+	Errors   []validate.FieldError `json:"errors,omitempty"`
+	TraceID  string                `json:"trace_id,omitempty"`
 }
 type cleanedBody struct {
 	Body string `json:"cleaned_body"`
@@ -64,7 +122,7 @@ type cleanedBody struct {
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
-		cfg.fileserverHits.Add(1)
+		cfg.metrics.FileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
@@ -79,37 +137,29 @@ func (cfg *apiConfig) UpgradeUser(w http.ResponseWriter, r *http.Request) {
 		Data  Data   `json:"data"`   
 	}
 
-	key, err := auth.GetAPIKey(r.Header)
-	if err != nil {
-		respondWithError(w,401,"Couldn't GetApi key from  header")
-		return
-	}
-
-	if key != os.Getenv("POLKA_KEY") {
-		respondWithError(w,401,"Key does not match .env key")
-		return
-
-
-	}
-	
-	decoder := json.NewDecoder(r.Body)
    var upgradeStruct upgradeParams
-	err = decoder.Decode(&upgradeStruct)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+	if !decodeJSONLenient(w, r, &upgradeStruct) {
 		return
 	}
+	cfg.metrics.WebhookEvents.WithLabelValues(metrics.WebhookInbound, upgradeStruct.Event).Inc()
 
 	if upgradeStruct.Event != "user.upgraded" {
 		respondWithJSON(w,204,"")
 		return
 	}
 	parsedID, err := uuid.Parse(upgradeStruct.Data.User_id)
-	err = cfg.dbs.Upgradeuser(r.Context(),uuid.UUID(parsedID))
+	var v validate.Validator
+	v.Check(err == nil, "data.user_id", "must be a UUID")
+	if !checkValid(w, r, &v) {
+		return
+	}
+	err = cfg.store.UpgradeUser(r.Context(), parsedID)
 	if err != nil {
-		respondWithError(w, http.StatusNotFound, "Couldn't Find Upgrade User")
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't Find Upgrade User", err)
 		return
 	}
+	cfg.emitUserWebhook(r, webhooks.EventUserUpgraded, parsedID, map[string]uuid.UUID{"user_id": parsedID})
+	cfg.emitNotification(r, notify.UserUpgraded{UserID: parsedID})
 
 
 	respondWithJSON(w,204,"")
@@ -117,49 +167,33 @@ func (cfg *apiConfig) UpgradeUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) DeleteChirp(w http.ResponseWriter, r *http.Request) {
-  	authHeader := r.Header.Get("Authorization")	
-	if authHeader == ""{
-		respondWithError(w, http.StatusUnauthorized, "Header auth token was empty")
-		return
-	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "Bearer" {
-		respondWithError(w, http.StatusBadRequest, "malformed authorization header")
-		return
-	}
-
-	token := splitAuth[1]
-
-   tokenSecret := os.Getenv("SECRET")
-
-	authedUserID, err := auth.ValidateJWT(token,tokenSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't Get user from token")
-		return
-	}
+	authedUserID := principal(r).UserID
 
 	currID := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(currID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, r, apierror.ChirpNotFound, "", err)
 		return
 	}
 
-   chirp,err := cfg.dbs.GetChirpByID(r.Context(), chirpID)
+   chirp,err := cfg.store.GetChirp(r.Context(), chirpID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, r, apierror.For(err, apierror.ChirpNotFound), "Couldn't get chirp", err)
 		return
 	}
    if chirp.UserID != authedUserID {
-      respondWithError(w,403,"Chirp User ID did not match authorized user ID")
+      respondWithError(w, r, apierror.ChirpForbidden, "Chirp User ID did not match authorized user ID", nil)
       return
    }
 
-   err = cfg.dbs.DeleteChirpByID(r.Context(), chirpID)
+   err = cfg.store.DeleteChirp(r.Context(), chirpID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, r, apierror.For(err, apierror.ChirpNotFound), "Couldn't delete chirp", err)
 		return
 	}
+	deleted := map[string]uuid.UUID{"id": chirp.ID, "user_id": chirp.UserID}
+	cfg.emitChirpWebhook(r, webhooks.EventChirpDeleted, chirp.UserID, deleted)
+	cfg.publishChirp(streamChirpDeleted, chirp.UserID, deleted)
 
 	respondWithJSON(w,204,"")
 }
@@ -171,50 +205,42 @@ func (cfg *apiConfig) UpdateUserInfo(w http.ResponseWriter, r *http.Request) {
 		Email            string `json:"email"`
 	}
 
-	authHeader := r.Header.Get("Authorization")	
-	if authHeader == ""{
-		respondWithError(w, http.StatusUnauthorized, "Header auth token was empty")
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
 		return
 	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "Bearer" {
-		respondWithError(w, http.StatusBadRequest, "malformed authorization header")
+	var v validate.Validator
+	v.Email("email", params.Email)
+	v.Password("password", params.Password)
+	if !checkValid(w, r, &v) {
 		return
 	}
 
-	token := splitAuth[1]
-
-	userID, err := auth.GetUserIDFromToken(token)
+	hashedPassword, err := auth.HashPassword(r.Context(), params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't Get user from token")
+		respondWithError(w, r, apierror.Internal, "couldn't hash password", err)
 		return
 	}
 
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+	updatedUser, err := cfg.store.UpdateUserCredentials(r.Context(), userID, params.Email, hashedPassword)
+	if errors.Is(err, store.ErrConflict) {
+		respondWithError(w, r, apierror.UserEmailTaken, "", err)
 		return
 	}
-
-	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "couldn't hash password")
+		respondWithError(w, r, apierror.For(err, apierror.UserNotFound), "Couldn't update credentials", err)
 		return
 	}
 
-	updatedUser, err := cfg.dbs.UpdateEmailAndPass(r.Context(),database.UpdateEmailAndPassParams{Email: params.Email, HashedPassword: hashedPassword,ID: userID})
-	if err != nil {
-		respondWithError(w,http.StatusInternalServerError, "Couldn't update credentials")
-	}
-
 	userStruct := User{
 			ID:        updatedUser.ID,
 			CreatedAt: updatedUser.CreatedAt,
 			UpdatedAt: updatedUser.UpdatedAt,
 			Email:     updatedUser.Email,
 		   Is_Chirpy_Red: updatedUser.IsChirpyRed.Bool,
+		   IsPrivate:     updatedUser.IsPrivate,
 		}
 
 	respondWithJSON(w, http.StatusOK,userStruct)
@@ -222,22 +248,9 @@ func (cfg *apiConfig) UpdateUserInfo(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) Revoke(w http.ResponseWriter, r *http.Request) {
-		authHeader := r.Header.Get("Authorization")	
-	if authHeader == ""{
-		respondWithError(w, http.StatusBadRequest, "Header auth token was empty")
-		return
-	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "Bearer" {
-		respondWithError(w, http.StatusBadRequest, "malformed authorization header")
-		return
-	}
-
-	token := splitAuth[1]
-
-	 err := cfg.dbs.RevokeToken(r.Context(),token)
+	 err := cfg.store.RevokeRefreshToken(r.Context(), principal(r).Token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Token couldn't be revoked")
+		respondWithError(w, r, apierror.For(err, apierror.AuthRefreshTokenInvalid), "Token couldn't be revoked", err)
 		return
 	}	
 	respondWithJSON(w,204,"")
@@ -250,28 +263,12 @@ func (cfg *apiConfig) Refresh(w http.ResponseWriter, r *http.Request) {
 		Token string `json:"token"`
 	}
 
-	authHeader := r.Header.Get("Authorization")	
-	if authHeader == ""{
-		respondWithError(w, http.StatusBadRequest, "Header auth token was empty")
-		return
-	}
-	splitAuth := strings.Split(authHeader, " ")
-	if len(splitAuth) < 2 || splitAuth[0] != "Bearer" {
-		respondWithError(w, http.StatusBadRequest, "malformed authorization header")
-		return
-	}
-
-	token := splitAuth[1]
-
-	validUser, err := cfg.dbs.GetUserFromRefreshToken(r.Context(),token)
+	accessToken, err := auth.MakeJWT(r.Context(), principal(r).UserID, cfg.Secret, time.Hour)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Token not in database or expired")
+		respondWithError(w, r, apierror.Internal, "Couldn't create access JWT", err)
 		return
 	}
 
-	accessToken, err := auth.MakeJWT(validUser.ID,cfg.Secret,time.Hour)
-
-
 
 	tokenStruct := tokenResponse{Token: accessToken}
 
@@ -286,68 +283,78 @@ type parameters struct {
 		Password         string `json:"password"`
 		Email            string `json:"email"`
 	}
-	type response struct {
-		User
-		Token        string `json:"token"`
-		RefreshToken string `json:"refresh_token"`
-	}
 
-	decoder := json.NewDecoder(r.Body)
 	params := parameters{}
-	err := decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+	if !decodeJSON(w, r, &params) {
+		return
+	}
+	// Only check presence here: passwords set before the strength rules
+	// existed must still work.
+	var v validate.Validator
+	v.Required("email", params.Email)
+	v.Required("password", params.Password)
+	if !checkValid(w, r, &v) {
 		return
 	}
 
-	user, err := cfg.dbs.GetUserByEmail(r.Context(), params.Email)
+	user, err := cfg.store.GetUserByEmail(r.Context(), params.Email)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password")
+		cfg.metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+		respondWithError(w, r, apierror.AuthInvalidCredentials, "Incorrect email or password", err)
 		return
 	}
 
-	err = auth.CheckPasswordHash(params.Password, user.HashedPassword)
+	err = auth.CheckPasswordHash(r.Context(), params.Password, user.HashedPassword)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Incorrect email or password",)
+		cfg.metrics.Logins.WithLabelValues(metrics.LoginFailed).Inc()
+		respondWithError(w, r, apierror.AuthInvalidCredentials, "Incorrect email or password", err)
 		return
 	}
+	cfg.startSession(w, r, user)
+}
 
-	expirationTime := time.Hour
-	
+// startSession logs user in: it issues an access and refresh token and
+// responds with them. Every way of logging in ends here.
+func (cfg *apiConfig) startSession(w http.ResponseWriter, r *http.Request, user database.User) {
+	type response struct {
+		User
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
+	}
 
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.Secret,
-		expirationTime,
-	)
+	logging.SetUserID(r.Context(), user.ID)
+
+	accessToken, err := auth.MakeJWT(r.Context(), user.ID, cfg.Secret, time.Hour)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create access JWT")
+		respondWithError(w, r, apierror.Internal, "Couldn't create access JWT", err)
 		return
 	}
 
 	refreshToken, err := auth.MakeRefreshToken()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create refreshToken")
+		respondWithError(w, r, apierror.Internal, "Couldn't create refreshToken", err)
+		return
 	}
 
-	expireTime := time.Now().AddDate(0,0,60) 
-
-   cfg.dbs.CreateTokenDB(r.Context(), database.CreateTokenDBParams{Token: refreshToken, UserID: user.ID,ExpiresAt: expireTime })
-
+	err = cfg.store.CreateRefreshToken(r.Context(), refreshToken, user.ID, time.Now().AddDate(0, 0, 60))
+	if err != nil {
+		respondWithError(w, r, apierror.Internal, "Couldn't save refreshToken", err)
+		return
+	}
+	cfg.metrics.Logins.WithLabelValues(metrics.LoginSucceeded).Inc()
 
 	respondWithJSON(w, http.StatusOK, response{
 		User: User{
-			ID:        user.ID,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-			Email:     user.Email,
-		   Is_Chirpy_Red: user.IsChirpyRed.Bool,
+			ID:            user.ID,
+			CreatedAt:     user.CreatedAt,
+			UpdatedAt:     user.UpdatedAt,
+			Email:         user.Email,
+			Is_Chirpy_Red: user.IsChirpyRed.Bool,
+			IsPrivate:     user.IsPrivate,
 		},
-		Token: accessToken,
+		Token:        accessToken,
 		RefreshToken: refreshToken,
 	})
-
-
 }
 
 func (cfg *apiConfig) GetChirp(w http.ResponseWriter, r *http.Request) {
@@ -355,13 +362,25 @@ func (cfg *apiConfig) GetChirp(w http.ResponseWriter, r *http.Request) {
 	currID := r.PathValue("chirpID")
 	chirpID, err := uuid.Parse(currID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, r, apierror.ChirpNotFound, "", err)
+		return
+	}
+
+	chirp, err := cfg.store.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, r, apierror.For(err, apierror.ChirpNotFound), "Couldn't get chirp", err)
 		return
 	}
 
-	chirp, err := cfg.dbs.GetChirpByID(r.Context(), chirpID)
+	// Blocks and private accounts hide chirps as if they didn't exist.
+	// Muting only keeps chirps off timelines.
+	visible, err := cfg.store.CanSeeChirps(r.Context(), principal(r).UserID, chirp.UserID)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
+		respondWithError(w, r, apierror.Internal, "Couldn't get chirp", err)
+		return
+	}
+	if !visible {
+		respondWithError(w, r, apierror.ChirpNotFound, "", nil)
 		return
 	}
 
@@ -382,42 +401,58 @@ func (cfg *apiConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 	sortOrder := r.URL.Query().Get("sort")
 
 	s := r.URL.Query().Get("author_id")
-	if s != "" {
-    	parsedID, err := uuid.Parse(s)
-		dbResult, err := cfg.dbs.GetChirpsByID(r.Context(),parsedID)
+
+	// Listing one author's chirps ignores mutes; the whole timeline
+	// doesn't.
+	hidden, err := cfg.hiddenAuthors(r.Context(), principal(r).UserID, s == "")
 	if err != nil {
-		msg := fmt.Sprintf("Error getting all chrips: %s", err)
-		respondWithError(w, 500, msg)
+		respondWithError(w, r, apierror.Internal, "Couldn't get chirps", err)
 		return
-
 	}
-	chirps := make([]Chirp, 0)
-	for _, dbRow := range dbResult {
-		chirps = append(chirps, Chirp{
-			ID:        dbRow.ID,
-			Body:      dbRow.Body,
-			CreatedAt: dbRow.CreatedAt,
-			UpdatedAt: dbRow.UpdatedAt,
-			UserID:    dbRow.UserID,
-		})
-	}
-   if sortOrder == "desc" {
-		sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt)})
+
+	if s != "" {
+		parsedID, err := uuid.Parse(s)
+		if err != nil {
+			respondWithError(w, r, apierror.RequestInvalidParameter, "author_id must be a UUID", err)
+			return
+		}
+		dbResult, err := cfg.store.ListChirpsByAuthor(r.Context(), parsedID)
+		if err != nil {
+			respondWithError(w, r, apierror.Internal, "Couldn't get chirps", err)
+			return
+		}
+		chirps := make([]Chirp, 0)
+		for _, dbRow := range dbResult {
+			if hidden[dbRow.UserID] {
+				continue
+			}
+			chirps = append(chirps, Chirp{
+				ID:        dbRow.ID,
+				Body:      dbRow.Body,
+				CreatedAt: dbRow.CreatedAt,
+				UpdatedAt: dbRow.UpdatedAt,
+				UserID:    dbRow.UserID,
+			})
+		}
+		if sortOrder == "desc" {
+			sort.Slice(chirps, func(i, j int) bool { return chirps[i].CreatedAt.After(chirps[j].CreatedAt) })
 		}
 
-	respondWithJSON(w, 200, chirps)
-	return
+		respondWithJSON(w, 200, chirps)
+		return
 	}
 
-	dbResult, err := cfg.dbs.GetChirps(r.Context())
+	dbResult, err := cfg.store.ListChirps(r.Context())
 	if err != nil {
-		msg := fmt.Sprintf("Error getting all chrips: %s", err)
-		respondWithError(w, 500, msg)
+		respondWithError(w, r, apierror.Internal, "Couldn't get chirps", err)
 		return
 
 	}
 	chirps := make([]Chirp, 0)
 	for _, dbRow := range dbResult {
+		if hidden[dbRow.UserID] {
+			continue
+		}
 		chirps = append(chirps, Chirp{
 			ID:        dbRow.ID,
 			Body:      dbRow.Body,
@@ -438,13 +473,12 @@ func (cfg *apiConfig) ResetDB(w http.ResponseWriter, r *http.Request) {
 
 	if cfg.Platform != "dev" {
 		msg := "Unauthorized Access"
-		respondWithError(w, 403, msg)
+		respondWithError(w, r, apierror.AuthForbidden, msg, nil)
 		return
 	} else {
-		err := cfg.dbs.ResetTable(r.Context())
+		err := cfg.store.Reset(r.Context())
 		if err != nil {
-			msg := fmt.Sprintf("Error decoding parameters: %s", err)
-			respondWithError(w, 500, msg)
+			respondWithError(w, r, apierror.Internal, "Couldn't reset database", err)
 			return
 		}
 		w.WriteHeader(200)
@@ -452,32 +486,31 @@ func (cfg *apiConfig) ResetDB(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (cfg *apiConfig) metricsHandler(w http.ResponseWriter, r *http.Request) {
-
-	fmt.Fprintf(w, "Hits: %d", cfg.fileserverHits.Load())
-}
-
 func (cfg *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var userParams User
-	decoder := json.NewDecoder(r.Body)
-
-	err := decoder.Decode(&userParams)
-	if err != nil {
-		msg := fmt.Sprintf("Error decoding parameters: %s", err)
-		respondWithError(w, 500, msg)
+	var userParams LoginRequest
+	if !decodeJSON(w, r, &userParams) {
+		return
+	}
+	var v validate.Validator
+	v.Email("email", userParams.Email)
+	v.Password("password", userParams.Password)
+	if !checkValid(w, r, &v) {
 		return
 	}
 
-	hPass, err := auth.HashPassword(userParams.Password)
+	hPass, err := auth.HashPassword(r.Context(), userParams.Password)
 	if err != nil {
-		fmt.Errorf("hashing password failed %s", err)
+		respondWithError(w, r, apierror.Internal, "Couldn't hash password", err)
 		return
 	}
 
-	dbUser, err := cfg.dbs.CreateUser(r.Context(), database.CreateUserParams{Email: userParams.Email, HashedPassword: hPass})
+	dbUser, err := cfg.store.CreateUser(r.Context(), userParams.Email, hPass)
+	if errors.Is(err, store.ErrConflict) {
+		respondWithError(w, r, apierror.UserEmailTaken, "", err)
+		return
+	}
 	if err != nil {
-		msg := fmt.Sprintf("Error creating user for DB: %s", err)
-		respondWithError(w, 500, msg)
+		respondWithError(w, r, apierror.Internal, "Couldn't create user", err)
 		return
 	}
 
@@ -488,6 +521,7 @@ func (cfg *apiConfig) CreateUser(w http.ResponseWriter, r *http.Request) {
 		Email:     dbUser.Email,
 		Password:  dbUser.HashedPassword,
 		Is_Chirpy_Red: dbUser.IsChirpyRed.Bool,
+		IsPrivate:     dbUser.IsPrivate,
 
 	}
 	respondWithJSON(w, 201, user)
@@ -510,22 +544,81 @@ func FilterProfanity(in string) string {
 
 }
 
-func respondWithError(w http.ResponseWriter, code int, msg string) {
+// respondWithError sends code's status and msg to the client, as
+// problem details if the client accepts them. An empty msg uses code's
+// title. err is the server-side cause, if any; it goes to the access log
+// and is never sent to the client.
+func respondWithError(w http.ResponseWriter, r *http.Request, code apierror.Code, msg string, err error) {
+	writeError(w, r, code, msg, nil, err)
+}
+
+// respondWithFieldErrors is respondWithError for a request with invalid
+// fields, which are listed in the response.
+func respondWithFieldErrors(w http.ResponseWriter, r *http.Request, code apierror.Code, msg string, fields []validate.FieldError) {
+	writeError(w, r, code, msg, fields, nil)
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, code apierror.Code, msg string, fields []validate.FieldError, err error) {
+	status := code.Status()
+	if msg == "" {
+		msg = code.Title()
+	}
+	// A 501 is how this deployment is set up, not something going wrong.
+	fault := status > 499 && status != http.StatusNotImplemented
+	if err != nil {
+		logging.RecordError(w, fmt.Errorf("%s: %w", msg, err))
+	} else if fault {
+		logging.RecordError(w, errors.New(msg))
+	}
+	// Never leak internal details, whatever the handler wrote.
+	if fault {
+		msg = code.Title()
+	}
 
-	errResp := ErrorResponse{
-		Error: msg}
+	if !acceptsProblemJSON(r) {
+		respondWithJSON(w, status, ErrorResponse{
+			Error:   msg,
+			Code:    code,
+			Fields:  fields,
+			TraceID: logging.TraceID(w),
+		})
+		return
+	}
 
-	dat, err := json.Marshal(errResp)
+	dat, err := json.Marshal(ProblemDetails{
+		Type:     problemTypePrefix + string(code),
+		Title:    code.Title(),
+		Status:   status,
+		Detail:   msg,
+		Instance: r.URL.Path,
+		Code:     code,
+		Errors:   fields,
+		TraceID:  logging.TraceID(w),
+	})
 	if err != nil {
 		log.Printf("Error marshalling JSON: %s", err)
 		w.WriteHeader(500)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(code)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
 	w.Write(dat)
 }
 
+// acceptsProblemJSON reports whether the client asked for RFC 7807
+// problem details in its Accept header.
+func acceptsProblemJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaType := range strings.Split(accept, ",") {
+			mediaType, _, _ = strings.Cut(mediaType, ";")
+			if strings.TrimSpace(mediaType) == "application/problem+json" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 
 	dat, err := json.Marshal(payload)
@@ -546,37 +639,25 @@ type parameters struct {
 		Body string `json:"body"`
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT")
+	userID := principal(r).UserID
+
+	params := parameters{}
+	if !decodeJSON(w, r, &params) {
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.Secret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT")
-		return
+	var v validate.Validator
+	if v.Required("body", params.Body) {
+		v.MaxLength("body", params.Body, maxChirpLength)
 	}
-
-	decoder := json.NewDecoder(r.Body)
-	params := parameters{}
-	err = decoder.Decode(&params)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters")
+	if !checkValid(w, r, &v) {
 		return
 	}
 
 	cleaned := FilterProfanity(params.Body)
-	if err != nil {
-		respondWithError(w, http.StatusBadRequest, err.Error())
-		return
-	}
 
-	chirp, err := cfg.dbs.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   cleaned,
-		UserID: userID,
-	})
+	chirp, err := cfg.store.CreateChirp(r.Context(), userID, cleaned)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create chirp")
+		respondWithError(w, r, apierror.Internal, "Couldn't create chirp", err)
 		return
 	}
 
@@ -588,78 +669,170 @@ type parameters struct {
 		Body:      chirp.Body,
 		UserID:    chirp.UserID,
 	}
+	cfg.metrics.ChirpsCreated.Inc()
+	cfg.emitChirpWebhook(r, webhooks.EventChirpCreated, chirpStruct.UserID, chirpStruct)
+	cfg.publishChirp(streamChirpCreated, chirpStruct.UserID, chirpStruct)
+	cfg.emitNotification(r, notify.ChirpCreated{ChirpID: chirp.ID, AuthorID: chirp.UserID, Body: chirp.Body})
 
 	respondWithJSON(w, 201, chirpStruct)
 
 }
 
-func main() {
-	godotenv.Load()
-	dbURL := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
-	secret := os.Getenv("SECRET")
-	db, err := sql.Open("postgres", dbURL)
+// serve runs the HTTP server and background workers until SIGINT or
+// SIGTERM.
+func serve(conf config.Config) {
+	err := conf.Validate()
 	if err != nil {
-		fmt.Errorf("error opening database %s", err)
-		return
+		log.Fatalf("error loading config: %s", err)
 	}
-
-	dbQueries := database.New(db)
-
-	mux := http.NewServeMux()
-	var apiCfg apiConfig
-
-	apiCfg.dbs = dbQueries
-	apiCfg.Platform = platform
-	apiCfg.Secret = secret
-
-	handler := http.StripPrefix("/app/", http.FileServer(http.Dir('.')))
-
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(handler))
-	servStruct := http.Server{
-		Handler: mux,
-		Addr:    ":8080",
+	level, err := logging.ParseLevel(conf.Log.Level)
+	if err != nil {
+		log.Fatalf("error loading config: %s", err)
 	}
-	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, req *http.Request) {
-
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	logger, err := logging.New(os.Stdout, conf.Log.Format, level)
+	if err != nil {
+		log.Fatalf("error creating logger: %s", err)
+	}
+	slog.SetDefault(logger)
+	slog.Info("Loaded config", "config", conf)
 
-	mux.HandleFunc("GET /admin/metrics", func(w http.ResponseWriter, req *http.Request) {
+	driver, db, err := store.Open(conf.DBURL)
+	if err != nil {
+		log.Fatalf("error opening database %s", err)
+	}
+	if driver == store.DriverMemory && conf.Platform != "dev" {
+		log.Fatal("DB_URL=memory:// loses everything on restart and needs PLATFORM=dev")
+	}
 
-		w.Header().Set("Content-Type", "text/html; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-		visitCount := apiCfg.fileserverHits.Load()
-		fmt.Fprintf(w, "<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>", visitCount)
+	// The memory store has no schema, so db and migrator stay nil.
+	var migrator *migrations.Migrator
+	if db != nil {
+		migrator, err = migrations.New(db, driver)
+		if err != nil {
+			log.Fatalf("error loading migrations %s", err)
+		}
+		if conf.AutoMigrate {
+			results, err := migrator.Up(context.Background())
+			if err != nil {
+				log.Fatalf("error migrating database: %s", err)
+			}
+			for _, res := range results {
+				slog.Info("Applied migration", "version", res.Source.Version, "duration", res.Duration)
+			}
+		}
+		err = migrator.Check(context.Background())
+		if err != nil {
+			log.Fatalf("refusing to serve: %s (run `chirpy migrate up` or set AUTO_MIGRATE=true)", err)
+		}
+	}
 
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    conf.Tracing.Exporter,
+		ServiceName: conf.Tracing.ServiceName,
+		SampleRatio: conf.Tracing.SampleRatio,
 	})
+	if err != nil {
+		log.Fatalf("error setting up tracing: %s", err)
+	}
 
-	mux.HandleFunc("POST /api/users", apiCfg.CreateUser)
-
-	mux.HandleFunc("POST /admin/reset", apiCfg.ResetDB)
-
-	mux.HandleFunc("POST /api/chirps", apiCfg.CreateChirp)
-
-	mux.HandleFunc("GET /api/chirps", apiCfg.GetChirps)
-
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.GetChirp)
-
-	mux.HandleFunc("POST /api/login", apiCfg.Login)
+	var tracedDB store.DB
+	if db != nil {
+		tracedDB = tracing.DB(db)
+	}
 
-	mux.HandleFunc("POST /api/refresh", apiCfg.Refresh)
+	var apiCfg apiConfig
 
-	mux.HandleFunc("POST /api/revoke", apiCfg.Revoke)
+	apiCfg.store, err = store.New(driver, tracedDB)
+	if err != nil {
+		log.Fatalf("error opening store %s", err)
+	}
+	apiCfg.Platform = conf.Platform
+	apiCfg.Secret = conf.Secret
+	apiCfg.PolkaKey = conf.PolkaKey
+	apiCfg.AdminKey = conf.AdminKey
+	apiCfg.PublicURL = conf.PublicURL
+	apiCfg.oidc = make(map[string]*oidc.Provider)
+	for name, p := range conf.OIDC {
+		apiCfg.oidc[name] = oidc.New(name, oidc.Config{
+			Issuer:       p.Issuer,
+			ClientID:     p.ClientID,
+			ClientSecret: p.ClientSecret,
+			RedirectURL:  oidcRedirectURL(conf.PublicURL, name),
+		})
+	}
+	apiCfg.StreamWriteTimeout = conf.Server.StreamWriteTimeout
+	apiCfg.shutdown = make(chan struct{})
+	apiCfg.broker = pubsub.NewBroker(1024)
+	apiCfg.metrics = metrics.New(db)
 
-	mux.HandleFunc("PUT /api/users", apiCfg.UpdateUserInfo)
+	apiCfg.jobs = jobs.New(apiCfg.store)
+	apiCfg.webhooks = webhooks.NewDispatcher(apiCfg.store, apiCfg.jobs)
+	// Let developers point webhooks at services on their own machine.
+	apiCfg.webhooks.AllowPrivateNetworks = conf.Platform == "dev"
+	apiCfg.notifications = notify.NewService(apiCfg.store, apiCfg.broker)
 
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.DeleteChirp)
+	err = registerJobs(apiCfg.jobs, apiCfg.store)
+	if err != nil {
+		log.Fatalf("error registering jobs %s", err)
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.UpgradeUser)
+	checker := health.NewChecker()
+	if db != nil {
+		checker.Register("database", health.Ping(db))
+		checker.Register("migrations", migrator.Check)
+	}
 
-	err = servStruct.ListenAndServe()
+	mux := apiCfg.routes(checker)
 
-	fmt.Print("err is: ", err)
+	servStruct := http.Server{
+		Handler:           apiCfg.handler(logger, mux),
+		Addr:              conf.Server.Addr,
+		ReadHeaderTimeout: conf.Server.ReadHeaderTimeout,
+		ReadTimeout:       conf.Server.ReadTimeout,
+		WriteTimeout:      conf.Server.WriteTimeout,
+		IdleTimeout:       conf.Server.IdleTimeout,
+	}
+	servStruct.RegisterOnShutdown(func() { close(apiCfg.shutdown) })
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// A second signal kills the process instead of waiting for drain.
+		<-ctx.Done()
+		stop()
+	}()
+
+	ln, err := net.Listen("tcp", servStruct.Addr)
+	if err != nil {
+		log.Fatalf("error listening on %s: %s", servStruct.Addr, err)
+	}
+	log.Printf("Serving on %s", ln.Addr())
+
+	cleanup := []func(ctx context.Context) error{
+		func(ctx context.Context) error {
+			err := shutdownTracing(ctx)
+			if err != nil {
+				return fmt.Errorf("flushing traces: %w", err)
+			}
+			return nil
+		},
+	}
+	if db != nil {
+		cleanup = append(cleanup, func(context.Context) error {
+			err := db.Close()
+			if err != nil {
+				return fmt.Errorf("closing database: %w", err)
+			}
+			return nil
+		})
+	}
 
+	runServer(ctx, &servStruct, ln, serveOptions{
+		checker:         checker,
+		drainDelay:      conf.Server.DrainDelay,
+		shutdownTimeout: conf.Server.ShutdownTimeout,
+		workers:         apiCfg.jobs.Run,
+		cleanup: cleanup,
+	})
+	log.Print("Shutdown complete")
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/apierror"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/health"
+	"github.com/willmelton21/chirpy/internal/metrics"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// undocumented lists the routes deliberately left out of the spec.
+var undocumented = map[string]string{
+	"/app/":                 "static files for the web app",
+	"GET /api/docs/assets/": "Swagger UI for /api/docs",
+}
+
+type openAPIDoc struct {
+	OpenAPI string                                `json:"openapi"`
+	Paths   map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+func loadOpenAPISpec(t *testing.T) openAPIDoc {
+	t.Helper()
+	var doc openAPIDoc
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+	require.Equal(t, "3.1.0", doc.OpenAPI)
+	return doc
+}
+
+// allRoutes registers every route.
+func allRoutes() *router {
+	cfg := &apiConfig{metrics: metrics.New(nil)}
+	return cfg.routes(health.NewChecker())
+}
+
+// securitySchemes maps each auth.Scheme to its name in the spec.
+var securitySchemes = map[auth.Scheme]string{
+	auth.SchemeAccessToken:  "accessToken",
+	auth.SchemeRefreshToken: "refreshToken",
+	auth.SchemePolkaKey:     "polkaKey",
+	auth.SchemeAdminKey:     "adminKey",
+}
+
+// operation splits a mux pattern such as "GET /api/chirps/{chirpID}" into
+// an OpenAPI method and path.
+func operation(pattern string) (method, path string, ok bool) {
+	method, path, ok = strings.Cut(pattern, " ")
+	return strings.ToLower(method), path, ok
+}
+
+func TestOpenAPICoversRoutes(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+
+	for _, pattern := range allRoutes().patterns {
+		if _, ok := undocumented[pattern]; ok {
+			continue
+		}
+		method, path, ok := operation(pattern)
+		if !assert.True(t, ok, "route %q has no method; document it or add it to undocumented", pattern) {
+			continue
+		}
+		_, documented := doc.Paths[path][method]
+		assert.True(t, documented, "route %q is missing from api/openapi.json", pattern)
+	}
+}
+
+func TestOpenAPIHasNoStaleRoutes(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	registered := make(map[string]bool)
+	for _, pattern := range allRoutes().patterns {
+		registered[pattern] = true
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			pattern := strings.ToUpper(method) + " " + path
+			assert.True(t, registered[pattern], "api/openapi.json documents %q, which isn't registered", pattern)
+		}
+	}
+}
+
+func TestOpenAPISecurityMatchesRoutes(t *testing.T) {
+	doc := loadOpenAPISpec(t)
+	routes := allRoutes()
+
+	for _, pattern := range routes.patterns {
+		method, path, ok := operation(pattern)
+		if !ok {
+			continue
+		}
+		var op struct {
+			Security []map[string][]string `json:"security"`
+		}
+		raw, documented := doc.Paths[path][method]
+		if !documented {
+			continue // TestOpenAPICoversRoutes reports it.
+		}
+		require.NoError(t, json.Unmarshal(raw, &op))
+
+		var want []map[string][]string
+		if scheme, ok := routes.schemes[pattern]; ok {
+			want = []map[string][]string{{securitySchemes[scheme]: {}}}
+		}
+		if scope, ok := routes.scopes[pattern]; ok {
+			want = append(want,
+				map[string][]string{"apiKey": {string(scope)}},
+				map[string][]string{"oauth2": {string(scope)}})
+		}
+		if routes.optional[pattern] {
+			want = append(want, map[string][]string{})
+		}
+		assert.Equal(t, want, op.Security, "security for %q doesn't match its HandleAuth scheme", pattern)
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch n := node.(type) {
+		case map[string]any:
+			if ref, ok := n["$ref"].(string); ok {
+				var target any = doc
+				for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+					m, _ := target.(map[string]any)
+					target = m[part]
+				}
+				assert.NotNil(t, target, "$ref %q doesn't resolve", ref)
+			}
+			for _, child := range n {
+				walk(child)
+			}
+		case []any:
+			for _, child := range n {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func TestOpenAPIListsErrorCodes(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas struct {
+				ErrorCode struct {
+					Enum []apierror.Code `json:"enum"`
+				} `json:"ErrorCode"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	require.NoError(t, json.Unmarshal(openAPISpec, &doc))
+	assert.ElementsMatch(t, apierror.Codes(), doc.Components.Schemas.ErrorCode.Enum)
+}
+
+func TestServeDocs(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+
+	var doc openAPIDoc
+	srv.do(t, call{method: "GET", path: "/api/openapi.json"}, http.StatusOK, &doc)
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	page := srv.do(t, call{method: "GET", path: "/api/docs"}, http.StatusOK, nil)
+	assert.Contains(t, string(page), `url: "/api/openapi.json"`)
+
+	// Swagger UI is served from the binary, not a CDN.
+	assert.NotContains(t, string(page), "https://")
+	for _, asset := range []string{"/api/docs/assets/swagger-ui.css", "/api/docs/assets/swagger-ui-bundle.js"} {
+		assert.Contains(t, string(page), `"`+asset+`"`)
+		body := srv.do(t, call{method: "GET", path: asset}, http.StatusOK, nil)
+		assert.NotEmpty(t, body)
+	}
+	srv.do(t, call{method: "GET", path: "/api/docs/assets/LICENSE"}, http.StatusNotFound, nil)
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/health"
+	"github.com/willmelton21/chirpy/internal/logging"
+	"github.com/willmelton21/chirpy/internal/tracing"
+)
+
+// router is an http.ServeMux that remembers its patterns and the
+// credential each one requires, so tests can check them against
+// api/openapi.json.
+type router struct {
+	*http.ServeMux
+	patterns []string
+	schemes  map[string]auth.Scheme
+	scopes   map[string]auth.Scope
+	optional map[string]bool
+
+	authenticate func(auth.Scheme, auth.Scope, http.Handler) http.Handler
+}
+
+func (rt *router) Handle(pattern string, handler http.Handler) {
+	rt.patterns = append(rt.patterns, pattern)
+	rt.ServeMux.Handle(pattern, handler)
+}
+
+func (rt *router) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	rt.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// HandleAuth registers a route that requires a credential of the given
+// scheme. The handler can read the caller with principal(r).
+func (rt *router) HandleAuth(pattern string, scheme auth.Scheme, handler func(http.ResponseWriter, *http.Request)) {
+	rt.schemes[pattern] = scheme
+	rt.Handle(pattern, rt.authenticate(scheme, "", http.HandlerFunc(handler)))
+}
+
+// HandleScope registers a route that requires an access token or a
+// personal API key granted scope.
+func (rt *router) HandleScope(pattern string, scope auth.Scope, handler func(http.ResponseWriter, *http.Request)) {
+	rt.schemes[pattern] = auth.SchemeAccessToken
+	rt.scopes[pattern] = scope
+	rt.Handle(pattern, rt.authenticate(auth.SchemeAccessToken, scope, http.HandlerFunc(handler)))
+}
+
+// HandleOptional registers a public route that also takes the credentials
+// HandleScope does, so it can tailor the response to the caller. Requests
+// without an Authorization header reach the handler with no principal;
+// requests with a bad credential are still rejected.
+func (rt *router) HandleOptional(pattern string, scope auth.Scope, handler func(http.ResponseWriter, *http.Request)) {
+	rt.schemes[pattern] = auth.SchemeAccessToken
+	rt.scopes[pattern] = scope
+	rt.optional[pattern] = true
+	authed := rt.authenticate(auth.SchemeAccessToken, scope, http.HandlerFunc(handler))
+	rt.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			handler(w, r)
+			return
+		}
+		authed.ServeHTTP(w, r)
+	})
+}
+
+// routes registers every endpoint on a new router.
+func (cfg *apiConfig) routes(checker *health.Checker) *router {
+	mux := &router{
+		ServeMux:     http.NewServeMux(),
+		schemes:      make(map[string]auth.Scheme),
+		scopes:       make(map[string]auth.Scope),
+		optional:     make(map[string]bool),
+		authenticate: cfg.authenticate,
+	}
+
+	handler := http.StripPrefix("/app/", http.FileServer(http.Dir('.')))
+
+	mux.Handle("/app/", cfg.middlewareMetricsInc(handler))
+
+	mux.HandleFunc("GET /livez", checker.Live)
+
+	mux.HandleFunc("GET /readyz", checker.Ready)
+
+	// /api/healthz predates /livez and is kept for existing clients.
+	mux.HandleFunc("GET /api/healthz", func(w http.ResponseWriter, req *http.Request) {
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("OK"))
+	})
+
+	mux.HandleFunc("GET /admin/metrics", func(w http.ResponseWriter, req *http.Request) {
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+		visitCount := cfg.metrics.Hits()
+		fmt.Fprintf(w, "<html><body><h1>Welcome, Chirpy Admin</h1><p>Chirpy has been visited %d times!</p></body></html>", visitCount)
+
+	})
+
+	mux.Handle("GET /metrics", cfg.metrics.Handler())
+
+	mux.HandleFunc("GET /api/openapi.json", serveOpenAPISpec)
+
+	mux.HandleFunc("GET /api/docs", serveDocs)
+
+	mux.Handle("GET /api/docs/assets/", serveDocsAssets())
+
+	mux.HandleFunc("POST /api/users", cfg.CreateUser)
+
+	mux.HandleFunc("POST /admin/reset", cfg.ResetDB)
+
+	mux.HandleScope("POST /api/chirps", auth.ScopeChirpsWrite, cfg.CreateChirp)
+
+	mux.HandleOptional("GET /api/chirps", auth.ScopeChirpsRead, cfg.GetChirps)
+
+	mux.HandleOptional("GET /api/chirps/{chirpID}", auth.ScopeChirpsRead, cfg.GetChirp)
+
+	mux.HandleOptional("GET /api/stream/chirps", auth.ScopeChirpsRead, cfg.StreamChirps)
+
+	mux.HandleScope("GET /api/ws", auth.ScopeChirpsRead, cfg.ServeWebSocket)
+
+	mux.HandleFunc("POST /api/login", cfg.Login)
+
+	mux.HandleFunc("GET /api/auth/oidc", cfg.GetOIDCProviders)
+
+	mux.HandleFunc("GET /api/auth/oidc/{provider}/login", cfg.OIDCLogin)
+
+	mux.HandleFunc("GET /api/auth/oidc/{provider}/callback", cfg.OIDCCallback)
+
+	mux.HandleAuth("POST /api/refresh", auth.SchemeRefreshToken, cfg.Refresh)
+
+	mux.HandleAuth("POST /api/revoke", auth.SchemeRefreshToken, cfg.Revoke)
+
+	mux.HandleScope("PUT /api/users", auth.ScopeProfileWrite, cfg.UpdateUserInfo)
+
+	mux.HandleScope("DELETE /api/chirps/{chirpID}", auth.ScopeChirpsWrite, cfg.DeleteChirp)
+
+	mux.HandleAuth("POST /api/chirps/{chirpID}/bookmark", auth.SchemeAccessToken, cfg.BookmarkChirp)
+
+	mux.HandleAuth("DELETE /api/chirps/{chirpID}/bookmark", auth.SchemeAccessToken, cfg.RemoveBookmark)
+
+	mux.HandleAuth("GET /api/bookmarks", auth.SchemeAccessToken, cfg.GetBookmarks)
+
+	mux.HandleAuth("GET /api/bookmarks/collections", auth.SchemeAccessToken, cfg.GetBookmarkCollections)
+
+	mux.HandleAuth("POST /api/bookmarks/collections", auth.SchemeAccessToken, cfg.CreateBookmarkCollection)
+
+	mux.HandleAuth("DELETE /api/bookmarks/collections/{collectionID}", auth.SchemeAccessToken, cfg.DeleteBookmarkCollection)
+
+	mux.HandleAuth("POST /api/users/me/api_keys", auth.SchemeAccessToken, cfg.CreateAPIKey)
+
+	mux.HandleAuth("GET /api/users/me/api_keys", auth.SchemeAccessToken, cfg.GetAPIKeys)
+
+	mux.HandleAuth("DELETE /api/users/me/api_keys/{keyID}", auth.SchemeAccessToken, cfg.DeleteAPIKey)
+
+	mux.HandleAuth("GET /api/users/me/identities", auth.SchemeAccessToken, cfg.GetIdentities)
+
+	mux.HandleAuth("POST /api/users/me/identities/{provider}", auth.SchemeAccessToken, cfg.LinkIdentity)
+
+	mux.HandleAuth("DELETE /api/users/me/identities/{provider}", auth.SchemeAccessToken, cfg.UnlinkIdentity)
+
+	mux.HandleAuth("GET /api/users/me/blocks", auth.SchemeAccessToken, cfg.GetBlocks)
+
+	mux.HandleAuth("PUT /api/users/me/blocks/{userID}", auth.SchemeAccessToken, cfg.BlockUser)
+
+	mux.HandleAuth("DELETE /api/users/me/blocks/{userID}", auth.SchemeAccessToken, cfg.UnblockUser)
+
+	mux.HandleAuth("GET /api/users/me/mutes", auth.SchemeAccessToken, cfg.GetMutes)
+
+	mux.HandleAuth("PUT /api/users/me/mutes/{userID}", auth.SchemeAccessToken, cfg.MuteUser)
+
+	mux.HandleAuth("DELETE /api/users/me/mutes/{userID}", auth.SchemeAccessToken, cfg.UnmuteUser)
+
+	mux.HandleScope("PUT /api/users/me/privacy", auth.ScopeProfileWrite, cfg.UpdatePrivacy)
+
+	mux.HandleAuth("GET /api/users/me/following", auth.SchemeAccessToken, cfg.GetFollowing)
+
+	mux.HandleAuth("PUT /api/users/me/following/{userID}", auth.SchemeAccessToken, cfg.FollowUser)
+
+	mux.HandleAuth("DELETE /api/users/me/following/{userID}", auth.SchemeAccessToken, cfg.UnfollowUser)
+
+	mux.HandleAuth("GET /api/users/me/followers", auth.SchemeAccessToken, cfg.GetFollowers)
+
+	mux.HandleAuth("GET /api/users/me/follow_requests", auth.SchemeAccessToken, cfg.GetFollowRequests)
+
+	mux.HandleAuth("POST /api/users/me/follow_requests/{userID}/approve", auth.SchemeAccessToken, cfg.ApproveFollowRequest)
+
+	mux.HandleAuth("POST /api/users/me/follow_requests/{userID}/deny", auth.SchemeAccessToken, cfg.DenyFollowRequest)
+
+	mux.HandleAuth("POST /api/oauth/clients", auth.SchemeAccessToken, cfg.CreateOAuthClient)
+
+	mux.HandleAuth("GET /api/oauth/clients", auth.SchemeAccessToken, cfg.GetOAuthClients)
+
+	mux.HandleFunc("GET /api/oauth/clients/{clientID}", cfg.GetOAuthClient)
+
+	mux.HandleAuth("DELETE /api/oauth/clients/{clientID}", auth.SchemeAccessToken, cfg.DeleteOAuthClient)
+
+	mux.HandleFunc("GET /api/oauth/authorize", cfg.Authorize)
+
+	mux.HandleAuth("POST /api/oauth/authorize", auth.SchemeAccessToken, cfg.ApproveAuthorization)
+
+	// The token endpoints authenticate the OAuth client themselves.
+	mux.HandleFunc("POST /api/oauth/token", cfg.Token)
+
+	mux.HandleFunc("POST /api/oauth/introspect", cfg.Introspect)
+
+	mux.HandleFunc("POST /api/oauth/revoke", cfg.RevokeOAuthToken)
+
+	mux.HandleAuth("POST /api/polka/webhooks", auth.SchemePolkaKey, cfg.UpgradeUser)
+
+	mux.HandleAuth("GET /api/notifications", auth.SchemeAccessToken, cfg.GetNotifications)
+
+	mux.HandleAuth("POST /api/notifications/read", auth.SchemeAccessToken, cfg.MarkNotificationsRead)
+
+	mux.HandleAuth("GET /api/notifications/preferences", auth.SchemeAccessToken, cfg.GetNotificationPreferences)
+
+	mux.HandleAuth("PUT /api/notifications/preferences", auth.SchemeAccessToken, cfg.UpdateNotificationPreferences)
+
+	mux.HandleAuth("POST /api/webhooks", auth.SchemeAccessToken, cfg.CreateWebhook)
+
+	mux.HandleAuth("GET /api/webhooks", auth.SchemeAccessToken, cfg.GetWebhooks)
+
+	mux.HandleAuth("DELETE /api/webhooks/{webhookID}", auth.SchemeAccessToken, cfg.DeleteWebhook)
+
+	mux.HandleAuth("GET /admin/jobs", auth.SchemeAdminKey, cfg.GetJobs)
+
+	mux.HandleAuth("GET /admin/jobs/{jobID}", auth.SchemeAdminKey, cfg.GetJob)
+
+	mux.HandleAuth("POST /admin/jobs/{jobID}/retry", auth.SchemeAdminKey, cfg.RetryJob)
+
+	mux.HandleAuth("GET /admin/webhooks/deliveries/{deliveryID}", auth.SchemeAdminKey, cfg.GetWebhookDelivery)
+
+	mux.HandleAuth("POST /admin/webhooks/deliveries/{deliveryID}/replay", auth.SchemeAdminKey, cfg.ReplayWebhookDelivery)
+
+	return mux
+}
+
+// handler wraps mux in the middleware every request goes through.
+// tracing.Routes must wrap the mux directly so it sees the matched pattern.
+func (cfg *apiConfig) handler(logger *slog.Logger, mux http.Handler) http.Handler {
+	return tracing.Handler(logging.Middleware(logger, cfg.metrics.Instrument(tracing.Routes(mux))))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/willmelton21/chirpy/internal/health"
+)
+
+// serveOptions is what runServer runs alongside the server and how it
+// stops.
+type serveOptions struct {
+	checker *health.Checker
+	// drainDelay is how long /readyz reports draining before listeners
+	// close, so load balancers stop sending traffic first.
+	drainDelay time.Duration
+	// shutdownTimeout bounds draining requests, stopping the workers and
+	// cleanup together.
+	shutdownTimeout time.Duration
+	// workers runs background work until its context is cancelled.
+	workers func(ctx context.Context)
+	// cleanup runs in order once requests and workers have stopped, with
+	// whatever is left of the shutdown deadline.
+	cleanup []func(ctx context.Context) error
+}
+
+// runServer runs srv on ln, and the workers, until ctx is done or the
+// server fails. It then drains in-flight requests, stops the workers and runs
+// cleanup, and returns the error that stopped the server, if any.
+func runServer(ctx context.Context, srv *http.Server, ln net.Listener, opts serveOptions) error {
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	var workers sync.WaitGroup
+	if opts.workers != nil {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			opts.workers(workersCtx)
+		}()
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- srv.Serve(ln)
+	}()
+
+	var err error
+	select {
+	case err = <-serveErr:
+		log.Printf("Server stopped: %s", err)
+	case <-ctx.Done():
+		log.Print("Shutting down, draining in-flight requests")
+	}
+
+	if opts.checker != nil {
+		opts.checker.SetDraining()
+	}
+	if err == nil && opts.drainDelay > 0 {
+		log.Printf("Reporting not-ready for %s before closing listeners", opts.drainDelay)
+		time.Sleep(opts.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
+	defer cancel()
+
+	shutdownErr := srv.Shutdown(shutdownCtx)
+	if shutdownErr != nil && !errors.Is(shutdownErr, http.ErrServerClosed) {
+		log.Printf("Error draining requests: %s", shutdownErr)
+	}
+
+	stopWorkers()
+	workersDone := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(workersDone)
+	}()
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		log.Print("Background workers didn't stop before the shutdown deadline")
+	}
+
+	for _, fn := range opts.cleanup {
+		cleanupErr := fn(shutdownCtx)
+		if cleanupErr != nil {
+			log.Printf("Error during shutdown: %s", cleanupErr)
+		}
+	}
+	return err
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/health"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/wsapi"
+)
+
+// startServer runs runServer in the background with a handler that holds
+// requests to /slow until release is closed. The returned channel yields
+// runServer's result.
+func startServer(t *testing.T, opts serveOptions, release chan struct{}) (string, context.CancelFunc, chan error) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /slow", func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		io.WriteString(w, "done")
+	})
+	mux.HandleFunc("GET /readyz", opts.checker.Ready)
+	srv := &http.Server{Handler: mux}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- runServer(ctx, srv, ln, opts) }()
+	t.Cleanup(cancel)
+	return "http://" + ln.Addr().String(), cancel, done
+}
+
+// events records the order shutdown steps happen in.
+type events struct {
+	mu   sync.Mutex
+	list []string
+}
+
+func (e *events) add(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.list = append(e.list, name)
+}
+
+func (e *events) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.list...)
+}
+
+func TestRunServerDrainsRequests(t *testing.T) {
+	var got events
+	release := make(chan struct{})
+	workerStarted := make(chan struct{})
+	opts := serveOptions{
+		checker:         health.NewChecker(),
+		drainDelay:      100 * time.Millisecond,
+		shutdownTimeout: 5 * time.Second,
+		workers: func(ctx context.Context) {
+			close(workerStarted)
+			<-ctx.Done()
+			got.add("workers stopped")
+		},
+		cleanup: []func(ctx context.Context) error{
+			func(ctx context.Context) error {
+				assert.NoError(t, ctx.Err())
+				got.add("flush")
+				return nil
+			},
+			func(context.Context) error {
+				got.add("close")
+				return nil
+			},
+		},
+	}
+	url, cancel, done := startServer(t, opts, release)
+	<-workerStarted
+
+	resp, err := http.Get(url + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// A request is in flight when the signal arrives.
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(url + "/slow")
+		if !assert.NoError(t, err) {
+			slow <- ""
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		got.add("request finished")
+		slow <- string(body)
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	// During the drain delay the server still answers, but isn't ready.
+	time.Sleep(20 * time.Millisecond)
+	resp, err = http.Get(url + "/readyz")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	// Workers keep going until in-flight requests are done.
+	time.Sleep(200 * time.Millisecond)
+	assert.Empty(t, got.get())
+	close(release)
+	assert.Equal(t, "done", <-slow)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer didn't return")
+	}
+	assert.Equal(t, []string{"request finished", "workers stopped", "flush", "close"}, got.get())
+
+	_, err = http.Get(url + "/readyz")
+	assert.Error(t, err, "the listener should be closed")
+}
+
+func TestRunServerShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	cleanedUp := make(chan error, 1)
+	opts := serveOptions{
+		checker:         health.NewChecker(),
+		shutdownTimeout: 200 * time.Millisecond,
+		// A worker that ignores cancellation doesn't hold shutdown up.
+		workers: func(ctx context.Context) { <-release },
+		cleanup: []func(ctx context.Context) error{
+			func(ctx context.Context) error {
+				cleanedUp <- ctx.Err()
+				return nil
+			},
+		},
+	}
+	url, cancel, done := startServer(t, opts, release)
+
+	// Neither does a request that never finishes.
+	go http.Get(url + "/slow")
+	time.Sleep(50 * time.Millisecond)
+	start := time.Now()
+	cancel()
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("runServer didn't return")
+	}
+	assert.Less(t, time.Since(start), time.Second)
+	assert.ErrorIs(t, <-cleanedUp, context.DeadlineExceeded)
+}
+
+func TestRunServerReturnsServeError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	ln.Close()
+
+	cleanedUp := false
+	err = runServer(context.Background(), &http.Server{}, ln, serveOptions{
+		shutdownTimeout: time.Second,
+		// The drain delay is skipped when the server never served.
+		drainDelay: time.Hour,
+		cleanup: []func(ctx context.Context) error{
+			func(context.Context) error {
+				cleanedUp = true
+				return nil
+			},
+		},
+	})
+	assert.Error(t, err)
+	assert.True(t, cleanedUp)
+}
+
+func TestStreamsOutliveWriteTimeout(t *testing.T) {
+	api := newTestServer(t, store.NewMemory())
+	alice := api.newUser(t, "alice@example.com")
+
+	// The same API behind a server whose WriteTimeout would cut any
+	// ordinary response off after 100ms.
+	short := httptest.NewUnstartedServer(api.Config.Handler)
+	short.Config.WriteTimeout = 100 * time.Millisecond
+	short.Start()
+	defer short.Close()
+	srv := &testServer{Server: short, cfg: api.cfg, drain: api.drain}
+
+	stream := srv.openStream(t, alice.Token, "")
+	conn, _ := srv.dialWS(t, alice.Token)
+	subscribeWS(t, conn, wsapi.ChannelChirps)
+	time.Sleep(300 * time.Millisecond)
+
+	chirp := api.postChirp(t, alice.Token, "still here")
+	assert.Equal(t, chirp, stream.nextChirp(t))
+	assert.Equal(t, chirp, nextChirpWS(t, conn))
+
+	// Draining ends both.
+	srv.drain()
+	select {
+	case _, ok := <-stream.events:
+		assert.False(t, ok, "stream should have ended")
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream still open after drain")
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseGoingAway), "got %v", err)
+}
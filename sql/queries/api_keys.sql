@@ -0,0 +1,32 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, user_id, name, prefix, hashed_key, scopes, last_used_at)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   $4,
+   $5,
+   NULL
+   )
+   RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys
+WHERE hashed_key = $1;
+
+-- name: ListAPIKeysByUser :many
+SELECT * FROM api_keys
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = $1
+   AND user_id = $2;
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = NOW()
+WHERE id = $1;
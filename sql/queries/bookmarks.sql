@@ -0,0 +1,56 @@
+-- name: CreateBookmark :one
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (user_id, chirp_id) DO UPDATE SET collection_id = EXCLUDED.collection_id
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1
+   AND chirp_id = $2;
+
+-- name: ListBookmarks :many
+SELECT sqlc.embed(bookmarks), sqlc.embed(chirp) FROM bookmarks
+JOIN chirp ON chirp.id = bookmarks.chirp_id
+JOIN users ON users.id = chirp.user_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+   AND (NOT sqlc.arg(in_collection)::boolean OR bookmarks.collection_id = sqlc.arg(collection_id)::uuid)
+   AND (NOT sqlc.arg(paged)::boolean
+      OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.arg(after_created_at)::timestamp, sqlc.arg(after_chirp_id)::uuid))
+   AND (
+      NOT users.is_private
+      OR users.id = sqlc.arg(user_id)
+      OR EXISTS (
+         SELECT 1 FROM follows
+         WHERE follower_id = sqlc.arg(user_id)
+            AND followee_id = users.id
+            AND status = 'accepted'
+      )
+   )
+   AND NOT EXISTS (
+      SELECT 1 FROM blocks
+      WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = users.id)
+         OR (blocker_id = users.id AND blocked_id = sqlc.arg(user_id))
+   )
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, user_id, name)
+VALUES (gen_random_uuid(), NOW(), $1, $2)
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+SELECT * FROM bookmark_collections
+WHERE id = $1
+   AND user_id = $2;
+
+-- name: ListBookmarkCollections :many
+SELECT * FROM bookmark_collections
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections
+WHERE id = $1
+   AND user_id = $2;
@@ -22,6 +22,6 @@ ORDER BY created_at ASC;
 SELECT * FROM chirp 
 WHERE id = $1;
 
--- name: DeleteChirpByID :exec
+-- name: DeleteChirpByID :execrows
 DELETE FROM chirp 
 WHERE id = $1;
@@ -0,0 +1,82 @@
+-- name: CreateFollow :one
+INSERT INTO follows (follower_id, followee_id, status, created_at)
+SELECT sqlc.arg(follower_id)::uuid, id, CASE WHEN is_private THEN 'pending' ELSE 'accepted' END, NOW()
+FROM users
+WHERE id = sqlc.arg(followee_id)
+ON CONFLICT (follower_id, followee_id) DO UPDATE SET status = follows.status
+RETURNING *;
+
+-- name: GetFollow :one
+SELECT * FROM follows
+WHERE follower_id = $1
+   AND followee_id = $2;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+   AND followee_id = $2;
+
+-- name: DeletePendingFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1
+   AND followee_id = $2
+   AND status = 'pending';
+
+-- name: AcceptFollow :one
+UPDATE follows
+SET status = 'accepted'
+WHERE follower_id = $1
+   AND followee_id = $2
+   AND status = 'pending'
+RETURNING *;
+
+-- name: AcceptPendingFollows :exec
+UPDATE follows
+SET status = 'accepted'
+WHERE followee_id = $1
+   AND status = 'pending';
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg(user_id) AND followee_id = sqlc.arg(other_id))
+   OR (follower_id = sqlc.arg(other_id) AND followee_id = sqlc.arg(user_id));
+
+-- name: ListFollowing :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListFollowers :many
+SELECT * FROM follows
+WHERE followee_id = $1
+   AND status = $2
+ORDER BY created_at ASC;
+
+-- name: ListHiddenPrivateUserIDs :many
+SELECT id FROM users
+WHERE is_private
+   AND id <> sqlc.arg(viewer_id)
+   AND NOT EXISTS (
+      SELECT 1 FROM follows
+      WHERE follower_id = sqlc.arg(viewer_id)
+         AND followee_id = users.id
+         AND status = 'accepted'
+   );
+
+-- name: CanSeeChirps :one
+SELECT (
+   NOT users.is_private
+   OR users.id = sqlc.arg(viewer_id)
+   OR EXISTS (
+      SELECT 1 FROM follows
+      WHERE follower_id = sqlc.arg(viewer_id)
+         AND followee_id = users.id
+         AND status = 'accepted'
+   )
+) AND NOT EXISTS (
+   SELECT 1 FROM blocks
+   WHERE (blocker_id = sqlc.arg(viewer_id) AND blocked_id = users.id)
+      OR (blocker_id = users.id AND blocked_id = sqlc.arg(viewer_id))
+) AS visible
+FROM users
+WHERE users.id = sqlc.arg(author_id);
@@ -0,0 +1,26 @@
+-- name: CreateIdentity :one
+INSERT INTO identities (id, created_at, user_id, provider, subject, email)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   $4
+   )
+   RETURNING *;
+
+-- name: GetIdentity :one
+SELECT * FROM identities
+WHERE provider = $1
+   AND subject = $2;
+
+-- name: ListIdentitiesByUser :many
+SELECT * FROM identities
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteIdentity :execrows
+DELETE FROM identities
+WHERE user_id = $1
+   AND provider = $2;
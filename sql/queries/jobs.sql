@@ -0,0 +1,76 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, unique_key)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   NOW(),
+   $1,
+   $2,
+   'pending',
+   0,
+   $3,
+   $4,
+   $5
+   )
+ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: ClaimJobs :many
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_at = NOW(), updated_at = NOW()
+WHERE id IN (
+   SELECT id FROM jobs
+   WHERE status = 'pending'
+      AND run_at <= NOW()
+      AND kind = ANY(sqlc.arg(kinds)::text[])
+   ORDER BY run_at ASC
+   LIMIT sqlc.arg(max_jobs)
+   FOR UPDATE SKIP LOCKED
+   )
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_at = NULL, last_error = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, run_at = $2, last_error = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: KillJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, last_error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RequeueStaleJobs :execrows
+-- The claim already counted the attempt that went stale, so a job that
+-- has used all its attempts is dead rather than pending.
+UPDATE jobs
+SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+   locked_at = NULL, last_error = sqlc.arg(last_error), updated_at = NOW()
+WHERE status = 'running' AND locked_at < sqlc.arg(locked_before);
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = $1;
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE sqlc.arg(status)::text = '' OR status = sqlc.arg(status)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: RetryDeadJob :one
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = NOW(), last_error = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'dead'
+RETURNING *;
+
+-- name: DeleteFinishedJobsBefore :execrows
+DELETE FROM jobs
+WHERE status IN ('succeeded', 'dead') AND updated_at < sqlc.arg(before);
+
+-- name: DeleteAllJobs :exec
+DELETE FROM jobs;
@@ -0,0 +1,53 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, type, actor_id, chirp_id)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   $4
+   )
+   RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+   AND (NOT sqlc.arg(unread_only)::boolean OR read_at IS NULL)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id)
+   AND id = ANY(sqlc.arg(ids)::uuid[])
+   AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1 AND read_at IS NULL;
+
+-- name: GetNotificationPreference :one
+SELECT enabled FROM notification_preferences
+WHERE user_id = $1 AND type = $2;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = $1;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (
+   $1,
+   $2,
+   $3,
+   NOW()
+   )
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = EXCLUDED.enabled, updated_at = NOW();
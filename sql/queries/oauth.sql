@@ -0,0 +1,43 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, user_id, name, redirect_uris, hashed_secret)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   $4
+   )
+   RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: ListOAuthClientsByUser :many
+SELECT * FROM oauth_clients
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1
+   AND user_id = $2;
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_codes (code, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+   $1,
+   NOW(),
+   $2,
+   $3,
+   $4,
+   $5,
+   $6,
+   $7
+   );
+
+-- name: ConsumeAuthorizationCode :one
+DELETE FROM oauth_codes
+WHERE code = $1
+RETURNING *;
@@ -0,0 +1,32 @@
+-- name: CreateAPIKey :one
+INSERT INTO api_keys (id, created_at, user_id, name, prefix, hashed_key, scopes, last_used_at)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(name),
+   sqlc.arg(prefix),
+   sqlc.arg(hashed_key),
+   sqlc.arg(scopes),
+   NULL
+   )
+   RETURNING *;
+
+-- name: GetAPIKeyByHash :one
+SELECT * FROM api_keys
+WHERE hashed_key = ?;
+
+-- name: ListAPIKeysByUser :many
+SELECT * FROM api_keys
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeleteAPIKey :execrows
+DELETE FROM api_keys
+WHERE id = sqlc.arg(id)
+   AND user_id = sqlc.arg(user_id);
+
+-- name: TouchAPIKey :exec
+UPDATE api_keys
+SET last_used_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,56 @@
+-- name: CreateBookmark :one
+INSERT INTO bookmarks (user_id, chirp_id, collection_id, created_at)
+VALUES (sqlc.arg(user_id), sqlc.arg(chirp_id), sqlc.arg(collection_id), sqlc.arg(now))
+ON CONFLICT (user_id, chirp_id) DO UPDATE SET collection_id = excluded.collection_id
+RETURNING *;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = ?
+   AND chirp_id = ?;
+
+-- name: ListBookmarks :many
+SELECT sqlc.embed(bookmarks), sqlc.embed(chirp) FROM bookmarks
+JOIN chirp ON chirp.id = bookmarks.chirp_id
+JOIN users ON users.id = chirp.user_id
+WHERE bookmarks.user_id = sqlc.arg(user_id)
+   AND (NOT sqlc.arg(in_collection) OR bookmarks.collection_id = sqlc.arg(collection_id))
+   AND (NOT sqlc.arg(paged)
+      OR (bookmarks.created_at, bookmarks.chirp_id) < (sqlc.arg(after_created_at), sqlc.arg(after_chirp_id)))
+   AND (
+      NOT users.is_private
+      OR users.id = sqlc.arg(user_id)
+      OR EXISTS (
+         SELECT 1 FROM follows
+         WHERE follower_id = sqlc.arg(user_id)
+            AND followee_id = users.id
+            AND status = 'accepted'
+      )
+   )
+   AND NOT EXISTS (
+      SELECT 1 FROM blocks
+      WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = users.id)
+         OR (blocker_id = users.id AND blocked_id = sqlc.arg(user_id))
+   )
+ORDER BY bookmarks.created_at DESC, bookmarks.chirp_id DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CreateBookmarkCollection :one
+INSERT INTO bookmark_collections (id, created_at, user_id, name)
+VALUES (sqlc.arg(id), sqlc.arg(now), sqlc.arg(user_id), sqlc.arg(name))
+RETURNING *;
+
+-- name: GetBookmarkCollection :one
+SELECT * FROM bookmark_collections
+WHERE id = ?
+   AND user_id = ?;
+
+-- name: ListBookmarkCollections :many
+SELECT * FROM bookmark_collections
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeleteBookmarkCollection :execrows
+DELETE FROM bookmark_collections
+WHERE id = ?
+   AND user_id = ?;
@@ -0,0 +1,27 @@
+-- name: CreateChirp :one
+INSERT INTO chirp (id, created_at, updated_at, body, user_id)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(body),
+   sqlc.arg(user_id)
+   )
+   RETURNING *;
+
+-- name: GetChirps :many
+SELECT * FROM chirp
+ORDER BY created_at ASC;
+
+-- name: GetChirpsByID :many
+SELECT * FROM chirp
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: GetChirpByID :one
+SELECT * FROM chirp
+WHERE id = ?;
+
+-- name: DeleteChirpByID :execrows
+DELETE FROM chirp
+WHERE id = ?;
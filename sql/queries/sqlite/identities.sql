@@ -0,0 +1,26 @@
+-- name: CreateIdentity :one
+INSERT INTO identities (id, created_at, user_id, provider, subject, email)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(provider),
+   sqlc.arg(subject),
+   sqlc.arg(email)
+   )
+   RETURNING *;
+
+-- name: GetIdentity :one
+SELECT * FROM identities
+WHERE provider = sqlc.arg(provider)
+   AND subject = sqlc.arg(subject);
+
+-- name: ListIdentitiesByUser :many
+SELECT * FROM identities
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeleteIdentity :execrows
+DELETE FROM identities
+WHERE user_id = sqlc.arg(user_id)
+   AND provider = sqlc.arg(provider);
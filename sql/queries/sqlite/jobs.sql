@@ -0,0 +1,79 @@
+-- name: EnqueueJob :one
+INSERT INTO jobs (id, created_at, updated_at, kind, payload, status, attempts, max_attempts, run_at, unique_key)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(kind),
+   sqlc.arg(payload),
+   'pending',
+   0,
+   sqlc.arg(max_attempts),
+   sqlc.arg(run_at),
+   sqlc.arg(unique_key)
+   )
+ON CONFLICT (unique_key) WHERE unique_key IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: ClaimJobs :many
+-- SQLite lets one connection write at a time, so this statement, which is
+-- its own transaction, claims jobs without the SKIP LOCKED Postgres needs.
+-- Its parameters are unnumbered because sqlc.slice can't follow numbered
+-- ones.
+UPDATE jobs
+SET status = 'running', attempts = attempts + 1, locked_at = ?, updated_at = ?
+WHERE id IN (
+   SELECT id FROM jobs
+   WHERE status = 'pending'
+      AND run_at <= ?
+      AND kind IN (sqlc.slice(kinds))
+   ORDER BY run_at ASC
+   LIMIT ?
+   )
+RETURNING *;
+
+-- name: CompleteJob :exec
+UPDATE jobs
+SET status = 'succeeded', locked_at = NULL, last_error = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RetryJob :exec
+UPDATE jobs
+SET status = 'pending', locked_at = NULL, run_at = sqlc.arg(run_at), last_error = sqlc.arg(last_error), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: KillJob :exec
+UPDATE jobs
+SET status = 'dead', locked_at = NULL, last_error = sqlc.arg(last_error), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: RequeueStaleJobs :execrows
+-- The claim already counted the attempt that went stale, so a job that
+-- has used all its attempts is dead rather than pending.
+UPDATE jobs
+SET status = CASE WHEN attempts >= max_attempts THEN 'dead' ELSE 'pending' END,
+   locked_at = NULL, last_error = sqlc.arg(last_error), updated_at = sqlc.arg(now)
+WHERE status = 'running' AND locked_at < sqlc.arg(locked_before);
+
+-- name: GetJob :one
+SELECT * FROM jobs
+WHERE id = ?;
+
+-- name: ListJobs :many
+SELECT * FROM jobs
+WHERE sqlc.arg(status) = '' OR status = sqlc.arg(status)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: RetryDeadJob :one
+UPDATE jobs
+SET status = 'pending', attempts = 0, run_at = sqlc.arg(now), last_error = NULL, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND status = 'dead'
+RETURNING *;
+
+-- name: DeleteFinishedJobsBefore :execrows
+DELETE FROM jobs
+WHERE status IN ('succeeded', 'dead') AND updated_at < sqlc.arg(before);
+
+-- name: DeleteAllJobs :exec
+DELETE FROM jobs;
@@ -0,0 +1,55 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, type, actor_id, chirp_id)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(type),
+   sqlc.arg(actor_id),
+   sqlc.arg(chirp_id)
+   )
+   RETURNING *;
+
+-- name: ListNotifications :many
+SELECT * FROM notifications
+WHERE user_id = sqlc.arg(user_id)
+   AND (NOT sqlc.arg(unread_only) OR read_at IS NULL)
+ORDER BY created_at DESC
+LIMIT sqlc.arg(max_results);
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = ? AND read_at IS NULL;
+
+-- name: MarkNotificationsRead :execrows
+-- Its parameters are unnumbered because sqlc.slice can't follow numbered
+-- ones.
+UPDATE notifications
+SET read_at = ?
+WHERE user_id = ?
+   AND id IN (sqlc.slice(ids))
+   AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :execrows
+UPDATE notifications
+SET read_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id) AND read_at IS NULL;
+
+-- name: GetNotificationPreference :one
+SELECT enabled FROM notification_preferences
+WHERE user_id = ? AND type = ?;
+
+-- name: ListNotificationPreferences :many
+SELECT * FROM notification_preferences
+WHERE user_id = ?;
+
+-- name: UpsertNotificationPreference :exec
+INSERT INTO notification_preferences (user_id, type, enabled, updated_at)
+VALUES (
+   sqlc.arg(user_id),
+   sqlc.arg(type),
+   sqlc.arg(enabled),
+   sqlc.arg(now)
+   )
+ON CONFLICT (user_id, type) DO UPDATE
+SET enabled = excluded.enabled, updated_at = excluded.updated_at;
@@ -0,0 +1,43 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, created_at, user_id, name, redirect_uris, hashed_secret)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(name),
+   sqlc.arg(redirect_uris),
+   sqlc.arg(hashed_secret)
+   )
+   RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = ?;
+
+-- name: ListOAuthClientsByUser :many
+SELECT * FROM oauth_clients
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = sqlc.arg(id)
+   AND user_id = sqlc.arg(user_id);
+
+-- name: CreateAuthorizationCode :exec
+INSERT INTO oauth_codes (code, created_at, client_id, user_id, redirect_uri, scopes, code_challenge, expires_at)
+VALUES (
+   sqlc.arg(code),
+   sqlc.arg(now),
+   sqlc.arg(client_id),
+   sqlc.arg(user_id),
+   sqlc.arg(redirect_uri),
+   sqlc.arg(scopes),
+   sqlc.arg(code_challenge),
+   sqlc.arg(expires_at)
+   );
+
+-- name: ConsumeAuthorizationCode :one
+DELETE FROM oauth_codes
+WHERE code = ?
+RETURNING *;
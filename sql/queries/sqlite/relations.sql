@@ -0,0 +1,41 @@
+-- name: CreateBlock :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES (sqlc.arg(blocker_id), sqlc.arg(blocked_id), sqlc.arg(now))
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBlock :execrows
+DELETE FROM blocks
+WHERE blocker_id = sqlc.arg(blocker_id)
+   AND blocked_id = sqlc.arg(blocked_id);
+
+-- name: ListBlocksByUser :many
+SELECT * FROM blocks
+WHERE blocker_id = ?
+ORDER BY created_at ASC;
+
+-- name: ListBlockedUserIDs :many
+SELECT blocked_id AS user_id FROM blocks WHERE blocker_id = sqlc.arg(user_id)
+UNION
+SELECT blocker_id AS user_id FROM blocks WHERE blocked_id = sqlc.arg(user_id);
+
+-- name: BlockExists :one
+SELECT EXISTS (
+   SELECT 1 FROM blocks
+   WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = sqlc.arg(other_id))
+      OR (blocker_id = sqlc.arg(other_id) AND blocked_id = sqlc.arg(user_id))
+);
+
+-- name: CreateMute :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES (sqlc.arg(muter_id), sqlc.arg(muted_id), sqlc.arg(now))
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteMute :execrows
+DELETE FROM mutes
+WHERE muter_id = sqlc.arg(muter_id)
+   AND muted_id = sqlc.arg(muted_id);
+
+-- name: ListMutesByUser :many
+SELECT * FROM mutes
+WHERE muter_id = ?
+ORDER BY created_at ASC;
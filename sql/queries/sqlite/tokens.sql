@@ -0,0 +1,59 @@
+-- name: CreateTokenDB :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at)
+VALUES (
+   sqlc.arg(token),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(expires_at),
+   NULL
+   );
+
+-- name: GetUserFromRefreshToken :one
+SELECT users.* FROM users
+JOIN refresh_tokens ON users.id = refresh_tokens.user_id
+WHERE refresh_tokens.token = sqlc.arg(token)
+   AND refresh_tokens.client_id IS NULL
+   AND refresh_tokens.expires_at > sqlc.arg(now)
+   AND refresh_tokens.revoked_at IS NULL;
+
+-- name: RevokeToken :exec
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE token = sqlc.arg(token);
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < sqlc.arg(now)
+   OR revoked_at < sqlc.arg(revoked_before);
+
+-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE user_id = sqlc.arg(user_id)
+   AND revoked_at IS NULL;
+
+-- name: CreateClientRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes)
+VALUES (
+   sqlc.arg(token),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(expires_at),
+   NULL,
+   sqlc.arg(client_id),
+   sqlc.arg(scopes)
+   );
+
+-- name: GetClientRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = sqlc.arg(token)
+   AND client_id IS NOT NULL
+   AND expires_at > sqlc.arg(now)
+   AND revoked_at IS NULL;
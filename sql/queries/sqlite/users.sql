@@ -0,0 +1,45 @@
+-- SQLite has no gen_random_uuid() or NOW(), so IDs and timestamps come
+-- from the caller. Timestamps must be UTC so they compare correctly as text.
+
+-- name: CreateUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(email),
+   sqlc.arg(hashed_password)
+   )
+   RETURNING *;
+
+-- name: ResetTable :exec
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = ?;
+
+-- name: UpdateEmailAndPass :one
+UPDATE users
+SET email = sqlc.arg(email), hashed_password = sqlc.arg(hashed_password), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?;
+
+-- name: Upgradeuser :execrows
+UPDATE users
+SET is_chirpy_red = true
+WHERE id = ?;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = ?;
+
+-- name: SetUserPrivate :one
+UPDATE users
+SET is_private = sqlc.arg(is_private), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING *;
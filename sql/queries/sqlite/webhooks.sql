@@ -0,0 +1,99 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(user_id),
+   sqlc.arg(url),
+   sqlc.arg(secret),
+   sqlc.arg(events)
+   )
+   RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = ?;
+
+-- name: ListWebhookEndpointsByUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = ?
+ORDER BY created_at ASC;
+
+-- name: ListWebhookEndpointsForEvent :many
+-- events is space-separated, so padding both sides with spaces matches
+-- whole event names only.
+SELECT * FROM webhook_endpoints
+WHERE disabled_at IS NULL
+   AND instr(' ' || events || ' ', ' ' || sqlc.arg(event) || ' ') > 0;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = ? AND user_id = ?;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id)
+RETURNING consecutive_failures;
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: DisableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = sqlc.arg(now), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: EnableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = NULL, consecutive_failures = 0, updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event, payload, status, attempts)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(now),
+   sqlc.arg(endpoint_id),
+   sqlc.arg(event),
+   sqlc.arg(payload),
+   'pending',
+   0
+   )
+   RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = ?;
+
+-- name: UpdateWebhookDeliveryState :exec
+UPDATE webhook_deliveries
+SET status = sqlc.arg(status), attempts = sqlc.arg(attempts), last_error = sqlc.arg(last_error), updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id);
+
+-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', updated_at = sqlc.arg(now)
+WHERE id = sqlc.arg(id) AND status = 'failed'
+RETURNING *;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, created_at, delivery_id, attempt, status_code, error, duration_ms)
+VALUES (
+   sqlc.arg(id),
+   sqlc.arg(now),
+   sqlc.arg(delivery_id),
+   sqlc.arg(attempt),
+   sqlc.arg(status_code),
+   sqlc.arg(error),
+   sqlc.arg(duration_ms)
+   );
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = ?
+ORDER BY attempt ASC;
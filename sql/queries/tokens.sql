@@ -15,6 +15,7 @@ VALUES (
 SELECT users.* FROM users
 JOIN refresh_tokens ON users.id = refresh_tokens.user_id
 WHERE refresh_tokens.token = $1
+   AND refresh_tokens.client_id IS NULL
    AND refresh_tokens.expires_at > NOW()
    AND refresh_tokens.revoked_at IS NULL;
 
@@ -23,3 +24,39 @@ UPDATE refresh_tokens
 SET revoked_at = NOW(), updated_at = NOW()
 WHERE token = $1;
 
+
+-- name: DeleteExpiredRefreshTokens :execrows
+DELETE FROM refresh_tokens
+WHERE expires_at < NOW()
+   OR revoked_at < NOW() - INTERVAL '30 days';
+
+-- name: RevokeAllRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :execrows
+UPDATE refresh_tokens
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1
+   AND revoked_at IS NULL;
+
+-- name: CreateClientRefreshToken :exec
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, revoked_at, client_id, scopes)
+VALUES (
+   $1,
+   NOW(),
+   NOW(),
+   $2,
+   $3,
+   NULL,
+   $4,
+   $5
+   );
+
+-- name: GetClientRefreshToken :one
+SELECT * FROM refresh_tokens
+WHERE token = $1
+   AND client_id IS NOT NULL
+   AND expires_at > NOW()
+   AND revoked_at IS NULL;
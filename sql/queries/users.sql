@@ -26,7 +26,17 @@ RETURNING *;
 SELECT * FROM users
 WHERE id = $1;
 
--- name: Upgradeuser :exec
+-- name: Upgradeuser :execrows
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1;
+
+-- name: DeleteUser :execrows
+DELETE FROM users
+WHERE id = $1;
+
+-- name: SetUserPrivate :one
+UPDATE users
+SET is_private = $1, updated_at = NOW()
+WHERE id = $2
+RETURNING *;
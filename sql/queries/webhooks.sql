@@ -0,0 +1,97 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   $4
+   )
+   RETURNING *;
+
+-- name: GetWebhookEndpoint :one
+SELECT * FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: ListWebhookEndpointsByUser :many
+SELECT * FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at ASC;
+
+-- name: ListWebhookEndpointsForEvent :many
+SELECT * FROM webhook_endpoints
+WHERE disabled_at IS NULL
+   AND sqlc.arg(event)::text = ANY(events);
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: RecordWebhookEndpointFailure :one
+UPDATE webhook_endpoints
+SET consecutive_failures = consecutive_failures + 1, updated_at = NOW()
+WHERE id = $1
+RETURNING consecutive_failures;
+
+-- name: ResetWebhookEndpointFailures :exec
+UPDATE webhook_endpoints
+SET consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DisableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = NOW(), updated_at = NOW()
+WHERE id = $1;
+
+-- name: EnableWebhookEndpoint :exec
+UPDATE webhook_endpoints
+SET disabled_at = NULL, consecutive_failures = 0, updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, created_at, updated_at, endpoint_id, event, payload, status, attempts)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   'pending',
+   0
+   )
+   RETURNING *;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: UpdateWebhookDeliveryState :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = $3, last_error = $4, updated_at = NOW()
+WHERE id = $1;
+
+-- name: ReplayWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', updated_at = NOW()
+WHERE id = $1 AND status = 'failed'
+RETURNING *;
+
+-- name: CreateWebhookDeliveryAttempt :exec
+INSERT INTO webhook_delivery_attempts (id, created_at, delivery_id, attempt, status_code, error, duration_ms)
+VALUES (
+   gen_random_uuid(),
+   NOW(),
+   $1,
+   $2,
+   $3,
+   $4,
+   $5
+   );
+
+-- name: ListWebhookDeliveryAttempts :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = $1
+ORDER BY attempt ASC;
@@ -0,0 +1,43 @@
+-- +goose Up 
+CREATE TABLE webhook_endpoints(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   url TEXT NOT NULL,
+   secret TEXT NOT NULL,
+   events TEXT[] NOT NULL,
+   consecutive_failures INTEGER NOT NULL DEFAULT 0,
+   disabled_at TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   endpoint_id UUID NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+   event TEXT NOT NULL,
+   payload JSONB NOT NULL,
+   status TEXT NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   next_attempt_at TIMESTAMP NOT NULL,
+   last_error TEXT
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+   WHERE status = 'pending';
+
+CREATE TABLE webhook_delivery_attempts(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   delivery_id UUID NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+   attempt INTEGER NOT NULL,
+   status_code INTEGER,
+   error TEXT,
+   duration_ms INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
@@ -0,0 +1,31 @@
+-- +goose Up 
+CREATE TABLE jobs(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   kind TEXT NOT NULL,
+   payload JSONB NOT NULL,
+   status TEXT NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   max_attempts INTEGER NOT NULL,
+   run_at TIMESTAMP NOT NULL,
+   locked_at TIMESTAMP,
+   last_error TEXT,
+   unique_key TEXT
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key) WHERE unique_key IS NOT NULL;
+
+-- Webhook deliveries are retried by the job queue now.
+DROP INDEX webhook_deliveries_due_idx;
+ALTER TABLE webhook_deliveries
+   DROP COLUMN next_attempt_at;
+
+-- +goose Down
+ALTER TABLE webhook_deliveries
+   ADD next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW();
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at)
+   WHERE status = 'pending';
+
+DROP TABLE jobs;
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE api_keys(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   prefix TEXT NOT NULL,
+   hashed_key TEXT NOT NULL UNIQUE,
+   scopes TEXT NOT NULL,
+   last_used_at TIMESTAMP
+);
+
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
+
+-- +goose Down
+DROP TABLE api_keys;
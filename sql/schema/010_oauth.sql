@@ -0,0 +1,36 @@
+-- +goose Up
+CREATE TABLE oauth_clients(
+   id UUID PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   redirect_uris TEXT NOT NULL,
+   hashed_secret TEXT
+);
+
+CREATE INDEX oauth_clients_user_id_idx ON oauth_clients (user_id);
+
+CREATE TABLE oauth_codes(
+   code TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   client_id UUID NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+   user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   redirect_uri TEXT NOT NULL,
+   scopes TEXT NOT NULL,
+   code_challenge TEXT NOT NULL,
+   expires_at TIMESTAMP NOT NULL
+);
+
+-- Refresh tokens issued to an OAuth client carry its ID and the scopes
+-- the user granted. First-party tokens from login have neither.
+ALTER TABLE refresh_tokens
+   ADD client_id UUID REFERENCES oauth_clients(id) ON DELETE CASCADE,
+   ADD scopes TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE refresh_tokens
+   DROP COLUMN scopes,
+   DROP COLUMN client_id;
+
+DROP TABLE oauth_codes;
+DROP TABLE oauth_clients;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE users
+   ADD is_private BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE follows(
+   follower_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   followee_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   status TEXT NOT NULL CHECK (status IN ('pending', 'accepted')),
+   created_at TIMESTAMP NOT NULL,
+   PRIMARY KEY (follower_id, followee_id),
+   CHECK (follower_id <> followee_id)
+);
+
+CREATE INDEX follows_followee_idx ON follows (followee_id, status);
+
+-- +goose Down
+DROP TABLE follows;
+
+ALTER TABLE users
+DROP COLUMN is_private;
@@ -0,0 +1,20 @@
+// Package schema embeds chirpy's goose migrations so the binary can apply
+// them without the goose CLI or a checkout of this directory.
+package schema
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// FS holds every Postgres migration in this directory.
+//
+//go:embed *.sql
+var FS embed.FS
+
+//go:embed sqlite/*.sql
+var sqliteFS embed.FS
+
+// SQLite holds the migrations in sqlite/, which mirror the Postgres ones
+// with SQLite types.
+var SQLite, _ = fs.Sub(sqliteFS, "sqlite")
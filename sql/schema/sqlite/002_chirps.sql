@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE chirp(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   body TEXT NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp;
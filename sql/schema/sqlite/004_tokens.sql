@@ -0,0 +1,12 @@
+-- +goose Up
+CREATE TABLE refresh_tokens(
+   token TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   expires_at TIMESTAMP NOT NULL,
+   revoked_at TIMESTAMP
+);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
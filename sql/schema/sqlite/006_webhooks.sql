@@ -0,0 +1,41 @@
+-- +goose Up
+-- events is space-separated. Deliveries are retried by the job queue from
+-- 008_jobs, so unlike Postgres there was never a next_attempt_at column.
+CREATE TABLE webhook_endpoints(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   url TEXT NOT NULL,
+   secret TEXT NOT NULL,
+   events TEXT NOT NULL,
+   consecutive_failures INTEGER NOT NULL DEFAULT 0,
+   disabled_at TIMESTAMP
+);
+
+CREATE TABLE webhook_deliveries(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   endpoint_id TEXT NOT NULL REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+   event TEXT NOT NULL,
+   payload TEXT NOT NULL,
+   status TEXT NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   last_error TEXT
+);
+
+CREATE TABLE webhook_delivery_attempts(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   delivery_id TEXT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+   attempt INTEGER NOT NULL,
+   status_code INTEGER,
+   error TEXT,
+   duration_ms INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE webhook_delivery_attempts;
+DROP TABLE webhook_deliveries;
+DROP TABLE webhook_endpoints;
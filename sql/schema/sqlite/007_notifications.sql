@@ -0,0 +1,24 @@
+-- +goose Up
+CREATE TABLE notifications(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   type TEXT NOT NULL,
+   actor_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id TEXT REFERENCES chirp(id) ON DELETE CASCADE,
+   read_at TIMESTAMP
+);
+
+CREATE INDEX notifications_user_created_idx ON notifications (user_id, created_at DESC);
+
+CREATE TABLE notification_preferences(
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   type TEXT NOT NULL,
+   enabled BOOLEAN NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   PRIMARY KEY (user_id, type)
+);
+
+-- +goose Down
+DROP TABLE notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE jobs(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   kind TEXT NOT NULL,
+   payload TEXT NOT NULL,
+   status TEXT NOT NULL DEFAULT 'pending',
+   attempts INTEGER NOT NULL DEFAULT 0,
+   max_attempts INTEGER NOT NULL,
+   run_at TIMESTAMP NOT NULL,
+   locked_at TIMESTAMP,
+   last_error TEXT,
+   unique_key TEXT
+);
+
+CREATE INDEX jobs_due_idx ON jobs (run_at) WHERE status = 'pending';
+CREATE UNIQUE INDEX jobs_unique_key_idx ON jobs (unique_key) WHERE unique_key IS NOT NULL;
+
+-- +goose Down
+DROP TABLE jobs;
@@ -0,0 +1,50 @@
+-- +goose Up
+CREATE TABLE oauth_clients(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   redirect_uris TEXT NOT NULL,
+   hashed_secret TEXT
+);
+
+CREATE INDEX oauth_clients_user_id_idx ON oauth_clients (user_id);
+
+CREATE TABLE oauth_codes(
+   code TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   client_id TEXT NOT NULL REFERENCES oauth_clients(id) ON DELETE CASCADE,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   redirect_uri TEXT NOT NULL,
+   scopes TEXT NOT NULL,
+   code_challenge TEXT NOT NULL,
+   expires_at TIMESTAMP NOT NULL
+);
+
+-- Refresh tokens issued to an OAuth client carry its ID and the scopes
+-- the user granted. First-party tokens from login have neither.
+ALTER TABLE refresh_tokens
+   ADD client_id TEXT REFERENCES oauth_clients(id) ON DELETE CASCADE;
+ALTER TABLE refresh_tokens
+   ADD scopes TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+-- SQLite can't drop a column with a foreign key, so rebuild the table,
+-- dropping the tokens that belonged to clients.
+CREATE TABLE refresh_tokens_old(
+   token TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   updated_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   expires_at TIMESTAMP NOT NULL,
+   revoked_at TIMESTAMP
+);
+INSERT INTO refresh_tokens_old
+SELECT token, created_at, updated_at, user_id, expires_at, revoked_at
+FROM refresh_tokens
+WHERE client_id IS NULL;
+DROP TABLE refresh_tokens;
+ALTER TABLE refresh_tokens_old RENAME TO refresh_tokens;
+
+DROP TABLE oauth_codes;
+DROP TABLE oauth_clients;
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE identities(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   provider TEXT NOT NULL,
+   subject TEXT NOT NULL,
+   email TEXT NOT NULL,
+   UNIQUE (provider, subject),
+   UNIQUE (user_id, provider)
+);
+
+-- +goose Down
+DROP TABLE identities;
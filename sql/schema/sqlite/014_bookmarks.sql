@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE bookmark_collections(
+   id TEXT PRIMARY KEY,
+   created_at TIMESTAMP NOT NULL,
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   name TEXT NOT NULL,
+   UNIQUE (user_id, name)
+);
+
+-- Deleting a collection keeps its bookmarks, unfiled. Deleting the chirp
+-- deletes every bookmark of it.
+CREATE TABLE bookmarks(
+   user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+   chirp_id TEXT NOT NULL REFERENCES chirp(id) ON DELETE CASCADE,
+   collection_id TEXT REFERENCES bookmark_collections(id) ON DELETE SET NULL,
+   created_at TIMESTAMP NOT NULL,
+   PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_created_idx ON bookmarks (user_id, created_at DESC, chirp_id DESC);
+CREATE INDEX bookmarks_chirp_idx ON bookmarks (chirp_id);
+
+-- +goose Down
+DROP TABLE bookmarks;
+DROP TABLE bookmark_collections;
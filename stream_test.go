@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/store"
+)
+
+// sseEvent is one event read from an event stream.
+type sseEvent struct {
+	ID   string
+	Type string
+	Data string
+}
+
+// sseStream is an open GET /api/stream/chirps.
+type sseStream struct {
+	events chan sseEvent
+}
+
+// openStream connects to the chirp stream as the user with token, or
+// anonymously if token is empty, and returns once the server has
+// subscribed it.
+func (s *testServer) openStream(t *testing.T, token, query string) *sseStream {
+	t.Helper()
+	req, err := http.NewRequest("GET", s.URL+"/api/stream/chirps"+query, nil)
+	require.NoError(t, err)
+	if token != "" {
+		req.Header.Set("Authorization", bearer(token))
+	}
+	resp, err := s.Client().Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// The handler subscribes before writing the retry field.
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "retry: 3000\n", line)
+
+	stream := &sseStream{events: make(chan sseEvent)}
+	go func() {
+		defer close(stream.events)
+		var event sseEvent
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			field, value, _ := strings.Cut(line, ": ")
+			switch field {
+			case "id":
+				event.ID = value
+			case "event":
+				event.Type = value
+			case "data":
+				event.Data = value
+			case "":
+				if event.Type != "" {
+					stream.events <- event
+				}
+				event = sseEvent{}
+			}
+		}
+	}()
+	return stream
+}
+
+// nextChirp waits for the next event, which must be a chirp.created.
+func (s *sseStream) nextChirp(t *testing.T) Chirp {
+	t.Helper()
+	select {
+	case event, ok := <-s.events:
+		require.True(t, ok, "stream closed")
+		require.Equal(t, streamChirpCreated, event.Type)
+		assert.NotEmpty(t, event.ID)
+		var chirp Chirp
+		require.NoError(t, json.Unmarshal([]byte(event.Data), &chirp))
+		return chirp
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event on the stream")
+		return Chirp{}
+	}
+}
+
+func TestStreamChirps(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		dave := srv.newUser(t, "dave@example.com")
+		erin := srv.newUser(t, "erin@example.com")
+		frank := srv.newUser(t, "frank@example.com")
+
+		// Alice blocks Bob and mutes Carol, Dave's account is private and
+		// Frank blocks Alice.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + bob.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/mutes/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(dave.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(frank.Token)}, http.StatusNoContent, nil)
+
+		stream := srv.openStream(t, alice.Token, "")
+		anonymous := srv.openStream(t, "", "")
+		carols := srv.openStream(t, alice.Token, "?author_id="+carol.ID.String())
+
+		// Events arrive in order, so the first one Alice sees after the
+		// hidden authors post must be Erin's.
+		srv.postChirp(t, bob.Token, "from bob")
+		fromCarol := srv.postChirp(t, carol.Token, "from carol")
+		srv.postChirp(t, dave.Token, "from dave")
+		srv.postChirp(t, frank.Token, "from frank")
+		fromErin := srv.postChirp(t, erin.Token, "from erin")
+		fromAlice := srv.postChirp(t, alice.Token, "from alice")
+
+		assert.Equal(t, fromErin, stream.nextChirp(t))
+		assert.Equal(t, fromAlice, stream.nextChirp(t))
+
+		// Mutes only apply to the timeline, and only Dave is hidden from
+		// anonymous readers.
+		assert.Equal(t, fromCarol, carols.nextChirp(t))
+		for _, want := range []string{"from bob", "from carol", "from frank", "from erin", "from alice"} {
+			assert.Equal(t, want, anonymous.nextChirp(t).Body)
+		}
+	})
+}
+
+func TestStreamChirpsRechecksVisibility(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		erin := srv.newUser(t, "erin@example.com")
+
+		// Carol is private and has approved Alice.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(carol.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/following/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "POST", path: "/api/users/me/follow_requests/" + alice.ID.String() + "/approve", auth: bearer(carol.Token)}, http.StatusOK, nil)
+
+		stream := srv.openStream(t, alice.Token, "")
+		bobs := srv.openStream(t, alice.Token, "?author_id="+bob.ID.String())
+		anonymous := srv.openStream(t, "", "")
+
+		fromBob := srv.postChirp(t, bob.Token, "from bob")
+		fromCarol := srv.postChirp(t, carol.Token, "from carol")
+		assert.Equal(t, fromBob, stream.nextChirp(t))
+		assert.Equal(t, fromCarol, stream.nextChirp(t))
+		assert.Equal(t, fromBob, bobs.nextChirp(t))
+		assert.Equal(t, fromBob, anonymous.nextChirp(t))
+
+		// Bob going private and Alice unfollowing Carol apply to the open
+		// streams, so the next event each one sees is Erin's.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(bob.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/following/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.postChirp(t, bob.Token, "private now")
+		srv.postChirp(t, carol.Token, "unfollowed")
+		fromErin := srv.postChirp(t, erin.Token, "from erin")
+		assert.Equal(t, fromErin, stream.nextChirp(t))
+		assert.Equal(t, fromErin, anonymous.nextChirp(t))
+
+		// Approving Alice shows Bob's chirps on her stream again.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/following/" + bob.ID.String(), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "POST", path: "/api/users/me/follow_requests/" + alice.ID.String() + "/approve", auth: bearer(bob.Token)}, http.StatusOK, nil)
+		approved := srv.postChirp(t, bob.Token, "approved")
+		assert.Equal(t, approved, bobs.nextChirp(t))
+	})
+}
+
+func TestStreamChirpsAppliesNewBlocks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		erin := srv.newUser(t, "erin@example.com")
+
+		stream := srv.openStream(t, alice.Token, "")
+		bobs := srv.openStream(t, alice.Token, "?author_id="+bob.ID.String())
+
+		// Blocks made while the streams are open apply at once, whichever
+		// side makes them.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.postChirp(t, bob.Token, "from bob")
+		srv.postChirp(t, carol.Token, "from carol")
+		fromErin := srv.postChirp(t, erin.Token, "from erin")
+		assert.Equal(t, fromErin, stream.nextChirp(t))
+
+		// Lifting the block shows Bob's chirps again.
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		unblocked := srv.postChirp(t, bob.Token, "unblocked")
+		assert.Equal(t, unblocked, bobs.nextChirp(t))
+	})
+}
+
+func TestStreamChirpsRejectsBadParameters(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	srv.do(t, call{method: "GET", path: "/api/stream/chirps?author_id=nope"}, http.StatusBadRequest, nil)
+	srv.do(t, call{method: "GET", path: "/api/stream/chirps?last_event_id=nope"}, http.StatusBadRequest, nil)
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/willmelton21/chirpy/internal/auth"
+	"github.com/willmelton21/chirpy/internal/store"
+	"github.com/willmelton21/chirpy/internal/wsapi"
+)
+
+// dialWS opens /api/ws as the user with token and reads the welcome
+// envelope.
+func (s *testServer) dialWS(t *testing.T, token string) (*websocket.Conn, wsapi.Welcome) {
+	t.Helper()
+	header := http.Header{"Authorization": {bearer(token)}}
+	conn, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(s.URL, "http")+"/api/ws", header)
+	require.NoError(t, err)
+	resp.Body.Close()
+	t.Cleanup(func() { conn.Close() })
+
+	msg := readWS(t, conn)
+	require.Equal(t, wsapi.TypeWelcome, msg.Type)
+	var welcome wsapi.Welcome
+	require.NoError(t, json.Unmarshal(msg.Data, &welcome))
+	return conn, welcome
+}
+
+// readWS reads the next envelope, failing the test if none arrives.
+func readWS(t *testing.T, conn *websocket.Conn) wsapi.Envelope {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg wsapi.Envelope
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, wsapi.Version, msg.V)
+	return msg
+}
+
+// sendWS sends msg at the current protocol version and returns the reply
+// with the same ID.
+func sendWS(t *testing.T, conn *websocket.Conn, msg wsapi.Envelope) wsapi.Envelope {
+	t.Helper()
+	msg.V = wsapi.Version
+	require.NoError(t, conn.WriteJSON(msg))
+	reply := readWS(t, conn)
+	require.Equal(t, msg.ID, reply.ID, "reply to %s: %+v", msg.Type, reply)
+	return reply
+}
+
+func subscribeWS(t *testing.T, conn *websocket.Conn, channel string) {
+	t.Helper()
+	reply := sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypeSubscribe, ID: "sub-" + channel, Channel: channel})
+	require.Equal(t, wsapi.TypeAck, reply.Type, "%+v", reply)
+}
+
+// nextChirpWS reads the next envelope, which must be a chirp.created event.
+func nextChirpWS(t *testing.T, conn *websocket.Conn) Chirp {
+	t.Helper()
+	msg := readWS(t, conn)
+	require.Equal(t, wsapi.TypeEvent, msg.Type, "%+v", msg)
+	require.Equal(t, streamChirpCreated, msg.Event)
+	var chirp Chirp
+	require.NoError(t, json.Unmarshal(msg.Data, &chirp))
+	return chirp
+}
+
+func TestWebSocket(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	alice := srv.newUser(t, "alice@example.com")
+
+	// Upgrading needs credentials.
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/api/ws", nil)
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	conn, welcome := srv.dialWS(t, alice.Token)
+	assert.Equal(t, alice.ID, welcome.UserID)
+	assert.False(t, welcome.ExpiresAt.IsZero())
+
+	reply := sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypePing, ID: "1"})
+	assert.Equal(t, wsapi.TypePong, reply.Type)
+
+	reply = sendWS(t, conn, wsapi.Envelope{Type: "shout", ID: "2"})
+	assert.Equal(t, wsapi.CodeBadRequest, reply.Code)
+	require.NoError(t, conn.WriteJSON(wsapi.Envelope{V: 99, Type: wsapi.TypePing, ID: "3"}))
+	assert.Equal(t, wsapi.CodeBadVersion, readWS(t, conn).Code)
+	reply = sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypeSubscribe, ID: "4", Channel: "gossip"})
+	assert.Equal(t, wsapi.CodeBadChannel, reply.Code)
+
+	// Subscribing twice is fine; unsubscribing stops events.
+	subscribeWS(t, conn, wsapi.ChannelChirps)
+	subscribeWS(t, conn, wsapi.ChannelChirps)
+	first := srv.postChirp(t, alice.Token, "first")
+	assert.Equal(t, first, nextChirpWS(t, conn))
+	reply = sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypeUnsubscribe, ID: "5", Channel: wsapi.ChannelChirps})
+	assert.Equal(t, wsapi.TypeAck, reply.Type)
+	srv.postChirp(t, alice.Token, "second")
+	reply = sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypePing, ID: "6"})
+	assert.Equal(t, wsapi.TypePong, reply.Type)
+}
+
+func TestWebSocketHidesAuthors(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		dave := srv.newUser(t, "dave@example.com")
+		erin := srv.newUser(t, "erin@example.com")
+		frank := srv.newUser(t, "frank@example.com")
+
+		// Alice blocks Bob and mutes Carol, Dave's account is private and
+		// Frank blocks Alice.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + bob.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/mutes/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(dave.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(frank.Token)}, http.StatusNoContent, nil)
+
+		timeline, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, timeline, wsapi.ChannelChirps)
+		carols, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, carols, wsapi.ChannelChirps+":"+carol.ID.String())
+		bobs, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, bobs, wsapi.ChannelChirps+":"+bob.ID.String())
+
+		srv.postChirp(t, bob.Token, "from bob")
+		fromCarol := srv.postChirp(t, carol.Token, "from carol")
+		srv.postChirp(t, dave.Token, "from dave")
+		srv.postChirp(t, frank.Token, "from frank")
+		fromErin := srv.postChirp(t, erin.Token, "from erin")
+
+		// Events arrive in order, so nothing from the hidden authors came
+		// before Erin's chirp.
+		assert.Equal(t, fromErin, nextChirpWS(t, timeline))
+		// Mutes only apply to the timeline; blocks apply everywhere.
+		assert.Equal(t, fromCarol, nextChirpWS(t, carols))
+		reply := sendWS(t, bobs, wsapi.Envelope{Type: wsapi.TypePing, ID: "1"})
+		assert.Equal(t, wsapi.TypePong, reply.Type)
+	})
+}
+
+func TestWebSocketRechecksVisibility(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		erin := srv.newUser(t, "erin@example.com")
+
+		// Carol is private and has approved Alice.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(carol.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/following/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "POST", path: "/api/users/me/follow_requests/" + alice.ID.String() + "/approve", auth: bearer(carol.Token)}, http.StatusOK, nil)
+
+		timeline, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, timeline, wsapi.ChannelChirps)
+		bobs, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, bobs, wsapi.ChannelChirps+":"+bob.ID.String())
+
+		fromBob := srv.postChirp(t, bob.Token, "from bob")
+		fromCarol := srv.postChirp(t, carol.Token, "from carol")
+		assert.Equal(t, fromBob, nextChirpWS(t, timeline))
+		assert.Equal(t, fromCarol, nextChirpWS(t, timeline))
+		assert.Equal(t, fromBob, nextChirpWS(t, bobs))
+
+		// Bob going private and Alice unfollowing Carol apply to the open
+		// subscriptions.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/privacy", body: map[string]any{"is_private": true}, auth: bearer(bob.Token)}, http.StatusOK, nil)
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/following/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.postChirp(t, bob.Token, "private now")
+		srv.postChirp(t, carol.Token, "unfollowed")
+		fromErin := srv.postChirp(t, erin.Token, "from erin")
+		assert.Equal(t, fromErin, nextChirpWS(t, timeline))
+		reply := sendWS(t, bobs, wsapi.Envelope{Type: wsapi.TypePing, ID: "1"})
+		assert.Equal(t, wsapi.TypePong, reply.Type)
+	})
+}
+
+func TestWebSocketAppliesNewBlocks(t *testing.T) {
+	forEachBackend(t, func(t *testing.T, srv *testServer) {
+		alice := srv.newUser(t, "alice@example.com")
+		bob := srv.newUser(t, "bob@example.com")
+		carol := srv.newUser(t, "carol@example.com")
+		erin := srv.newUser(t, "erin@example.com")
+
+		timeline, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, timeline, wsapi.ChannelChirps)
+		bobs, _ := srv.dialWS(t, alice.Token)
+		subscribeWS(t, bobs, wsapi.ChannelChirps+":"+bob.ID.String())
+
+		// Blocks made while subscribed apply at once, whichever side makes
+		// them.
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		srv.do(t, call{method: "PUT", path: "/api/users/me/blocks/" + carol.ID.String(), auth: bearer(alice.Token)}, http.StatusNoContent, nil)
+		srv.postChirp(t, bob.Token, "from bob")
+		srv.postChirp(t, carol.Token, "from carol")
+		fromErin := srv.postChirp(t, erin.Token, "from erin")
+		assert.Equal(t, fromErin, nextChirpWS(t, timeline))
+
+		// Lifting the block shows Bob's chirps again.
+		srv.do(t, call{method: "DELETE", path: "/api/users/me/blocks/" + alice.ID.String(), auth: bearer(bob.Token)}, http.StatusNoContent, nil)
+		unblocked := srv.postChirp(t, bob.Token, "unblocked")
+		assert.Equal(t, unblocked, nextChirpWS(t, bobs))
+	})
+}
+
+func TestWebSocketKeepalive(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory(), func(cfg *apiConfig) { cfg.wsPongWait = 200 * time.Millisecond })
+	alice := srv.newUser(t, "alice@example.com")
+
+	// A client that answers pings stays connected well past the pong
+	// wait. gorilla's default ping handler answers for us while we read.
+	conn, _ := srv.dialWS(t, alice.Token)
+	pings := make(chan struct{}, 16)
+	conn.SetPingHandler(func(data string) error {
+		pings <- struct{}{}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	var netErr net.Error
+	require.ErrorAs(t, err, &netErr)
+	require.True(t, netErr.Timeout(), "got %v", err)
+	assert.GreaterOrEqual(t, len(pings), 3)
+
+	// One that doesn't is disconnected once the pong wait passes.
+	silent, _ := srv.dialWS(t, alice.Token)
+	silent.SetPingHandler(func(string) error { return nil })
+	silent.SetReadDeadline(time.Now().Add(5 * time.Second))
+	start := time.Now()
+	_, _, err = silent.ReadMessage()
+	require.Error(t, err)
+	if errors.As(err, &netErr) {
+		assert.False(t, netErr.Timeout(), "the server didn't close the connection")
+	}
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestWebSocketTokenExpiry(t *testing.T) {
+	srv := newTestServer(t, store.NewMemory())
+	alice := srv.newUser(t, "alice@example.com")
+	bob := srv.newUser(t, "bob@example.com")
+	token := func(expiresIn time.Duration) string {
+		token, err := auth.MakeJWT(context.Background(), alice.ID, testSecret, expiresIn)
+		require.NoError(t, err)
+		return token
+	}
+
+	// The connection is closed when its token expires.
+	conn, welcome := srv.dialWS(t, token(2*time.Second))
+	msg := readWS(t, conn)
+	assert.Equal(t, wsapi.TypeError, msg.Type)
+	assert.Equal(t, wsapi.CodeTokenExpired, msg.Code)
+	assert.WithinDuration(t, welcome.ExpiresAt, time.Now(), time.Second)
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation), "got %v", err)
+
+	// Sending a fresh token keeps it open; a token for someone else
+	// doesn't count.
+	conn, _ = srv.dialWS(t, token(2*time.Second))
+	reply := sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypeAuth, ID: "1", Token: bob.Token})
+	assert.Equal(t, wsapi.CodeUnauthorized, reply.Code)
+	reply = sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypeAuth, ID: "2", Token: token(time.Hour)})
+	assert.Equal(t, wsapi.TypeAck, reply.Type)
+	time.Sleep(2500 * time.Millisecond)
+	reply = sendWS(t, conn, wsapi.Envelope{Type: wsapi.TypePing, ID: "3"})
+	assert.Equal(t, wsapi.TypePong, reply.Type)
+}